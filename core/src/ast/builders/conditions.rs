@@ -108,3 +108,8 @@ pub fn similar_to(column: &str, pattern: &str) -> Condition {
 pub fn key_exists(column: &str, key: &str) -> Condition {
     make_condition(column, Operator::KeyExists, Value::String(key.to_string()))
 }
+
+/// Create a JSON-path predicate match (column @@ path)
+pub fn json_path_match(column: &str, path: &str) -> Condition {
+    make_condition(column, Operator::JsonPathMatch, Value::String(path.to_string()))
+}
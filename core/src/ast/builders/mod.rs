@@ -68,8 +68,9 @@ pub use literals::{bind, boolean, float, int, null, text};
 
 // Conditions
 pub use conditions::{
-    between, cond, contains, eq, gt, gte, ilike, is_in, is_not_null, is_null, key_exists, like, lt,
-    lte, ne, not_between, not_in, not_like, overlaps, regex, regex_i, similar_to,
+    between, cond, contains, eq, gt, gte, ilike, is_in, is_not_null, is_null, json_path_match,
+    key_exists, like, lt, lte, ne, not_between, not_in, not_like, overlaps, regex, regex_i,
+    similar_to,
 };
 
 // Time
@@ -177,6 +177,8 @@ pub enum Operator {
     SimilarTo,
     ContainedBy,
     Overlaps,
+    /// jsonb @@ jsonpath predicate match
+    JsonPathMatch,
 }
 
 impl Operator {
@@ -213,6 +215,7 @@ impl Operator {
             Operator::SimilarTo => "SIMILAR TO",
             Operator::ContainedBy => "<@",
             Operator::Overlaps => "&&",
+            Operator::JsonPathMatch => "@@",
         }
     }
 
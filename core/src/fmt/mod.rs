@@ -401,6 +401,7 @@ impl Formatter {
                 Operator::IsNotNull => write!(self.buffer, " is not null")?,
                 Operator::Contains => write!(self.buffer, " @> ")?,
                 Operator::KeyExists => write!(self.buffer, " ? ")?,
+                Operator::JsonPathMatch => write!(self.buffer, " @@ ")?,
                 _ => write!(self.buffer, " {:?} ", cond.op)?,
             }
 
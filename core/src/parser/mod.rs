@@ -33,6 +33,15 @@ pub fn parse(input: &str) -> QailResult<Qail> {
             input.len() - remaining.len(),
             format!("Unexpected trailing content: '{}'", remaining),
         )),
-        Err(e) => Err(QailError::parse(0, format!("Parse failed: {:?}", e))),
+        Err(e) => {
+            // Report where nom gave up rather than the start of the query.
+            let position = match &e {
+                nom::Err::Error(err) | nom::Err::Failure(err) => {
+                    input.len().saturating_sub(err.input.len())
+                }
+                nom::Err::Incomplete(_) => input.len(),
+            };
+            Err(QailError::parse(position, format!("Parse failed: {:?}", e)))
+        }
     }
 }
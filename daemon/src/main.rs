@@ -4,17 +4,21 @@
 //! to communicate via Unix socket without CGO overhead.
 
 use qail_core::ast::Qail;
-use qail_pg::PgDriver;
+use qail_pg::protocol::AstEncoder;
+use qail_pg::{PgDriver, PgEncoder, PgError};
 use serde::{Deserialize, Serialize};
 use std::path::Path;
 use std::sync::Arc;
 use tokio::io::{AsyncReadExt, AsyncWriteExt};
 use tokio::net::{UnixListener, UnixStream};
-use tokio::sync::RwLock;
+use tokio::sync::{RwLock, watch};
+use tokio::task::JoinSet;
 use tracing::{error, info, warn};
 
 const SOCKET_PATH: &str = "/tmp/qail.sock";
 const MAX_MESSAGE_SIZE: usize = 16 * 1024 * 1024; // 16MB
+/// How long shutdown waits for in-flight requests to finish
+const SHUTDOWN_GRACE: std::time::Duration = std::time::Duration::from_secs(10);
 
 // ============================================================================
 // IPC Protocol Messages
@@ -37,6 +41,16 @@ pub enum Request {
         columns: Vec<String>,
         filter: Option<String>,
         limit: Option<i64>,
+        /// Return column names and type OIDs alongside the rows
+        #[serde(default)]
+        include_types: bool,
+    },
+    /// Execute one raw SQL statement, binding `params` to $1..$n as text
+    Query {
+        sql: String,
+        /// None is NULL
+        #[serde(default)]
+        params: Vec<Option<String>>,
     },
     /// Execute a batch of GET commands (sequential)
     GetBatch { queries: Vec<GetQuery> },
@@ -63,6 +77,18 @@ pub struct GetQuery {
     pub columns: Vec<String>,
     pub filter: Option<String>,
     pub limit: Option<i64>,
+    #[serde(default)]
+    pub include_types: bool,
+    /// Client-assigned id, echoed in the matching QueryResult
+    #[serde(default)]
+    pub id: Option<u64>,
+    /// Raw SQL run in place of the GET (Pipeline only), so a pipeline can
+    /// mix reads and writes
+    #[serde(default)]
+    pub sql: Option<String>,
+    /// Text parameters for `sql`; None is NULL
+    #[serde(default)]
+    pub params: Vec<Option<String>>,
 }
 
 #[derive(Debug, Clone, Serialize, Deserialize)]
@@ -82,7 +108,12 @@ pub enum Response {
     /// Connection established
     Connected,
     /// Query results
-    Results { rows: Vec<Row>, affected: u64 },
+    Results {
+        rows: Vec<Row>,
+        affected: u64,
+        #[serde(default, skip_serializing_if = "Option::is_none")]
+        columns: Option<Vec<ColumnMeta>>,
+    },
     /// Batch results
     BatchResults { results: Vec<QueryResult> },
     /// Count only (fast mode)
@@ -91,6 +122,9 @@ pub enum Response {
     PreparedHandle { handle: String },
     /// Pong response
     Pong,
+    /// Daemon is shutting down; sent in place of the next response, after
+    /// which the socket is closed
+    Shutdown,
     /// Error occurred
     Error { message: String },
 }
@@ -104,6 +138,20 @@ pub struct Row {
 pub struct QueryResult {
     pub rows: Vec<Row>,
     pub affected: u64,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub columns: Option<Vec<ColumnMeta>>,
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub id: Option<u64>,
+    /// Why this query failed (Pipeline only); the others still ran
+    #[serde(default, skip_serializing_if = "Option::is_none")]
+    pub error: Option<String>,
+}
+
+/// Column metadata from RowDescription, sent when `include_types` is set.
+#[derive(Debug, Serialize, Deserialize)]
+pub struct ColumnMeta {
+    pub name: String,
+    pub oid: u32,
 }
 
 use qail_pg::driver::PreparedStatement;
@@ -143,30 +191,83 @@ async fn main() -> Result<(), Box<dyn std::error::Error>> {
     let listener = UnixListener::bind(SOCKET_PATH)?;
     info!("📡 Listening on {}", SOCKET_PATH);
 
-    // Accept connections
+    let (shutdown_tx, shutdown_rx) = watch::channel(false);
+    let mut clients = JoinSet::new();
+
+    // Accept connections until SIGINT/SIGTERM
+    let shutdown = shutdown_signal();
+    tokio::pin!(shutdown);
     loop {
-        match listener.accept().await {
-            Ok((stream, _addr)) => {
-                info!("🔌 New client connected");
-                tokio::spawn(handle_client(stream));
-            }
-            Err(e) => {
-                error!("Failed to accept connection: {}", e);
+        tokio::select! {
+            accepted = listener.accept() => match accepted {
+                Ok((stream, _addr)) => {
+                    info!("🔌 New client connected");
+                    clients.spawn(handle_client(stream, shutdown_rx.clone()));
+                }
+                Err(e) => {
+                    error!("Failed to accept connection: {}", e);
+                }
+            },
+            _ = &mut shutdown => break,
+        }
+        // Reap finished clients so the set doesn't grow unbounded
+        while clients.try_join_next().is_some() {}
+    }
+
+    // Tell idle clients to go away; busy ones finish their request first
+    info!("🛑 Shutting down, notifying {} clients", clients.len());
+    let _ = shutdown_tx.send(true);
+    let drained = tokio::time::timeout(SHUTDOWN_GRACE, async {
+        while clients.join_next().await.is_some() {}
+    })
+    .await;
+    if drained.is_err() {
+        warn!("Shutdown grace period elapsed, dropping {} clients", clients.len());
+    }
+
+    let _ = std::fs::remove_file(SOCKET_PATH);
+    Ok(())
+}
+
+/// Resolves on SIGINT or SIGTERM.
+async fn shutdown_signal() {
+    use tokio::signal::unix::{SignalKind, signal};
+
+    match signal(SignalKind::terminate()) {
+        Ok(mut term) => {
+            tokio::select! {
+                _ = tokio::signal::ctrl_c() => {}
+                _ = term.recv() => {}
             }
         }
+        Err(e) => {
+            warn!("Failed to install SIGTERM handler: {}", e);
+            let _ = tokio::signal::ctrl_c().await;
+        }
     }
 }
 
-async fn handle_client(mut stream: UnixStream) {
+async fn handle_client(mut stream: UnixStream, mut shutdown: watch::Receiver<bool>) {
     let state = Arc::new(RwLock::new(ConnectionState::new()));
     let mut buf = vec![0u8; MAX_MESSAGE_SIZE];
 
     loop {
-        // Read message length (4 bytes, big-endian)
+        // Read message length (4 bytes, big-endian), unless the daemon is
+        // shutting down first. A request that was already read still gets
+        // its response; the client then sees Shutdown on the next one.
         let mut len_buf = [0u8; 4];
-        if stream.read_exact(&mut len_buf).await.is_err() {
-            info!("Client disconnected");
-            break;
+        tokio::select! {
+            read = stream.read_exact(&mut len_buf) => {
+                if read.is_err() {
+                    info!("Client disconnected");
+                    break;
+                }
+            }
+            _ = shutdown.changed() => {
+                send_response(&mut stream, &Response::Shutdown).await;
+                info!("Sent shutdown to client");
+                break;
+            }
         }
         let msg_len = u32::from_be_bytes(len_buf) as usize;
 
@@ -243,6 +344,7 @@ async fn handle_request(state: &Arc<RwLock<ConnectionState>>, request: Request)
             columns,
             filter,
             limit,
+            include_types,
         } => {
             let mut state = state.write().await;
             match &mut state.driver {
@@ -258,15 +360,27 @@ async fn handle_request(state: &Arc<RwLock<ConnectionState>>, request: Request)
                         cmd = cmd.limit(l);
                     }
 
-                    match driver.fetch_all(&cmd).await {
+                    // Cached statements skip RowDescription, so typed
+                    // requests go through the uncached path.
+                    let fetched = if include_types {
+                        driver.fetch_all_uncached(&cmd).await
+                    } else {
+                        driver.fetch_all(&cmd).await
+                    };
+                    match fetched {
                         Ok(pg_rows) => {
+                            let columns = include_types.then(|| column_meta(&pg_rows));
                             let rows = pg_rows
                                 .iter()
                                 .map(|r| Row {
                                     columns: r.columns.iter().map(column_to_value).collect(),
                                 })
                                 .collect();
-                            Response::Results { rows, affected: 0 }
+                            Response::Results {
+                                rows,
+                                affected: 0,
+                                columns,
+                            }
                         }
                         Err(e) => Response::Error {
                             message: format!("Query failed: {}", e),
@@ -279,6 +393,49 @@ async fn handle_request(state: &Arc<RwLock<ConnectionState>>, request: Request)
             }
         }
 
+        Request::Query { sql, params } => {
+            let mut state = state.write().await;
+            match &mut state.driver {
+                Some(driver) => {
+                    let params: Vec<Option<Vec<u8>>> = params
+                        .iter()
+                        .map(|p| p.as_ref().map(|p| p.as_bytes().to_vec()))
+                        .collect();
+                    let wire = PgEncoder::encode_extended_query(&sql, &params)
+                        .map_err(|e| PgError::Encode(e.to_string()));
+
+                    // A pipeline of one, so raw SQL takes the same path as in
+                    // Pipeline and reports rows affected.
+                    match driver.pipeline_fetch_each(vec![wire]).await {
+                        Ok(mut outcomes) => match outcomes.pop() {
+                            Some(Ok((pg_rows, affected))) => Response::Results {
+                                rows: pg_rows
+                                    .iter()
+                                    .map(|r| Row {
+                                        columns: r.columns.iter().map(column_to_value).collect(),
+                                    })
+                                    .collect(),
+                                affected,
+                                columns: None,
+                            },
+                            Some(Err(e)) => Response::Error {
+                                message: format!("Query failed: {}", e),
+                            },
+                            None => Response::Error {
+                                message: "Query failed: missing result".to_string(),
+                            },
+                        },
+                        Err(e) => Response::Error {
+                            message: format!("Query failed: {}", e),
+                        },
+                    }
+                }
+                None => Response::Error {
+                    message: "Not connected".to_string(),
+                },
+            }
+        }
+
         Request::GetBatch { queries } => {
             let mut state = state.write().await;
             match &mut state.driver {
@@ -296,15 +453,27 @@ async fn handle_request(state: &Arc<RwLock<ConnectionState>>, request: Request)
                             cmd = cmd.limit(l);
                         }
 
-                        match driver.fetch_all(&cmd).await {
+                        let fetched = if q.include_types {
+                            driver.fetch_all_uncached(&cmd).await
+                        } else {
+                            driver.fetch_all(&cmd).await
+                        };
+                        match fetched {
                             Ok(pg_rows) => {
+                                let columns = q.include_types.then(|| column_meta(&pg_rows));
                                 let rows = pg_rows
                                     .iter()
                                     .map(|r| Row {
                                         columns: r.columns.iter().map(column_to_value).collect(),
                                     })
                                     .collect();
-                                results.push(QueryResult { rows, affected: 0 });
+                                results.push(QueryResult {
+                                    rows,
+                                    affected: 0,
+                                    columns,
+                                    id: q.id,
+                                    error: None,
+                                });
                             }
                             Err(e) => {
                                 return Response::Error {
@@ -326,38 +495,66 @@ async fn handle_request(state: &Arc<RwLock<ConnectionState>>, request: Request)
             let mut state = state.write().await;
             match &mut state.driver {
                 Some(driver) => {
-                    // Build Qail list for pipeline
-                    let cmds: Vec<Qail> = queries
+                    // Encode each query on its own, ending in its own Sync,
+                    // so one failing query doesn't abort the rest.
+                    let wires = queries
                         .iter()
                         .map(|q| {
-                            let mut cmd = Qail::get(&q.table);
-                            for col in &q.columns {
-                                cmd = cmd.column(col);
-                            }
-                            if let Some(l) = q.limit {
-                                cmd = cmd.limit(l);
-                            }
-                            cmd
+                            let wire = match &q.sql {
+                                Some(sql) => {
+                                    let params: Vec<Option<Vec<u8>>> = q
+                                        .params
+                                        .iter()
+                                        .map(|p| p.as_ref().map(|p| p.as_bytes().to_vec()))
+                                        .collect();
+                                    PgEncoder::encode_extended_query(sql, &params)
+                                }
+                                None => {
+                                    let mut cmd = Qail::get(&q.table);
+                                    for col in &q.columns {
+                                        cmd = cmd.column(col);
+                                    }
+                                    if let Some(l) = q.limit {
+                                        cmd = cmd.limit(l);
+                                    }
+                                    AstEncoder::try_encode_batch(std::slice::from_ref(&cmd))
+                                }
+                            };
+                            wire.map_err(|e| PgError::Encode(e.to_string()))
                         })
                         .collect();
 
-                    // Use true PostgreSQL pipeline mode with full results
-                    match driver.pipeline_fetch(&cmds).await {
-                        Ok(all_pg_rows) => {
-                            let results: Vec<QueryResult> = all_pg_rows
-                                .iter()
-                                .map(|pg_rows| QueryResult {
-                                    rows: pg_rows
-                                        .iter()
-                                        .map(|r| Row {
-                                            columns: r
-                                                .columns
-                                                .iter()
-                                                .map(column_to_value)
-                                                .collect(),
-                                        })
-                                        .collect(),
-                                    affected: 0,
+                    match driver.pipeline_fetch_each(wires).await {
+                        Ok(outcomes) => {
+                            // Outcomes line up with queries, so zip ids back on.
+                            let results: Vec<QueryResult> = outcomes
+                                .into_iter()
+                                .zip(&queries)
+                                .map(|(outcome, q)| match outcome {
+                                    Ok((pg_rows, affected)) => QueryResult {
+                                        rows: pg_rows
+                                            .iter()
+                                            .map(|r| Row {
+                                                columns: r
+                                                    .columns
+                                                    .iter()
+                                                    .map(column_to_value)
+                                                    .collect(),
+                                            })
+                                            .collect(),
+                                        affected,
+                                        // Pipelined rows carry no RowDescription.
+                                        columns: None,
+                                        id: q.id,
+                                        error: None,
+                                    },
+                                    Err(e) => QueryResult {
+                                        rows: Vec::new(),
+                                        affected: 0,
+                                        columns: None,
+                                        id: q.id,
+                                        error: Some(e.to_string()),
+                                    },
                                 })
                                 .collect();
                             Response::BatchResults { results }
@@ -519,3 +716,22 @@ fn column_to_value(column: &Option<Vec<u8>>) -> Value {
         }
     }
 }
+
+/// Build column metadata from the RowDescription attached to the rows.
+/// PgRow only carries it per row, so an empty result yields no columns.
+fn column_meta(rows: &[qail_pg::PgRow]) -> Vec<ColumnMeta> {
+    let Some(info) = rows.first().and_then(|r| r.column_info.as_ref()) else {
+        return Vec::new();
+    };
+    let mut names = vec![String::new(); info.oids.len()];
+    for (name, &i) in &info.name_to_index {
+        if let Some(slot) = names.get_mut(i) {
+            *slot = name.clone();
+        }
+    }
+    names
+        .into_iter()
+        .zip(info.oids.iter())
+        .map(|(name, &oid)| ColumnMeta { name, oid })
+        .collect()
+}
@@ -12,6 +12,8 @@
 #ifndef QAIL_H
 #define QAIL_H
 
+#include <stddef.h>
+
 #ifdef __cplusplus
 extern "C" {
 #endif
@@ -35,6 +37,21 @@ char* qail_transpile(const char* qail);
  */
 char* qail_transpile_with_dialect(const char* qail, const char* dialect);
 
+/**
+ * Transpile a QAIL string, returning the error through an out-parameter.
+ * A NULL dialect means "postgres".
+ * 
+ * Prefer this over `qail_last_error` from runtimes that may switch OS
+ * threads between calls, such as Go: the last error is per thread, so a
+ * goroutine that migrates can read another call's error, or none.
+ * 
+ * @param qail       QAIL query string (UTF-8)
+ * @param dialect    Dialect name, or NULL
+ * @param error_out  Out: NULL on success, else the error message (may be NULL)
+ * @return           SQL string, or NULL on error. Free both with `qail_free`.
+ */
+char* qail_transpile_err(const char* qail, const char* dialect, char** error_out);
+
 /**
  * Parse QAIL string and return AST as JSON.
  * 
@@ -51,9 +68,25 @@ char* qail_parse_json(const char* qail);
  */
 int qail_validate(const char* qail);
 
+/**
+ * Validate QAIL syntax and report where it fails.
+ * 
+ * @param qail      QAIL query string (UTF-8)
+ * @param position  Out: byte offset of the error in qail (may be NULL)
+ * @param line      Out: 1-based line of the error (may be NULL)
+ * @param column    Out: 1-based column of the error, in characters (may be NULL)
+ * @return          1 if valid, 0 if invalid; call `qail_last_error` for the message
+ */
+int qail_validate_detailed(const char* qail, size_t* position, unsigned int* line, unsigned int* column);
+
 /**
  * Get the last error message.
  * 
+ * The error is stored per OS thread and refers to the most recent QAIL
+ * call on that thread. Callers whose threads can change between calls
+ * (Go goroutines without runtime.LockOSThread) must pin the thread
+ * around the call and this read, or use `qail_transpile_err`.
+ * 
  * @return  Error message (do NOT free), or NULL if no error
  */
 const char* qail_last_error(void);
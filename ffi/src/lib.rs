@@ -135,6 +135,44 @@ pub extern "C" fn qail_transpile_with_dialect(
     }
 }
 
+/// Transpile QAIL, returning any error through `error_out` instead of
+/// qail_last_error. A NULL dialect means Postgres.
+///
+/// qail_last_error is per OS thread, so a caller that can move between
+/// threads between two calls (a Go goroutine, for one) may read another
+/// call's error or none at all. Tying the error to the call avoids that.
+///
+/// On success returns the SQL and sets `*error_out` to NULL. On failure
+/// returns NULL and sets `*error_out` to the message. Free both with
+/// qail_free().
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_transpile_err(
+    qail: *const c_char,
+    dialect: *const c_char,
+    error_out: *mut *mut c_char,
+) -> *mut c_char {
+    let sql = if dialect.is_null() {
+        qail_transpile(qail)
+    } else {
+        qail_transpile_with_dialect(qail, dialect)
+    };
+
+    if !error_out.is_null() {
+        let err = if sql.is_null() {
+            let msg = LAST_ERROR
+                .with(|e| e.borrow().clone())
+                .unwrap_or_else(|| "unknown error".to_string());
+            CString::new(msg).unwrap_or_default().into_raw()
+        } else {
+            std::ptr::null_mut()
+        };
+        unsafe {
+            *error_out = err;
+        }
+    }
+    sql
+}
+
 /// Parse QAIL and return AST as JSON string.
 /// Returns NULL on error.
 /// Caller must free the returned string with qail_free().
@@ -198,6 +236,72 @@ pub extern "C" fn qail_validate(qail: *const c_char) -> i32 {
     }
 }
 
+/// Validate QAIL syntax and report where it fails.
+/// Returns 1 if valid, 0 if invalid. On failure, each non-NULL out-pointer
+/// receives the error's byte offset into `qail` and its 1-based line and
+/// column (in characters); the message is available from qail_last_error.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_validate_detailed(
+    qail: *const c_char,
+    position: *mut usize,
+    line: *mut u32,
+    column: *mut u32,
+) -> i32 {
+    clear_error();
+
+    if qail.is_null() {
+        set_error("NULL input".to_string());
+        return 0;
+    }
+
+    let c_str = unsafe { CStr::from_ptr(qail) };
+    let qail_str = match c_str.to_str() {
+        Ok(s) => s,
+        Err(e) => {
+            set_error(format!("Invalid UTF-8: {}", e));
+            return 0;
+        }
+    };
+
+    let err = match qail_core::parse(qail_str) {
+        Ok(_) => return 1,
+        Err(e) => e,
+    };
+
+    // The parser reports offsets into the trimmed input.
+    let leading = qail_str.len() - qail_str.trim_start().len();
+    let offset = match &err {
+        qail_core::error::QailError::Parse { position, .. } => leading + position,
+        _ => leading,
+    };
+    let offset = offset.min(qail_str.len());
+    // Back up to a char boundary so the prefix slice below is valid.
+    let offset = (0..=offset)
+        .rev()
+        .find(|&i| qail_str.is_char_boundary(i))
+        .unwrap_or(0);
+
+    let before = &qail_str[..offset];
+    let err_line = before.matches('\n').count() as u32 + 1;
+    let line_start = before.rfind('\n').map_or(0, |i| i + 1);
+    let err_column = before[line_start..].chars().count() as u32 + 1;
+
+    unsafe {
+        if !position.is_null() {
+            *position = offset;
+        }
+        if !line.is_null() {
+            *line = err_line;
+        }
+        if !column.is_null() {
+            *column = err_column;
+        }
+    }
+
+    set_error(err.to_string());
+    0
+}
+
 /// Get the last error message.
 /// Returns NULL if no error.
 /// The returned string is valid until the next QAIL function call.
@@ -591,4 +695,39 @@ mod tests {
         let result = qail_transpile(std::ptr::null());
         assert!(result.is_null());
     }
+
+    #[test]
+    fn test_transpile_err_concurrent() {
+        let handles: Vec<_> = (0..8)
+            .map(|t| {
+                std::thread::spawn(move || {
+                    let valid = CString::new("get users fields *").unwrap();
+                    let invalid = CString::new("invalid syntax!!!").unwrap();
+                    for i in 0..100 {
+                        let ok = (t + i) % 2 == 0;
+                        let input = if ok { &valid } else { &invalid };
+                        let mut err: *mut c_char = std::ptr::null_mut();
+                        let sql = qail_transpile_err(input.as_ptr(), std::ptr::null(), &mut err);
+                        if ok {
+                            // A success must not report the previous call's error.
+                            assert!(!sql.is_null());
+                            assert!(err.is_null());
+                            let text = unsafe { CStr::from_ptr(sql) }.to_str().unwrap();
+                            assert!(text.contains("FROM users"), "{text}");
+                            qail_free(sql);
+                        } else {
+                            assert!(sql.is_null());
+                            assert!(!err.is_null());
+                            let msg = unsafe { CStr::from_ptr(err) }.to_str().unwrap();
+                            assert!(!msg.is_empty());
+                            qail_free(err);
+                        }
+                    }
+                })
+            })
+            .collect();
+        for h in handles {
+            h.join().unwrap();
+        }
+    }
 }
@@ -0,0 +1,174 @@
+package qail
+
+import (
+	"encoding/binary"
+	"strings"
+)
+
+// GetIntArray returns an integer array column (int2[], int4[], int8[]) as
+// int64s. NULL elements become 0. Multi-dimensional arrays are flattened
+// in row-major order. A NULL column returns nil; an empty array returns
+// an empty, non-nil slice.
+func (r Row) GetIntArray(idx int) []int64 {
+	elems, ok := r.arrayElements(idx)
+	if !ok {
+		return nil
+	}
+	out := make([]int64, len(elems))
+	for i, e := range elems {
+		if e == nil {
+			continue
+		}
+		if r.isBinary(idx) {
+			out[i], _ = decodeBinaryInt(e)
+		} else {
+			out[i] = parseTextInt(e)
+		}
+	}
+	return out
+}
+
+// GetStringArray returns a text array column (text[], varchar[], or any
+// array in text format) as strings. NULL elements become "".
+// Multi-dimensional arrays are flattened in row-major order. A NULL column
+// returns nil; an empty array returns an empty, non-nil slice.
+func (r Row) GetStringArray(idx int) []string {
+	elems, ok := r.arrayElements(idx)
+	if !ok {
+		return nil
+	}
+	out := make([]string, len(elems))
+	for i, e := range elems {
+		out[i] = string(e)
+	}
+	return out
+}
+
+// arrayElements splits array column idx into its elements, nil for NULL.
+func (r Row) arrayElements(idx int) ([][]byte, bool) {
+	b := r.Get(idx)
+	if b == nil {
+		return nil, false
+	}
+	if r.isBinary(idx) {
+		return parseBinaryArray(b)
+	}
+	return parseTextArray(b)
+}
+
+// parseTextArray parses an array literal such as {1,2,NULL} or
+// {"a b","c\"d"}, including nested braces and an optional [l:u]=
+// dimension prefix.
+func parseTextArray(b []byte) ([][]byte, bool) {
+	s := string(b)
+	if strings.HasPrefix(s, "[") {
+		i := strings.IndexByte(s, '=')
+		if i < 0 {
+			return nil, false
+		}
+		s = s[i+1:]
+	}
+	if len(s) < 2 || s[0] != '{' || s[len(s)-1] != '}' {
+		return nil, false
+	}
+
+	elems := [][]byte{}
+	depth := 0
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case c == '{':
+			depth++
+			i++
+		case c == '}':
+			depth--
+			i++
+		case c == ',' || c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '"':
+			var e []byte
+			i++
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				e = append(e, s[i])
+				i++
+			}
+			if i >= len(s) {
+				return nil, false
+			}
+			i++ // closing quote
+			if e == nil {
+				e = []byte{}
+			}
+			elems = append(elems, e)
+		default:
+			var e []byte
+			for i < len(s) && s[i] != ',' && s[i] != '}' {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				e = append(e, s[i])
+				i++
+			}
+			if v := strings.TrimSpace(string(e)); strings.EqualFold(v, "NULL") {
+				elems = append(elems, nil)
+			} else {
+				elems = append(elems, []byte(v))
+			}
+		}
+		if depth < 0 {
+			return nil, false
+		}
+	}
+	if depth != 0 {
+		return nil, false
+	}
+	return elems, true
+}
+
+// parseBinaryArray parses the binary array format: ndim, flags and
+// element type OID (int32 each), a length and lower bound per dimension,
+// then each element as an int32 length (-1 = NULL) and its bytes.
+func parseBinaryArray(b []byte) ([][]byte, bool) {
+	if len(b) < 12 {
+		return nil, false
+	}
+	ndim := int(int32(binary.BigEndian.Uint32(b[0:4])))
+	if ndim < 0 || len(b) < 12+8*ndim {
+		return nil, false
+	}
+	count := 1
+	if ndim == 0 {
+		count = 0
+	}
+	for d := 0; d < ndim; d++ {
+		n := int(int32(binary.BigEndian.Uint32(b[12+8*d:])))
+		if n < 0 {
+			return nil, false
+		}
+		count *= n
+	}
+
+	pos := 12 + 8*ndim
+	if count > (len(b)-pos)/4 {
+		return nil, false
+	}
+	elems := make([][]byte, count)
+	for i := range elems {
+		if pos+4 > len(b) {
+			return nil, false
+		}
+		n := int(int32(binary.BigEndian.Uint32(b[pos:])))
+		pos += 4
+		if n < 0 {
+			continue // NULL
+		}
+		if pos+n > len(b) {
+			return nil, false
+		}
+		elems[i] = b[pos : pos+n : pos+n]
+		pos += n
+	}
+	return elems, true
+}
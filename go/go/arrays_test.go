@@ -0,0 +1,60 @@
+package qail
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestGetStringArray(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want []string
+	}{
+		{`{}`, []string{}},
+		{`{a,b}`, []string{"a", "b"}},
+		// Quoted elements may hold spaces, commas, braces and escaped
+		// quotes or backslashes; only an unquoted NULL is NULL.
+		{`{"a b","c,d","{x}","e\"f","g\\h"}`, []string{"a b", "c,d", "{x}", `e"f`, `g\h`}},
+		{`{"",NULL,"NULL",null}`, []string{"", "", "NULL", ""}},
+		{`{{a,b},{c,d}}`, []string{"a", "b", "c", "d"}},
+		{`[0:1]={a,b}`, []string{"a", "b"}},
+		{`{a,b`, nil},
+		{`{"a}`, nil},
+	} {
+		r := Row{columns: [][]byte{[]byte(tt.in)}, fields: []ColumnInfo{{Format: FormatText}}}
+		if got := r.GetStringArray(0); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetStringArray(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+
+	if got := (Row{columns: [][]byte{nil}}).GetStringArray(0); got != nil {
+		t.Errorf("NULL column = %#v, want nil", got)
+	}
+}
+
+func TestGetIntArray(t *testing.T) {
+	for _, tt := range []struct {
+		in     string
+		format int16
+		want   []int64
+	}{
+		{`{}`, FormatText, []int64{}},
+		{`{1,-2,NULL}`, FormatText, []int64{1, -2, 0}},
+		{`{{1,2},{3,4}}`, FormatText, []int64{1, 2, 3, 4}},
+		// ndim 0 is how binary format sends an empty array.
+		{"00000000 00000000 00000017", FormatBinary, []int64{}},
+		// int4[] {7,NULL}: one dimension of 2 elements, lower bound 1.
+		{"00000001 00000001 00000017 00000002 00000001 00000004 00000007 ffffffff", FormatBinary, []int64{7, 0}},
+		// Truncated: claims 2 elements but holds one.
+		{"00000001 00000000 00000017 00000002 00000001 00000004 00000007", FormatBinary, nil},
+	} {
+		col := []byte(tt.in)
+		if tt.format == FormatBinary {
+			col = wireHex(t, tt.in)
+		}
+		r := Row{columns: [][]byte{col}, fields: []ColumnInfo{{Format: tt.format}}}
+		if got := r.GetIntArray(0); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("GetIntArray(%s) = %#v, want %#v", tt.in, got, tt.want)
+		}
+	}
+}
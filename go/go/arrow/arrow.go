@@ -0,0 +1,129 @@
+// Package arrow collects qail query results into Apache Arrow record
+// batches for analytics tools (DuckDB, Polars, Parquet writers).
+//
+// It lives in its own module so the core qail-go module stays free of the
+// Arrow dependency.
+//
+// Example:
+//
+//	rec, err := arrow.FetchArrow(driver, qail.Get("trips").Columns("id", "fare"))
+//	if err != nil {
+//	    return err
+//	}
+//	defer rec.Release()
+package arrow
+
+import (
+	"fmt"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	qail "github.com/qail-lang/qail-go"
+)
+
+// FetchArrow executes cmd and returns the result as a single Arrow record.
+// Column types are chosen from the PostgreSQL type OIDs; see ArrowType.
+// The caller must Release the record.
+func FetchArrow(d *qail.Driver, cmd *qail.Qail) (arrow.Record, error) {
+	return FetchArrowWith(memory.DefaultAllocator, d, cmd)
+}
+
+// FetchArrowWith is FetchArrow using the given allocator.
+func FetchArrowWith(mem memory.Allocator, d *qail.Driver, cmd *qail.Qail) (arrow.Record, error) {
+	res, err := d.FetchResult(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return ToRecord(mem, res.Fields(), res.Rows)
+}
+
+// ToRecord converts rows with the given column metadata into an Arrow record.
+func ToRecord(mem memory.Allocator, cols []qail.ColumnInfo, rows []qail.Row) (arrow.Record, error) {
+	fields := make([]arrow.Field, len(cols))
+	for i, c := range cols {
+		fields[i] = arrow.Field{Name: c.Name, Type: ArrowType(c.TypeOID), Nullable: true}
+	}
+	schema := arrow.NewSchema(fields, nil)
+
+	b := array.NewRecordBuilder(mem, schema)
+	defer b.Release()
+
+	for i := range cols {
+		fb := b.Field(i)
+		fb.Reserve(len(rows))
+		for _, r := range rows {
+			if err := appendValue(fb, r, i); err != nil {
+				return nil, fmt.Errorf("column %q: %w", cols[i].Name, err)
+			}
+		}
+	}
+	return b.NewRecord(), nil
+}
+
+// ArrowType maps a PostgreSQL type OID to an Arrow data type. Types without
+// a dedicated mapping are carried as UTF-8 strings of their text form.
+func ArrowType(oid uint32) arrow.DataType {
+	switch oid {
+	case qail.OIDBool:
+		return arrow.FixedWidthTypes.Boolean
+	case qail.OIDInt2:
+		return arrow.PrimitiveTypes.Int16
+	case qail.OIDInt4:
+		return arrow.PrimitiveTypes.Int32
+	case qail.OIDInt8, qail.OIDOid:
+		return arrow.PrimitiveTypes.Int64
+	case qail.OIDFloat4:
+		return arrow.PrimitiveTypes.Float32
+	case qail.OIDFloat8:
+		return arrow.PrimitiveTypes.Float64
+	case qail.OIDDate:
+		return arrow.FixedWidthTypes.Date32
+	case qail.OIDTimestamp:
+		return &arrow.TimestampType{Unit: arrow.Microsecond}
+	case qail.OIDTimestampTz:
+		return &arrow.TimestampType{Unit: arrow.Microsecond, TimeZone: "UTC"}
+	case qail.OIDBytea:
+		return arrow.BinaryTypes.Binary
+	default:
+		return arrow.BinaryTypes.String
+	}
+}
+
+func appendValue(fb array.Builder, r qail.Row, idx int) error {
+	if r.Get(idx) == nil {
+		fb.AppendNull()
+		return nil
+	}
+	switch b := fb.(type) {
+	case *array.BooleanBuilder:
+		b.Append(r.GetBool(idx))
+	case *array.Int16Builder:
+		b.Append(int16(r.GetInt(idx)))
+	case *array.Int32Builder:
+		b.Append(int32(r.GetInt(idx)))
+	case *array.Int64Builder:
+		b.Append(r.GetInt(idx))
+	case *array.Float32Builder:
+		b.Append(float32(r.GetFloat64(idx)))
+	case *array.Float64Builder:
+		b.Append(r.GetFloat64(idx))
+	case *array.Date32Builder:
+		b.Append(arrow.Date32FromTime(r.GetTime(idx)))
+	case *array.TimestampBuilder:
+		b.Append(arrow.Timestamp(r.GetTime(idx).UnixMicro()))
+	case *array.BinaryBuilder:
+		// Text-format bytea arrives hex-encoded; Value decodes it.
+		v, err := r.Value(idx)
+		if err != nil {
+			return err
+		}
+		b.Append(v.([]byte))
+	case *array.StringBuilder:
+		b.Append(r.GetString(idx))
+	default:
+		return fmt.Errorf("unsupported arrow builder %T", fb)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package arrow
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+
+	qail "github.com/qail-lang/qail-go"
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func fetch(t *testing.T, srv *qailtest.Server, sql string) *qail.Result {
+	t.Helper()
+	d, err := qail.NewDriver(qail.Config{User: "test", Database: "test", SSLMode: "disable", DialFunc: srv.Dial})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Close)
+	res, err := d.SimpleExec(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res[0]
+}
+
+func TestToRecord(t *testing.T) {
+	cols := []qailtest.Column{
+		{Name: "flag", OID: qail.OIDBool},
+		{Name: "i2", OID: qail.OIDInt2},
+		{Name: "i4", OID: qail.OIDInt4},
+		{Name: "i8", OID: qail.OIDInt8},
+		{Name: "f4", OID: qail.OIDFloat4},
+		{Name: "f8", OID: qail.OIDFloat8},
+		{Name: "day", OID: qail.OIDDate},
+		{Name: "ts", OID: qail.OIDTimestamp},
+		{Name: "tstz", OID: qail.OIDTimestampTz},
+		{Name: "raw", OID: qail.OIDBytea},
+		{Name: "name", OID: qail.OIDText},
+	}
+	srv := qailtest.NewServer()
+	srv.Handle("SELECT everything", qailtest.Response{
+		Columns: cols,
+		Rows: [][]any{
+			{true, -2, 40000, int64(1) << 40, 1.5, 2.25, "2024-02-29",
+				"2024-02-29 13:14:15.123456", "2024-02-29 13:14:15.123456+00", `\x00ff5c`, "Valletta"},
+			make([]any, len(cols)),
+		},
+	})
+	res := fetch(t, srv, "SELECT everything")
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	rec, err := ToRecord(mem, res.Fields(), res.Rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rec.Release()
+
+	if rec.NumRows() != 2 || rec.NumCols() != int64(len(cols)) {
+		t.Fatalf("record is %dx%d, want 2x%d", rec.NumRows(), rec.NumCols(), len(cols))
+	}
+	for i, c := range cols {
+		if got, want := rec.Schema().Field(i).Type, ArrowType(c.OID); !arrow.TypeEqual(got, want) {
+			t.Errorf("column %q: type %v, want %v", c.Name, got, want)
+		}
+		if !rec.Column(i).IsNull(1) {
+			t.Errorf("column %q: row 1 is not null", c.Name)
+		}
+	}
+
+	day := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC)
+	ts := time.Date(2024, 2, 29, 13, 14, 15, 123456000, time.UTC)
+	checks := []struct {
+		name string
+		got  any
+		want any
+	}{
+		{"flag", rec.Column(0).(*array.Boolean).Value(0), true},
+		{"i2", rec.Column(1).(*array.Int16).Value(0), int16(-2)},
+		{"i4", rec.Column(2).(*array.Int32).Value(0), int32(40000)},
+		{"i8", rec.Column(3).(*array.Int64).Value(0), int64(1) << 40},
+		{"f4", rec.Column(4).(*array.Float32).Value(0), float32(1.5)},
+		{"f8", rec.Column(5).(*array.Float64).Value(0), 2.25},
+		{"day", rec.Column(6).(*array.Date32).Value(0), arrow.Date32FromTime(day)},
+		{"ts", rec.Column(7).(*array.Timestamp).Value(0), arrow.Timestamp(ts.UnixMicro())},
+		{"tstz", rec.Column(8).(*array.Timestamp).Value(0), arrow.Timestamp(ts.UnixMicro())},
+		{"name", rec.Column(10).(*array.String).Value(0), "Valletta"},
+	}
+	for _, c := range checks {
+		if c.got != c.want {
+			t.Errorf("%s = %v, want %v", c.name, c.got, c.want)
+		}
+	}
+	if got, want := rec.Column(9).(*array.Binary).Value(0), []byte{0x00, 0xff, '\\'}; !bytes.Equal(got, want) {
+		t.Errorf("raw = %q, want the decoded bytes %q", got, want)
+	}
+}
+
+func TestToRecordInvalidBytea(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Handle("SELECT raw", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "raw", OID: qail.OIDBytea}},
+		Rows:    [][]any{{`\xzz`}},
+	})
+	res := fetch(t, srv, "SELECT raw")
+
+	mem := memory.NewCheckedAllocator(memory.NewGoAllocator())
+	defer mem.AssertSize(t, 0)
+	if rec, err := ToRecord(mem, res.Fields(), res.Rows); err == nil {
+		rec.Release()
+		t.Error("malformed bytea hex: no error")
+	}
+}
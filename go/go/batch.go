@@ -0,0 +1,88 @@
+package qail
+
+import "fmt"
+
+// Batch queues commands to run pipelined on one connection; see
+// Driver.SendBatch. Unlike BatchExecute it returns every command's rows,
+// and unlike ExecutePrepared it accepts any command.
+type Batch struct {
+	cmds []*Qail
+}
+
+// Queue adds cmd to the batch and returns the batch for chaining.
+func (b *Batch) Queue(cmd *Qail) *Batch {
+	b.cmds = append(b.cmds, cmd)
+	return b
+}
+
+// Len returns the number of queued commands.
+func (b *Batch) Len() int {
+	return len(b.cmds)
+}
+
+// BatchResult is the outcome of one command in a Batch.
+type BatchResult struct {
+	Rows       []Row
+	CommandTag string
+	Err        error // this command's server error, if any
+}
+
+// SendBatch encodes every queued command, writes them in one flush and
+// reads one result per command, in queue order.
+//
+// Each command is encoded with its own Sync, so the responses are
+// delimited by ReadyForQuery and the server runs each command in its own
+// implicit transaction: a command that fails reports Err on its
+// BatchResult and the commands after it still run. The error result is
+// for encoding and I/O failures; after an I/O failure the results read
+// so far are returned with it.
+func (d *Driver) SendBatch(b *Batch) (results []BatchResult, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("SendBatch", batchSQL(b.cmds))
+		defer func() { d.traceEnd(q, len(results), err) }()
+	}
+	if len(b.cmds) == 0 {
+		return nil, nil
+	}
+
+	// Encode everything before taking a connection, so an encoding
+	// failure never leaves a partial batch on the wire.
+	wires := make([][]byte, len(b.cmds))
+	for i, cmd := range b.cmds {
+		if wires[i] = cmd.Encode(); len(wires[i]) == 0 {
+			return nil, fmt.Errorf("command %d: %w", i, encodeError(cmd))
+		}
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	for _, wire := range wires {
+		if c.resultFormat != FormatText {
+			wire = setResultFormat(wire, c.resultFormat)
+		}
+		if _, err := c.writer.Write(wire); err != nil {
+			return nil, fmt.Errorf("write failed: %w", err)
+		}
+	}
+	if err := c.writer.Flush(); err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+
+	results = make([]BatchResult, 0, len(b.cmds))
+	for range b.cmds {
+		res, err := c.readResult(nil)
+		if err != nil {
+			if c.poisoned.Load() {
+				return results, err
+			}
+			results = append(results, BatchResult{Err: err})
+			continue
+		}
+		results = append(results, BatchResult{Rows: res.Rows, CommandTag: res.CommandTag})
+	}
+	return results, nil
+}
@@ -0,0 +1,71 @@
+package qail
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"time"
+)
+
+// cancelRequestCode is the CancelRequest protocol code (1234 << 16 | 5678).
+const cancelRequestCode = 80877102
+
+// cancelDialTimeout bounds the side connection that carries a
+// CancelRequest.
+const cancelDialTimeout = 5 * time.Second
+
+// watchCancel arranges for ctx ending to cancel the server's current
+// query on c and unblock c's pending reads. The returned stop reports
+// false if that already happened, in which case c must be discarded.
+func (c *Conn) watchCancel(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		c.cancelRequest()
+		c.conn.SetDeadline(time.Now())
+	})
+}
+
+// cancelRequest asks the server, over a separate connection, to cancel
+// the query c is running. Delivery is best-effort, as in the protocol.
+func (c *Conn) cancelRequest() {
+	if c.addr == "" || c.pid == 0 {
+		return
+	}
+	dial := c.dial
+	if dial == nil {
+		var dialer net.Dialer
+		dial = dialer.DialContext
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), cancelDialTimeout)
+	defer cancel()
+	conn, err := dial(ctx, "tcp", c.addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	var msg [16]byte
+	binary.BigEndian.PutUint32(msg[0:4], 16)
+	binary.BigEndian.PutUint32(msg[4:8], cancelRequestCode)
+	binary.BigEndian.PutUint32(msg[8:12], c.pid)
+	binary.BigEndian.PutUint32(msg[12:16], c.secret)
+	conn.Write(msg[:])
+}
+
+// withContext runs fn on c, cancelling it as watchCancel does if ctx ends
+// first. c is then marked poisoned, so putConn discards it, and ctx.Err()
+// is returned in place of fn's error.
+func (c *Conn) withContext(ctx context.Context, fn func() error) error {
+	if ctx.Done() == nil {
+		return fn()
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	stop := c.watchCancel(ctx)
+	err := fn()
+	if !stop() {
+		c.poisoned.Store(true)
+		return ctx.Err()
+	}
+	return err
+}
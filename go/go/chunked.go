@@ -0,0 +1,131 @@
+package qail
+
+import (
+	"fmt"
+	"math"
+)
+
+// FetchChunked runs cmd and hands its rows to fn in chunks of at most
+// chunkSize, so a result of millions of rows never needs to fit in memory:
+//
+//	n, err := d.FetchChunked(qail.Get("events"), 10_000, func(rows []qail.Row) error {
+//		for _, r := range rows { ... }
+//		return nil
+//	})
+//
+// It uses the extended protocol's Execute row limit: the server suspends
+// the portal after each chunk and resumes it when asked for the next, with
+// no DECLARE CURSOR and no explicit transaction. fn may keep the rows it
+// is given. If fn returns an error, the query is abandoned and that error
+// returned. It returns the number of rows delivered.
+func (d *Driver) FetchChunked(cmd *Qail, chunkSize int, fn func(rows []Row) error) (n int64, err error) {
+	if d.replica != nil && cmd.IsReadOnly() {
+		return d.replica.FetchChunked(cmd, chunkSize, fn)
+	}
+	if chunkSize <= 0 || chunkSize > math.MaxInt32 {
+		return 0, fmt.Errorf("FetchChunked: chunk size %d out of range", chunkSize)
+	}
+	sql, params, err := cmd.sqlParams()
+	if err != nil {
+		return 0, err
+	}
+	if d.tracer != nil {
+		q := d.traceStart("FetchChunked", sql)
+		defer func() { d.traceEnd(q, int(n), err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return 0, err
+	}
+	defer d.putConn(c)
+
+	// Flush, not Sync, after each Execute: Sync would end the implicit
+	// transaction and with it the suspended portal.
+	buf := appendParse(nil, "", sql)
+	buf = appendBind(buf, "", "", params, c.resultFormat)
+	buf = appendDescribe(buf, 'P', "")
+	buf = appendExecute(buf, "", int32(chunkSize))
+	buf = append(buf, 'H', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return 0, fmt.Errorf("write failed: %w", err)
+	}
+	return c.readChunks(int32(chunkSize), fn)
+}
+
+// readChunks reads the portal's output chunk by chunk, re-executing it on
+// each PortalSuspended, until CommandComplete or an error; it then syncs
+// and reads up to ReadyForQuery so the connection stays usable.
+func (c *Conn) readChunks(chunkSize int32, fn func(rows []Row) error) (n int64, err error) {
+	var fields []ColumnInfo
+	var chunk []Row
+
+	// deliver hands a chunk to fn; after an error the remaining rows are
+	// not delivered.
+	deliver := func() {
+		if err == nil && len(chunk) > 0 {
+			n += int64(len(chunk))
+			if cbErr := fn(chunk); cbErr != nil {
+				err = cbErr
+			}
+		}
+		chunk = nil
+	}
+
+	synced := false
+	sendSync := func() {
+		if synced {
+			return
+		}
+		synced = true
+		if _, werr := c.conn.Write([]byte{'S', 0, 0, 0, 4}); werr != nil && err == nil {
+			err = fmt.Errorf("write failed: %w", werr)
+		}
+	}
+
+	for {
+		msgType, data, rerr := c.readMessage()
+		if rerr != nil {
+			return n, rerr
+		}
+		switch msgType {
+		case '1', '2', 'n': // ParseComplete, BindComplete, NoData
+		case 'T': // RowDescription
+			if fields, rerr = parseRowDescription(data); rerr != nil && err == nil {
+				err = rerr
+			}
+		case 'D': // DataRow; data is freshly allocated, so rows may be kept
+			if err != nil {
+				continue
+			}
+			cols, perr := parseDataRow(data)
+			if perr != nil {
+				err = perr
+				continue
+			}
+			chunk = append(chunk, Row{columns: cols, fields: fields})
+		case 's': // PortalSuspended: more rows remain
+			deliver()
+			if err != nil {
+				sendSync()
+				continue
+			}
+			next := appendExecute(nil, "", chunkSize)
+			next = append(next, 'H', 0, 0, 0, 4)
+			if _, werr := c.conn.Write(next); werr != nil {
+				return n, fmt.Errorf("write failed: %w", werr)
+			}
+		case 'C': // CommandComplete: the last chunk
+			deliver()
+			sendSync()
+		case 'E':
+			// The server discards messages until Sync, then sends 'Z'.
+			if err == nil {
+				err = serverError("query error", data)
+			}
+			sendSync()
+		case 'Z':
+			return n, err
+		}
+	}
+}
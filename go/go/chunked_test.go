@@ -0,0 +1,91 @@
+package qail
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestFetchChunked(t *testing.T) {
+	tests := []struct {
+		name   string
+		rows   []int
+		chunk  int
+		chunks [][]int64
+	}{
+		{"partial last chunk", []int{1, 2, 3, 4, 5, 6, 7}, 3, [][]int64{{1, 2, 3}, {4, 5, 6}, {7}}},
+		{"exact multiple", []int{1, 2, 3, 4, 5, 6}, 3, [][]int64{{1, 2, 3}, {4, 5, 6}}},
+		{"one chunk", []int{1, 2}, 10, [][]int64{{1, 2}}},
+		{"empty", nil, 3, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qailtest.NewServer()
+			srv.HandleFunc(intRows(tt.rows...))
+			d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+			var kept [][]Row
+			n, err := d.FetchChunked(Get("events"), tt.chunk, func(rows []Row) error {
+				kept = append(kept, rows)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(tt.rows)) {
+				t.Errorf("delivered %d rows, want %d", n, len(tt.rows))
+			}
+			// Rows from earlier chunks are read after the later ones
+			// arrive: fn may keep them.
+			var got [][]int64
+			for _, rows := range kept {
+				var ids []int64
+				for _, r := range rows {
+					ids = append(ids, r.GetInt(0))
+				}
+				got = append(got, ids)
+			}
+			if !reflect.DeepEqual(got, tt.chunks) {
+				t.Errorf("chunks = %v, want %v", got, tt.chunks)
+			}
+		})
+	}
+}
+
+func TestFetchChunkedCallbackError(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(intRows(1, 2, 3, 4, 5, 6, 7))
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	stop := errors.New("stop")
+	calls := 0
+	n, err := d.FetchChunked(Get("events"), 2, func(rows []Row) error {
+		calls++
+		return stop
+	})
+	if !errors.Is(err, stop) {
+		t.Fatalf("err = %v, want the callback's error", err)
+	}
+	if calls != 1 || n != 2 {
+		t.Errorf("calls = %d, n = %d; want 1 call delivering 2 rows", calls, n)
+	}
+
+	// The abandoned portal was synced away: the connection still works.
+	srv.HandleFunc(intRows(9))
+	row, err := d.FetchOne(Get("numbers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := row.GetInt(0); v != 9 {
+		t.Errorf("next query = %d, want 9", v)
+	}
+
+	for _, size := range []int{0, -1} {
+		if _, err := d.FetchChunked(Get("events"), size, func([]Row) error { return nil }); err == nil {
+			t.Errorf("chunk size %d: want an error", size)
+		}
+	}
+}
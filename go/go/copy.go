@@ -0,0 +1,429 @@
+package qail
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CopyTo runs COPY (query) TO STDOUT and streams the output to w as it
+// arrives, without buffering the result set. The data is in COPY's text
+// format (tab-separated, \N for NULL); use CopyToSQL for options such as
+// CSV with a header.
+//
+// It returns the number of bytes written to w. If w fails, the rest of
+// the stream is read and discarded so the connection stays usable, and
+// the write error is returned.
+func (d *Driver) CopyTo(query string, w io.Writer) (int64, error) {
+	return d.CopyToSQL("COPY ("+query+") TO STDOUT", w)
+}
+
+// CopyToSQL is CopyTo for a complete COPY ... TO STDOUT statement, such
+// as "COPY users TO STDOUT (FORMAT csv, HEADER)".
+func (d *Driver) CopyToSQL(copySQL string, w io.Writer) (written int64, err error) {
+	var tag string
+	if d.tracer != nil {
+		q := d.traceStart("CopyTo", copySQL)
+		defer func() { d.traceEnd(q, int(tagRows(tag)), err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return 0, err
+	}
+	defer d.putConn(c)
+
+	if err := c.sendQuery(copySQL); err != nil {
+		return 0, err
+	}
+
+	var copyErr error
+	for {
+		// Each CopyData payload is written out before the next read, so
+		// the connection's scratch buffer can hold it.
+		msgType, data, err := c.readMessageFast()
+		if err != nil {
+			return written, err
+		}
+		switch msgType {
+		case 'H': // CopyOutResponse
+			continue
+		case 'd': // CopyData
+			if copyErr != nil {
+				continue
+			}
+			n, err := w.Write(data)
+			written += int64(n)
+			if err != nil {
+				copyErr = fmt.Errorf("copy write: %w", err)
+			}
+		case 'c': // CopyDone
+			continue
+		case 'C': // CommandComplete: "COPY n"
+			tag = cstring(data)
+		case 'E':
+			// The server still sends 'Z'; keep reading so the connection stays in sync
+			if copyErr == nil {
+				copyErr = serverError("copy error", data)
+			}
+		case 'Z':
+			return written, copyErr
+		}
+	}
+}
+
+// CopyFrom bulk-loads rows into table with COPY ... FROM STDIN and
+// returns the number of rows copied. columns names the target columns, in
+// the order of each row's values; nil means all of the table's columns.
+// Table and column names are case-insensitive unless they need quoting,
+// as in the query builder: "Users" is users, "Order Lines" keeps its case.
+//
+// format selects COPY's wire format. FormatText sends tab-separated text
+// and accepts every type encodeTextArg does, leaving the server to parse
+// each value for its column; []byte is sent in bytea's hex form (\x...),
+// so it is meant for bytea columns. FormatBinary sends the PGCOPY binary
+// format, which is faster to load and carries floats and timestamps
+// exactly, but each Go value must match its column's type:
+//
+//	int16, int32, int/int64   int2, int4, int8
+//	float32, float64          float4, float8
+//	bool                      bool
+//	string, []byte            text/varchar, bytea
+//	time.Time                 timestamp, timestamptz
+//	[16]byte                  uuid
+//
+// A time.Time is an instant in either format, which is what a
+// timestamptz column stores. A timestamp column stores a wall clock, and
+// the formats pick different ones: text sends the value's own wall clock
+// and offset, and the server drops the offset, while binary sends the
+// instant in UTC. Pass times in UTC to get the same timestamp from both.
+//
+// A nil value is NULL in either format. If a value cannot be encoded the
+// COPY is aborted with CopyFail, so no rows are loaded.
+func (d *Driver) CopyFrom(table string, columns []string, rows [][]any, format int16) (n int64, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("CopyFrom", "COPY "+table+" FROM STDIN")
+		defer func() { d.traceEnd(q, int(n), err) }()
+	}
+	var opts string
+	switch format {
+	case FormatText:
+	case FormatBinary:
+		opts = " (FORMAT binary)"
+	default:
+		return 0, fmt.Errorf("unsupported COPY format %d", format)
+	}
+	var sql strings.Builder
+	sql.WriteString("COPY ")
+	sql.WriteString(quoteQualified(table))
+	if len(columns) > 0 {
+		sql.WriteString(" (")
+		for i, col := range columns {
+			if i > 0 {
+				sql.WriteString(", ")
+			}
+			sql.WriteString(foldIdent(col))
+		}
+		sql.WriteString(")")
+	}
+	sql.WriteString(" FROM STDIN")
+	sql.WriteString(opts)
+
+	c, err := d.getConn()
+	if err != nil {
+		return 0, err
+	}
+	defer d.putConn(c)
+
+	if err := c.sendQuery(sql.String()); err != nil {
+		return 0, err
+	}
+	// Wait for CopyInResponse; an error here (unknown table or column)
+	// is followed by ReadyForQuery.
+	for ready := false; !ready; {
+		msgType, data, err := c.readMessage()
+		if err != nil {
+			return 0, err
+		}
+		switch msgType {
+		case 'G': // CopyInResponse
+			ready = true
+		case 'E':
+			return 0, c.finishCopy(serverError("copy error", data))
+		}
+	}
+
+	copyErr := c.sendCopyData(rows, len(columns), format)
+	return c.readCopyResult(copyErr)
+}
+
+// copyChunkSize is how much row data is gathered into each CopyData.
+const copyChunkSize = 64 * 1024
+
+// binaryCopyHeader is the PGCOPY signature followed by zero flags and a
+// zero-length header extension.
+var binaryCopyHeader = []byte("PGCOPY\n\xff\r\n\x00\x00\x00\x00\x00\x00\x00\x00\x00")
+
+// sendCopyData streams rows as CopyData messages and ends the COPY with
+// CopyDone, or with CopyFail if a row cannot be encoded, in which case
+// the encoding error is returned. I/O errors are returned as is.
+func (c *Conn) sendCopyData(rows [][]any, width int, format int16) error {
+	var buf []byte
+	if format == FormatBinary {
+		buf = append(buf, binaryCopyHeader...)
+	}
+	var encErr error
+	for i, row := range rows {
+		if width > 0 && len(row) != width {
+			encErr = fmt.Errorf("row %d has %d values, want %d", i, len(row), width)
+			break
+		}
+		var err error
+		if format == FormatBinary {
+			buf, err = appendBinaryCopyRow(buf, row)
+		} else {
+			buf, err = appendTextCopyRow(buf, row)
+		}
+		if err != nil {
+			encErr = fmt.Errorf("row %d: %w", i, err)
+			break
+		}
+		if len(buf) >= copyChunkSize {
+			if err := c.writeCopyData(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+
+	if encErr != nil {
+		// CopyFail: the server aborts the COPY and replies with an error.
+		msg := encErr.Error()
+		fail := []byte{'f', 0, 0, 0, 0}
+		binary.BigEndian.PutUint32(fail[1:], uint32(4+len(msg)+1))
+		fail = append(append(fail, msg...), 0)
+		if _, err := c.writer.Write(fail); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+		if err := c.writer.Flush(); err != nil {
+			return fmt.Errorf("write failed: %w", err)
+		}
+		return encErr
+	}
+
+	if format == FormatBinary {
+		buf = binary.BigEndian.AppendUint16(buf, 0xFFFF) // trailer: field count -1
+	}
+	if len(buf) > 0 {
+		if err := c.writeCopyData(buf); err != nil {
+			return err
+		}
+	}
+	if _, err := c.writer.Write([]byte{'c', 0, 0, 0, 4}); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	if err := c.writer.Flush(); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// writeCopyData buffers one CopyData message carrying data.
+func (c *Conn) writeCopyData(data []byte) error {
+	var hdr [5]byte
+	hdr[0] = 'd'
+	binary.BigEndian.PutUint32(hdr[1:], uint32(4+len(data)))
+	if _, err := c.writer.Write(hdr[:]); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	if _, err := c.writer.Write(data); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// readCopyResult reads the server's reply to the end of a COPY and returns
+// the row count from its CommandComplete. copyErr, from sending the data,
+// takes precedence over the server's error; if it is an I/O error the
+// connection is already poisoned and nothing is read.
+func (c *Conn) readCopyResult(copyErr error) (int64, error) {
+	if copyErr != nil && c.poisoned.Load() {
+		return 0, copyErr
+	}
+	var n int64
+	err := copyErr
+	for {
+		msgType, data, rerr := c.readMessage()
+		if rerr != nil {
+			return 0, rerr
+		}
+		switch msgType {
+		case 'C': // CommandComplete: "COPY n"
+			tag := cstring(data)
+			if i := strings.LastIndexByte(tag, ' '); i >= 0 {
+				n, _ = strconv.ParseInt(tag[i+1:], 10, 64)
+			}
+		case 'E':
+			if err == nil {
+				err = serverError("copy error", data)
+			}
+		case 'Z':
+			if err != nil {
+				return 0, err
+			}
+			return n, nil
+		}
+	}
+}
+
+// finishCopy reads to ReadyForQuery after the server rejected a COPY
+// statement, and returns err.
+func (c *Conn) finishCopy(err error) error {
+	for {
+		msgType, _, rerr := c.readMessage()
+		if rerr != nil {
+			return rerr
+		}
+		if msgType == 'Z' {
+			return err
+		}
+	}
+}
+
+// appendTextCopyRow appends one row in COPY's text format: values
+// separated by tabs, NULL as \N, and backslash, tab, newline and carriage
+// return escaped.
+func appendTextCopyRow(buf []byte, row []any) ([]byte, error) {
+	for i, v := range row {
+		if i > 0 {
+			buf = append(buf, '\t')
+		}
+		b, err := encodeTextArg(v)
+		if err != nil {
+			return buf, fmt.Errorf("column %d: %w", i, err)
+		}
+		if b == nil {
+			buf = append(buf, '\\', 'N')
+			continue
+		}
+		for _, ch := range b {
+			switch ch {
+			case '\\':
+				buf = append(buf, '\\', '\\')
+			case '\t':
+				buf = append(buf, '\\', 't')
+			case '\n':
+				buf = append(buf, '\\', 'n')
+			case '\r':
+				buf = append(buf, '\\', 'r')
+			default:
+				buf = append(buf, ch)
+			}
+		}
+	}
+	return append(buf, '\n'), nil
+}
+
+// appendBinaryCopyRow appends one row in the PGCOPY binary format: the
+// field count, then each field as a length (-1 for NULL) and its value in
+// the type's binary send format.
+func appendBinaryCopyRow(buf []byte, row []any) ([]byte, error) {
+	if len(row) > 0x7FFF {
+		return buf, fmt.Errorf("%d columns exceeds the COPY limit", len(row))
+	}
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(row)))
+	for i, v := range row {
+		switch v := v.(type) {
+		case nil:
+			buf = binary.BigEndian.AppendUint32(buf, 0xFFFFFFFF)
+		case int16:
+			buf = binary.BigEndian.AppendUint32(buf, 2)
+			buf = binary.BigEndian.AppendUint16(buf, uint16(v))
+		case int32:
+			buf = binary.BigEndian.AppendUint32(buf, 4)
+			buf = binary.BigEndian.AppendUint32(buf, uint32(v))
+		case int:
+			buf = binary.BigEndian.AppendUint32(buf, 8)
+			buf = binary.BigEndian.AppendUint64(buf, uint64(v))
+		case int64:
+			buf = binary.BigEndian.AppendUint32(buf, 8)
+			buf = binary.BigEndian.AppendUint64(buf, uint64(v))
+		case float32:
+			buf = binary.BigEndian.AppendUint32(buf, 4)
+			buf = binary.BigEndian.AppendUint32(buf, math.Float32bits(v))
+		case float64:
+			buf = binary.BigEndian.AppendUint32(buf, 8)
+			buf = binary.BigEndian.AppendUint64(buf, math.Float64bits(v))
+		case bool:
+			buf = binary.BigEndian.AppendUint32(buf, 1)
+			if v {
+				buf = append(buf, 1)
+			} else {
+				buf = append(buf, 0)
+			}
+		case string:
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(v)))
+			buf = append(buf, v...)
+		case []byte:
+			if v == nil {
+				buf = binary.BigEndian.AppendUint32(buf, 0xFFFFFFFF)
+				continue
+			}
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(v)))
+			buf = append(buf, v...)
+		case time.Time:
+			buf = binary.BigEndian.AppendUint32(buf, 8)
+			buf = binary.BigEndian.AppendUint64(buf, uint64(v.Unix()*1_000_000+int64(v.Nanosecond()/1000)-pgEpochMicros))
+		case [16]byte:
+			buf = binary.BigEndian.AppendUint32(buf, 16)
+			buf = append(buf, v[:]...)
+		default:
+			return buf, fmt.Errorf("column %d: unsupported binary COPY type %T", i, v)
+		}
+	}
+	return buf, nil
+}
+
+// quoteIdent quotes a single SQL identifier, doubling embedded quotes.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// quoteQualified quotes a possibly schema-qualified name such as
+// "public.users" with foldIdent, one part at a time.
+func quoteQualified(name string) string {
+	parts := strings.Split(name, ".")
+	for i, p := range parts {
+		parts[i] = foldIdent(p)
+	}
+	return strings.Join(parts, ".")
+}
+
+// foldIdent quotes name so that it means what the query builder's
+// identifiers mean: a plain name (letters, digits and underscores, not
+// starting with a digit) is folded to lower case, as the server folds an
+// unquoted one, while any other name keeps its case. Quoting even plain
+// names keeps reserved words such as "order" usable.
+func foldIdent(name string) string {
+	plain := name != ""
+	for i, r := range name {
+		if !(unicode.IsLetter(r) || r == '_' || i > 0 && unicode.IsNumber(r)) {
+			plain = false
+			break
+		}
+	}
+	if plain {
+		name = strings.Map(func(r rune) rune {
+			if 'A' <= r && r <= 'Z' {
+				return r + ('a' - 'A')
+			}
+			return r
+		}, name)
+	}
+	return quoteIdent(name)
+}
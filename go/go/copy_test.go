@@ -0,0 +1,235 @@
+package qail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestAppendTextCopyRow(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []any
+		want string
+	}{
+		{"escapes", []any{"a\tb\\c\nd\re", 7, true}, "a\\tb\\\\c\\nd\\re\t7\tt\n"},
+		{"null", []any{nil, []byte(nil), ""}, "\\N\t\\N\t\n"},
+		{"bytea as hex", []any{[]byte{0xff, '\\', 0, '\t'}}, "\\\\xff5c0009\n"},
+		{"empty bytea", []any{[]byte{}}, "\\\\x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := appendTextCopyRow(nil, tt.row)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := appendTextCopyRow(nil, []any{struct{}{}}); err == nil {
+		t.Error("unsupported type: got no error")
+	}
+}
+
+// copyRows splits the data a COPY ... FROM STDIN sent into rows of raw
+// column values, nil for NULL, undoing the text format's escapes.
+func copyRows(t *testing.T, data []byte, format int16) [][][]byte {
+	t.Helper()
+	var rows [][][]byte
+	if format == FormatText {
+		unescape := strings.NewReplacer(`\\`, `\`, `\t`, "\t", `\n`, "\n", `\r`, "\r")
+		for _, line := range strings.SplitAfter(string(data), "\n") {
+			if line == "" {
+				continue
+			}
+			var row [][]byte
+			for _, field := range strings.Split(strings.TrimSuffix(line, "\n"), "\t") {
+				if field == `\N` {
+					row = append(row, nil)
+				} else {
+					row = append(row, []byte(unescape.Replace(field)))
+				}
+			}
+			rows = append(rows, row)
+		}
+		return rows
+	}
+
+	if !bytes.HasPrefix(data, binaryCopyHeader) {
+		t.Fatalf("binary COPY data starts % x, want the PGCOPY header", data[:min(len(data), 19)])
+	}
+	data = data[len(binaryCopyHeader):]
+	for {
+		if len(data) < 2 {
+			t.Fatal("binary COPY data ends without a trailer")
+		}
+		n := int16(binary.BigEndian.Uint16(data))
+		data = data[2:]
+		if n == -1 {
+			if len(data) != 0 {
+				t.Fatalf("%d bytes after the trailer", len(data))
+			}
+			return rows
+		}
+		row := make([][]byte, n)
+		for i := range row {
+			l := int32(binary.BigEndian.Uint32(data))
+			data = data[4:]
+			if l < 0 {
+				continue
+			}
+			row[i], data = data[:l:l], data[l:]
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestCopyFromRoundTrip(t *testing.T) {
+	columns := []string{"small", "medium", "big", "data", "at"}
+	oids := []uint32{OIDInt2, OIDInt4, OIDInt8, OIDBytea, OIDTimestampTz}
+	rows := [][]any{
+		{int16(-2), int32(70000), int64(1) << 40, []byte{0xde, 0xad, 0, '\t', '\\'}, time.Date(2024, 3, 1, 12, 30, 0, 123456000, time.UTC)},
+		{nil, nil, nil, []byte(nil), nil},
+		// More than 292 years from 2000, past what a time.Duration holds.
+		{int16(0), int32(-1), int64(-5), []byte{0}, time.Date(2500, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{int16(7), int32(0), int64(0), []byte("x"), time.Date(1650, 1, 1, 0, 0, 0, 1000, time.UTC)},
+	}
+
+	for _, format := range []int16{FormatText, FormatBinary} {
+		t.Run(fmt.Sprint("format ", format), func(t *testing.T) {
+			srv := qailtest.NewServer()
+			srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+				if !strings.HasPrefix(q.SQL, "COPY ") {
+					return qailtest.Response{}, false
+				}
+				return qailtest.Response{Tag: fmt.Sprint("COPY ", len(rows))}, true
+			})
+			d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+			n, err := d.CopyFrom("items", columns, rows, format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(rows)) {
+				t.Errorf("copied %d rows, want %d", n, len(rows))
+			}
+			queries := srv.Queries()
+			if len(queries) != 1 {
+				t.Fatalf("queries = %v, want one COPY", queries)
+			}
+			got := copyRows(t, queries[0].Copy, format)
+			if len(got) != len(rows) {
+				t.Fatalf("server received %d rows, want %d", len(got), len(rows))
+			}
+
+			// Decode what the server received as the driver decodes results.
+			fields := make([]ColumnInfo, len(columns))
+			for i := range fields {
+				fields[i] = ColumnInfo{Name: columns[i], TypeOID: oids[i], Format: format}
+			}
+			for i, want := range rows {
+				r := Row{columns: got[i], fields: fields}
+				for j, w := range want {
+					v, err := r.Value(j)
+					if err != nil {
+						t.Fatalf("row %d column %s: %v", i, columns[j], err)
+					}
+					switch w := w.(type) {
+					case nil:
+						if r.Get(j) != nil {
+							t.Errorf("row %d column %s = %v, want NULL", i, columns[j], v)
+						}
+					case []byte:
+						if w == nil && r.Get(j) != nil || w != nil && !bytes.Equal(v.([]byte), w) {
+							t.Errorf("row %d column %s = %x, want %x", i, columns[j], v, w)
+						}
+					case time.Time:
+						if !v.(time.Time).Equal(w) {
+							t.Errorf("row %d column %s = %v, want %v", i, columns[j], v, w)
+						}
+					default:
+						if fmt.Sprint(v) != fmt.Sprint(w) {
+							t.Errorf("row %d column %s = %v, want %v", i, columns[j], v, w)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestCopyFromEncodeErrorSendsCopyFail(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		return qailtest.Response{Tag: "COPY 1"}, strings.HasPrefix(q.SQL, "COPY ")
+	})
+	srv.Handle("SELECT 1", qailtest.Response{Columns: []qailtest.Column{{Name: "?column?", OID: qailtest.OIDInt4}}, Rows: [][]any{{1}}})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	_, err := d.CopyFrom("items", nil, [][]any{{1}, {struct{}{}}}, FormatBinary)
+	if err == nil || !strings.Contains(err.Error(), "row 1") {
+		t.Fatalf("err = %v, want the row 1 encoding error", err)
+	}
+	if _, err := d.SimpleExec("SELECT 1"); err != nil {
+		t.Fatalf("next query: %v", err)
+	}
+}
+
+func TestCopyFromNames(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		return qailtest.Response{Tag: "COPY 1"}, strings.HasPrefix(q.SQL, "COPY ")
+	})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	if _, err := d.CopyFrom("Shop.Items", []string{"ID", "order", "Unit Price", "2nd"}, [][]any{{1, 2, 3, 4}}, FormatText); err != nil {
+		t.Fatal(err)
+	}
+	want := `COPY "shop"."items" ("id", "order", "Unit Price", "2nd") FROM STDIN`
+	if q := srv.Queries(); len(q) != 1 || q[0].SQL != want {
+		t.Errorf("queries = %q, want %q", q, want)
+	}
+}
+
+// TestCopyFromTimestampFormats pins how each format writes a time.Time
+// that is not in UTC: text keeps its wall clock and offset, binary sends
+// the instant, so a timestamp column stores 12:00 from text and 10:00
+// from binary. In UTC the two agree.
+func TestCopyFromTimestampFormats(t *testing.T) {
+	local := time.Date(2024, 1, 2, 12, 0, 0, 0, time.FixedZone("", 2*60*60))
+	utc := time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)
+
+	for _, tt := range []struct {
+		in   time.Time
+		text string
+	}{
+		{local, "2024-01-02 12:00:00+02:00"},
+		{local.UTC(), "2024-01-02 10:00:00Z"},
+	} {
+		text, err := appendTextCopyRow(nil, []any{tt.in})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(string(text), "\n"); got != tt.text {
+			t.Errorf("text %v = %q, want %q", tt.in, got, tt.text)
+		}
+
+		bin, err := appendBinaryCopyRow(nil, []any{tt.in})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Field count, length, then the value.
+		got, ok := decodeBinaryTime(OIDTimestamp, bin[6:])
+		if !ok || !got.Equal(utc) {
+			t.Errorf("binary %v = %v, want %v as a timestamp", tt.in, got, utc)
+		}
+	}
+}
@@ -0,0 +1,160 @@
+package qail
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+// ErrCursorClosed is returned by Fetch on a closed Cursor.
+var ErrCursorClosed = errors.New("cursor is closed")
+
+// Cursor is a server-side cursor declared with Tx.DeclareCursor. Rows are
+// read a page at a time with Fetch, so a large result never needs to fit
+// in memory:
+//
+//	cur, err := tx.DeclareCursor("big", qail.Get("events"))
+//	if err != nil { ... }
+//	defer cur.Close()
+//	for {
+//		rows, err := cur.Fetch(1000)
+//		if err != nil { ... }
+//		if len(rows) == 0 {
+//			break
+//		}
+//		...
+//	}
+//
+// Like its Tx, a Cursor is not safe for concurrent use, and it shares the
+// Tx's connection.
+type Cursor struct {
+	tx     *Tx
+	c      *Conn
+	name   string
+	hold   bool
+	closed bool
+}
+
+// DeclareCursor declares a cursor named name over cmd's result. It lives
+// until Close or the end of the transaction, whichever comes first.
+func (tx *Tx) DeclareCursor(name string, cmd *Qail) (*Cursor, error) {
+	return tx.declareCursor(name, cmd, false)
+}
+
+// DeclareCursorWithHold declares a WITH HOLD cursor, which outlives the
+// transaction: after a successful Commit it can still be fetched from,
+// and the Tx's connection stays checked out until every such cursor is
+// closed. The server materializes the remaining rows at Commit, so this
+// suits results that must be read across transactions rather than the
+// very largest ones. A Rollback, or a Commit that returns
+// ErrTxRolledBack, drops the cursor and releases the connection; Fetch
+// then returns ErrTxDone.
+func (tx *Tx) DeclareCursorWithHold(name string, cmd *Qail) (*Cursor, error) {
+	return tx.declareCursor(name, cmd, true)
+}
+
+func (tx *Tx) declareCursor(name string, cmd *Qail, hold bool) (*Cursor, error) {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return nil, ErrTxDone
+	}
+	if err := validateIdentifier(name); err != nil {
+		return nil, fmt.Errorf("cursor: %w", err)
+	}
+	sql, params, err := cmd.sqlParams()
+	if err != nil {
+		return nil, err
+	}
+	declare := "DECLARE " + name + " NO SCROLL CURSOR "
+	if hold {
+		declare += "WITH HOLD "
+	}
+	declare += "FOR " + sql
+
+	// The extended protocol binds cmd's parameters into the cursor's query.
+	buf := appendParse(nil, "", declare)
+	buf = appendBind(buf, "", "", params, FormatText)
+	buf = appendExecute(buf, "", 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := tx.c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	if _, err := tx.c.readResult(nil); err != nil {
+		return nil, err
+	}
+	if hold {
+		tx.held++
+	}
+	return &Cursor{tx: tx, c: tx.c, name: name, hold: hold}, nil
+}
+
+// Name returns the cursor's name.
+func (cur *Cursor) Name() string {
+	return cur.name
+}
+
+// Fetch returns the next n rows (FETCH n), or fewer at the end of the
+// result; an empty slice means the cursor is exhausted.
+func (cur *Cursor) Fetch(n int) ([]Row, error) {
+	cur.tx.enter()
+	defer cur.tx.exit()
+
+	if err := cur.usable(); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("cursor: fetch count %d must be positive", n)
+	}
+	buf := appendParse(nil, "", "FETCH FORWARD "+strconv.Itoa(n)+" FROM "+cur.name)
+	buf = appendBind(buf, "", "", nil, cur.c.resultFormat)
+	buf = appendDescribe(buf, 'P', "")
+	buf = appendExecute(buf, "", 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := cur.c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	return cur.c.readRows()
+}
+
+// Close closes the cursor. Closing the last WITH HOLD cursor of an ended
+// Tx returns its connection to the pool. Calling Close again is a no-op.
+func (cur *Cursor) Close() error {
+	cur.tx.enter()
+	defer cur.tx.exit()
+
+	if cur.closed {
+		return nil
+	}
+	cur.closed = true
+
+	if !cur.open() {
+		return nil // the server dropped it when the Tx ended
+	}
+	err := cur.c.simpleExec("CLOSE " + cur.name)
+	if cur.hold {
+		cur.tx.held--
+		if cur.tx.done.Load() && cur.tx.held == 0 {
+			cur.tx.d.putConn(cur.c)
+		}
+	}
+	return err
+}
+
+// usable reports why the cursor can't be used, if it can't.
+func (cur *Cursor) usable() error {
+	if cur.closed {
+		return ErrCursorClosed
+	}
+	if !cur.open() {
+		return ErrTxDone
+	}
+	return nil
+}
+
+// open reports whether the server still has the cursor: its Tx is
+// running, or it is a WITH HOLD cursor of a Tx that committed.
+func (cur *Cursor) open() bool {
+	return !cur.tx.done.Load() || cur.hold && cur.tx.kept
+}
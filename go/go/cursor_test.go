@@ -0,0 +1,189 @@
+package qail
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+// cursorServer serves BEGIN, COMMIT, ROLLBACK, DECLARE and CLOSE, and
+// answers each FETCH with the next page of rows 1..total.
+func cursorServer(total int) *qailtest.Server {
+	srv := qailtest.NewServer()
+	srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+	srv.Handle("COMMIT", qailtest.Response{Tag: "COMMIT"})
+	srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+	next := 1
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		switch {
+		case strings.HasPrefix(q.SQL, "DECLARE "):
+			return qailtest.Response{Tag: "DECLARE CURSOR"}, true
+		case strings.HasPrefix(q.SQL, "CLOSE "):
+			return qailtest.Response{Tag: "CLOSE CURSOR"}, true
+		case strings.HasPrefix(q.SQL, "FETCH FORWARD 2 FROM "):
+			r := qailtest.Response{Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}}, Rows: [][]any{}}
+			if q.Args == nil { // Describe
+				return r, true
+			}
+			for ; next <= total && len(r.Rows) < 2; next++ {
+				r.Rows = append(r.Rows, []any{next})
+			}
+			return r, true
+		}
+		return qailtest.Response{}, false
+	})
+	return srv
+}
+
+func fetchIDs(t *testing.T, cur *Cursor) []int64 {
+	t.Helper()
+	rows, err := cur.Fetch(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []int64{}
+	for _, r := range rows {
+		ids = append(ids, r.GetInt(0))
+	}
+	return ids
+}
+
+func TestCursorWithHoldOutlivesTx(t *testing.T) {
+	srv := cursorServer(5)
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := tx.DeclareCursorWithHold("big", Get("events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fetchIDs(t, cur); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("first page = %v", got)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cursor keeps the connection checked out past Commit.
+	if s := d.Stats(); s.InUse != 1 || s.Idle != 0 {
+		t.Fatalf("after Commit: InUse = %d, Idle = %d; want the connection held", s.InUse, s.Idle)
+	}
+	for _, want := range [][]int64{{3, 4}, {5}, {}} {
+		if got := fetchIDs(t, cur); !reflect.DeepEqual(got, want) {
+			t.Fatalf("page = %v, want %v", got, want)
+		}
+	}
+
+	if err := cur.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if s := d.Stats(); s.InUse != 0 || s.Idle != 1 {
+		t.Errorf("after Close: InUse = %d, Idle = %d; want the connection back in the pool", s.InUse, s.Idle)
+	}
+	queries := srv.Queries()
+	if last := queries[len(queries)-1].SQL; last != "CLOSE big" {
+		t.Errorf("last query = %q, want CLOSE big", last)
+	}
+	if _, err := cur.Fetch(2); !errors.Is(err, ErrCursorClosed) {
+		t.Errorf("Fetch after Close: err = %v, want ErrCursorClosed", err)
+	}
+	if err := cur.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestCursorEndsWithTx(t *testing.T) {
+	srv := cursorServer(5)
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cur, err := tx.DeclareCursor("big", Get("events"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fetchIDs(t, cur); !reflect.DeepEqual(got, []int64{1, 2}) {
+		t.Fatalf("first page = %v", got)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if s := d.Stats(); s.InUse != 0 || s.Idle != 1 {
+		t.Errorf("after Commit: InUse = %d, Idle = %d; want the connection back in the pool", s.InUse, s.Idle)
+	}
+	if _, err := cur.Fetch(2); !errors.Is(err, ErrTxDone) {
+		t.Errorf("Fetch after Commit: err = %v, want ErrTxDone", err)
+	}
+	// The server closed it at Commit; Close must not send CLOSE.
+	n := len(srv.Queries())
+	if err := cur.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if len(srv.Queries()) != n {
+		t.Errorf("Close after Commit sent %v", srv.Queries()[n:])
+	}
+
+	if _, err := tx.DeclareCursor("late", Get("events")); !errors.Is(err, ErrTxDone) {
+		t.Errorf("DeclareCursor after Commit: err = %v, want ErrTxDone", err)
+	}
+}
+
+func TestCursorWithHoldDroppedByRollback(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		end     func(tx *Tx) error
+		wantErr error
+	}{
+		{"Rollback", (*Tx).Rollback, nil},
+		{"failed Commit", func(tx *Tx) error {
+			if err := tx.ExecuteSimple("SELECT boom"); err == nil {
+				return errors.New("SELECT boom succeeded")
+			}
+			return tx.Commit()
+		}, ErrTxRolledBack},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := cursorServer(5)
+			d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+			tx, err := d.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			cur, err := tx.DeclareCursorWithHold("big", Get("events"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.end(tx); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ending the Tx: err = %v, want %v", err, tt.wantErr)
+			}
+
+			// The server dropped the cursor, so nothing holds the connection.
+			if s := d.Stats(); s.InUse != 0 || s.Idle != 1 {
+				t.Errorf("after the rollback: InUse = %d, Idle = %d; want the connection back in the pool", s.InUse, s.Idle)
+			}
+			if _, err := cur.Fetch(2); !errors.Is(err, ErrTxDone) {
+				t.Errorf("Fetch after the rollback: err = %v, want ErrTxDone", err)
+			}
+			n := len(srv.Queries())
+			if err := cur.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if len(srv.Queries()) != n {
+				t.Errorf("Close after the rollback sent %v", srv.Queries()[n:])
+			}
+			if s := d.Stats(); s.InUse != 0 || s.Idle != 1 {
+				t.Errorf("after Close: InUse = %d, Idle = %d; want 0, 1", s.InUse, s.Idle)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package qail
+
+import (
+	"net"
+	"sync"
+	"time"
+)
+
+// deadlineConn gives every Read and Write its own deadline, ReadTimeout or
+// WriteTimeout from the start of the call, so a server that stops
+// responding fails the call instead of blocking it forever. The failed
+// call poisons the Conn, and putConn then discards it.
+//
+// A deadline set explicitly (cancellation, ping, a handshake bound by a
+// context) takes precedence until it is cleared with the zero time. Read
+// and write deadlines are tracked apart, so an explicit read deadline
+// leaves WriteTimeout in force and vice versa.
+type deadlineConn struct {
+	net.Conn
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+
+	mu            sync.Mutex // orders automatic deadlines against explicit ones
+	explicitRead  bool       // an explicit read deadline is in force
+	explicitWrite bool       // an explicit write deadline is in force
+	suspended     bool       // reads may block indefinitely; see Conn.suspendReadTimeout
+}
+
+func (c *deadlineConn) Read(p []byte) (int, error) {
+	if c.readTimeout > 0 {
+		c.mu.Lock()
+		if !c.explicitRead && !c.suspended {
+			c.Conn.SetReadDeadline(time.Now().Add(c.readTimeout))
+		}
+		c.mu.Unlock()
+	}
+	return c.Conn.Read(p)
+}
+
+func (c *deadlineConn) Write(p []byte) (int, error) {
+	if c.writeTimeout > 0 {
+		c.mu.Lock()
+		if !c.explicitWrite {
+			c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+		}
+		c.mu.Unlock()
+	}
+	return c.Conn.Write(p)
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.explicitRead, c.explicitWrite = !t.IsZero(), !t.IsZero()
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.explicitRead = !t.IsZero()
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.explicitWrite = !t.IsZero()
+	return c.Conn.SetWriteDeadline(t)
+}
+
+// suspendReadTimeout lifts (or restores) Config.ReadTimeout on c, for a
+// connection that waits on the server by design, as a Listener's does.
+func (c *Conn) suspendReadTimeout(suspend bool) {
+	if c.deadline == nil {
+		return
+	}
+	c.deadline.mu.Lock()
+	defer c.deadline.mu.Unlock()
+	c.deadline.suspended = suspend
+	if suspend && !c.deadline.explicitRead {
+		c.deadline.Conn.SetReadDeadline(time.Time{})
+	}
+}
@@ -2,15 +2,25 @@ package qail
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"io"
+	"math/big"
 	"net"
+	"os"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-// Buffer pool for reducing allocations (like pgx)
+// Buffer pool for reducing allocations (like pgx). readMessagePooled
+// borrows message bodies from it.
 var bufferPool = sync.Pool{
 	New: func() interface{} {
 		buf := make([]byte, 8192) // 8KB like pgx default
@@ -18,25 +28,133 @@ var bufferPool = sync.Pool{
 	},
 }
 
+// Pools for result set slices handed back by Result.Release.
+var (
+	columnsPool sync.Pool // *[][]byte, one per Row
+	rowsPool    sync.Pool // *[]Row, one per Result
+)
+
 // Driver provides connection pooling and query execution.
+//
+// A Driver is safe for concurrent use: every method takes its own pooled
+// connection for the duration of the call. Tx and Conn are not; see their
+// documentation.
 type Driver struct {
-	host     string
-	port     string
-	user     string
-	database string
-	password string
-	sslMode  string
+	host         string
+	port         string
+	user         string
+	database     string
+	password     string
+	sslMode      string
+	rootCAs      *x509.CertPool // Config.SSLRootCert; nil for the system roots
+	resultFormat int16
+
+	hosts              []hostPort // candidates tried in order by connect
+	lastHost           int        // index of the last host that connected (guarded by mu)
+	targetSessionAttrs string
+
+	readBufferSize  int
+	writeBufferSize int
 	
 	pool     chan *Conn
 	poolSize int
-	mu       sync.Mutex
+	minConns int // idle connections Warmup fills the pool to
+
+	slots        chan struct{} // one token per checked-out connection; nil without MaxOpenConns
+	poolTimeout  time.Duration
+	closed       chan struct{} // closed by Close, waking callers waiting for a slot
+	waitCount    int64         // guarded by mu
+	waitDuration time.Duration // guarded by mu
+
+	replica *Driver // pool over Config.ReadHosts, nil if unset
+
+	statementTimeout time.Duration
+	readTimeout      time.Duration
+	writeTimeout     time.Duration
+	maxResultBytes   int64
+
+	stopKeepalive chan struct{} // closed by Close; nil without keepalive
+
+	validateOnCheckout bool
+	mu                 sync.Mutex
+
+	closing bool          // set by Close; no new connections are handed out (guarded by mu)
+	active  int           // connections checked out by in-flight queries (guarded by mu)
+	drained chan struct{} // closed when active reaches 0 during Close (guarded by mu)
+
+	statements map[string]string // prepared statement name -> SQL (guarded by mu)
+
+	trace  *traceWriter // nil unless Config.WireTrace is set
+	tracer Tracer       // nil unless Config.Tracer is set
+
+	dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	maxStmts  int
+	stmtStats stmtCacheStats
 }
 
 // Conn represents a single PostgreSQL connection with buffered I/O.
+// A Conn is not safe for concurrent use; the Driver hands each one to a
+// single caller at a time.
 type Conn struct {
 	conn   net.Conn
 	reader *bufio.Reader
 	writer *bufio.Writer
+
+	resultFormat int16 // FormatText or FormatBinary for query results
+
+	stmts     map[string]*StatementDescription // Describe cache by statement name
+	stmtUsed  map[string]uint64                // last use of each cached statement, for LRU eviction
+	stmtTick  uint64
+	maxStmts  int             // Config.MaxPreparedStatements; 0 = unbounded
+	stmtStats *stmtCacheStats // shared with the Driver; nil for none
+
+	scratch []byte // reusable body buffer for readMessageFast
+
+	params map[string]string // ParameterStatus values reported by the server
+
+	txStatus atomic.Uint32 // TxStatus from the last ReadyForQuery; read by Tx.Status from any goroutine
+
+	maxResultBytes int64 // Config.MaxResultBytes; 0 = unlimited
+
+	ssl        bool              // the connection was upgraded to TLS
+	serverCert *x509.Certificate // TLS peer certificate, for SCRAM channel binding
+	authMethod string            // how startup authenticated; see ConnInfo
+
+	deadline *deadlineConn // Config.ReadTimeout/WriteTimeout; nil if neither is set
+
+	addr        string // server address, for CancelRequest
+	dial        func(ctx context.Context, network, addr string) (net.Conn, error)
+	pid, secret uint32 // BackendKeyData, for CancelRequest
+
+	// poisoned is set once a read or write has failed or a malformed
+	// message was seen. The stream may then be mid-message, so the next
+	// query would read the tail of this one: putConn closes the
+	// connection instead of pooling it. A Listener's reader and command
+	// sender may both fail at once, hence atomic.
+	poisoned atomic.Bool
+}
+
+// poisonConn marks its Conn poisoned when any read or write fails.
+type poisonConn struct {
+	net.Conn
+	poisoned *atomic.Bool
+}
+
+func (p *poisonConn) Read(b []byte) (int, error) {
+	n, err := p.Conn.Read(b)
+	if err != nil {
+		p.poisoned.Store(true)
+	}
+	return n, err
+}
+
+func (p *poisonConn) Write(b []byte) (int, error) {
+	n, err := p.Conn.Write(b)
+	if err != nil {
+		p.poisoned.Store(true)
+	}
+	return n, err
 }
 
 // Config for creating a Driver.
@@ -47,7 +165,133 @@ type Config struct {
 	Database string
 	Password string
 	PoolSize int
-	SSLMode  string // "disable", "require", "prefer"
+	SSLMode  string // "disable", "require", "prefer", "verify-ca", "verify-full"
+
+	// SSLRootCert is a PEM file of CA certificates that "verify-ca" and
+	// "verify-full" check the server's certificate against; empty means
+	// the system roots. "verify-ca" checks only that the certificate
+	// chains to one of them, ignoring the host name, for servers behind a
+	// load balancer or reached by IP with a shared certificate.
+	// "verify-full" also checks the host name.
+	SSLRootCert string
+
+	// ResultFormat selects the wire format for result columns:
+	// FormatText (default) or FormatBinary.
+	ResultFormat int16
+
+	// WireTrace, when set, receives every byte sent and received on every
+	// connection, in the framed format read by ReadTrace. Replay a trace
+	// with ServeTrace to reproduce a session without the original server.
+	//
+	// A trace holds the authentication exchange as sent: a cleartext
+	// password, an MD5 hash or a SCRAM proof. Treat trace files as
+	// credentials.
+	WireTrace io.Writer
+
+	// Hosts lists several servers (e.g. a primary and its standbys) as
+	// "host" or "host:port"; Port is used when an entry has none. Hosts
+	// are tried in order until one accepts the connection, and later
+	// connections start from the host that last worked. Host may also be
+	// a comma-separated list. Hosts takes precedence over Host.
+	Hosts []string
+
+	// WriteHosts and ReadHosts split traffic between a primary and its
+	// replicas. When ReadHosts is set, FetchAll, FetchOne, FetchResult,
+	// Query and QueryOne send read-only commands (see Qail.IsReadOnly) to
+	// a separate pool over ReadHosts; everything else, including
+	// transactions, uses WriteHosts (or Hosts/Host when WriteHosts is
+	// empty). Use FetchAllOnPrimary to read your own writes.
+	WriteHosts []string
+	ReadHosts  []string
+
+	// TargetSessionAttrs is "any" (default) or "read-write". With
+	// "read-write", hosts reporting transaction_read_only = on are skipped.
+	TargetSessionAttrs string
+
+	// Tracer, when set, is notified around every query a Driver method
+	// runs. See Tracer for what is not traced.
+	Tracer Tracer
+
+	// ReadBufferSize and WriteBufferSize size each connection's buffered
+	// reader and writer, in bytes. Zero means DefaultBufferSize; other
+	// values must be at least MinBufferSize. Larger buffers save syscalls
+	// for wide rows and big pipelined batches.
+	ReadBufferSize  int
+	WriteBufferSize int
+
+	// ValidateOnCheckout pings each pooled connection before handing it
+	// out, replacing ones the server or network dropped while idle. Off
+	// by default: it costs a round trip per query.
+	ValidateOnCheckout bool
+
+	// StatementTimeout, when positive, makes the server cancel any
+	// statement running longer (statement_timeout, millisecond precision).
+	// Such queries fail with an error matching ErrStatementTimeout.
+	StatementTimeout time.Duration
+
+	// ReadTimeout and WriteTimeout, when positive, bound each read from
+	// and write to the server, so a hung server or network fails the
+	// query (with an error matching os.ErrDeadlineExceeded) instead of
+	// blocking it forever. The connection is then discarded. They are a
+	// safety net, not a query timeout: a long query whose rows keep
+	// arriving is not affected. Listener connections ignore ReadTimeout.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+
+	// MaxResultBytes, when positive, caps the DataRow bytes a buffered
+	// query (FetchAll, FetchResult, Query, ...) may return. A larger result
+	// fails with ErrResultTooLarge; the rest of it is read and discarded,
+	// so the connection stays usable. Use CopyTo for unbounded exports.
+	MaxResultBytes int64
+
+	// KeepaliveInterval, when positive, starts a goroutine that pings
+	// every idle pooled connection this often, so firewalls and load
+	// balancers see traffic and dead connections are evicted before a
+	// query picks them up. It stops on Close.
+	KeepaliveInterval time.Duration
+
+	// MinConns is how many idle connections Warmup (and so
+	// NewDriverContext) fills the pool to: default 1, at most PoolSize.
+	// NewDriver alone does not connect.
+	MinConns int
+
+	// MaxOpenConns, when positive, caps the connections checked out at
+	// once (and so the connections open, since a connection is only
+	// dialed when none is idle). Callers beyond the cap wait for one to be
+	// returned, up to PoolTimeout or their context's deadline, and then
+	// fail with ErrPoolTimeout. Zero means no cap.
+	MaxOpenConns int
+
+	// PoolTimeout bounds how long a caller waits for a connection when
+	// MaxOpenConns are checked out. Zero waits until the context, if any,
+	// is done.
+	PoolTimeout time.Duration
+
+	// DialFunc, when set, opens the transport to each server instead of
+	// a TCP dial, for routing through a proxy or testing against an
+	// in-memory server (net.Pipe). addr is "host:port". The SSL upgrade,
+	// startup handshake and cancel requests all run over what it returns.
+	DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// MaxPreparedStatements, when positive, caps the named statements
+	// (see Prepare) kept prepared on each connection. Past the cap, the
+	// least recently used one is closed on the server before the next is
+	// prepared. Zero keeps every statement for the connection's lifetime,
+	// which suits a fixed set of queries; set a cap, e.g. 100, when
+	// statement names are generated per query shape.
+	MaxPreparedStatements int
+}
+
+// Connection buffer sizes; see Config.ReadBufferSize.
+const (
+	DefaultBufferSize = 16384
+	MinBufferSize     = 4096
+)
+
+// hostPort is one candidate server address.
+type hostPort struct {
+	host string
+	port string
 }
 
 // NewDriver creates a new connection pool.
@@ -58,80 +302,553 @@ func NewDriver(cfg Config) (*Driver, error) {
 	if cfg.SSLMode == "" {
 		cfg.SSLMode = "prefer"
 	}
+	if cfg.MinConns <= 0 {
+		cfg.MinConns = 1
+	}
+	if cfg.MinConns > cfg.PoolSize {
+		cfg.MinConns = cfg.PoolSize
+	}
+	if cfg.ReadBufferSize == 0 {
+		cfg.ReadBufferSize = DefaultBufferSize
+	}
+	if cfg.WriteBufferSize == 0 {
+		cfg.WriteBufferSize = DefaultBufferSize
+	}
+	if cfg.ReadBufferSize < MinBufferSize || cfg.WriteBufferSize < MinBufferSize {
+		return nil, fmt.Errorf("buffer sizes must be at least %d bytes", MinBufferSize)
+	}
+	switch cfg.SSLMode {
+	case "disable", "require", "prefer", "verify-ca", "verify-full":
+	default:
+		return nil, fmt.Errorf("unsupported SSLMode %q", cfg.SSLMode)
+	}
+	var rootCAs *x509.CertPool
+	if cfg.SSLRootCert != "" {
+		pem, err := os.ReadFile(cfg.SSLRootCert)
+		if err != nil {
+			return nil, fmt.Errorf("SSLRootCert: %w", err)
+		}
+		rootCAs = x509.NewCertPool()
+		if !rootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("SSLRootCert: no certificates in %s", cfg.SSLRootCert)
+		}
+	}
+	switch cfg.TargetSessionAttrs {
+	case "", "any", "read-write":
+	default:
+		return nil, fmt.Errorf("unsupported TargetSessionAttrs %q", cfg.TargetSessionAttrs)
+	}
+	var replica *Driver
+	if len(cfg.ReadHosts) > 0 {
+		rcfg := cfg
+		rcfg.Hosts, rcfg.ReadHosts, rcfg.WriteHosts = cfg.ReadHosts, nil, nil
+		rcfg.TargetSessionAttrs = ""
+		var err error
+		if replica, err = NewDriver(rcfg); err != nil {
+			return nil, err
+		}
+	}
+	hostList := cfg.Hosts
+	if len(cfg.WriteHosts) > 0 {
+		hostList = cfg.WriteHosts
+	}
+	if len(hostList) == 0 {
+		hostList = strings.Split(cfg.Host, ",")
+	}
+	var hosts []hostPort
+	for _, h := range hostList {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+		hp := hostPort{host: h, port: cfg.Port}
+		if host, port, err := net.SplitHostPort(h); err == nil {
+			hp = hostPort{host: host, port: port}
+		}
+		hosts = append(hosts, hp)
+	}
+	if len(hosts) == 0 {
+		hosts = []hostPort{{host: cfg.Host, port: cfg.Port}}
+	}
 	
 	d := &Driver{
-		host:     cfg.Host,
-		port:     cfg.Port,
-		user:     cfg.User,
-		database: cfg.Database,
-		password: cfg.Password,
-		sslMode:  cfg.SSLMode,
-		pool:     make(chan *Conn, cfg.PoolSize),
-		poolSize: cfg.PoolSize,
+		host:               cfg.Host,
+		port:               cfg.Port,
+		user:               cfg.User,
+		database:           cfg.Database,
+		password:           cfg.Password,
+		sslMode:            cfg.SSLMode,
+		rootCAs:            rootCAs,
+		resultFormat:       cfg.ResultFormat,
+		hosts:              hosts,
+		targetSessionAttrs: cfg.TargetSessionAttrs,
+		pool:               make(chan *Conn, cfg.PoolSize),
+		poolSize:           cfg.PoolSize,
+		tracer:             cfg.Tracer,
+		replica:            replica,
+		minConns:           cfg.MinConns,
+		statementTimeout:   cfg.StatementTimeout,
+		readTimeout:        cfg.ReadTimeout,
+		writeTimeout:       cfg.WriteTimeout,
+		maxResultBytes:     cfg.MaxResultBytes,
+		validateOnCheckout: cfg.ValidateOnCheckout,
+		readBufferSize:     cfg.ReadBufferSize,
+		writeBufferSize:    cfg.WriteBufferSize,
+		poolTimeout:        cfg.PoolTimeout,
+		closed:             make(chan struct{}),
+		dialFunc:           cfg.DialFunc,
+		maxStmts:           cfg.MaxPreparedStatements,
+	}
+	if cfg.MaxOpenConns > 0 {
+		d.slots = make(chan struct{}, cfg.MaxOpenConns)
+	}
+	if cfg.WireTrace != nil {
+		d.trace = &traceWriter{w: cfg.WireTrace}
+	}
+	if cfg.KeepaliveInterval > 0 {
+		d.stopKeepalive = make(chan struct{})
+		go d.keepalive(cfg.KeepaliveInterval)
 	}
 	
 	return d, nil
 }
 
+// NewDriverContext creates a connection pool and opens Config.MinConns
+// connections up front, so an unreachable server or failed authentication
+// is reported here instead of on the first query. ctx bounds the whole
+// warm-up. NewDriver remains the lazy alternative.
+func NewDriverContext(ctx context.Context, cfg Config) (*Driver, error) {
+	d, err := NewDriver(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := d.Warmup(ctx); err != nil {
+		d.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Warmup dials connections until the pool (and the replica pool, if any)
+// holds Config.MinConns idle ones, so the first queries after a cold
+// start skip the handshake. It
+// can be called again later, e.g. after a failover emptied the pool.
+func (d *Driver) Warmup(ctx context.Context) error {
+	if d.replica != nil {
+		if err := d.replica.Warmup(ctx); err != nil {
+			return err
+		}
+	}
+	for len(d.pool) < d.minConns {
+		// Counted as checked out while dialing, so Close waits for it.
+		if err := d.reserve(ctx); err != nil {
+			return err
+		}
+
+		c, err := d.connect(ctx)
+		if err != nil {
+			d.release()
+			return err
+		}
+		d.putConn(c)
+	}
+	return nil
+}
+
 // getConn gets a connection from pool or creates new one.
 func (d *Driver) getConn() (*Conn, error) {
+	return d.getConnContext(context.Background())
+}
+
+// getConnContext is getConn with ctx bounding any wait for a slot under
+// Config.MaxOpenConns and any dial.
+func (d *Driver) getConnContext(ctx context.Context) (*Conn, error) {
+	pt, traced := d.tracer.(PoolTracer)
+	var start time.Time
+	if traced {
+		start = time.Now()
+	}
+
+	if err := d.reserve(ctx); err != nil {
+		if traced {
+			pt.ConnAcquired(time.Since(start), err)
+		}
+		return nil, err
+	}
+
+	if traced {
+		c, err := d.acquire(ctx)
+		pt.ConnAcquired(time.Since(start), err)
+		return c, err
+	}
+	return d.acquire(ctx)
+}
+
+// reserve counts the caller in active, first waiting for a free slot when
+// Config.MaxOpenConns is set. Every successful reserve is paired with one
+// release.
+func (d *Driver) reserve(ctx context.Context) error {
+	if d.slots != nil {
+		select {
+		case d.slots <- struct{}{}:
+		default:
+			if err := d.waitSlot(ctx); err != nil {
+				return err
+			}
+		}
+	}
+	return d.enter()
+}
+
+// enter counts a caller holding a slot (if slots are in use) in active,
+// giving the slot back if the driver is closing.
+func (d *Driver) enter() error {
+	d.mu.Lock()
+	if d.closing {
+		d.mu.Unlock()
+		if d.slots != nil {
+			<-d.slots
+		}
+		return ErrDriverClosed
+	}
+	d.active++
+	d.mu.Unlock()
+	return nil
+}
+
+// waitSlot blocks until a slot frees up, the pool timeout or ctx expires,
+// or the driver is closed, and records the wait in Stats.
+func (d *Driver) waitSlot(ctx context.Context) error {
+	start := time.Now()
+	var timeout <-chan time.Time
+	if d.poolTimeout > 0 {
+		timer := time.NewTimer(d.poolTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	var err error
 	select {
-	case c := <-d.pool:
-		return c, nil
-	default:
-		return d.connect()
+	case d.slots <- struct{}{}:
+	case <-timeout:
+		err = fmt.Errorf("%w after %v", ErrPoolTimeout, d.poolTimeout)
+	case <-ctx.Done():
+		err = fmt.Errorf("%w: %w", ErrPoolTimeout, ctx.Err())
+	case <-d.closed:
+		err = ErrDriverClosed
+	}
+
+	d.mu.Lock()
+	d.waitCount++
+	d.waitDuration += time.Since(start)
+	d.mu.Unlock()
+	return err
+}
+
+// acquire takes an idle connection from the pool or dials a new one.
+// With ValidateOnCheckout, pooled connections that fail a ping are closed
+// and the next one (or a fresh dial) is tried instead.
+func (d *Driver) acquire(ctx context.Context) (*Conn, error) {
+	for {
+		select {
+		case c := <-d.pool:
+			if d.validateOnCheckout {
+				if err := c.ping(); err != nil {
+					c.Close()
+					continue
+				}
+			}
+			return c, nil
+		default:
+			return d.dial(ctx)
+		}
+	}
+}
+
+// dial opens a new connection for a caller already counted in active.
+func (d *Driver) dial(ctx context.Context) (*Conn, error) {
+	c, err := d.connect(ctx)
+	if err != nil {
+		d.release()
+	}
+	return c, err
+}
+
+// Ping checks that the server is reachable by round-tripping an empty
+// Sync on a pooled connection. A connection that fails is closed rather
+// than returned to the pool.
+func (d *Driver) Ping() error {
+	c, err := d.getConn()
+	if err != nil {
+		return err
+	}
+	if err := c.ping(); err != nil {
+		c.Close()
+		d.release()
+		return err
+	}
+	d.putConn(c)
+	return nil
+}
+
+// keepalive pings idle pooled connections every interval until Close.
+func (d *Driver) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stopKeepalive:
+			return
+		case <-ticker.C:
+			d.pingIdle()
+		}
+	}
+}
+
+// pingIdle pings the connections idle in the pool right now. Each is
+// checked out like a query's connection while pinged, so getConn never
+// sees one mid-ping and Close waits for it; dead ones are closed.
+func (d *Driver) pingIdle() {
+	for n := len(d.pool); n > 0; n-- {
+		if d.slots != nil {
+			select {
+			case d.slots <- struct{}{}:
+			default:
+				return // every slot is in use, so nothing is idle
+			}
+		}
+		if err := d.enter(); err != nil {
+			return
+		}
+
+		var c *Conn
+		select {
+		case c = <-d.pool:
+		default:
+			d.release() // taken by queries meanwhile
+			return
+		}
+		if err := c.ping(); err != nil {
+			c.Close()
+			d.release()
+			continue
+		}
+		d.putConn(c)
 	}
 }
 
+// pingTimeout bounds a ping, so a peer that vanished without closing the
+// TCP connection is detected instead of blocking the caller.
+const pingTimeout = 5 * time.Second
+
+// ping sends Sync and waits for ReadyForQuery, the cheapest round trip
+// the protocol offers.
+func (c *Conn) ping() error {
+	c.conn.SetDeadline(time.Now().Add(pingTimeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if _, err := c.conn.Write([]byte{'S', 0, 0, 0, 4}); err != nil {
+		return err
+	}
+	for {
+		msgType, _, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		if msgType == 'Z' {
+			return nil
+		}
+	}
+}
+
+// release marks one checked-out connection as returned and wakes Close
+// once the last one is back.
+func (d *Driver) release() {
+	if d.slots != nil {
+		<-d.slots
+	}
+	d.mu.Lock()
+	d.active--
+	if d.closing && d.active == 0 && d.drained != nil {
+		close(d.drained)
+		d.drained = nil
+	}
+	d.mu.Unlock()
+}
+
 // putConn returns connection to pool.
+// During shutdown the connection is closed instead.
 func (d *Driver) putConn(c *Conn) {
-	select {
-	case d.pool <- c:
-	default:
+	d.mu.Lock()
+	closing := d.closing
+	d.mu.Unlock()
+
+	// A connection left inside a transaction (e.g. a PooledConn released
+	// after BEGIN, or an error path that skipped Rollback) would run the
+	// next caller's queries in it: roll it back, or discard it if that
+	// fails.
+	if !closing && !c.poisoned.Load() && c.TxStatus() != TxIdle {
+		if err := c.simpleExec("ROLLBACK"); err != nil || c.TxStatus() != TxIdle {
+			c.poisoned.Store(true)
+		}
+	}
+	if closing || c.poisoned.Load() {
 		c.Close()
+	} else {
+		select {
+		case d.pool <- c:
+		default:
+			c.Close()
+		}
+	}
+	d.release()
+}
+
+// connect creates a new connection, trying each configured host in turn
+// starting with the last one that worked.
+func (d *Driver) connect(ctx context.Context) (*Conn, error) {
+	d.mu.Lock()
+	start := d.lastHost
+	d.mu.Unlock()
+
+	var lastErr error
+	for i := range d.hosts {
+		idx := (start + i) % len(d.hosts)
+		host, port := d.hosts[idx].host, d.hosts[idx].port
+		c, err := d.connectHost(ctx, host, port)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			lastErr = fmt.Errorf("%s: %w", net.JoinHostPort(host, port), err)
+			continue
+		}
+		d.mu.Lock()
+		d.lastHost = idx
+		d.mu.Unlock()
+		return c, nil
+	}
+	if len(d.hosts) > 1 {
+		return nil, fmt.Errorf("all %d hosts failed, last error: %w", len(d.hosts), lastErr)
 	}
+	return nil, lastErr
 }
 
-// connect creates a new connection.
-func (d *Driver) connect() (*Conn, error) {
-	addr := net.JoinHostPort(d.host, d.port)
-	conn, err := net.Dial("tcp", addr)
+// connectHost creates a new connection to a single host. ctx bounds the
+// dial and the handshake.
+func (d *Driver) connectHost(ctx context.Context, host, port string) (c *Conn, err error) {
+	addr := net.JoinHostPort(host, port)
+	dial := d.dialFunc
+	if dial == nil {
+		var dialer net.Dialer
+		dial = dialer.DialContext
+	}
+	conn, err := dial(ctx, "tcp", addr)
 	if err != nil {
 		return nil, err
 	}
+	// Wrap the raw connection, so TLS and the explicit deadlines below
+	// all go through the wrapper.
+	var deadline *deadlineConn
+	if d.readTimeout > 0 || d.writeTimeout > 0 {
+		deadline = &deadlineConn{Conn: conn, readTimeout: d.readTimeout, writeTimeout: d.writeTimeout}
+		conn = deadline
+	}
+
+	// Interrupt handshake I/O if ctx ends first.
+	raw := conn
+	stop := context.AfterFunc(ctx, func() { raw.SetDeadline(time.Unix(1, 0)) })
+	defer func() {
+		if !stop() {
+			if err == nil {
+				c.Close()
+			}
+			c, err = nil, ctx.Err()
+		}
+	}()
 	
 	// Try SSL if enabled
-	if d.sslMode == "require" || d.sslMode == "prefer" {
-		sslConn, err := d.upgradeToSSL(conn)
+	usedSSL := false
+	var serverCert *x509.Certificate
+	if d.sslMode != "disable" {
+		sslConn, err := d.upgradeToSSL(conn, host)
 		if err != nil {
-			if d.sslMode == "require" {
+			if d.sslMode != "prefer" {
 				conn.Close()
-				return nil, errors.New("SSL required but failed: " + err.Error())
+				return nil, fmt.Errorf("SSL required but failed: %w", err)
 			}
 			// prefer mode - continue without SSL
 		} else {
 			conn = sslConn
+			usedSSL = true
+			if certs := sslConn.ConnectionState().PeerCertificates; len(certs) > 0 {
+				serverCert = certs[0]
+			}
 		}
 	}
+
+	if it, ok := d.tracer.(IOTracer); ok {
+		conn = &countingConn{Conn: conn, io: it}
+	}
+	if d.trace != nil {
+		conn = &tracedConn{Conn: conn, trace: d.trace, id: d.trace.newConnID()}
+	}
 	
-	// Create buffered I/O (like pgx - 16KB buffers)
-	c := &Conn{
-		conn:   conn,
-		reader: bufio.NewReaderSize(conn, 16384), // 16KB read buffer
-		writer: bufio.NewWriterSize(conn, 16384), // 16KB write buffer
+	// Create buffered I/O (like pgx - 16KB buffers by default)
+	c = &Conn{
+		resultFormat: d.resultFormat,
+
+		maxResultBytes: d.maxResultBytes,
+		ssl:            usedSSL,
+		serverCert:     serverCert,
+		deadline:       deadline,
+		addr:           addr,
+		dial:           dial,
+		maxStmts:       d.maxStmts,
+		stmtStats:      &d.stmtStats,
 	}
+	conn = &poisonConn{Conn: conn, poisoned: &c.poisoned}
+	c.conn = conn
+	c.reader = bufio.NewReaderSize(conn, d.readBufferSize)
+	c.writer = bufio.NewWriterSize(conn, d.writeBufferSize)
 	
 	// Startup handshake
-	if err := c.startup(d.user, d.database, d.password); err != nil {
+	if err := c.startup(d.user, d.database, d.password, d.statementTimeout); err != nil {
 		conn.Close()
 		return nil, err
 	}
+
+	if d.targetSessionAttrs == "read-write" {
+		if err := c.checkWritable(); err != nil {
+			c.Close()
+			return nil, err
+		}
+	}
 	
 	return c, nil
 }
 
+// checkWritable fails if the server is a read-only standby.
+func (c *Conn) checkWritable() error {
+	if err := c.sendQuery("SHOW transaction_read_only"); err != nil {
+		return err
+	}
+	results, err := c.readResults()
+	if err != nil {
+		return err
+	}
+	if len(results) == 0 || len(results[0].Rows) == 0 {
+		return fmt.Errorf("SHOW transaction_read_only: %w", ErrNoRows)
+	}
+	if results[0].Rows[0].GetString(0) == "on" {
+		return ErrReadOnlyHost
+	}
+	return nil
+}
+
 // upgradeToSSL attempts SSL/TLS upgrade.
-func (d *Driver) upgradeToSSL(conn net.Conn) (net.Conn, error) {
+func (d *Driver) upgradeToSSL(conn net.Conn, host string) (*tls.Conn, error) {
 	// Send SSLRequest message
 	// Message: 8 bytes - length(8) + SSL code (80877103)
 	sslRequest := []byte{0, 0, 0, 8, 4, 210, 22, 47} // len=8, code=80877103
@@ -146,13 +863,24 @@ func (d *Driver) upgradeToSSL(conn net.Conn) (net.Conn, error) {
 	}
 	
 	if response[0] != 'S' {
-		return nil, errors.New("server does not support SSL")
+		return nil, ErrSSLNotSupported
 	}
 	
 	// Upgrade to TLS
 	tlsConfig := &tls.Config{
-		InsecureSkipVerify: true, // For now, skip certificate verification
-		ServerName:         d.host,
+		InsecureSkipVerify: true, // require/prefer encrypt without verifying
+		ServerName:         host,
+	}
+	switch d.sslMode {
+	case "verify-full":
+		tlsConfig.InsecureSkipVerify = false
+		tlsConfig.RootCAs = d.rootCAs
+	case "verify-ca":
+		// crypto/tls cannot verify the chain without the host name, so
+		// skip its verification and check the chain here instead.
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, d.rootCAs)
+		}
 	}
 	
 	tlsConn := tls.Client(conn, tlsConfig)
@@ -163,10 +891,19 @@ func (d *Driver) upgradeToSSL(conn net.Conn) (net.Conn, error) {
 	return tlsConn, nil
 }
 
-// startup performs PostgreSQL startup handshake.
-func (c *Conn) startup(user, database, password string) error {
-	// Build startup message (protocol 3.0)
-	params := "user\x00" + user + "\x00database\x00" + database + "\x00\x00"
+// startup performs PostgreSQL startup handshake. A positive
+// statementTimeout is applied to the session as statement_timeout.
+func (c *Conn) startup(user, database, password string, statementTimeout time.Duration) error {
+	// Build startup message (protocol 3.0). client_encoding=UTF8 makes the
+	// server transcode text from the database encoding (LATIN1, WIN1252,
+	// ...), so string getters can treat every text value as UTF-8.
+	params := "user\x00" + user + "\x00database\x00" + database +
+		"\x00client_encoding\x00UTF8\x00"
+	if statementTimeout > 0 {
+		// Run-time parameters may be set in the startup packet directly.
+		params += "statement_timeout\x00" + strconv.FormatInt(statementTimeout.Milliseconds(), 10) + "\x00"
+	}
+	params += "\x00"
 	length := 4 + 4 + len(params)
 	
 	buf := make([]byte, length)
@@ -190,30 +927,41 @@ func (c *Conn) startup(user, database, password string) error {
 			authType := binary.BigEndian.Uint32(data[:4])
 			switch authType {
 			case 0: // AuthenticationOk
+				if c.authMethod == "" {
+					c.authMethod = "trust"
+				}
 				continue
 			case 3: // CleartextPassword
+				c.authMethod = "password"
 				if err := c.sendPassword(password); err != nil {
 					return err
 				}
 			case 5: // MD5Password
+				c.authMethod = "md5"
 				// MD5 auth: md5(md5(password + user) + salt)
 				salt := data[4:8]
 				if err := c.sendMD5Password(user, password, salt); err != nil {
 					return err
 				}
-			case 10: // SASL (SCRAM-SHA-256)
-				return errors.New("SCRAM-SHA-256 not yet implemented - use md5 or trust")
+			case 10: // SASL (SCRAM-SHA-256, -PLUS over TLS)
+				if err := c.authSCRAM(password, data[4:]); err != nil {
+					return err
+				}
 			default:
-				return errors.New("unsupported auth method")
+				return fmt.Errorf("%w: method %d", ErrUnsupportedAuth, binary.BigEndian.Uint32(data[0:4]))
+			}
+		case 'K': // BackendKeyData: process ID and secret for CancelRequest
+			if len(data) >= 8 {
+				c.pid = binary.BigEndian.Uint32(data[0:4])
+				c.secret = binary.BigEndian.Uint32(data[4:8])
 			}
-		case 'K': // BackendKeyData
-			continue
-		case 'S': // ParameterStatus
-			continue
 		case 'Z': // ReadyForQuery
+			if enc := c.params["client_encoding"]; enc != "" && enc != "UTF8" {
+				return fmt.Errorf("server reports client_encoding %q, expected UTF8", enc)
+			}
 			return nil
 		case 'E': // ErrorResponse
-			return errors.New("auth error: " + string(data))
+			return serverError("auth error", data)
 		}
 	}
 }
@@ -232,19 +980,77 @@ func (c *Conn) sendPassword(password string) error {
 func (c *Conn) sendMD5Password(user, password string, salt []byte) error {
 	// MD5 implementation would go here
 	// For now, fall back to error
-	return errors.New("MD5 password not yet implemented")
+	return fmt.Errorf("%w: MD5 password not yet implemented", ErrUnsupportedAuth)
+}
+
+// maxScratchRetain is the largest scratch buffer a Conn keeps between
+// readMessageFast calls. Bigger messages get a one-off allocation so a
+// single huge row doesn't pin megabytes per pooled connection.
+const maxScratchRetain = 1 << 20
+
+// readHeader reads a message header and returns the type and body length.
+// ParameterStatus messages, which the server may send at any time (e.g.
+// after a SET), are consumed here and recorded on the connection, as is
+// the transaction status carried by ReadyForQuery.
+func (c *Conn) readHeader() (byte, int, error) {
+	for {
+		var header [5]byte
+		if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+			return 0, 0, connError(err)
+		}
+		length := int(binary.BigEndian.Uint32(header[1:5]))
+		if length < 4 {
+			c.poisoned.Store(true)
+			return 0, 0, fmt.Errorf("invalid message length %d for type %q", length, header[0])
+		}
+		if header[0] == 'Z' && length == 5 {
+			// Peek, so the caller still reads the body as usual.
+			if b, err := c.reader.Peek(1); err == nil {
+				c.txStatus.Store(uint32(b[0]))
+			}
+		}
+		if header[0] != 'S' {
+			return header[0], length - 4, nil
+		}
+		if err := c.readParameterStatus(length - 4); err != nil {
+			return 0, 0, err
+		}
+	}
+}
+
+// readParameterStatus reads a ParameterStatus body: name and value as
+// null-terminated strings.
+func (c *Conn) readParameterStatus(length int) error {
+	data := make([]byte, length)
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		return err
+	}
+	name := cstring(data)
+	if len(name) >= len(data) {
+		return errMalformed("ParameterStatus")
+	}
+	if c.params == nil {
+		c.params = make(map[string]string)
+	}
+	c.params[name] = cstring(data[len(name)+1:])
+	return nil
+}
+
+// Parameter returns a run-time parameter reported by the server, such as
+// "server_version", "server_encoding", "client_encoding" or "TimeZone".
+// Values are updated when the server reports a change (e.g. after SET).
+func (c *Conn) Parameter(name string) string {
+	return c.params[name]
 }
 
 func (c *Conn) readMessage() (byte, []byte, error) {
-	header := make([]byte, 5)
-	if _, err := io.ReadFull(c.reader, header); err != nil {
+	msgType, length, err := c.readHeader()
+	if err != nil {
 		return 0, nil, err
 	}
 	
-	msgType := header[0]
-	length := binary.BigEndian.Uint32(header[1:5]) - 4
-	
 	if length > 0 {
+		// Freshly allocated: the data may be retained by returned Rows.
 		data := make([]byte, length)
 		if _, err := io.ReadFull(c.reader, data); err != nil {
 			return 0, nil, err
@@ -255,25 +1061,58 @@ func (c *Conn) readMessage() (byte, []byte, error) {
 	return msgType, nil, nil
 }
 
-// readMessageFast reads a message, reusing the provided buffer if possible.
+// readMessagePooled reads a message whose body fits an 8KB bufferPool
+// buffer into a borrowed one, returned as buf; larger bodies are freshly
+// allocated and buf is nil. The data is only valid until the caller passes
+// buf to putBuffer, so anything that must outlive it has to be copied.
+func (c *Conn) readMessagePooled() (msgType byte, data []byte, buf *[]byte, err error) {
+	msgType, length, err := c.readHeader()
+	if err != nil || length == 0 {
+		return msgType, nil, nil, err
+	}
+
+	buf = bufferPool.Get().(*[]byte)
+	if length <= cap(*buf) {
+		data = (*buf)[:length]
+	} else {
+		bufferPool.Put(buf)
+		buf = nil
+		data = make([]byte, length)
+	}
+	if _, err := io.ReadFull(c.reader, data); err != nil {
+		putBuffer(buf)
+		return 0, nil, nil, err
+	}
+	return msgType, data, buf, nil
+}
+
+// putBuffer returns a buffer from readMessagePooled to bufferPool.
+func putBuffer(buf *[]byte) {
+	if buf != nil {
+		bufferPool.Put(buf)
+	}
+}
+
+// readMessageFast reads a message into the connection's scratch buffer,
+// growing it when a message doesn't fit.
 // Returns: msgType, data slice, error
-// The returned data is ONLY VALID until the next call!
-func (c *Conn) readMessageFast(buf []byte) (byte, []byte, error) {
-	// Read header: 1 byte type + 4 bytes length
-	var header [5]byte
-	if _, err := io.ReadFull(c.reader, header[:]); err != nil {
+// The returned data is ONLY VALID until the next call! Copy anything that
+// must outlive it.
+func (c *Conn) readMessageFast() (byte, []byte, error) {
+	msgType, length, err := c.readHeader()
+	if err != nil {
 		return 0, nil, err
 	}
 	
-	msgType := header[0]
-	length := int(binary.BigEndian.Uint32(header[1:])) - 4
-	
 	if length > 0 {
-		// Reuse buffer if possible
-		if cap(buf) >= length {
-			buf = buf[:length]
+		var buf []byte
+		if cap(c.scratch) >= length {
+			buf = c.scratch[:length]
 		} else {
 			buf = make([]byte, length)
+			if length <= maxScratchRetain {
+				c.scratch = buf
+			}
 		}
 		if _, err := io.ReadFull(c.reader, buf); err != nil {
 			return 0, nil, err
@@ -284,44 +1123,163 @@ func (c *Conn) readMessageFast(buf []byte) (byte, []byte, error) {
 	return msgType, nil, nil
 }
 
-// FetchAll executes a query and returns all rows.
+// FetchAll executes a query and returns all rows. Read-only commands go
+// to the replica pool when Config.ReadHosts is set.
 func (d *Driver) FetchAll(cmd *Qail) ([]Row, error) {
-	c, err := d.getConn()
+	return d.FetchAllContext(context.Background(), cmd)
+}
+
+// FetchAllContext is FetchAll that stops waiting when ctx ends: the server
+// is sent a CancelRequest, ctx.Err() is returned, and the connection is
+// closed rather than returned to the pool.
+func (d *Driver) FetchAllContext(ctx context.Context, cmd *Qail) ([]Row, error) {
+	if d.replica != nil && cmd.IsReadOnly() {
+		return d.replica.FetchAllContext(ctx, cmd)
+	}
+	return d.fetchAllOnPrimary(ctx, cmd)
+}
+
+// FetchAllOnPrimary is FetchAll without replica routing: the query always
+// runs on the primary pool, so it sees the caller's own committed writes.
+func (d *Driver) FetchAllOnPrimary(cmd *Qail) ([]Row, error) {
+	return d.fetchAllOnPrimary(context.Background(), cmd)
+}
+
+func (d *Driver) fetchAllOnPrimary(ctx context.Context, cmd *Qail) (rows []Row, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("FetchAll", cmd.SQL())
+		defer func() { d.traceEnd(q, len(rows), err) }()
+	}
+
+	c, err := d.getConnContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	defer d.putConn(c)
 
-	bytes := cmd.Encode()
-	if bytes == nil {
-		return nil, fmt.Errorf("failed to encode command")
+	err = c.withContext(ctx, func() (err error) {
+		rows, err = c.fetchAll(cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
 	}
+	return rows, nil
+}
 
-	if _, err := c.conn.Write(bytes); err != nil {
-		return nil, fmt.Errorf("write failed: %w", err)
+// FetchOne executes a query and returns its first row, or ErrNoRows if
+// the result is empty. Any further rows are read and discarded, so the
+// connection is left ready for reuse.
+func (d *Driver) FetchOne(cmd *Qail) (Row, error) {
+	return firstRow(d.FetchAll(cmd))
+}
+
+// firstRow returns the first of rows, or ErrNoRows if there are none.
+func firstRow(rows []Row, err error) (Row, error) {
+	if err != nil {
+		return Row{}, err
+	}
+	if len(rows) == 0 {
+		return Row{}, ErrNoRows
 	}
+	return rows[0], nil
+}
 
-	return c.readRows()
+// FetchResult executes a query and returns its rows together with the
+// column metadata, which is available even when no rows match.
+func (d *Driver) FetchResult(cmd *Qail) (res *Result, err error) {
+	if d.replica != nil && cmd.IsReadOnly() {
+		return d.replica.FetchResult(cmd)
+	}
+	if d.tracer != nil {
+		q := d.traceStart("FetchResult", cmd.SQL())
+		defer func() {
+			n := 0
+			if res != nil {
+				n = len(res.Rows)
+			}
+			d.traceEnd(q, n, err)
+		}()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	if err := c.sendCmd(cmd, true); err != nil {
+		return nil, err
+	}
+	return c.readResult(nil)
+}
+
+// FetchAllWithMeta executes a query and returns the result's column names
+// along with its rows. The names are known even when no rows match, which
+// generic tooling such as CSV export needs for its header.
+func (d *Driver) FetchAllWithMeta(cmd *Qail) (columns []string, rows []Row, err error) {
+	res, err := d.FetchResult(cmd)
+	if err != nil {
+		return nil, nil, err
+	}
+	return res.Columns(), res.Rows, nil
 }
 
 // Execute executes a command that doesn't return rows (INSERT/UPDATE/DELETE).
 func (d *Driver) Execute(cmd *Qail) error {
-	c, err := d.getConn()
+	return d.ExecuteContext(context.Background(), cmd)
+}
+
+// ExecuteContext is Execute that stops waiting when ctx ends, as
+// FetchAllContext does. The command may still have taken effect.
+func (d *Driver) ExecuteContext(ctx context.Context, cmd *Qail) (err error) {
+	if d.tracer != nil {
+		q := d.traceStart("Execute", cmd.SQL())
+		defer func() { d.traceEnd(q, 0, err) }()
+	}
+
+	c, err := d.getConnContext(ctx)
 	if err != nil {
 		return err
 	}
 	defer d.putConn(c)
 
+	return c.withContext(ctx, func() error { return c.execute(cmd) })
+}
+
+// sendCmd encodes cmd and writes it, requesting the connection's result
+// format when withRows is set.
+func (c *Conn) sendCmd(cmd *Qail, withRows bool) error {
 	bytes := cmd.Encode()
-	if bytes == nil {
-		return fmt.Errorf("failed to encode command")
+	if len(bytes) == 0 {
+		return encodeError(cmd)
+	}
+	if withRows && c.resultFormat != FormatText {
+		bytes = setResultFormat(bytes, c.resultFormat)
+	}
+
+	if _, err := c.conn.Write(bytes); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// fetchAll executes a query on this connection and returns all rows.
+func (c *Conn) fetchAll(cmd *Qail) ([]Row, error) {
+	if err := c.sendCmd(cmd, true); err != nil {
+		return nil, err
 	}
+	return c.readRows()
+}
 
-	if _, err := c.conn.Write(bytes); err != nil {
-		return fmt.Errorf("write failed: %w", err)
+// execute runs a command that returns no rows on this connection.
+func (c *Conn) execute(cmd *Qail) error {
+	if err := c.sendCmd(cmd, false); err != nil {
+		return err
 	}
 
 	// Read until ReadyForQuery
+	var queryErr error
 	for {
 		msgType, data, err := c.readMessage()
 		if err != nil {
@@ -329,36 +1287,78 @@ func (d *Driver) Execute(cmd *Qail) error {
 		}
 		switch msgType {
 		case 'Z':
-			return nil
+			return queryErr
 		case 'E':
-			return errors.New("query error: " + string(data))
+			// The server still sends 'Z'; keep reading so the connection stays in sync
+			queryErr = serverError("query error", data)
 		}
 	}
 }
 
 // BatchExecute executes multiple commands in single round-trip.
-func (d *Driver) BatchExecute(cmds []*Qail) (int, error) {
-	c, err := d.getConn()
-	if err != nil {
-		return 0, err
+func (d *Driver) BatchExecute(cmds []*Qail) (completed int, err error) {
+	return d.BatchExecuteContext(context.Background(), cmds)
+}
+
+// BatchExecuteContext is BatchExecute that stops waiting when ctx ends:
+// the server is sent a CancelRequest, and the count completed so far is
+// returned with ctx.Err(). The connection is then closed rather than
+// returned to the pool, since the rest of the batch's responses are
+// still in flight. ctx also bounds the wait for a pooled connection.
+func (d *Driver) BatchExecuteContext(ctx context.Context, cmds []*Qail) (completed int, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("BatchExecute", batchSQL(cmds))
+		defer func() { d.traceEnd(q, completed, err) }()
 	}
-	defer d.putConn(c)
-	
+
 	// Encode all commands in ONE CGO call
 	wireBytes := EncodeBatch(cmds)
-	if wireBytes == nil {
-		return 0, errors.New("failed to encode batch")
+	if len(wireBytes) == 0 {
+		for _, cmd := range cmds {
+			if cmd.err != nil {
+				return 0, cmd.err
+			}
+			if err := checkParamCount(cmd); err != nil {
+				return 0, err
+			}
+		}
+		return 0, fmt.Errorf("%w: batch", ErrEncode)
 	}
-	
-	// Send entire batch
-	if _, err := c.conn.Write(wireBytes); err != nil {
+
+	c, err := d.getConnContext(ctx)
+	if err != nil {
 		return 0, err
 	}
-	
+	return d.runBatch(ctx, c, func() error {
+		_, err := c.conn.Write(wireBytes)
+		return err
+	})
+}
+
+// runBatch sends a pipelined batch with send and counts its completed
+// commands until ReadyForQuery, returning c to the pool, or closing it if
+// ctx ended first (see BatchExecuteContext).
+func (d *Driver) runBatch(ctx context.Context, c *Conn, send func() error) (completed int, err error) {
+	stop := c.watchCancel(ctx)
+	defer func() {
+		if !stop() {
+			c.Close()
+			d.release()
+			err = ctx.Err()
+			return
+		}
+		d.putConn(c)
+	}()
+
+	if err := send(); err != nil {
+		return 0, err
+	}
+
 	// Count completed commands
-	completed := 0
+	var batchErr error
 	for {
-		msgType, data, err := c.readMessage()
+		// data aliases the connection's scratch buffer; it is not retained
+		msgType, data, err := c.readMessageFast()
 		if err != nil {
 			return completed, err
 		}
@@ -366,57 +1366,71 @@ func (d *Driver) BatchExecute(cmds []*Qail) (int, error) {
 		case 'C', 'n': // CommandComplete or NoData
 			completed++
 		case 'Z':
-			return completed, nil
+			return completed, batchErr
 		case 'E':
-			return completed, errors.New("batch error: " + string(data))
+			// The server skips to Sync and still sends 'Z'; keep reading so
+			// the connection stays in sync
+			if batchErr == nil {
+				batchErr = serverError("batch error", data)
+			}
 		}
 	}
 }
 
 // BatchExecuteFast executes batch of SELECT queries with minimal CGO overhead.
 // Uses ONE CGO call for the entire batch encoding.
-func (d *Driver) BatchExecuteFast(table, columns string, limits []int64) (int, error) {
-	c, err := d.getConn()
-	if err != nil {
-		return 0, err
+func (d *Driver) BatchExecuteFast(table, columns string, limits []int64) (completed int, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("BatchExecuteFast", "SELECT "+columns+" FROM "+table)
+		defer func() { d.traceEnd(q, completed, err) }()
 	}
-	defer d.putConn(c)
-	
+
 	// ONE CGO call for entire batch!
 	wireBytes := EncodeSelectBatchFast(table, columns, limits)
-	if wireBytes == nil {
-		return 0, errors.New("failed to encode batch")
+	if len(wireBytes) == 0 {
+		return 0, fmt.Errorf("%w: batch", ErrEncode)
 	}
-	
-	// Send entire batch
-	if _, err := c.conn.Write(wireBytes); err != nil {
+
+	c, err := d.getConn()
+	if err != nil {
 		return 0, err
 	}
-	
-	// Count completed commands
-	completed := 0
-	for {
-		msgType, data, err := c.readMessage()
-		if err != nil {
-			return completed, err
-		}
-		switch msgType {
-		case 'C', 'n': // CommandComplete or NoData
-			completed++
-		case 'Z':
-			return completed, nil
-		case 'E':
-			return completed, errors.New("batch error: " + string(data))
-		}
-	}
+	return d.runBatch(context.Background(), c, func() error {
+		_, err := c.conn.Write(wireBytes)
+		return err
+	})
 }
 
 func (c *Conn) readRows() ([]Row, error) {
-	var rows []Row
-	var colNames []string
-	
+	return c.readRowsWith(nil)
+}
+
+// readRowsWith reads rows until ReadyForQuery. fields supplies the column
+// metadata when no RowDescription is expected (cached Describe).
+func (c *Conn) readRowsWith(fields []ColumnInfo) ([]Row, error) {
+	res, err := c.readResult(fields)
+	if err != nil {
+		return nil, err
+	}
+	return res.Rows, nil
+}
+
+// readResult reads one statement's response until ReadyForQuery, keeping
+// the column metadata even when no rows are returned.
+func (c *Conn) readResult(fields []ColumnInfo) (*Result, error) {
+	res := &Result{fields: fields}
+	var parseErr error
+	var resultBytes int64 // DataRow bytes so far, checked against maxResultBytes
+
+	// Message bodies are borrowed from bufferPool and handed back once the
+	// next message is read; DataRow bodies are copied because rows keep them.
+	var buf *[]byte
+	defer func() { putBuffer(buf) }()
+
 	for {
-		msgType, data, err := c.readMessage()
+		putBuffer(buf)
+		msgType, data, b, err := c.readMessagePooled()
+		buf = b
 		if err != nil {
 			return nil, err
 		}
@@ -425,30 +1439,240 @@ func (c *Conn) readRows() ([]Row, error) {
 		case '1', '2': // ParseComplete, BindComplete
 			continue
 		case 'T': // RowDescription
-			colNames = parseRowDescription(data)
+			if res.fields, err = parseRowDescription(data); err != nil && parseErr == nil {
+				parseErr = err
+			}
 		case 'D': // DataRow
-			cols := parseDataRow(data)
-			rows = append(rows, Row{columns: cols, names: colNames})
+			// After a parse error, keep reading to 'Z' so the connection stays in sync
+			if parseErr != nil {
+				continue
+			}
+			if c.maxResultBytes > 0 {
+				resultBytes += int64(len(data))
+				if resultBytes > c.maxResultBytes {
+					parseErr = fmt.Errorf("%w: over %d bytes", ErrResultTooLarge, c.maxResultBytes)
+					res.Release() // free what was buffered; the rest is discarded
+					continue
+				}
+			}
+			var cols [][]byte
+			if p, ok := columnsPool.Get().(*[][]byte); ok {
+				cols = (*p)[:0]
+			}
+			if buf != nil {
+				data = append([]byte(nil), data...)
+			}
+			cols, err := parseDataRowInto(cols, data)
+			if err != nil {
+				parseErr = err
+				continue
+			}
+			if res.Rows == nil {
+				if p, ok := rowsPool.Get().(*[]Row); ok {
+					res.Rows = (*p)[:0]
+				}
+			}
+			res.Rows = append(res.Rows, Row{columns: cols, fields: res.fields})
 		case 'C': // CommandComplete
-			continue
+			res.CommandTag = cstring(data)
 		case 'Z': // ReadyForQuery
-			return rows, nil
+			if parseErr != nil {
+				res.Release()
+				return nil, parseErr
+			}
+			return res, nil
 		case 'E':
-			return nil, errors.New("query error: " + string(data))
+			// The server still sends 'Z'; keep reading so the connection stays in sync
+			parseErr = serverError("query error", data)
 		}
 	}
 }
 
-// Close closes all connections.
+// Close stops handing out connections, waits for in-flight queries to
+// return theirs, and closes every connection.
+//
+// It waits for every checked-out connection, with no bound: that
+// includes one held by an open Tx, Listener, PooledConn or Rows, or by a
+// WITH HOLD cursor, so Close blocks forever if any of them is never
+// closed or released. Use CloseTimeout to bound the wait.
 func (d *Driver) Close() {
-	close(d.pool)
-	for c := range d.pool {
-		c.Close()
+	d.CloseTimeout(0)
+}
+
+// CloseTimeout is Close with a bound on how long to wait for in-flight
+// queries. timeout <= 0 waits indefinitely. If the timeout expires, idle
+// connections are closed, ErrCloseTimeout is returned, and connections
+// still checked out are closed when their queries return them.
+func (d *Driver) CloseTimeout(timeout time.Duration) error {
+	d.mu.Lock()
+	if d.closing {
+		d.mu.Unlock()
+		return nil
+	}
+	d.closing = true
+	close(d.closed)
+	if d.stopKeepalive != nil {
+		close(d.stopKeepalive)
+	}
+	if d.replica != nil {
+		defer d.replica.CloseTimeout(timeout)
+	}
+	var drained chan struct{}
+	if d.active > 0 {
+		drained = make(chan struct{})
+		d.drained = drained
+	}
+	d.mu.Unlock()
+
+	var err error
+	if drained != nil {
+		if timeout > 0 {
+			timer := time.NewTimer(timeout)
+			select {
+			case <-drained:
+			case <-timer.C:
+				err = ErrCloseTimeout
+			}
+			timer.Stop()
+		} else {
+			<-drained
+		}
+	}
+
+	for {
+		select {
+		case c := <-d.pool:
+			c.Close()
+		default:
+			return err
+		}
+	}
+}
+
+// PoolStats is a snapshot of the driver's primary pool; see Driver.Stats.
+type PoolStats struct {
+	MaxOpenConns int // Config.MaxOpenConns; 0 = no cap
+	InUse        int // connections checked out or being dialed
+	Idle         int // connections waiting in the pool
+
+	WaitCount    int64         // checkouts that had to wait for a slot
+	WaitDuration time.Duration // total time spent waiting for a slot
+
+	// Per-connection prepared statement cache, summed over connections.
+	StmtCacheHits      int64
+	StmtCacheMisses    int64
+	StmtCacheEvictions int64 // statements closed under MaxPreparedStatements
+}
+
+// Stats returns the pool's current usage and its cumulative waits for a
+// connection under Config.MaxOpenConns, for sizing the pool.
+func (d *Driver) Stats() PoolStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return PoolStats{
+		MaxOpenConns: cap(d.slots),
+		InUse:        d.active,
+		Idle:         len(d.pool),
+		WaitCount:    d.waitCount,
+		WaitDuration: d.waitDuration,
+
+		StmtCacheHits:      d.stmtStats.hits.Load(),
+		StmtCacheMisses:    d.stmtStats.misses.Load(),
+		StmtCacheEvictions: d.stmtStats.evictions.Load(),
+	}
+}
+
+// ConnInfo describes what a connection negotiated during startup.
+type ConnInfo struct {
+	Addr          string // server address, "host:port"
+	SSL           bool
+	AuthMethod    string // "trust", "password" (cleartext), "md5", "scram-sha-256" or "scram-sha-256-plus"
+	ServerVersion string // server_version, e.g. "16.2"
+	BackendPID    int
+	Parameters    map[string]string // every ParameterStatus value, copied
+}
+
+// Info returns what the connection negotiated during startup, with the
+// server's current run-time parameters.
+func (c *Conn) Info() ConnInfo {
+	params := make(map[string]string, len(c.params))
+	for k, v := range c.params {
+		params[k] = v
+	}
+	return ConnInfo{
+		Addr:          c.addr,
+		SSL:           c.ssl,
+		AuthMethod:    c.authMethod,
+		ServerVersion: c.params["server_version"],
+		BackendPID:    int(c.pid),
+		Parameters:    params,
+	}
+}
+
+// ConnInfo returns Conn.Info for a pooled connection. Connections to the
+// same server normally agree on everything but BackendPID.
+func (d *Driver) ConnInfo() (ConnInfo, error) {
+	c, err := d.getConn()
+	if err != nil {
+		return ConnInfo{}, err
+	}
+	defer d.putConn(c)
+	return c.Info(), nil
+}
+
+// verifyChain checks that the leaf of rawCerts chains to roots (the
+// system roots if nil) through the intermediates sent with it, without
+// checking the host name.
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("server sent no certificate")
+	}
+	certs := make([]*x509.Certificate, len(rawCerts))
+	for i, raw := range rawCerts {
+		cert, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return fmt.Errorf("parse server certificate: %w", err)
+		}
+		certs[i] = cert
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(opts)
+	return err
+}
+
+// ServerParameter returns a run-time parameter reported by the server
+// (see Conn.Parameter), read from a pooled connection.
+func (d *Driver) ServerParameter(name string) (string, error) {
+	c, err := d.getConn()
+	if err != nil {
+		return "", err
 	}
+	defer d.putConn(c)
+	return c.Parameter(name), nil
+}
+
+// SetResultFormat sets the format (FormatText or FormatBinary) requested for
+// result columns on subsequent queries. Binary avoids text parsing on the
+// server and client for numeric and timestamp columns.
+func (c *Conn) SetResultFormat(format int16) {
+	c.resultFormat = format
 }
 
-// Close closes the connection.
+// ResultFormat returns the result column format used by this connection.
+func (c *Conn) ResultFormat() int16 {
+	return c.resultFormat
+}
+
+// Close closes the connection. A closed connection is not pooled again
+// by PooledConn.Release.
 func (c *Conn) Close() error {
+	c.poisoned.Store(true)
 	// Send Terminate
 	c.conn.Write([]byte{'X', 0, 0, 0, 4})
 	return c.conn.Close()
@@ -457,7 +1681,37 @@ func (c *Conn) Close() error {
 // Row represents a query result row.
 type Row struct {
 	columns [][]byte
-	names   []string
+	fields  []ColumnInfo
+}
+
+// ColumnInfo is the per-column metadata from RowDescription.
+type ColumnInfo struct {
+	Name         string
+	TableOID     uint32 // 0 if not a table column
+	ColumnAttr   int16  // attribute number within the table, 0 if none
+	TypeOID      uint32 // e.g. OIDInt4, OIDText
+	TypeSize     int16  // negative for variable-width types
+	TypeModifier int32  // type-specific, e.g. varchar length
+	Format       int16  // FormatText or FormatBinary
+}
+
+// Fields returns the column metadata for this row's result set.
+func (r Row) Fields() []ColumnInfo {
+	return r.fields
+}
+
+// field returns the column metadata for idx, if known.
+func (r Row) field(idx int) (ColumnInfo, bool) {
+	if idx >= 0 && idx < len(r.fields) {
+		return r.fields[idx], true
+	}
+	return ColumnInfo{}, false
+}
+
+// isBinary reports whether column idx was sent in binary format.
+func (r Row) isBinary(idx int) bool {
+	f, ok := r.field(idx)
+	return ok && f.Format == FormatBinary
 }
 
 // Get returns column value by index.
@@ -468,7 +1722,9 @@ func (r Row) Get(idx int) []byte {
 	return nil
 }
 
-// GetString returns column as string.
+// GetString returns column as string. Text arrives as UTF-8 because the
+// driver sets client_encoding=UTF8 at startup; a later
+// SET client_encoding on the session is not supported.
 func (r Row) GetString(idx int) string {
 	b := r.Get(idx)
 	if b == nil {
@@ -483,51 +1739,238 @@ func (r Row) GetInt(idx int) int64 {
 	if b == nil {
 		return 0
 	}
+	if r.isBinary(idx) {
+		n, _ := decodeBinaryInt(b)
+		return n
+	}
 	// Parse text format
-	var n int64
-	for _, c := range b {
-		if c >= '0' && c <= '9' {
-			n = n*10 + int64(c-'0')
+	return parseTextInt(b)
+}
+
+// GetFloat64 returns column as float64.
+func (r Row) GetFloat64(idx int) float64 {
+	b := r.Get(idx)
+	if b == nil {
+		return 0
+	}
+	if r.isBinary(idx) {
+		if f, ok := decodeBinaryFloat(b); ok {
+			return f
 		}
+		n, _ := decodeBinaryInt(b)
+		return float64(n)
+	}
+	return parseTextFloat(b)
+}
+
+// GetBool returns column as bool.
+func (r Row) GetBool(idx int) bool {
+	b := r.Get(idx)
+	if len(b) == 0 {
+		return false
+	}
+	if r.isBinary(idx) {
+		return b[0] != 0
+	}
+	return b[0] == 't'
+}
+
+// IsNull reports whether column idx is NULL. An out-of-range idx also
+// reports true.
+func (r Row) IsNull(idx int) bool {
+	return r.Get(idx) == nil
+}
+
+// GetNullString is GetString that tells NULL apart from the empty string:
+// valid is false for NULL.
+func (r Row) GetNullString(idx int) (s string, valid bool) {
+	if r.IsNull(idx) {
+		return "", false
+	}
+	return r.GetString(idx), true
+}
+
+// GetNullInt64 is GetInt that tells NULL apart from zero: valid is false
+// for NULL.
+func (r Row) GetNullInt64(idx int) (n int64, valid bool) {
+	if r.IsNull(idx) {
+		return 0, false
+	}
+	return r.GetInt(idx), true
+}
+
+// GetNullFloat64 is GetFloat64 that tells NULL apart from zero: valid is
+// false for NULL.
+func (r Row) GetNullFloat64(idx int) (f float64, valid bool) {
+	if r.IsNull(idx) {
+		return 0, false
+	}
+	return r.GetFloat64(idx), true
+}
+
+// GetNullBool is GetBool that tells NULL apart from false: valid is false
+// for NULL.
+func (r Row) GetNullBool(idx int) (b bool, valid bool) {
+	if r.IsNull(idx) {
+		return false, false
+	}
+	return r.GetBool(idx), true
+}
+
+// GetTime returns a date, timestamp or timestamptz column as time.Time,
+// or the zero time for NULL or unparseable values; see ParseTime.
+func (r Row) GetTime(idx int) time.Time {
+	t, _ := r.ParseTime(idx)
+	return t
+}
+
+// ParseTime is GetTime reporting malformed values (including "infinity")
+// as an error. NULL returns the zero time and no error.
+func (r Row) ParseTime(idx int) (time.Time, error) {
+	b := r.Get(idx)
+	if b == nil {
+		return time.Time{}, nil
+	}
+	var t time.Time
+	var ok bool
+	if r.isBinary(idx) {
+		f, _ := r.field(idx)
+		t, ok = decodeBinaryTime(f.TypeOID, b)
+	} else {
+		t, ok = parseTextTime(b)
+	}
+	if !ok {
+		return time.Time{}, fmt.Errorf("column %d: invalid time value %q", idx, b)
+	}
+	return t, nil
+}
+
+// GetUUID returns a uuid column as 16 bytes, or the zero UUID for NULL or
+// malformed values; see ParseUUID.
+func (r Row) GetUUID(idx int) [16]byte {
+	u, _ := r.ParseUUID(idx)
+	return u
+}
+
+// ParseUUID is GetUUID reporting malformed values as an error. NULL
+// returns the zero UUID and no error.
+func (r Row) ParseUUID(idx int) ([16]byte, error) {
+	b := r.Get(idx)
+	if b == nil {
+		return [16]byte{}, nil
+	}
+	if r.isBinary(idx) && len(b) == 16 {
+		return [16]byte(b), nil
+	}
+	u, ok := parseUUID(b)
+	if !ok {
+		return [16]byte{}, fmt.Errorf("column %d: invalid uuid %q", idx, b)
+	}
+	return u, nil
+}
+
+// GetDecimalString returns a numeric column as its exact decimal text,
+// e.g. "1234.50", in either result format. NaN and infinities come back
+// as "NaN", "Infinity" and "-Infinity".
+func (r Row) GetDecimalString(idx int) string {
+	b := r.Get(idx)
+	if b == nil {
+		return ""
+	}
+	if r.isBinary(idx) {
+		s, _ := decodeBinaryNumeric(b)
+		return s
+	}
+	return string(b)
+}
+
+// GetDecimal returns a numeric column as an exact rational, avoiding the
+// rounding of GetFloat64 for money and other fixed-point values. It
+// returns nil for NULL, NaN and infinities.
+func (r Row) GetDecimal(idx int) *big.Rat {
+	d, ok := new(big.Rat).SetString(r.GetDecimalString(idx))
+	if !ok {
+		return nil
 	}
-	return n
+	return d
+}
+
+// errMalformed reports a backend message whose contents don't match its
+// declared layout.
+func errMalformed(msg string) error {
+	return fmt.Errorf("malformed %s message", msg)
 }
 
-func parseRowDescription(data []byte) []string {
+func parseRowDescription(data []byte) ([]ColumnInfo, error) {
+	if len(data) < 2 {
+		return nil, errMalformed("RowDescription")
+	}
 	colCount := binary.BigEndian.Uint16(data[:2])
-	names := make([]string, 0, colCount)
+	fields := make([]ColumnInfo, 0, colCount)
 	offset := 2
 	
 	for i := 0; i < int(colCount); i++ {
 		end := offset
-		for data[end] != 0 {
+		for end < len(data) && data[end] != 0 {
 			end++
 		}
-		names = append(names, string(data[offset:end]))
+		// Need the null terminator plus 18 bytes of metadata
+		if end+1+18 > len(data) {
+			return nil, errMalformed("RowDescription")
+		}
+		name := string(data[offset:end])
+		// Metadata: table OID(4), attr(2), type OID(4), typlen(2), typmod(4), format(2)
+		meta := data[end+1 : end+1+18]
+		fields = append(fields, ColumnInfo{
+			Name:         name,
+			TableOID:     binary.BigEndian.Uint32(meta[0:4]),
+			ColumnAttr:   int16(binary.BigEndian.Uint16(meta[4:6])),
+			TypeOID:      binary.BigEndian.Uint32(meta[6:10]),
+			TypeSize:     int16(binary.BigEndian.Uint16(meta[10:12])),
+			TypeModifier: int32(binary.BigEndian.Uint32(meta[12:16])),
+			Format:       int16(binary.BigEndian.Uint16(meta[16:18])),
+		})
 		offset = end + 1 + 18 // Skip null + metadata
 	}
 	
-	return names
+	return fields, nil
+}
+
+func parseDataRow(data []byte) ([][]byte, error) {
+	return parseDataRowInto(nil, data)
 }
 
-func parseDataRow(data []byte) [][]byte {
+// parseDataRowInto is parseDataRow appending to cols, which is reused when
+// it has room (see Result.Release).
+func parseDataRowInto(cols [][]byte, data []byte) ([][]byte, error) {
+	if len(data) < 2 {
+		return nil, errMalformed("DataRow")
+	}
 	colCount := binary.BigEndian.Uint16(data[:2])
-	cols := make([][]byte, 0, colCount)
+	if cap(cols) < int(colCount) {
+		cols = make([][]byte, 0, colCount)
+	}
 	offset := 2
 	
 	for i := 0; i < int(colCount); i++ {
+		if offset+4 > len(data) {
+			return nil, errMalformed("DataRow")
+		}
 		length := int32(binary.BigEndian.Uint32(data[offset : offset+4]))
 		offset += 4
 		
 		if length == -1 {
 			cols = append(cols, nil)
 		} else {
+			if length < 0 || int(length) > len(data)-offset {
+				return nil, errMalformed("DataRow")
+			}
 			cols = append(cols, data[offset:offset+int(length)])
 			offset += int(length)
 		}
 	}
 	
-	return cols
+	return cols, nil
 }
 
 // =============================================================================
@@ -537,7 +1980,7 @@ func parseDataRow(data []byte) [][]byte {
 // PreparedBatch holds pre-encoded wire bytes for repeated execution.
 // This is the FASTEST path - CGO only happens on Prepare(), not Execute()!
 type PreparedBatch struct {
-	wireBytes []byte
+	wireBytes  []byte
 	queryCount int
 }
 
@@ -545,7 +1988,20 @@ type PreparedBatch struct {
 // Returns PreparedBatch that can be executed many times with ZERO CGO overhead!
 func (d *Driver) PrepareBatch(table, columns string, limits []int64) *PreparedBatch {
 	wireBytes := EncodeSelectBatchFast(table, columns, limits)
-	if wireBytes == nil {
+	if len(wireBytes) == 0 {
+		return nil
+	}
+	return &PreparedBatch{
+		wireBytes:  wireBytes,
+		queryCount: len(limits),
+	}
+}
+
+// PrepareBatchBinary is PrepareBatch using EncodeSelectBatchBinary: one
+// parsed statement with binary int8 limit parameters.
+func (d *Driver) PrepareBatchBinary(table, columns string, limits []int64) *PreparedBatch {
+	wireBytes := EncodeSelectBatchBinary(table, columns, limits)
+	if len(wireBytes) == 0 {
 		return nil
 	}
 	return &PreparedBatch{
@@ -554,59 +2010,66 @@ func (d *Driver) PrepareBatch(table, columns string, limits []int64) *PreparedBa
 	}
 }
 
+// PrepareBatchParams is PrepareBatch using EncodeSelectBatchParams: one
+// parsed statement filtered on filters, with a row of binary parameters
+// per query.
+func (d *Driver) PrepareBatchParams(table, columns string, filters []string, rows [][]any) (*PreparedBatch, error) {
+	wireBytes, err := EncodeSelectBatchParams(table, columns, filters, rows)
+	if err != nil {
+		return nil, err
+	}
+	return &PreparedBatch{
+		wireBytes:  wireBytes,
+		queryCount: len(rows),
+	}, nil
+}
+
 // ExecutePrepared executes a prepared batch using PURE GO I/O.
 // NO CGO calls in this hot path! Uses buffered I/O for max performance.
-func (d *Driver) ExecutePrepared(pb *PreparedBatch) (int, error) {
-	if pb == nil || pb.wireBytes == nil {
-		return 0, errors.New("prepared batch is nil")
+func (d *Driver) ExecutePrepared(pb *PreparedBatch) (completed int, err error) {
+	return d.ExecutePreparedContext(context.Background(), pb)
+}
+
+// ExecutePreparedContext is ExecutePrepared with the cancellation
+// behavior of BatchExecuteContext.
+func (d *Driver) ExecutePreparedContext(ctx context.Context, pb *PreparedBatch) (completed int, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("ExecutePrepared", "")
+		defer func() { d.traceEnd(q, completed, err) }()
 	}
-	
-	c, err := d.getConn()
-	if err != nil {
-		return 0, err
+
+	if pb == nil || len(pb.wireBytes) == 0 {
+		return 0, fmt.Errorf("%w: prepared batch is nil", ErrInvalidArgument)
 	}
-	defer d.putConn(c)
 	
-	// Buffered write + flush (reduces syscalls)
-	if _, err := c.writer.Write(pb.wireBytes); err != nil {
-		return 0, err
-	}
-	if err := c.writer.Flush(); err != nil {
+	c, err := d.getConnContext(ctx)
+	if err != nil {
 		return 0, err
 	}
-	
-	// Pre-allocate reusable buffer for response parsing
-	buf := make([]byte, 1024)
-	
-	// Count completed commands
-	completed := 0
-	for {
-		msgType, data, err := c.readMessageFast(buf)
-		if err != nil {
-			return completed, err
-		}
-		// Reuse buffer for next read
-		if len(data) > 0 && cap(data) > cap(buf) {
-			buf = data[:0]
-		}
-		switch msgType {
-		case 'C', 'n': // CommandComplete or NoData
-			completed++
-		case 'Z':
-			return completed, nil
-		case 'E':
-			return completed, errors.New("batch error: " + string(data))
+
+	return d.runBatch(ctx, c, func() error {
+		// Buffered write + flush (reduces syscalls)
+		if _, err := c.writer.Write(pb.wireBytes); err != nil {
+			return err
 		}
-	}
+		return c.writer.Flush()
+	})
 }
 
-// PrepareBatchN creates a prepared batch for N queries with same pattern.
-// Uses fixed limits for benchmark comparison.
-func (d *Driver) PrepareBatchN(table, columns string, count int) *PreparedBatch {
+// PrepareBatchWith creates a prepared batch of count queries on table,
+// where gen(i) is the LIMIT of query i.
+func (d *Driver) PrepareBatchWith(table, columns string, count int, gen func(i int) int64) *PreparedBatch {
 	limits := make([]int64, count)
-	for i := 0; i < count; i++ {
-		limits[i] = int64((i % 10) + 1)
+	for i := range limits {
+		limits[i] = gen(i)
 	}
 	return d.PrepareBatch(table, columns, limits)
 }
 
+// PrepareBatchN creates a prepared batch for N queries with same pattern.
+// Uses fixed limits (1..10, repeating) for benchmark comparison.
+func (d *Driver) PrepareBatchN(table, columns string, count int) *PreparedBatch {
+	return d.PrepareBatchWith(table, columns, count, func(i int) int64 {
+		return int64((i % 10) + 1)
+	})
+}
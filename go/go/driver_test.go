@@ -0,0 +1,1310 @@
+package qail
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+// fakeDriver returns a Driver whose connections go to srv over in-memory
+// pipes. cfg supplies any pool settings; the connection fields are set
+// here, with SSLMode "disable" unless cfg chooses one.
+func fakeDriver(tb testing.TB, srv *qailtest.Server, cfg Config) *Driver {
+	tb.Helper()
+	cfg.User, cfg.Database = "test", "test"
+	if cfg.SSLMode == "" {
+		cfg.SSLMode = "disable"
+	}
+	cfg.DialFunc = srv.Dial
+	d, err := NewDriver(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(d.Close)
+	return d
+}
+
+// tcpDriver is fakeDriver over a loopback TCP listener. Pipelined
+// batches need it: a pipe has no buffering, so the server blocks writing
+// responses while the client is still writing the batch.
+func tcpDriver(tb testing.TB, srv *qailtest.Server, cfg Config) *Driver {
+	tb.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { ln.Close() })
+	go srv.Serve(ln)
+	cfg.User, cfg.Database, cfg.SSLMode = "test", "test", "disable"
+	cfg.Host, cfg.Port, _ = net.SplitHostPort(ln.Addr().String())
+	d, err := NewDriver(cfg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(d.Close)
+	return d
+}
+
+// serverDriver connects to the PostgreSQL server named by the standard
+// PGHOST, PGPORT, PGUSER, PGPASSWORD and PGDATABASE variables, skipping
+// the test when PGHOST is unset.
+func serverDriver(tb testing.TB) *Driver {
+	tb.Helper()
+	host := os.Getenv("PGHOST")
+	if host == "" {
+		tb.Skip("PGHOST not set; requires a PostgreSQL server")
+	}
+	env := func(key, def string) string {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+		return def
+	}
+	d, err := NewDriver(Config{
+		Host:     host,
+		Port:     env("PGPORT", "5432"),
+		User:     env("PGUSER", "postgres"),
+		Password: os.Getenv("PGPASSWORD"),
+		Database: env("PGDATABASE", "postgres"),
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(d.Close)
+	return d
+}
+
+// BenchmarkExecutePrepared runs the complex-query workload's 10,000-query
+// batch against a real server with text and with binary LIMIT parameters;
+// the binary batch parses its statement once. It needs a harbors(id, name)
+// table.
+func BenchmarkExecutePrepared(b *testing.B) {
+	d := serverDriver(b)
+	limits := complexLimits(10_000)
+	for _, bc := range []struct {
+		name    string
+		prepare func(table, columns string, limits []int64) *PreparedBatch
+	}{
+		{"text", d.PrepareBatch},
+		{"binary", d.PrepareBatchBinary},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			pb := bc.prepare("harbors", "id,name", limits)
+			if pb == nil {
+				b.Fatal("encode failed")
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				n, err := d.ExecutePrepared(pb)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if n != len(limits) {
+					b.Fatalf("completed %d of %d queries", n, len(limits))
+				}
+			}
+			b.ReportMetric(float64(b.N*len(limits))/b.Elapsed().Seconds(), "queries/s")
+		})
+	}
+}
+
+// BenchmarkBufferSize runs a 1,000-query prepared batch, each query
+// returning ten rows, with the smallest, default and a large connection
+// buffer size. Larger buffers mean fewer reads and writes on the
+// connection for the same batch.
+func BenchmarkBufferSize(b *testing.B) {
+	limits := complexLimits(1000)
+	rows := make([][]any, 10)
+	for i := range rows {
+		rows[i] = []any{i, "harbor"}
+	}
+	for _, size := range []int{MinBufferSize, DefaultBufferSize, 64 << 10} {
+		b.Run(strconv.Itoa(size), func(b *testing.B) {
+			srv := qailtest.NewServer()
+			srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+				return qailtest.Response{
+					Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}, {Name: "name", OID: qailtest.OIDText}},
+					Rows:    rows,
+				}, true
+			})
+			d := tcpDriver(b, srv, Config{ReadBufferSize: size, WriteBufferSize: size})
+			pb := d.PrepareBatch("harbors", "id,name", limits)
+			if pb == nil {
+				b.Fatal("encode failed")
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				n, err := d.ExecutePrepared(pb)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if n != len(limits) {
+					b.Fatalf("completed %d of %d queries", n, len(limits))
+				}
+			}
+		})
+	}
+}
+
+func TestPrepareBatchWith(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		return qailtest.Response{Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}}}, true
+	})
+	d := fakeDriver(t, srv, Config{})
+
+	pb := d.PrepareBatchWith("harbors", "id", 4, func(i int) int64 { return int64(i*i + 5) })
+	if pb == nil {
+		t.Fatal("encode failed")
+	}
+	want := []string{
+		"SELECT id FROM harbors LIMIT 5",
+		"SELECT id FROM harbors LIMIT 6",
+		"SELECT id FROM harbors LIMIT 9",
+		"SELECT id FROM harbors LIMIT 14",
+	}
+	if got := parsedSQL(t, pb.wireBytes); !reflect.DeepEqual(got, want) {
+		t.Fatalf("encoded %q, want %q", got, want)
+	}
+	n, err := d.ExecutePrepared(pb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(want) {
+		t.Fatalf("completed %d of %d queries", n, len(want))
+	}
+	var ran []string
+	for _, q := range srv.Queries() {
+		ran = append(ran, q.SQL)
+	}
+	if !reflect.DeepEqual(ran, want) {
+		t.Fatalf("server ran %q, want %q", ran, want)
+	}
+
+	// PrepareBatchN keeps its repeating 1..10 limits.
+	got := parsedSQL(t, d.PrepareBatchN("harbors", "id", 12).wireBytes)
+	if got[0] != "SELECT id FROM harbors LIMIT 1" || got[9] != "SELECT id FROM harbors LIMIT 10" || got[10] != "SELECT id FROM harbors LIMIT 1" {
+		t.Fatalf("PrepareBatchN encoded %q", got)
+	}
+}
+
+// TestEmptyEncodeSendsNothing checks that an encode producing no bytes is
+// an error rather than an empty write, after which the driver would wait
+// for a reply the server never sends.
+func TestEmptyEncodeSendsNothing(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	if b := EncodeSelectBatchFast("harbors", "id", nil); b != nil {
+		t.Errorf("EncodeSelectBatchFast with no limits = %q, want nil", b)
+	}
+	if b := EncodeBatch(nil); b != nil {
+		t.Errorf("EncodeBatch with no commands = %q, want nil", b)
+	}
+	if pb := d.PrepareBatch("harbors", "id", nil); pb != nil {
+		t.Errorf("PrepareBatch with no limits = %+v, want nil", pb)
+	}
+	for _, pb := range []*PreparedBatch{nil, {}, {wireBytes: []byte{}, queryCount: 1}} {
+		if _, err := d.ExecutePrepared(pb); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("ExecutePrepared(%+v): err = %v, want ErrInvalidArgument", pb, err)
+		}
+	}
+	if q := srv.Queries(); len(q) != 0 {
+		t.Errorf("server received %q, want nothing", q)
+	}
+	if s := d.Stats(); s.InUse != 0 || s.Idle != 0 {
+		t.Errorf("%d connections in use, %d idle; want none dialed", s.InUse, s.Idle)
+	}
+}
+
+func TestMaxOpenConnsSaturated(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1})
+	held, err := d.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.Stats(); s.MaxOpenConns != 1 || s.InUse != 1 || s.WaitCount != 0 {
+		t.Fatalf("stats with one conn held = %+v", s)
+	}
+
+	// ctx expires while waiting.
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	_, err = d.Acquire(ctx)
+	cancel()
+	if !errors.Is(err, ErrPoolTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Acquire with the pool exhausted: err = %v, want ErrPoolTimeout wrapping DeadlineExceeded", err)
+	}
+	if s := d.Stats(); s.WaitCount != 1 || s.WaitDuration < 30*time.Millisecond {
+		t.Errorf("after a timed-out wait: WaitCount %d, WaitDuration %v; want 1, >= 30ms", s.WaitCount, s.WaitDuration)
+	}
+
+	// Release hands the slot to a blocked Acquire.
+	acquired := make(chan *PooledConn)
+	go func() {
+		pc, err := d.Acquire(context.Background())
+		if err != nil {
+			t.Error(err)
+		}
+		acquired <- pc
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("second Acquire returned while the only conn was held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	held.Release()
+	select {
+	case pc := <-acquired:
+		if pc != nil {
+			pc.Release()
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Release did not unblock the waiting Acquire")
+	}
+	if s := d.Stats(); s.WaitCount != 2 || s.WaitDuration < 80*time.Millisecond || s.InUse != 0 {
+		t.Errorf("after a released wait: WaitCount %d, WaitDuration %v, InUse %d; want 2, >= 80ms, 0", s.WaitCount, s.WaitDuration, s.InUse)
+	}
+}
+
+func TestBatchContextBoundsPoolWait(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1})
+	pc, err := d.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Release()
+
+	pb := &PreparedBatch{wireBytes: []byte{'S', 0, 0, 0, 4}}
+	for name, run := range map[string]func(ctx context.Context) error{
+		"BatchExecuteContext": func(ctx context.Context) error {
+			_, err := d.BatchExecuteContext(ctx, []*Qail{Get("users")})
+			return err
+		},
+		"ExecutePreparedContext": func(ctx context.Context) error {
+			_, err := d.ExecutePreparedContext(ctx, pb)
+			return err
+		},
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		err := run(ctx)
+		cancel()
+		if !errors.Is(err, ErrPoolTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("%s with the pool exhausted: err = %v, want ErrPoolTimeout wrapping DeadlineExceeded", name, err)
+		}
+	}
+}
+
+func TestBatchExecuteFastErrorKeepsConnInSync(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if strings.HasSuffix(q.SQL, "LIMIT 2") {
+			return qailtest.Response{Err: &qailtest.Error{Code: "42P01", Message: `relation "users" does not exist`}}, true
+		}
+		return qailtest.Response{Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}}, Rows: [][]any{{1}}}, true
+	})
+	srv.Handle("SELECT 'next'", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "?column?", OID: qailtest.OIDText}},
+		Rows:    [][]any{{"next"}},
+	})
+	// The timeouts turn a desynchronized pipe into an error, not a hang.
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	completed, err := d.BatchExecuteFast("users", "id", []int64{1, 2, 3})
+	var pgErr *PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "42P01" {
+		t.Fatalf("err = %v, want the server's 42P01 error", err)
+	}
+	if completed != 1 {
+		t.Errorf("completed = %d, want 1", completed)
+	}
+
+	// The only connection goes back to the pool; the next query on it must
+	// see its own result, not the batch's pending ReadyForQuery.
+	results, err := d.SimpleExec("SELECT 'next'")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 1 || len(results[0].Rows) != 1 || results[0].Rows[0].GetString(0) != "next" {
+		t.Errorf("next query got %d results, want its own single row", len(results))
+	}
+}
+
+// rowDescription builds a RowDescription body for text columns of the
+// given names and type OIDs.
+func rowDescription(names []string, oids []uint32) []byte {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(names)))
+	for i, name := range names {
+		b = append(append(b, name...), 0)
+		b = binary.BigEndian.AppendUint32(b, 16384)       // table OID
+		b = binary.BigEndian.AppendUint16(b, uint16(i+1)) // column number
+		b = binary.BigEndian.AppendUint32(b, oids[i])     // type OID
+		b = binary.BigEndian.AppendUint16(b, 0xFFFF)      // type size: variable
+		b = binary.BigEndian.AppendUint32(b, 0xFFFFFFFF)  // type modifier: none
+		b = binary.BigEndian.AppendUint16(b, uint16(FormatText))
+	}
+	return b
+}
+
+func TestParseRowDescription(t *testing.T) {
+	data := rowDescription([]string{"id", "name"}, []uint32{OIDInt4, OIDText})
+	got, err := parseRowDescription(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []ColumnInfo{
+		{Name: "id", TableOID: 16384, ColumnAttr: 1, TypeOID: OIDInt4, TypeSize: -1, TypeModifier: -1, Format: FormatText},
+		{Name: "name", TableOID: 16384, ColumnAttr: 2, TypeOID: OIDText, TypeSize: -1, TypeModifier: -1, Format: FormatText},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":                 nil,
+		"missing column":        data[:len(data)-1],
+		"unterminated name":     {0, 1, 'i', 'd'},
+		"count exceeds columns": append([]byte{0, 3}, data[2:]...),
+	} {
+		if _, err := parseRowDescription(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestParseDataRow(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+		want [][]byte
+	}{
+		{"no columns", []byte{0, 0}, [][]byte{}},
+		{"values", []byte{0, 2, 0, 0, 0, 1, '7', 0, 0, 0, 3, 'a', 'd', 'a'}, [][]byte{[]byte("7"), []byte("ada")}},
+		{"null and empty", []byte{0, 2, 0xFF, 0xFF, 0xFF, 0xFF, 0, 0, 0, 0}, [][]byte{nil, {}}},
+	}
+	for _, tt := range tests {
+		got, err := parseDataRow(tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: got %d columns, want %d", tt.name, len(got), len(tt.want))
+			continue
+		}
+		for i := range got {
+			if (got[i] == nil) != (tt.want[i] == nil) || !bytes.Equal(got[i], tt.want[i]) {
+				t.Errorf("%s: column %d = %q, want %q", tt.name, i, got[i], tt.want[i])
+			}
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":             {0},
+		"truncated length":  {0, 1, 0, 0},
+		"value past end":    {0, 1, 0, 0, 0, 5, 'a'},
+		"negative length":   {0, 1, 0xFF, 0xFF, 0xFF, 0xFE},
+		"count exceeds row": {0, 2, 0, 0, 0, 1, 'a'},
+	} {
+		if _, err := parseDataRow(data); err == nil {
+			t.Errorf("%s: no error", name)
+		}
+	}
+}
+
+func TestAuthFailure(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Password = "secret"
+	srv.Handle("SELECT 1", qailtest.Response{Columns: []qailtest.Column{{Name: "?column?", OID: qailtest.OIDInt4}}, Rows: [][]any{{1}}})
+
+	d := fakeDriver(t, srv, Config{Password: "wrong"})
+	_, err := d.SimpleExec("SELECT 1")
+	var pgErr *PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Fatalf("wrong password: err = %v, want the server's 28P01 error", err)
+	}
+
+	d = fakeDriver(t, srv, Config{Password: "secret"})
+	if _, err := d.SimpleExec("SELECT 1"); err != nil {
+		t.Fatalf("right password: %v", err)
+	}
+}
+
+func TestWarmup(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{MinConns: 3, ReadHosts: []string{"replica"}})
+	if s := d.Stats(); s.Idle != 0 {
+		t.Fatalf("idle before Warmup = %d, want 0", s.Idle)
+	}
+
+	for range 2 { // a second Warmup finds the pools full
+		if err := d.Warmup(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		if s := d.Stats(); s.Idle != 3 || s.InUse != 0 {
+			t.Errorf("primary after Warmup: idle %d, in use %d; want 3, 0", s.Idle, s.InUse)
+		}
+		if s := d.replica.Stats(); s.Idle != 3 || s.InUse != 0 {
+			t.Errorf("replica after Warmup: idle %d, in use %d; want 3, 0", s.Idle, s.InUse)
+		}
+	}
+
+	srv.Password = "secret"
+	d = fakeDriver(t, srv, Config{MinConns: 2, Password: "wrong"})
+	var pgErr *PgError
+	if err := d.Warmup(context.Background()); !errors.As(err, &pgErr) || pgErr.Code != "28P01" {
+		t.Fatalf("Warmup with the wrong password: err = %v, want 28P01", err)
+	}
+	if s := d.Stats(); s.Idle != 0 || s.InUse != 0 {
+		t.Errorf("after a failed Warmup: idle %d, in use %d; want 0, 0", s.Idle, s.InUse)
+	}
+}
+
+func TestDialFunc(t *testing.T) {
+	release := make(chan struct{})
+	slow := Get("slow").SQL()
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL == slow {
+			<-release
+		}
+		return intRows(1)(q)
+	})
+	var mu sync.Mutex
+	var dials []string
+	d, err := NewDriver(Config{
+		Host: "db.internal", Port: "6432", User: "test", Database: "test", SSLMode: "disable",
+		DialFunc: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			mu.Lock()
+			dials = append(dials, network+" "+addr)
+			mu.Unlock()
+			client, server := net.Pipe()
+			go srv.ServeConn(server)
+			return client, nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	defer close(release)
+
+	if _, err := d.FetchOne(Get("numbers")); err != nil {
+		t.Fatal(err)
+	}
+	// The cancel request for an abandoned query goes through DialFunc too.
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := d.FetchAllContext(ctx, Get("slow")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("FetchAllContext: err = %v, want DeadlineExceeded", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	want := []string{"tcp db.internal:6432", "tcp db.internal:6432"}
+	if !reflect.DeepEqual(dials, want) {
+		t.Errorf("dials = %q, want %q", dials, want)
+	}
+}
+
+// startupParams returns the parameters in the startup packet a Driver
+// with cfg sends.
+func startupParams(t *testing.T, cfg Config) map[string]string {
+	t.Helper()
+	client, server := net.Pipe()
+	cfg.User, cfg.Database, cfg.SSLMode = "test", "test", "disable"
+	cfg.DialFunc = func(context.Context, string, string) (net.Conn, error) { return client, nil }
+	d, err := NewDriver(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	done := make(chan error, 1)
+	go func() { done <- d.Warmup(context.Background()) }()
+	defer func() { <-done }()
+	defer server.Close()
+
+	params, err := readStartup(server)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return params
+}
+
+func TestStatementTimeout(t *testing.T) {
+	if v, ok := startupParams(t, Config{})["statement_timeout"]; ok {
+		t.Errorf("no StatementTimeout: statement_timeout = %q, want unset", v)
+	}
+	if v := startupParams(t, Config{StatementTimeout: 1500 * time.Millisecond})["statement_timeout"]; v != "1500" {
+		t.Errorf("statement_timeout = %q, want 1500", v)
+	}
+
+	srv := qailtest.NewServer()
+	canceled := qailtest.Response{Err: &qailtest.Error{Code: "57014", Message: "canceling statement due to statement timeout"}}
+	srv.HandleCmd(Get("slow"), canceled)
+	srv.Handle("SELECT pg_sleep(10)", canceled)
+	srv.HandleCmd(Get("locked"), qailtest.Response{Err: &qailtest.Error{Code: "55P03", Message: "could not obtain lock"}})
+	d := fakeDriver(t, srv, Config{StatementTimeout: time.Second, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	if _, err := d.FetchAll(Get("slow")); !errors.Is(err, ErrStatementTimeout) {
+		t.Errorf("FetchAll: err = %v, want ErrStatementTimeout", err)
+	}
+	if _, err := d.SimpleExec("SELECT pg_sleep(10)"); !errors.Is(err, ErrStatementTimeout) {
+		t.Errorf("SimpleExec: err = %v, want ErrStatementTimeout", err)
+	}
+	if _, err := d.FetchAll(Get("locked")); err == nil || errors.Is(err, ErrStatementTimeout) {
+		t.Errorf("55P03: err = %v, want an error other than ErrStatementTimeout", err)
+	}
+}
+
+func TestConnInfo(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		password string // required by the server
+		sslMode  string
+		auth     string
+	}{
+		{"trust", "", "disable", "trust"},
+		{"cleartext password", "secret", "disable", "password"},
+		{"SSL refused", "", "prefer", "trust"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qailtest.NewServer()
+			srv.Password = tt.password
+			d := fakeDriver(t, srv, Config{Password: tt.password, SSLMode: tt.sslMode})
+
+			info, err := d.ConnInfo()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.ServerVersion != "16.0" || info.AuthMethod != tt.auth || info.SSL || info.BackendPID == 0 {
+				t.Errorf("info = %+v, want server 16.0, auth %s, no SSL and a backend PID", info, tt.auth)
+			}
+			if info.Parameters["integer_datetimes"] != "on" {
+				t.Errorf("parameters = %v, want integer_datetimes on", info.Parameters)
+			}
+		})
+	}
+}
+
+// selfSignedCert returns a self-signed certificate for host and its PEM
+// encoding, for use as both server certificate and root.
+func selfSignedCert(t *testing.T, host string) (tls.Certificate, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: host},
+		DNSNames:              []string{host},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key},
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestSSLModeVerify(t *testing.T) {
+	cert, rootPEM := selfSignedCert(t, "db.example")
+	_, otherPEM := selfSignedCert(t, "db.example")
+	dir := t.TempDir()
+	root, other := filepath.Join(dir, "root.pem"), filepath.Join(dir, "other.pem")
+	if err := os.WriteFile(root, rootPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(other, otherPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	srv := qailtest.NewServer()
+	srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+	for _, tt := range []struct {
+		mode, host, root string
+		fail             string // in the expected error; "" to connect
+	}{
+		{"verify-full", "db.example", root, ""},
+		{"verify-full", "wrong.example", root, "not wrong.example"},
+		{"verify-ca", "wrong.example", root, ""}, // host name not checked
+		{"verify-ca", "db.example", other, "unknown authority"},
+		{"require", "wrong.example", "", ""},
+	} {
+		t.Run(tt.mode+" "+tt.host, func(t *testing.T) {
+			d := fakeDriver(t, srv, Config{Host: tt.host, SSLMode: tt.mode, SSLRootCert: tt.root})
+			info, err := d.ConnInfo()
+			if tt.fail != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.fail) {
+					t.Fatalf("err = %v, want a certificate error with %q", err, tt.fail)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !info.SSL {
+				t.Error("ConnInfo.SSL = false over TLS")
+			}
+		})
+	}
+}
+
+// intRows answers every query with the single int4 column "n" and rows.
+func intRows(rows ...int) func(qailtest.Query) (qailtest.Response, bool) {
+	return func(qailtest.Query) (qailtest.Response, bool) {
+		r := qailtest.Response{Columns: []qailtest.Column{{Name: "n", OID: qailtest.OIDInt4}}, Rows: [][]any{}}
+		for _, n := range rows {
+			r.Rows = append(r.Rows, []any{n})
+		}
+		return r, true
+	}
+}
+
+func TestFetchOne(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	srv.HandleFunc(intRows(4, 5, 6))
+	row, err := d.FetchOne(Get("numbers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := row.GetInt(0); n != 4 {
+		t.Errorf("first row = %d, want 4", n)
+	}
+
+	srv.HandleFunc(intRows())
+	if _, err := d.FetchOne(Get("numbers")); !errors.Is(err, ErrNoRows) {
+		t.Errorf("empty result: err = %v, want ErrNoRows", err)
+	}
+
+	// The extra rows were read, so the connection is still in sync.
+	srv.HandleFunc(intRows(9))
+	row, err = d.FetchOne(Get("numbers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := row.GetInt(0); n != 9 {
+		t.Errorf("next query = %d, want 9", n)
+	}
+}
+
+func TestReadBuffersNotShared(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		table := strings.TrimPrefix(q.SQL, "SELECT * FROM ")
+		r := qailtest.Response{Columns: []qailtest.Column{{Name: "name", OID: qailtest.OIDText}}}
+		for range 20 {
+			r.Rows = append(r.Rows, []any{table})
+		}
+		return r, true
+	})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 4, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	// Rows kept from the first query must not change while later queries
+	// on the same and other connections recycle message buffers.
+	var wg sync.WaitGroup
+	for g := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			table := "t" + strconv.Itoa(g)
+			var kept []Row
+			for i := range 50 {
+				rows, err := d.FetchAll(Get(table))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if i == 0 {
+					kept = rows
+				}
+			}
+			for _, row := range kept {
+				if got := row.GetString(0); got != table {
+					t.Errorf("kept row = %q, want %q", got, table)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestMultiMegabyteRow(t *testing.T) {
+	huge := strings.Repeat("qail", 1<<20) // 4 MiB, past maxScratchRetain
+
+	// readMessageFast reads a body too big for the scratch buffer whole,
+	// without keeping the allocation, then goes on reading small ones.
+	var wire []byte
+	for _, body := range []string{"small", huge, "small"} {
+		wire = binary.BigEndian.AppendUint32(append(wire, 'd'), uint32(4+len(body)))
+		wire = append(wire, body...)
+	}
+	c := &Conn{reader: bufio.NewReader(bytes.NewReader(wire))}
+	for i, want := range []string{"small", huge, "small"} {
+		typ, data, err := c.readMessageFast()
+		if err != nil || typ != 'd' || string(data) != want {
+			t.Fatalf("message %d: %q, %d bytes, %v; want %d bytes", i, typ, len(data), err, len(want))
+		}
+		if cap(c.scratch) > maxScratchRetain {
+			t.Errorf("message %d: scratch buffer kept at %d bytes", i, cap(c.scratch))
+		}
+	}
+
+	srv := qailtest.NewServer()
+	srv.Handle("SELECT huge", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "huge", OID: qailtest.OIDText}},
+		Rows:    [][]any{{huge}, {"small"}},
+	})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: 5 * time.Second, WriteTimeout: 5 * time.Second})
+
+	results, err := d.SimpleExec("SELECT huge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := results[0].Rows; len(rows) != 2 || rows[0].GetString(0) != huge || rows[1].GetString(0) != "small" {
+		t.Errorf("SimpleExec returned %d rows, first %d bytes", len(rows), len(rows[0].Get(0)))
+	}
+
+	rows, err := d.QueryRows("SELECT huge")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rows.Next() || rows.Row().GetString(0) != huge {
+		t.Errorf("QueryRows: first row is %d bytes, want %d", len(rows.Row().Get(0)), len(huge))
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// ExecuteSimple discards the rows through the scratch buffer.
+	if err := d.ExecuteSimple("SELECT huge"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.SimpleExec("SELECT huge"); err != nil {
+		t.Fatalf("connection out of sync after the huge row: %v", err)
+	}
+}
+
+// repeatReader reads msg over and over.
+type repeatReader struct {
+	msg []byte
+	off int
+}
+
+func (r *repeatReader) Read(p []byte) (int, error) {
+	n := 0
+	for n < len(p) {
+		c := copy(p[n:], r.msg[r.off:])
+		n += c
+		r.off = (r.off + c) % len(r.msg)
+	}
+	return n, nil
+}
+
+// BenchmarkReadMessage reads a CommandComplete into a fresh allocation
+// and into a buffer borrowed from bufferPool.
+func BenchmarkReadMessage(b *testing.B) {
+	body := []byte("SELECT 1000\x00")
+	msg := binary.BigEndian.AppendUint32([]byte{'C'}, uint32(4+len(body)))
+	msg = append(msg, body...)
+	for _, bc := range []struct {
+		name string
+		read func(c *Conn) error
+	}{
+		{"alloc", func(c *Conn) error {
+			_, _, err := c.readMessage()
+			return err
+		}},
+		{"pooled", func(c *Conn) error {
+			_, _, buf, err := c.readMessagePooled()
+			putBuffer(buf)
+			return err
+		}},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			c := &Conn{reader: bufio.NewReader(&repeatReader{msg: msg})}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if err := bc.read(c); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestPoisonedConnNotPooled(t *testing.T) {
+	release := make(chan struct{})
+	slow := Get("slow").SQL()
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL == slow {
+			<-release
+		}
+		return intRows(1)(q)
+	})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: 50 * time.Millisecond, WriteTimeout: time.Second})
+
+	// The read deadline passes mid-query: the response may still arrive,
+	// so the connection must not be reused.
+	_, err := d.FetchAll(Get("slow"))
+	close(release)
+	if !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("err = %v, want os.ErrDeadlineExceeded", err)
+	}
+	if s := d.Stats(); s.Idle != 0 || s.InUse != 0 {
+		t.Errorf("after the failed read: idle %d, in use %d; want 0, 0", s.Idle, s.InUse)
+	}
+
+	row, err := d.FetchOne(Get("numbers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := row.GetInt(0); n != 1 {
+		t.Errorf("next query = %d, want 1", n)
+	}
+	if s := d.Stats(); s.Idle != 1 {
+		t.Errorf("after a good query: idle %d, want 1", s.Idle)
+	}
+}
+
+func TestDeadlinesTrackedApart(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &deadlineConn{Conn: client, readTimeout: 50 * time.Millisecond, writeTimeout: 50 * time.Millisecond}
+	defer c.Close()
+
+	// Nothing reads or writes the other end, so each call can only end
+	// at a deadline. An explicit deadline in one direction must leave the
+	// automatic one in force for the other.
+	c.SetReadDeadline(time.Now().Add(time.Hour))
+	if _, err := c.Write([]byte("x")); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Write under an explicit read deadline: err = %v, want os.ErrDeadlineExceeded", err)
+	}
+	c.SetReadDeadline(time.Time{})
+	c.SetWriteDeadline(time.Now().Add(time.Hour))
+	if _, err := c.Read(make([]byte, 1)); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Errorf("Read under an explicit write deadline: err = %v, want os.ErrDeadlineExceeded", err)
+	}
+}
+
+func TestMaxResultBytes(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, MaxResultBytes: 100, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	// Each DataRow body is a 2-byte column count, a 4-byte length and
+	// the text: 7 bytes for a one-digit number.
+	srv.HandleFunc(intRows(1, 2, 3))
+	if rows, err := d.FetchAll(Get("numbers")); err != nil || len(rows) != 3 {
+		t.Fatalf("small result: %d rows, err %v; want 3 rows", len(rows), err)
+	}
+
+	many := make([]int, 50)
+	for i := range many {
+		many[i] = i
+	}
+	srv.HandleFunc(intRows(many...))
+	if rows, err := d.FetchAll(Get("numbers")); !errors.Is(err, ErrResultTooLarge) || rows != nil {
+		t.Fatalf("large result: %d rows, err %v; want ErrResultTooLarge", len(rows), err)
+	}
+	if _, err := d.FetchResult(Get("numbers")); !errors.Is(err, ErrResultTooLarge) {
+		t.Fatalf("FetchResult: err = %v, want ErrResultTooLarge", err)
+	}
+
+	// The rest of the result was discarded, so the connection is in sync.
+	srv.HandleFunc(intRows(7))
+	row, err := d.FetchOne(Get("numbers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := row.GetInt(0); n != 7 {
+		t.Errorf("next query = %d, want 7", n)
+	}
+}
+
+func TestErrorReadsToReadyForQuery(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		run  func(d *Driver) error
+	}{
+		{"FetchAll", func(d *Driver) error {
+			_, err := d.FetchAll(Get("users"))
+			return err
+		}},
+		{"ExecuteSimple", func(d *Driver) error {
+			return d.ExecuteSimple("SELECT nme FROM users")
+		}},
+		{"QueryRows", func(d *Driver) error {
+			rows, err := d.QueryRows("SELECT nme FROM users")
+			if err != nil {
+				return err
+			}
+			if rows.Next() {
+				rows.Close()
+				return errors.New("Next reported a row from a failed statement")
+			}
+			return rows.Close()
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qailtest.NewServer()
+			undefined := qailtest.Response{Err: &qailtest.Error{Code: "42703", Message: `column "nme" does not exist`}}
+			srv.HandleCmd(Get("users"), undefined)
+			srv.Handle("SELECT nme FROM users", undefined)
+			srv.Handle("SELECT 'next'", qailtest.Response{
+				Columns: []qailtest.Column{{Name: "?column?", OID: qailtest.OIDText}},
+				Rows:    [][]any{{"next"}},
+			})
+			d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+			err := tt.run(d)
+			var pgErr *PgError
+			if !errors.As(err, &pgErr) || pgErr.Code != "42703" {
+				t.Fatalf("err = %v, want the server's 42703 error", err)
+			}
+
+			// The failed statement's ReadyForQuery was read, so the next
+			// query on the connection gets its own result.
+			results, err := d.SimpleExec("SELECT 'next'")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(results) != 1 || results[0].CommandTag != "SELECT 1" || results[0].Rows[0].GetString(0) != "next" {
+				t.Errorf("next query got %d results, want its own single row", len(results))
+			}
+		})
+	}
+}
+
+func TestFetchAllReadsRows(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleCmd(Get("users"), qailtest.Response{
+		Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt8}, {Name: "name", OID: qailtest.OIDText}, {Name: "active", OID: qailtest.OIDBool}},
+		Rows:    [][]any{{1, "ada", true}, {2, nil, false}, {3, "", nil}},
+	})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	res, err := d.FetchResult(Get("users"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.CommandTag != "SELECT 3" {
+		t.Errorf("tag = %q, want SELECT 3", res.CommandTag)
+	}
+	var oids []uint32
+	for _, f := range res.Fields() {
+		oids = append(oids, f.TypeOID)
+	}
+	if cols := res.Columns(); !reflect.DeepEqual(cols, []string{"id", "name", "active"}) || !reflect.DeepEqual(oids, []uint32{OIDInt8, OIDText, OIDBool}) {
+		t.Errorf("columns %q with OIDs %v, want id, name, active with int8, text, bool", cols, oids)
+	}
+	type user struct {
+		id     int64
+		name   string
+		named  bool
+		active bool
+		known  bool
+	}
+	var got []user
+	for _, r := range res.Rows {
+		var u user
+		u.id = r.GetInt(0)
+		u.name, u.named = r.GetString(1), r.Get(1) != nil
+		u.active, u.known = r.GetBool(2), r.Get(2) != nil
+		got = append(got, u)
+	}
+	want := []user{{1, "ada", true, true, true}, {2, "", false, false, true}, {3, "", true, false, false}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %+v, want %+v", got, want)
+	}
+}
+
+func TestGetNullAccessors(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleCmd(Get("nullable"), qailtest.Response{
+		Columns: []qailtest.Column{
+			{Name: "s", OID: qailtest.OIDText},
+			{Name: "n", OID: qailtest.OIDInt8},
+			{Name: "f", OID: qailtest.OIDFloat8},
+			{Name: "b", OID: qailtest.OIDBool},
+		},
+		// Zero values, NULLs, then non-zero values.
+		Rows: [][]any{{"", 0, 0.0, false}, {nil, nil, nil, nil}, {"x", -3, 1.5, true}},
+	})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+	rows, err := d.FetchAll(Get("nullable"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	type values struct {
+		s      string
+		sValid bool
+		n      int64
+		nValid bool
+		f      float64
+		fValid bool
+		b      bool
+		bValid bool
+		nulls  [4]bool
+	}
+	var got []values
+	for _, r := range rows {
+		var v values
+		v.s, v.sValid = r.GetNullString(0)
+		v.n, v.nValid = r.GetNullInt64(1)
+		v.f, v.fValid = r.GetNullFloat64(2)
+		v.b, v.bValid = r.GetNullBool(3)
+		for i := range v.nulls {
+			v.nulls[i] = r.IsNull(i)
+		}
+		got = append(got, v)
+	}
+	want := []values{
+		{"", true, 0, true, 0, true, false, true, [4]bool{}},
+		{"", false, 0, false, 0, false, false, false, [4]bool{true, true, true, true}},
+		{"x", true, -3, true, 1.5, true, true, true, [4]bool{}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got  %+v\nwant %+v", got, want)
+	}
+
+	for _, idx := range []int{-1, 4} {
+		if !rows[0].IsNull(idx) {
+			t.Errorf("IsNull(%d) = false for an out-of-range column", idx)
+		}
+		if _, valid := rows[0].GetNullString(idx); valid {
+			t.Errorf("GetNullString(%d) valid for an out-of-range column", idx)
+		}
+	}
+}
+
+func TestCloseWaitsForInFlightQuery(t *testing.T) {
+	running, release := make(chan struct{}), make(chan struct{})
+	slow := Get("slow").SQL()
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL == slow && q.Args != nil {
+			close(running)
+			<-release
+		}
+		return intRows(1)(q)
+	})
+	d := fakeDriver(t, srv, Config{})
+
+	queried := make(chan error, 1)
+	go func() {
+		_, err := d.FetchAll(Get("slow"))
+		queried <- err
+	}()
+	<-running
+	closed := make(chan struct{})
+	go func() {
+		d.Close()
+		close(closed)
+	}()
+
+	// Once Close has begun, new queries are refused.
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(time.Millisecond) {
+		_, err := d.FetchAll(Get("numbers"))
+		if errors.Is(err, ErrDriverClosed) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("query during Close: err = %v, want ErrDriverClosed", err)
+		}
+	}
+	select {
+	case <-closed:
+		t.Fatal("Close returned while a query was in flight")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-queried; err != nil {
+		t.Fatalf("in-flight query: %v", err)
+	}
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close did not return after the query finished")
+	}
+}
+
+func TestCloseTimeout(t *testing.T) {
+	running, release := make(chan struct{}), make(chan struct{})
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.Args != nil {
+			close(running)
+			<-release
+		}
+		return intRows(1)(q)
+	})
+	d := fakeDriver(t, srv, Config{})
+
+	queried := make(chan error, 1)
+	go func() {
+		_, err := d.FetchAll(Get("slow"))
+		queried <- err
+	}()
+	<-running
+	start := time.Now()
+	if err := d.CloseTimeout(50 * time.Millisecond); !errors.Is(err, ErrCloseTimeout) {
+		t.Errorf("CloseTimeout: err = %v, want ErrCloseTimeout", err)
+	}
+	if elapsed := time.Since(start); elapsed < 50*time.Millisecond {
+		t.Errorf("CloseTimeout returned after %v, before its timeout", elapsed)
+	}
+
+	// The straggler still finishes on its connection.
+	close(release)
+	if err := <-queried; err != nil {
+		t.Errorf("in-flight query: %v", err)
+	}
+}
+
+// readStartup reads a startup packet and returns its parameters.
+func readStartup(r io.Reader) (map[string]string, error) {
+	var hdr [8]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return nil, err
+	}
+	if v := binary.BigEndian.Uint32(hdr[4:]); v != 196608 {
+		return nil, fmt.Errorf("protocol version = %#x, want 3.0", v)
+	}
+	body := make([]byte, binary.BigEndian.Uint32(hdr[:4])-8)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	params := make(map[string]string)
+	fields := strings.Split(strings.TrimSuffix(string(body), "\x00\x00"), "\x00")
+	for i := 0; i+1 < len(fields); i += 2 {
+		params[fields[i]] = fields[i+1]
+	}
+	return params, nil
+}
+
+func TestClientEncoding(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	startup := make(chan map[string]string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		params, err := readStartup(conn)
+		startup <- params
+		if err != nil {
+			return
+		}
+		// A server that ignored the requested encoding.
+		msg := func(typ byte, body string) []byte {
+			m := []byte{typ, 0, 0, 0, 0}
+			binary.BigEndian.PutUint32(m[1:], uint32(4+len(body)))
+			return append(m, body...)
+		}
+		var resp []byte
+		resp = append(resp, msg('R', "\x00\x00\x00\x00")...)
+		resp = append(resp, msg('S', "client_encoding\x00SQL_ASCII\x00")...)
+		resp = append(resp, msg('Z', "I")...)
+		conn.Write(resp)
+		io.Copy(io.Discard, conn)
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	d, err := NewDriver(Config{Host: host, Port: port, User: "test", Database: "test", SSLMode: "disable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	if err := d.Ping(); err == nil || !strings.Contains(err.Error(), `client_encoding "SQL_ASCII"`) {
+		t.Errorf("server reporting SQL_ASCII: err = %v, want a client_encoding error", err)
+	}
+	if enc := (<-startup)["client_encoding"]; enc != "UTF8" {
+		t.Errorf("startup client_encoding = %q, want UTF8", enc)
+	}
+	if s := d.Stats(); s.InUse != 0 || s.Idle != 0 {
+		t.Errorf("after the failed startup: %d in use, %d idle; want no connections", s.InUse, s.Idle)
+	}
+}
+
+func TestConcurrentQueries(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		table, ok := strings.CutPrefix(q.SQL, "SELECT * FROM t")
+		if !ok {
+			return qailtest.Response{}, false
+		}
+		n, err := strconv.Atoi(table)
+		if err != nil {
+			return qailtest.Response{}, false
+		}
+		return intRows(n, n)(q)
+	})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 4})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				rows, err := d.FetchAll(Get("t" + strconv.Itoa(i)))
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				if len(rows) != 2 || rows[0].GetInt(0) != int64(i) || rows[1].GetInt(0) != int64(i) {
+					t.Errorf("goroutine %d got another query's rows", i)
+					return
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,163 @@
+package qail
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// PgError is an ErrorResponse sent by the server.
+type PgError struct {
+	Severity string // e.g. "ERROR", "FATAL"
+	Code     string // SQLSTATE, e.g. "23505"
+	Message  string
+	Detail   string
+	Hint     string
+	Position int // 1-based character offset into the query text, 0 if unknown
+}
+
+func (e *PgError) Error() string {
+	msg := e.Severity + ": " + e.Message
+	if e.Code != "" {
+		msg += " (SQLSTATE " + e.Code + ")"
+	}
+	return msg
+}
+
+// Is makes errors.Is match sentinel errors for specific SQLSTATEs, such
+// as ErrStatementTimeout for 57014 (query_canceled).
+func (e *PgError) Is(target error) bool {
+	return target == ErrStatementTimeout && e.Code == "57014"
+}
+
+// connError marks errors from a connection closed by the peer with
+// ErrConnClosed, keeping the underlying error.
+func connError(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return fmt.Errorf("%w: %w", ErrConnClosed, err)
+	}
+	return err
+}
+
+// serverError wraps an ErrorResponse body under prefix, keeping the
+// *PgError reachable with errors.As.
+func serverError(prefix string, data []byte) error {
+	return fmt.Errorf("%s: %w", prefix, parsePgError(data))
+}
+
+// parsePgError decodes the fields of an ErrorResponse body.
+func parsePgError(data []byte) *PgError {
+	e := &PgError{}
+	for len(data) > 0 && data[0] != 0 {
+		field := data[0]
+		value := cstring(data[1:])
+		data = data[min(1+len(value)+1, len(data)):]
+		switch field {
+		case 'S':
+			e.Severity = value
+		case 'C':
+			e.Code = value
+		case 'M':
+			e.Message = value
+		case 'D':
+			e.Detail = value
+		case 'H':
+			e.Hint = value
+		case 'P':
+			e.Position, _ = strconv.Atoi(value)
+		}
+	}
+	return e
+}
+
+// MaxParams is the most bind parameters a single statement can carry.
+// The Bind message encodes the count in 16 bits, which the server reads
+// as unsigned.
+const MaxParams = 65535
+
+// ErrTooManyParams is returned when a command binds more than MaxParams
+// parameters. Split the operation (e.g. a bulk insert or a large IN list)
+// into smaller commands.
+var ErrTooManyParams = errors.New("too many bind parameters")
+
+// ErrDriverClosed is returned by queries issued after Driver.Close.
+var ErrDriverClosed = errors.New("driver is closed")
+
+// ErrPoolClosed is ErrDriverClosed under the name used for pools; both
+// match the same errors, including those from a closed RustPool.
+var ErrPoolClosed = ErrDriverClosed
+
+// ErrConnClosed is matched, via errors.Is, by errors from reading a
+// connection that the server or network closed.
+var ErrConnClosed = errors.New("connection closed")
+
+// ErrConnReleased is returned by PooledConn methods called after Release.
+var ErrConnReleased = errors.New("connection already released")
+
+// ErrInvalidArgument is returned when a call is given an argument it
+// cannot use, such as an empty statement name or a nil PreparedBatch.
+var ErrInvalidArgument = errors.New("invalid argument")
+
+// ErrEncode is returned when a command cannot be encoded to the wire
+// protocol and no more specific cause (Qail.Err, ErrTooManyParams) is known.
+var ErrEncode = errors.New("failed to encode command")
+
+// ErrUnsupportedAuth is returned when the server asks for an
+// authentication method the driver does not implement.
+var ErrUnsupportedAuth = errors.New("unsupported authentication method")
+
+// ErrSSLNotSupported is returned when SSLMode is "require" and the server
+// declines SSL.
+var ErrSSLNotSupported = errors.New("server does not support SSL")
+
+// ErrNoRows is returned by FetchOne and QueryOne when the query returns
+// no rows.
+var ErrNoRows = errors.New("no rows in result set")
+
+// ErrReadOnlyHost is returned when TargetSessionAttrs is "read-write" and
+// the server is a read-only standby.
+var ErrReadOnlyHost = errors.New("server is read-only")
+
+// ErrStatementTimeout matches, via errors.Is, a server error cancelling a
+// statement (SQLSTATE 57014), as caused by Config.StatementTimeout.
+var ErrStatementTimeout = errors.New("statement timeout")
+
+// ErrResultTooLarge is returned when a query's rows exceed
+// Config.MaxResultBytes.
+var ErrResultTooLarge = errors.New("result too large")
+
+// ErrCloseTimeout is returned by Driver.CloseTimeout when in-flight
+// queries did not return their connections in time.
+var ErrCloseTimeout = errors.New("timed out waiting for in-flight queries")
+
+// ErrSCRAMTrace is returned by ServeTrace for a trace recorded on a
+// connection that authenticated with SCRAM-SHA-256. The client picks a
+// new random nonce for every exchange, so a replaying client never sends
+// the recorded bytes.
+var ErrSCRAMTrace = errors.New("trace uses SCRAM authentication and cannot be replayed")
+
+// checkParamCount returns ErrTooManyParams if cmd exceeds MaxParams.
+func checkParamCount(cmd *Qail) error {
+	if n := cmd.ParamCount(); n > MaxParams {
+		return fmt.Errorf("%w: command binds %d parameters, limit is %d", ErrTooManyParams, n, MaxParams)
+	}
+	return nil
+}
+
+// encodeError explains why cmd.Encode returned no bytes.
+func encodeError(cmd *Qail) error {
+	if cmd.err != nil {
+		return cmd.err
+	}
+	if err := checkParamCount(cmd); err != nil {
+		return err
+	}
+	return ErrEncode
+}
+
+// ErrPoolTimeout is returned when Config.MaxOpenConns connections are
+// checked out and none is returned within Config.PoolTimeout or before the
+// caller's context is done.
+var ErrPoolTimeout = errors.New("timed out waiting for a pooled connection")
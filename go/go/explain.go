@@ -0,0 +1,76 @@
+package qail
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Explain runs EXPLAIN on the command and returns the QUERY PLAN lines.
+// With analyze, the command is actually executed (EXPLAIN ANALYZE) to
+// report real row counts and timings, so it also performs any writes.
+// Commands with Param placeholders cannot be explained, since no
+// arguments are bound.
+func (d *Driver) Explain(cmd *Qail, analyze bool) ([]string, error) {
+	rows, err := d.explain(cmd, analyze, false)
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, len(rows))
+	for i, r := range rows {
+		lines[i] = r.GetString(0)
+	}
+	return lines, nil
+}
+
+// ExplainJSON is Explain with EXPLAIN (FORMAT JSON), returning the plan
+// document as raw JSON.
+func (d *Driver) ExplainJSON(cmd *Qail, analyze bool) (json.RawMessage, error) {
+	rows, err := d.explain(cmd, analyze, true)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("EXPLAIN: %w", ErrNoRows)
+	}
+	return json.RawMessage(rows[0].GetString(0)), nil
+}
+
+func (d *Driver) explain(cmd *Qail, analyze, asJSON bool) ([]Row, error) {
+	if d.replica != nil && cmd.IsReadOnly() {
+		return d.replica.explain(cmd, analyze, asJSON)
+	}
+
+	sql, params, err := cmd.sqlParams()
+	if err != nil {
+		return nil, err
+	}
+	var opts []string
+	if analyze {
+		opts = append(opts, "ANALYZE")
+	}
+	if asJSON {
+		opts = append(opts, "FORMAT JSON")
+	}
+	if len(opts) > 0 {
+		sql = "EXPLAIN (" + strings.Join(opts, ", ") + ") " + sql
+	} else {
+		sql = "EXPLAIN " + sql
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	// The plan is always requested as text, whatever Config.ResultFormat.
+	buf := appendParse(nil, "", sql)
+	buf = appendBind(buf, "", "", params, FormatText)
+	buf = appendExecute(buf, "", 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	return c.readRows()
+}
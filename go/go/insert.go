@@ -0,0 +1,87 @@
+package qail
+
+import "fmt"
+
+// InsertMany inserts rows into table with as few multi-row INSERTs as
+// MaxParams allows (see AddMany) and returns the number of rows inserted.
+// With returning columns, e.g. "id", each statement gets a RETURNING
+// clause and the returned rows are collected in insertion order.
+//
+// When the rows need more than one statement, they all run in one
+// transaction, so either every row is inserted or none is.
+func (d *Driver) InsertMany(table string, columns []string, rows [][]any, returning ...string) (n int64, returned []Row, err error) {
+	if len(rows) == 0 {
+		return 0, nil, nil
+	}
+	if len(columns) == 0 {
+		return 0, nil, fmt.Errorf("InsertMany: no columns")
+	}
+	if d.tracer != nil {
+		q := d.traceStart("InsertMany", "INSERT INTO "+table)
+		defer func() { d.traceEnd(q, int(n), err) }()
+	}
+
+	perStmt := MaxParams / len(columns)
+	if perStmt == 0 {
+		return 0, nil, fmt.Errorf("%w: a row of %d columns exceeds the limit of %d", ErrTooManyParams, len(columns), MaxParams)
+	}
+
+	// Encode every chunk up front, so an encoding error sends nothing.
+	var wires [][]byte
+	for start := 0; start < len(rows); start += perStmt {
+		end := min(start+perStmt, len(rows))
+		cmd := AddMany(table, columns, rows[start:end])
+		if len(returning) > 0 {
+			cmd.Returning(returning...)
+		}
+		wire := cmd.Encode()
+		if len(wire) == 0 {
+			err := encodeError(cmd)
+			cmd.Free()
+			return 0, nil, fmt.Errorf("rows %d-%d: %w", start, end-1, err)
+		}
+		cmd.Free()
+		wires = append(wires, wire)
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer d.putConn(c)
+
+	if len(wires) > 1 {
+		if err := c.simpleExec("BEGIN"); err != nil {
+			return 0, nil, err
+		}
+	}
+	for _, wire := range wires {
+		if c.resultFormat != FormatText {
+			wire = setResultFormat(wire, c.resultFormat)
+		}
+		if _, err = c.conn.Write(wire); err != nil {
+			err = fmt.Errorf("write failed: %w", err)
+			break
+		}
+		var res *Result
+		if res, err = c.readResult(nil); err != nil {
+			break
+		}
+		n += res.RowsAffected()
+		returned = append(returned, res.Rows...)
+	}
+
+	if len(wires) > 1 {
+		end := "COMMIT"
+		if err != nil {
+			end = "ROLLBACK"
+		}
+		if endErr := c.simpleExec(end); endErr != nil && err == nil {
+			err = endErr
+		}
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	return n, returned, nil
+}
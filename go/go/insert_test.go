@@ -0,0 +1,108 @@
+package qail
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestAddManyTooManyParams(t *testing.T) {
+	rows := make([][]any, MaxParams/2+1)
+	for i := range rows {
+		rows[i] = []any{i, "x"}
+	}
+	cmd := AddMany("items", []string{"id", "name"}, rows)
+	defer cmd.Free()
+	if err := cmd.Err(); !errors.Is(err, ErrTooManyParams) {
+		t.Errorf("err = %v, want ErrTooManyParams", err)
+	}
+}
+
+func TestAddManyValueTypes(t *testing.T) {
+	cmd := AddMany("files", []string{"small", "ratio", "data", "empty", "none"},
+		[][]any{{int16(-7), float32(0.5), []byte{0x00, 0xff, '\\'}, []byte{}, []byte(nil)}})
+	defer cmd.Free()
+	if err := cmd.Err(); err != nil {
+		t.Fatal(err)
+	}
+	binds := boundParams(t, cmd.Encode())
+	if len(binds) != 1 {
+		t.Fatalf("got %d Bind messages, want 1", len(binds))
+	}
+	want := [][]byte{[]byte("-7"), []byte("0.5"), []byte(`\x00ff5c`), []byte(`\x`), nil}
+	if !reflect.DeepEqual(binds[0], want) {
+		t.Errorf("params = %q, want %q", binds[0], want)
+	}
+}
+
+func TestInsertManyChunks(t *testing.T) {
+	columns := []string{"id", "name"}
+	rows := make([][]any, MaxParams/len(columns)+1) // one row too many for one statement
+	for i := range rows {
+		rows[i] = []any{i, fmt.Sprint("item", i)}
+	}
+
+	for _, tt := range []struct {
+		name     string
+		failLast bool
+	}{
+		{"committed", false},
+		{"rolled back", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qailtest.NewServer()
+			srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+			srv.Handle("COMMIT", qailtest.Response{Tag: "COMMIT"})
+			srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+			inserts := 0
+			srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+				if q.Args == nil { // Describe
+					return qailtest.Response{}, true
+				}
+				inserts++
+				if tt.failLast && len(q.Args) < MaxParams-1 {
+					return qailtest.Response{Err: &qailtest.Error{Code: "23505", Message: "duplicate key value"}}, true
+				}
+				return qailtest.Response{Tag: fmt.Sprintf("INSERT 0 %d", len(q.Args)/len(columns))}, true
+			})
+			d := fakeDriver(t, srv, Config{})
+
+			n, _, err := d.InsertMany("items", columns, rows)
+			var verbs []string
+			for _, q := range srv.Queries() {
+				if q.Args == nil {
+					verbs = append(verbs, q.SQL)
+				} else {
+					verbs = append(verbs, fmt.Sprintf("insert(%d)", len(q.Args)))
+				}
+			}
+			if tt.failLast {
+				var pgErr *PgError
+				if !errors.As(err, &pgErr) || pgErr.Code != "23505" || n != 0 {
+					t.Fatalf("n, err = %d, %v; want 0 and the server's 23505 error", n, err)
+				}
+				want := fmt.Sprint([]string{"BEGIN", "insert(65534)", "insert(2)", "ROLLBACK"})
+				if fmt.Sprint(verbs) != want {
+					t.Errorf("statements = %v, want %v", verbs, want)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if n != int64(len(rows)) {
+				t.Errorf("inserted %d rows, want %d", n, len(rows))
+			}
+			want := fmt.Sprint([]string{"BEGIN", "insert(65534)", "insert(2)", "COMMIT"})
+			if fmt.Sprint(verbs) != want {
+				t.Errorf("statements = %v, want %v", verbs, want)
+			}
+			if inserts != 2 {
+				t.Errorf("%d INSERT statements, want 2", inserts)
+			}
+		})
+	}
+}
@@ -3,23 +3,57 @@
 package ipc
 
 import (
+	"bufio"
+	"context"
 	"encoding/binary"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
 	DefaultSocketPath = "/tmp/qail.sock"
 	MaxMessageSize    = 16 * 1024 * 1024 // 16MB
+
+	// readBufferSize bounds the buffer responses are streamed through.
+	readBufferSize = 32 * 1024
 )
 
+// ErrNoRows is returned by QueryOne when the query returns no rows.
+var ErrNoRows = errors.New("no rows in result set")
+
+// ErrDaemonShutdown is returned when qail-daemon answered with a Shutdown
+// notice instead of a response. The request was not executed, and the
+// Client is unusable afterwards: callers should Connect again (to a
+// restarted daemon) and retry.
+var ErrDaemonShutdown = errors.New("qail-daemon is shutting down")
+
+// ErrClientClosed is returned by requests made once Close or
+// CloseContext has begun.
+var ErrClientClosed = errors.New("ipc client is closed")
+
 // Client is a connection to qail-daemon
 type Client struct {
 	conn net.Conn
+	r    *bufio.Reader // reused for every response
 	mu   sync.Mutex
+
+	// stmts maps SQL to its prepared handle for PrepareCached. Guarded by
+	// mu and cleared by ConnectPG, since handles belong to one session.
+	stmts map[string]string
+
+	shutdown bool // daemon sent Shutdown; guarded by mu
+
+	closing atomic.Bool // Close has begun: refuse new requests
+
+	metrics Metrics // nil unless created by ConnectMetrics
 }
 
 // Request types
@@ -36,6 +70,16 @@ type Query struct {
 	Columns []string `json:"columns"`
 	Filter  string   `json:"filter,omitempty"`
 	Limit   int64    `json:"limit,omitempty"`
+
+	// IncludeTypes asks the daemon for column names and type OIDs,
+	// returned in QueryResult.Columns. Ignored by Pipeline.
+	IncludeTypes bool `json:"include_types,omitempty"`
+
+	// SQL, if set, runs in place of the GET, with Params bound to $1..$n
+	// as text, as for Client.Query. Pipeline only: it lets one pipeline
+	// mix reads and writes.
+	SQL    string `json:"sql,omitempty"`
+	Params []any  `json:"-"`
 }
 
 // Response types
@@ -54,31 +98,99 @@ type Row struct {
 type QueryResult struct {
 	Rows     []Row  `json:"rows"`
 	Affected uint64 `json:"affected"`
+
+	// Columns is set only when the request had include_types. It is
+	// empty if the result had no rows.
+	Columns []ColumnMeta `json:"columns,omitempty"`
+}
+
+// PipelineResult is the outcome of one query in PipelineEach.
+type PipelineResult struct {
+	Rows     []Row
+	Affected uint64 // rows affected; for a SELECT, rows returned
+	Err      error  // why this query failed; the other queries still ran
+}
+
+// PipelineResults holds one PipelineResult per query, in query order.
+type PipelineResults []PipelineResult
+
+// Err returns the first query's error, or nil if every query succeeded.
+func (r PipelineResults) Err() error {
+	for i := range r {
+		if r[i].Err != nil {
+			return r[i].Err
+		}
+	}
+	return nil
+}
+
+// ColumnMeta describes one result column from the RowDescription.
+type ColumnMeta struct {
+	Name string `json:"name"`
+	OID  uint32 `json:"oid"`
 }
 
 // Connect creates a new connection to qail-daemon
 func Connect(socketPath string) (*Client, error) {
+	return ConnectMetrics(socketPath, nil)
+}
+
+// ConnectMetrics is Connect reporting the dial, and then every request of
+// the returned Client, to m. A nil m is the same as Connect.
+func ConnectMetrics(socketPath string, m Metrics) (*Client, error) {
 	if socketPath == "" {
 		socketPath = DefaultSocketPath
 	}
 
 	conn, err := net.Dial("unix", socketPath)
+	if m != nil {
+		m.Connect(err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to qail-daemon: %w", err)
 	}
 
-	return &Client{conn: conn}, nil
+	return &Client{conn: conn, r: bufio.NewReaderSize(conn, readBufferSize), metrics: m}, nil
 }
 
 // Close closes the connection
 func (c *Client) Close() error {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.CloseContext(context.Background())
+}
 
-	// Send close request
-	req := map[string]any{"Close": struct{}{}}
-	c.sendRequest(req)
+// CloseContext closes the connection gracefully: it refuses new requests,
+// waits for the request in flight (if any) to get its response, then tells
+// the daemon to close the session and shuts the socket. If ctx ends first,
+// the socket is closed at once, failing the in-flight request, and
+// ctx.Err() is returned.
+func (c *Client) CloseContext(ctx context.Context) error {
+	if c.closing.Swap(true) {
+		return nil
+	}
+
+	locked := make(chan struct{})
+	go func() {
+		c.mu.Lock()
+		close(locked)
+	}()
+	select {
+	case <-locked:
+	case <-ctx.Done():
+		c.conn.Close()
+		<-locked
+		c.mu.Unlock()
+		return ctx.Err()
+	}
+	defer c.mu.Unlock()
 
+	if !c.shutdown {
+		// The daemon acknowledges Close, so wait for that (bounded by
+		// ctx) before closing the socket.
+		if deadline, ok := ctx.Deadline(); ok {
+			c.conn.SetDeadline(deadline)
+		}
+		c.roundTrip(map[string]any{"type": "Close"})
+	}
 	return c.conn.Close()
 }
 
@@ -87,7 +199,7 @@ func (c *Client) Ping() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
-	req := map[string]any{"Ping": struct{}{}}
+	req := map[string]any{"type": "Ping"}
 	resp, err := c.sendRequest(req)
 	if err != nil {
 		return err
@@ -117,6 +229,9 @@ func (c *Client) ConnectPG(host string, port int, user, database, password strin
 		return err
 	}
 
+	// The daemon's session changed (or may have), so old handles are stale.
+	c.stmts = nil
+
 	if resp["type"] == "Connected" {
 		return nil
 	}
@@ -128,6 +243,16 @@ func (c *Client) ConnectPG(host string, port int, user, database, password strin
 
 // Get executes a QAIL GET query (SELECT)
 func (c *Client) Get(table string, columns []string, limit int64) (*QueryResult, error) {
+	return c.get(table, columns, limit, false)
+}
+
+// GetWithTypes is like Get but also returns the column names and type
+// OIDs in QueryResult.Columns, so values can be scanned by type.
+func (c *Client) GetWithTypes(table string, columns []string, limit int64) (*QueryResult, error) {
+	return c.get(table, columns, limit, true)
+}
+
+func (c *Client) get(table string, columns []string, limit int64, includeTypes bool) (*QueryResult, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
@@ -137,6 +262,9 @@ func (c *Client) Get(table string, columns []string, limit int64) (*QueryResult,
 		"columns": columns,
 		"limit":   limit,
 	}
+	if includeTypes {
+		req["include_types"] = true
+	}
 
 	resp, err := c.sendRequest(req)
 	if err != nil {
@@ -154,16 +282,21 @@ func (c *Client) Get(table string, columns []string, limit int64) (*QueryResult,
 	return nil, fmt.Errorf("unexpected response: %v", resp)
 }
 
-// Query executes a single query
+// Query executes a single SQL statement, binding params to $1..$n as
+// text; see textParams for the supported types.
 func (c *Client) Query(sql string, params ...any) (*QueryResult, error) {
+	text, err := textParams(params)
+	if err != nil {
+		return nil, err
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	req := map[string]any{
-		"Query": map[string]any{
-			"sql":    sql,
-			"params": params,
-		},
+		"type":   "Query",
+		"sql":    sql,
+		"params": text,
 	}
 
 	resp, err := c.sendRequest(req)
@@ -171,17 +304,30 @@ func (c *Client) Query(sql string, params ...any) (*QueryResult, error) {
 		return nil, err
 	}
 
-	if results, ok := resp["Results"].(map[string]any); ok {
-		return parseQueryResult(results), nil
+	if resp["type"] == "Results" {
+		return parseQueryResult(resp), nil
 	}
 
-	if errMsg, ok := resp["Error"].(map[string]any); ok {
-		return nil, fmt.Errorf("query failed: %s", errMsg["message"])
+	if resp["type"] == "Error" {
+		return nil, fmt.Errorf("query failed: %v", resp["message"])
 	}
 
 	return nil, fmt.Errorf("unexpected response: %v", resp)
 }
 
+// QueryOne executes a SQL query and returns its first row, or ErrNoRows
+// if the result is empty.
+func (c *Client) QueryOne(sql string, params ...any) (*Row, error) {
+	res, err := c.Query(sql, params...)
+	if err != nil {
+		return nil, err
+	}
+	if len(res.Rows) == 0 {
+		return nil, ErrNoRows
+	}
+	return &res.Rows[0], nil
+}
+
 // QueryBatch executes multiple queries in a single IPC call
 func (c *Client) QueryBatch(queries []Query) ([]QueryResult, error) {
 	c.mu.Lock()
@@ -216,14 +362,82 @@ func (c *Client) QueryBatch(queries []Query) ([]QueryResult, error) {
 	return nil, fmt.Errorf("unexpected response: %v", resp)
 }
 
-// Pipeline executes multiple queries using PostgreSQL pipeline mode (true async)
+// Pipeline executes multiple queries using PostgreSQL pipeline mode (true async).
+// It fails with the first query's error if any query failed; PipelineEach
+// reports each query's outcome instead.
 func (c *Client) Pipeline(queries []Query) ([]QueryResult, error) {
+	results, err := c.pipeline(queries)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]QueryResult, len(results))
+	for i, m := range results {
+		if msg, ok := m["error"].(string); ok {
+			return nil, fmt.Errorf("pipeline query %d failed: %s", i+1, msg)
+		}
+		out[i] = *parseQueryResult(m)
+	}
+	return out, nil
+}
+
+// PipelineEach executes queries in one pipeline, each isolated from the
+// others: a query that fails reports its error in its PipelineResult, and
+// the queries after it still run. Use Query.SQL to mix writes in:
+//
+//	res, err := client.PipelineEach([]ipc.Query{
+//		{SQL: "UPDATE accounts SET active = false WHERE id = $1", Params: []any{7}},
+//		{Table: "accounts", Columns: []string{"id", "name"}},
+//	})
+//	if err != nil { ... } // the pipeline itself failed
+//	for i, r := range res {
+//		if r.Err != nil { ... } // queries[i] failed
+//	}
+//
+// Each query runs in its own implicit transaction.
+func (c *Client) PipelineEach(queries []Query) (PipelineResults, error) {
+	results, err := c.pipeline(queries)
+	if err != nil {
+		return nil, err
+	}
+	out := make(PipelineResults, len(results))
+	for i, m := range results {
+		if msg, ok := m["error"].(string); ok {
+			out[i].Err = fmt.Errorf("pipeline query %d failed: %s", i+1, msg)
+			continue
+		}
+		res := parseQueryResult(m)
+		out[i].Rows = res.Rows
+		out[i].Affected = res.Affected
+	}
+	return out, nil
+}
+
+// pipeline sends queries as one Pipeline request and returns the raw
+// results in query order.
+func (c *Client) pipeline(queries []Query) ([]map[string]any, error) {
+	// Tag each query with its 1-based position so results can be matched
+	// back to queries regardless of the order the daemon returns them in.
+	tagged := make([]pipelineQuery, len(queries))
+	for i, q := range queries {
+		if q.Columns == nil {
+			q.Columns = []string{} // the daemon rejects null
+		}
+		tagged[i] = pipelineQuery{Query: q, ID: uint64(i + 1)}
+		if len(q.Params) > 0 {
+			params, err := textParams(q.Params)
+			if err != nil {
+				return nil, fmt.Errorf("pipeline query %d: %w", i+1, err)
+			}
+			tagged[i].Params = params
+		}
+	}
+
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	req := map[string]any{
 		"type":    "Pipeline",
-		"queries": queries,
+		"queries": tagged,
 	}
 
 	resp, err := c.sendRequest(req)
@@ -233,13 +447,7 @@ func (c *Client) Pipeline(queries []Query) ([]QueryResult, error) {
 
 	if resp["type"] == "BatchResults" {
 		if results, ok := resp["results"].([]any); ok {
-			out := make([]QueryResult, len(results))
-			for i, r := range results {
-				if m, ok := r.(map[string]any); ok {
-					out[i] = *parseQueryResult(m)
-				}
-			}
-			return out, nil
+			return correlateResults(results, len(queries))
 		}
 	}
 
@@ -250,6 +458,93 @@ func (c *Client) Pipeline(queries []Query) ([]QueryResult, error) {
 	return nil, fmt.Errorf("unexpected response: %v", resp)
 }
 
+// pipelineQuery is a Query with the client-assigned id that the daemon
+// echoes in the matching result, and its Params in text form.
+type pipelineQuery struct {
+	Query
+	ID     uint64    `json:"id"`
+	Params []*string `json:"params,omitempty"`
+}
+
+// textParams converts params to the text form the daemon binds, following
+// the driver's text encoding: nil is NULL, []byte is sent as bytea hex,
+// and time.Time as a timestamp with its zone offset. Other types than
+// string, bool, int, int16, int32, int64, uint32, float32 and float64 are
+// rejected.
+func textParams(params []any) ([]*string, error) {
+	out := make([]*string, len(params))
+	for i, p := range params {
+		var text string
+		switch v := p.(type) {
+		case nil:
+			continue
+		case string:
+			text = v
+		case []byte:
+			if v == nil {
+				continue
+			}
+			// JSON strings must be valid UTF-8, so bytes go as bytea's
+			// hex input format.
+			text = `\x` + hex.EncodeToString(v)
+		case int:
+			text = strconv.FormatInt(int64(v), 10)
+		case int16:
+			text = strconv.FormatInt(int64(v), 10)
+		case int32:
+			text = strconv.FormatInt(int64(v), 10)
+		case int64:
+			text = strconv.FormatInt(v, 10)
+		case uint32:
+			text = strconv.FormatUint(uint64(v), 10)
+		case float32:
+			text = strconv.FormatFloat(float64(v), 'g', -1, 32)
+		case float64:
+			text = strconv.FormatFloat(v, 'g', -1, 64)
+		case bool:
+			text = "f"
+			if v {
+				text = "t"
+			}
+		case time.Time:
+			text = v.Format("2006-01-02 15:04:05.999999Z07:00")
+		default:
+			return nil, fmt.Errorf("parameter $%d: unsupported type %T", i+1, p)
+		}
+		out[i] = &text
+	}
+	return out, nil
+}
+
+// correlateResults places each result at the index of the query whose id
+// it carries. It fails if the count differs from n or an id is missing,
+// out of range or repeated, rather than misattribute rows.
+func correlateResults(results []any, n int) ([]map[string]any, error) {
+	if len(results) != n {
+		return nil, fmt.Errorf("pipeline returned %d results for %d queries", len(results), n)
+	}
+	out := make([]map[string]any, n)
+	for _, r := range results {
+		m, ok := r.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("unexpected pipeline result: %v", r)
+		}
+		id, ok := m["id"].(float64)
+		if !ok {
+			return nil, fmt.Errorf("pipeline result without query id")
+		}
+		i := int(id) - 1
+		if i < 0 || i >= n || float64(i+1) != id {
+			return nil, fmt.Errorf("pipeline result has unknown query id %v", id)
+		}
+		if out[i] != nil {
+			return nil, fmt.Errorf("pipeline returned query id %d twice", i+1)
+		}
+		out[i] = m
+	}
+	return out, nil
+}
+
 // PipelineFast executes multiple queries using PostgreSQL pipeline mode (count only)
 // This matches native Rust benchmark performance (no row parsing overhead)
 func (c *Client) PipelineFast(queries []Query) (int, error) {
@@ -285,6 +580,49 @@ func (c *Client) Prepare(sql string) (string, error) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.prepare(sql)
+}
+
+// PrepareCached is like Prepare but returns the handle from an earlier
+// call with the same SQL instead of preparing again. The cache is reset
+// by ConnectPG.
+func (c *Client) PrepareCached(sql string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.prepareCached(sql)
+}
+
+func (c *Client) prepareCached(sql string) (string, error) {
+	if handle, ok := c.stmts[sql]; ok {
+		return handle, nil
+	}
+	handle, err := c.prepare(sql)
+	if err != nil {
+		return "", err
+	}
+	if c.stmts == nil {
+		c.stmts = make(map[string]string)
+	}
+	c.stmts[sql] = handle
+	return handle, nil
+}
+
+// QueryPrepared runs sql once per entry of paramsBatch through
+// PreparedPipeline, preparing it on first use via the PrepareCached cache.
+// It returns the number of rows, as PreparedPipeline does.
+func (c *Client) QueryPrepared(sql string, paramsBatch [][]string) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	handle, err := c.prepareCached(sql)
+	if err != nil {
+		return 0, err
+	}
+	return c.preparedPipeline(handle, paramsBatch)
+}
+
+func (c *Client) prepare(sql string) (string, error) {
 	req := map[string]any{
 		"type": "Prepare",
 		"sql":  sql,
@@ -314,6 +652,10 @@ func (c *Client) PreparedPipeline(handle string, paramsBatch [][]string) (int, e
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	return c.preparedPipeline(handle, paramsBatch)
+}
+
+func (c *Client) preparedPipeline(handle string, paramsBatch [][]string) (int, error) {
 	req := map[string]any{
 		"type":         "PreparedPipeline",
 		"handle":       handle,
@@ -339,6 +681,29 @@ func (c *Client) PreparedPipeline(handle string, paramsBatch [][]string) (int, e
 }
 
 func (c *Client) sendRequest(req any) (map[string]any, error) {
+	if c.closing.Load() {
+		return nil, ErrClientClosed
+	}
+	return c.roundTrip(req)
+}
+
+// roundTrip writes req and reads its response, reporting to c.metrics.
+// mu must be held.
+func (c *Client) roundTrip(req any) (map[string]any, error) {
+	if c.metrics == nil {
+		return c.exchange(req)
+	}
+	start := time.Now()
+	resp, err := c.exchange(req)
+	c.metrics.Request(requestType(req), time.Since(start), err)
+	return resp, err
+}
+
+func (c *Client) exchange(req any) (map[string]any, error) {
+	if c.shutdown {
+		return nil, ErrDaemonShutdown
+	}
+
 	// Encode request
 	data, err := json.Marshal(req)
 	if err != nil {
@@ -355,9 +720,12 @@ func (c *Client) sendRequest(req any) (map[string]any, error) {
 	if _, err := c.conn.Write(data); err != nil {
 		return nil, fmt.Errorf("failed to write data: %w", err)
 	}
+	if c.metrics != nil {
+		c.metrics.BytesSent(len(lenBuf) + len(data))
+	}
 
 	// Read response length (must read exactly 4 bytes)
-	if _, err := io.ReadFull(c.conn, lenBuf); err != nil {
+	if _, err := io.ReadFull(c.r, lenBuf); err != nil {
 		return nil, fmt.Errorf("failed to read response length: %w", err)
 	}
 	respLen := binary.BigEndian.Uint32(lenBuf)
@@ -365,17 +733,29 @@ func (c *Client) sendRequest(req any) (map[string]any, error) {
 	if respLen > MaxMessageSize {
 		return nil, fmt.Errorf("response too large: %d bytes", respLen)
 	}
+	if c.metrics != nil {
+		c.metrics.BytesReceived(len(lenBuf) + int(respLen))
+	}
 
-	// Read response (must read exactly respLen bytes)
-	respData := make([]byte, respLen)
-	if _, err := io.ReadFull(c.conn, respData); err != nil {
+	// Decode straight from the socket rather than allocating respLen bytes
+	// first. The limit keeps the decoder inside this frame.
+	body := io.LimitReader(c.r, int64(respLen))
+	var resp map[string]any
+	decErr := json.NewDecoder(body).Decode(&resp)
+
+	// Consume whatever the decoder left (trailing whitespace, or the rest
+	// of a malformed body) so the next response starts on a frame boundary.
+	if _, err := io.Copy(io.Discard, body); err != nil {
 		return nil, fmt.Errorf("failed to read response: %w", err)
 	}
+	if decErr != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", decErr)
+	}
 
-	// Decode response
-	var resp map[string]any
-	if err := json.Unmarshal(respData, &resp); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	// The daemon closes the socket right after a Shutdown notice.
+	if resp["type"] == "Shutdown" {
+		c.shutdown = true
+		return nil, ErrDaemonShutdown
 	}
 
 	return resp, nil
@@ -395,8 +775,25 @@ func parseQueryResult(m map[string]any) *QueryResult {
 		}
 	}
 
-	if affected, ok := m["affected"].(uint64); ok {
-		result.Affected = affected
+	// encoding/json decodes every number into a float64.
+	if affected, ok := m["affected"].(float64); ok {
+		result.Affected = uint64(affected)
+	}
+
+	if cols, ok := m["columns"].([]any); ok {
+		result.Columns = make([]ColumnMeta, 0, len(cols))
+		for _, col := range cols {
+			colMap, ok := col.(map[string]any)
+			if !ok {
+				continue
+			}
+			meta := ColumnMeta{}
+			meta.Name, _ = colMap["name"].(string)
+			if oid, ok := colMap["oid"].(float64); ok {
+				meta.OID = uint32(oid)
+			}
+			result.Columns = append(result.Columns, meta)
+		}
 	}
 
 	return result
@@ -0,0 +1,330 @@
+package ipc
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeDaemon returns a Client talking to a fake qail-daemon over a pipe.
+// Each request is decoded and passed to handle, whose result is sent back
+// as the response; a nil result sends nothing, leaving the request
+// waiting.
+func fakeDaemon(t *testing.T, handle func(req map[string]any) any) *Client {
+	t.Helper()
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		r := bufio.NewReader(server)
+		for {
+			var hdr [4]byte
+			if _, err := io.ReadFull(r, hdr[:]); err != nil {
+				return
+			}
+			body := make([]byte, binary.BigEndian.Uint32(hdr[:]))
+			if _, err := io.ReadFull(r, body); err != nil {
+				return
+			}
+			var req map[string]any
+			if err := json.Unmarshal(body, &req); err != nil {
+				t.Errorf("fake daemon: bad request %q: %v", body, err)
+				return
+			}
+			resp := handle(req)
+			if resp == nil {
+				continue
+			}
+			data, err := json.Marshal(resp)
+			if err != nil {
+				t.Errorf("fake daemon: %v", err)
+				return
+			}
+			binary.BigEndian.PutUint32(hdr[:], uint32(len(data)))
+			if _, err := server.Write(append(hdr[:], data...)); err != nil {
+				return
+			}
+		}
+	}()
+	t.Cleanup(func() { client.Close() })
+	return &Client{conn: client, r: bufio.NewReaderSize(client, readBufferSize)}
+}
+
+func TestPing(t *testing.T) {
+	c := fakeDaemon(t, func(req map[string]any) any {
+		if req["type"] != "Ping" {
+			return map[string]any{"type": "Error", "message": "unknown request"}
+		}
+		return map[string]any{"type": "Pong"}
+	})
+	if err := c.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestQueryOne(t *testing.T) {
+	var got map[string]any
+	c := fakeDaemon(t, func(req map[string]any) any {
+		got = req
+		switch req["sql"] {
+		case "SELECT name FROM users WHERE id = $1 AND team = $2":
+			return map[string]any{
+				"type":     "Results",
+				"rows":     []any{map[string]any{"columns": []any{"ada"}}},
+				"affected": 1,
+			}
+		case "SELECT 1 WHERE false":
+			return map[string]any{"type": "Results", "rows": []any{}, "affected": 0}
+		}
+		return map[string]any{"type": "Error", "message": "syntax error"}
+	})
+
+	row, err := c.QueryOne("SELECT name FROM users WHERE id = $1 AND team = $2", 7, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{
+		"type":   "Query",
+		"sql":    "SELECT name FROM users WHERE id = $1 AND team = $2",
+		"params": []any{"7", nil},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("request = %v, want %v", got, want)
+	}
+	if !reflect.DeepEqual(row.Columns, []any{"ada"}) {
+		t.Errorf("row = %v, want [ada]", row.Columns)
+	}
+
+	if _, err := c.QueryOne("SELECT 1 WHERE false"); !errors.Is(err, ErrNoRows) {
+		t.Errorf("empty result: err = %v, want ErrNoRows", err)
+	}
+	if _, err := c.QueryOne("SELEC 1"); err == nil || err.Error() != "query failed: syntax error" {
+		t.Errorf("failed query: err = %v", err)
+	}
+}
+
+func TestCorrelateResults(t *testing.T) {
+	result := func(id any) map[string]any {
+		return map[string]any{"id": id, "rows": []any{}}
+	}
+	tests := []struct {
+		name    string
+		results []any
+		n       int
+		wantErr string
+	}{
+		{"count mismatch", []any{result(1.0)}, 2, "pipeline returned 1 results for 2 queries"},
+		{"not an object", []any{"oops"}, 1, "unexpected pipeline result: oops"},
+		{"missing id", []any{map[string]any{"rows": []any{}}}, 1, "pipeline result without query id"},
+		{"non-numeric id", []any{result("1")}, 1, "pipeline result without query id"},
+		{"non-integral id", []any{result(1.5), result(2.0)}, 2, "pipeline result has unknown query id 1.5"},
+		{"zero id", []any{result(0.0)}, 1, "pipeline result has unknown query id 0"},
+		{"id out of range", []any{result(1.0), result(3.0)}, 2, "pipeline result has unknown query id 3"},
+		{"duplicate id", []any{result(2.0), result(2.0)}, 2, "pipeline returned query id 2 twice"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := correlateResults(tt.results, tt.n)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("reordered", func(t *testing.T) {
+		first, second := result(1.0), result(2.0)
+		out, err := correlateResults([]any{second, first}, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out[0]["id"] != 1.0 || out[1]["id"] != 2.0 {
+			t.Errorf("ids = %v, %v; want 1, 2", out[0]["id"], out[1]["id"])
+		}
+	})
+}
+
+func TestShutdownNotice(t *testing.T) {
+	requests := 0
+	c := fakeDaemon(t, func(req map[string]any) any {
+		requests++
+		return map[string]any{"type": "Shutdown"}
+	})
+	if err := c.Ping(); !errors.Is(err, ErrDaemonShutdown) {
+		t.Fatalf("Ping: err = %v, want ErrDaemonShutdown", err)
+	}
+	// The Client is unusable afterwards and does not send again.
+	if err := c.Ping(); !errors.Is(err, ErrDaemonShutdown) {
+		t.Errorf("second Ping: err = %v, want ErrDaemonShutdown", err)
+	}
+	if requests != 1 {
+		t.Errorf("daemon got %d requests, want 1", requests)
+	}
+}
+
+func TestCloseContextExpired(t *testing.T) {
+	received := make(chan struct{})
+	c := fakeDaemon(t, func(req map[string]any) any {
+		close(received)
+		return nil // never answer
+	})
+
+	pingErr := make(chan error)
+	go func() { pingErr <- c.Ping() }()
+	<-received
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := c.CloseContext(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("CloseContext: err = %v, want DeadlineExceeded", err)
+	}
+	// Closing the socket fails the request that was waiting on it.
+	select {
+	case err := <-pingErr:
+		if err == nil {
+			t.Error("in-flight Ping succeeded after the socket was closed")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("in-flight Ping still blocked after CloseContext")
+	}
+	if err := c.Ping(); !errors.Is(err, ErrClientClosed) {
+		t.Errorf("Ping after close: err = %v, want ErrClientClosed", err)
+	}
+}
+
+func TestTextParams(t *testing.T) {
+	at := time.Date(2024, 3, 9, 14, 5, 6, 789000000, time.FixedZone("", 2*60*60))
+	text, err := textParams([]any{
+		nil, "ada", []byte{0x00, 0xff, '\\'}, []byte(nil),
+		-7, int16(8), int32(9), int64(1) << 40, uint32(4000000000),
+		float32(0.1), 2.5, true, false,
+		at, at.Add(-789 * time.Millisecond).In(time.UTC),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []any{
+		nil, "ada", `\x00ff5c`, nil,
+		"-7", "8", "9", "1099511627776", "4000000000",
+		"0.1", "2.5", "t", "f",
+		"2024-03-09 14:05:06.789+02:00", "2024-03-09 12:05:06Z",
+	}
+	if len(text) != len(want) {
+		t.Fatalf("got %d params, want %d", len(text), len(want))
+	}
+	for i, p := range text {
+		var got any
+		if p != nil {
+			got = *p
+		}
+		if got != want[i] {
+			t.Errorf("param %d = %v, want %v", i+1, got, want[i])
+		}
+	}
+
+	for _, p := range []any{uint64(1), struct{}{}, []int{1}} {
+		if _, err := textParams([]any{"ok", p}); err == nil || !strings.HasPrefix(err.Error(), "parameter $2: unsupported type") {
+			t.Errorf("%T: err = %v, want unsupported type", p, err)
+		}
+	}
+}
+
+func TestUnsupportedParamNotSent(t *testing.T) {
+	requests := 0
+	c := fakeDaemon(t, func(req map[string]any) any {
+		requests++
+		return map[string]any{"type": "Error", "message": "unexpected request"}
+	})
+	if _, err := c.Query("SELECT $1", map[string]int{}); err == nil {
+		t.Error("Query accepted a map parameter")
+	}
+	queries := []Query{
+		{SQL: "SELECT 1"},
+		{SQL: "UPDATE t SET v = $1", Params: []any{complex(1, 2)}},
+	}
+	if _, err := c.PipelineEach(queries); err == nil || !strings.HasPrefix(err.Error(), "pipeline query 2: parameter $1") {
+		t.Errorf("PipelineEach: err = %v, want it to name query 2", err)
+	}
+	if requests != 0 {
+		t.Errorf("daemon got %d requests, want none", requests)
+	}
+}
+
+// largeResults is a Results response with n two-column rows.
+func largeResults(n int) map[string]any {
+	rows := make([]any, n)
+	for i := range rows {
+		rows[i] = map[string]any{"columns": []any{i, "harbor"}}
+	}
+	return map[string]any{"type": "Results", "rows": rows}
+}
+
+func TestQueryLargeResponse(t *testing.T) {
+	c := fakeDaemon(t, func(req map[string]any) any {
+		if req["type"] == "Ping" {
+			return map[string]any{"type": "Pong"}
+		}
+		return largeResults(10_000)
+	})
+	res, err := c.Query("SELECT id, name FROM harbors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Rows) != 10_000 {
+		t.Fatalf("got %d rows, want 10000", len(res.Rows))
+	}
+	if last := res.Rows[9_999].Columns; last[0] != float64(9_999) || last[1] != "harbor" {
+		t.Errorf("last row = %v", last)
+	}
+	// The response spanned many buffer fills; the next one still starts
+	// on a frame boundary.
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping after a large response: %v", err)
+	}
+}
+
+// BenchmarkQueryLargeResponse decodes a 10,000-row response, several
+// times the client's read buffer, from a daemon that sends the same
+// prebuilt frame for every request.
+func BenchmarkQueryLargeResponse(b *testing.B) {
+	data, err := json.Marshal(largeResults(10_000))
+	if err != nil {
+		b.Fatal(err)
+	}
+	frame := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	frame = append(frame, data...)
+
+	client, server := net.Pipe()
+	go func() {
+		defer server.Close()
+		var hdr [4]byte
+		for {
+			if _, err := io.ReadFull(server, hdr[:]); err != nil {
+				return
+			}
+			if _, err := io.CopyN(io.Discard, server, int64(binary.BigEndian.Uint32(hdr[:]))); err != nil {
+				return
+			}
+			if _, err := server.Write(frame); err != nil {
+				return
+			}
+		}
+	}()
+	c := &Client{conn: client, r: bufio.NewReaderSize(client, readBufferSize)}
+	b.Cleanup(func() { client.Close() })
+
+	b.SetBytes(int64(len(frame)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Query("SELECT id, name FROM harbors"); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
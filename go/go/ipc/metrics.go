@@ -0,0 +1,106 @@
+package ipc
+
+import (
+	"expvar"
+	"time"
+)
+
+// Metrics receives Client activity, for monitoring a service's use of
+// qail-daemon. Set it with ConnectMetrics; a Client without one does no
+// bookkeeping. Implementations must be safe for concurrent use, since one
+// Metrics is typically shared by every Client of a process.
+//
+// NewExpvarMetrics is the built-in implementation; the metrics module
+// provides a Prometheus one (metrics.NewIPCCollector).
+type Metrics interface {
+	// Request is called once per daemon round-trip. typ is the request
+	// type, e.g. "Get" or "Pipeline"; elapsed covers the write and the
+	// whole response. err is set only if the round-trip itself failed: an
+	// Error reply from the daemon is a completed round-trip.
+	Request(typ string, elapsed time.Duration, err error)
+	// BytesSent and BytesReceived count socket traffic, frame headers
+	// included.
+	BytesSent(n int)
+	BytesReceived(n int)
+	// Connect is called for every dial of the daemon socket. Services that
+	// reconnect on failure see their reconnects as its count minus one.
+	Connect(err error)
+}
+
+// ExpvarMetrics publishes Client metrics through expvar, and so on
+// /debug/vars when net/http/pprof or expvar's handler is mounted:
+//
+//	requests        map of request type to count
+//	request_errors  map of request type to failed count
+//	request_nanos   map of request type to total round-trip time
+//	bytes_sent, bytes_received, connects, connect_errors
+type ExpvarMetrics struct {
+	requests      expvar.Map
+	requestErrors expvar.Map
+	requestNanos  expvar.Map
+	bytesSent     expvar.Int
+	bytesReceived expvar.Int
+	connects      expvar.Int
+	connectErrors expvar.Int
+}
+
+var _ Metrics = (*ExpvarMetrics)(nil)
+
+// NewExpvarMetrics creates an ExpvarMetrics published under name ("qail_ipc"
+// if empty). Like expvar.Publish, it panics if name is already in use, so
+// create one per process and share it.
+func NewExpvarMetrics(name string) *ExpvarMetrics {
+	if name == "" {
+		name = "qail_ipc"
+	}
+	m := &ExpvarMetrics{}
+	root := new(expvar.Map)
+	root.Set("requests", &m.requests)
+	root.Set("request_errors", &m.requestErrors)
+	root.Set("request_nanos", &m.requestNanos)
+	root.Set("bytes_sent", &m.bytesSent)
+	root.Set("bytes_received", &m.bytesReceived)
+	root.Set("connects", &m.connects)
+	root.Set("connect_errors", &m.connectErrors)
+	expvar.Publish(name, root)
+	return m
+}
+
+// Request implements Metrics.
+func (m *ExpvarMetrics) Request(typ string, elapsed time.Duration, err error) {
+	m.requests.Add(typ, 1)
+	m.requestNanos.Add(typ, int64(elapsed))
+	if err != nil {
+		m.requestErrors.Add(typ, 1)
+	}
+}
+
+// BytesSent implements Metrics.
+func (m *ExpvarMetrics) BytesSent(n int) { m.bytesSent.Add(int64(n)) }
+
+// BytesReceived implements Metrics.
+func (m *ExpvarMetrics) BytesReceived(n int) { m.bytesReceived.Add(int64(n)) }
+
+// Connect implements Metrics.
+func (m *ExpvarMetrics) Connect(err error) {
+	m.connects.Add(1)
+	if err != nil {
+		m.connectErrors.Add(1)
+	}
+}
+
+// requestType names req for Metrics: its "type" field, or for the
+// {"Name": {...}} form, its single key.
+func requestType(req any) string {
+	m, ok := req.(map[string]any)
+	if !ok {
+		return "unknown"
+	}
+	if t, ok := m["type"].(string); ok {
+		return t
+	}
+	for k := range m {
+		return k
+	}
+	return "unknown"
+}
@@ -0,0 +1,127 @@
+package ipc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+)
+
+// Scan copies the row's columns into dest, one pointer per column, like
+// database/sql's Rows.Scan:
+//
+//	var id int64
+//	var name string
+//	var email *string // nil for NULL
+//	err := res.Rows[0].Scan(&id, &name, &email)
+//
+// Values arrive as decoded JSON, so they are coerced: numbers scan into
+// any integer type (if integral and in range), float type or string;
+// strings into string or []byte; booleans into bool; bytea columns into
+// []byte. A NULL sets a pointer destination (e.g. **string) to nil and
+// fails for any other type except *any, which receives the raw value.
+func (r Row) Scan(dest ...any) error {
+	if len(dest) != len(r.Columns) {
+		return fmt.Errorf("Scan: %d destinations for %d columns", len(dest), len(r.Columns))
+	}
+	for i, d := range dest {
+		v := reflect.ValueOf(d)
+		if v.Kind() != reflect.Pointer || v.IsNil() {
+			return fmt.Errorf("Scan: destination %d is not a non-nil pointer", i)
+		}
+		if err := assignValue(r.Columns[i], v.Elem()); err != nil {
+			return fmt.Errorf("Scan: column %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// errNull is returned when a NULL meets a destination that can't hold it.
+var errNull = errors.New("NULL value; use a pointer destination")
+
+// assignValue stores the JSON-decoded value src into v.
+func assignValue(src any, v reflect.Value) error {
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() == 0 {
+			if src == nil {
+				v.SetZero()
+			} else {
+				v.Set(reflect.ValueOf(src))
+			}
+			return nil
+		}
+	case reflect.Pointer:
+		if src == nil {
+			v.SetZero()
+			return nil
+		}
+		p := reflect.New(v.Type().Elem())
+		if err := assignValue(src, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	switch s := src.(type) {
+	case nil:
+		return errNull
+	case float64:
+		switch v.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			if s != math.Trunc(s) || s < math.MinInt64 || s >= math.MaxInt64 || v.OverflowInt(int64(s)) {
+				return fmt.Errorf("%v does not fit in %s", s, v.Type())
+			}
+			v.SetInt(int64(s))
+			return nil
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if s != math.Trunc(s) || s < 0 || s >= math.MaxUint64 || v.OverflowUint(uint64(s)) {
+				return fmt.Errorf("%v does not fit in %s", s, v.Type())
+			}
+			v.SetUint(uint64(s))
+			return nil
+		case reflect.Float32, reflect.Float64:
+			v.SetFloat(s)
+			return nil
+		case reflect.String:
+			// The daemon sends numeric-looking text as a number.
+			v.SetString(strconv.FormatFloat(s, 'f', -1, 64))
+			return nil
+		}
+	case string:
+		switch {
+		case v.Kind() == reflect.String:
+			v.SetString(s)
+			return nil
+		case isByteSlice(v.Type()):
+			v.SetBytes([]byte(s))
+			return nil
+		}
+	case bool:
+		if v.Kind() == reflect.Bool {
+			v.SetBool(s)
+			return nil
+		}
+	case []any:
+		// bytea: the daemon sends raw bytes as an array of numbers.
+		if isByteSlice(v.Type()) {
+			b := make([]byte, len(s))
+			for i, e := range s {
+				n, ok := e.(float64)
+				if !ok || n < 0 || n > 255 || n != math.Trunc(n) {
+					return fmt.Errorf("invalid byte %v at index %d", e, i)
+				}
+				b[i] = byte(n)
+			}
+			v.SetBytes(b)
+			return nil
+		}
+	}
+	return fmt.Errorf("cannot scan %T into %s", src, v.Type())
+}
+
+func isByteSlice(t reflect.Type) bool {
+	return t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8
+}
@@ -0,0 +1,115 @@
+package ipc
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type blob []byte
+
+func TestScan(t *testing.T) {
+	ptr := func(s string) *string { return &s }
+	tests := []struct {
+		name    string
+		src     any
+		dest    func() any // returns a fresh pointer destination
+		want    any        // the value dest points to after Scan
+		wantErr string
+	}{
+		// Numbers.
+		{"integral float into int64", 42.0, func() any { return new(int64) }, int64(42), ""},
+		{"negative into int8", -128.0, func() any { return new(int8) }, int8(-128), ""},
+		{"min int64", -9223372036854775808.0, func() any { return new(int64) }, int64(math.MinInt64), ""},
+		{"into uint16", 65535.0, func() any { return new(uint16) }, uint16(65535), ""},
+		{"into float32", 1.5, func() any { return new(float32) }, float32(1.5), ""},
+		{"into float64", 0.1, func() any { return new(float64) }, 0.1, ""},
+		{"number into string", 123.45, func() any { return new(string) }, "123.45", ""},
+		{"large number into string", 1e21, func() any { return new(string) }, "1000000000000000000000", ""},
+		{"fraction into int", 1.5, func() any { return new(int) }, 0, "1.5 does not fit in int"},
+		{"overflows int8", 128.0, func() any { return new(int8) }, int8(0), "128 does not fit in int8"},
+		{"2^63 into int64", 9223372036854775808.0, func() any { return new(int64) }, int64(0), "does not fit in int64"},
+		{"negative into uint", -1.0, func() any { return new(uint) }, uint(0), "-1 does not fit in uint"},
+		{"2^64 into uint64", 18446744073709551616.0, func() any { return new(uint64) }, uint64(0), "does not fit in uint64"},
+		{"NaN into int", math.NaN(), func() any { return new(int) }, 0, "does not fit in int"},
+		{"number into bool", 1.0, func() any { return new(bool) }, false, "cannot scan float64 into bool"},
+
+		// Strings and booleans.
+		{"string", "ada", func() any { return new(string) }, "ada", ""},
+		{"string into []byte", "ada", func() any { return new([]byte) }, []byte("ada"), ""},
+		{"string into int", "42", func() any { return new(int) }, 0, "cannot scan string into int"},
+		{"bool", true, func() any { return new(bool) }, true, ""},
+		{"bool into string", true, func() any { return new(string) }, "", "cannot scan bool into string"},
+
+		// bytea arrives as an array of numbers.
+		{"bytea", []any{0.0, 127.0, 255.0}, func() any { return new([]byte) }, []byte{0, 127, 255}, ""},
+		{"empty bytea", []any{}, func() any { return new([]byte) }, []byte{}, ""},
+		{"bytea into named type", []any{1.0}, func() any { return new(blob) }, blob{1}, ""},
+		{"byte out of range", []any{1.0, 256.0}, func() any { return new([]byte) }, []byte(nil), "invalid byte 256 at index 1"},
+		{"negative byte", []any{-1.0}, func() any { return new([]byte) }, []byte(nil), "invalid byte -1 at index 0"},
+		{"fractional byte", []any{0.5}, func() any { return new([]byte) }, []byte(nil), "invalid byte 0.5 at index 0"},
+		{"non-numeric byte", []any{"a"}, func() any { return new([]byte) }, []byte(nil), "invalid byte a at index 0"},
+		{"array into string", []any{1.0}, func() any { return new(string) }, "", "cannot scan []interface {} into string"},
+
+		// NULL.
+		{"NULL into pointer", nil, func() any { s := ptr("old"); return &s }, (*string)(nil), ""},
+		{"NULL into string", nil, func() any { return new(string) }, "", "NULL value"},
+		{"NULL into int", nil, func() any { return new(int) }, 0, "NULL value"},
+		{"NULL into []byte", nil, func() any { return new([]byte) }, []byte(nil), "NULL value"},
+		{"NULL into any", nil, func() any { var v any = "old"; return &v }, nil, ""},
+		{"value into pointer", "ada", func() any { return new(*string) }, ptr("ada"), ""},
+		{"number into pointer to int", 7.0, func() any { return new(*int32) }, func() *int32 { n := int32(7); return &n }(), ""},
+		{"bad value into pointer", "x", func() any { return new(*int) }, (*int)(nil), "cannot scan string into int"},
+
+		// *any receives the raw decoded value.
+		{"any number", 1.5, func() any { return new(any) }, 1.5, ""},
+		{"any array", []any{1.0}, func() any { return new(any) }, []any{1.0}, ""},
+		{"non-empty interface", "x", func() any { return new(error) }, error(nil), "cannot scan string into error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dest := tt.dest()
+			err := Row{Columns: []any{tt.src}}.Scan(dest)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want it to contain %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(dest).Elem().Interface(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanNullErrorIsErrNull(t *testing.T) {
+	var s string
+	err := Row{Columns: []any{nil}}.Scan(&s)
+	if !errors.Is(err, errNull) {
+		t.Errorf("err = %v, want errNull", err)
+	}
+}
+
+func TestScanDestinations(t *testing.T) {
+	row := Row{Columns: []any{1.0, "ada"}}
+	var id int
+	var name string
+	if err := row.Scan(&id); err == nil || err.Error() != "Scan: 1 destinations for 2 columns" {
+		t.Errorf("too few destinations: err = %v", err)
+	}
+	if err := row.Scan(id, &name); err == nil || err.Error() != "Scan: destination 0 is not a non-nil pointer" {
+		t.Errorf("non-pointer destination: err = %v", err)
+	}
+	if err := row.Scan(&id, (*string)(nil)); err == nil || err.Error() != "Scan: destination 1 is not a non-nil pointer" {
+		t.Errorf("nil pointer destination: err = %v", err)
+	}
+	if err := row.Scan(&id, &name); err != nil || id != 1 || name != "ada" {
+		t.Errorf("Scan = %v; got %d, %q", err, id, name)
+	}
+}
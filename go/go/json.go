@@ -0,0 +1,159 @@
+package qail
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// ToJSON encodes the row as a JSON object keyed by column name, in column
+// order. NULL becomes null. With column type information, integer, float
+// and numeric columns become numbers, bool becomes true/false, json and
+// jsonb are embedded as-is, dates and timestamps become RFC 3339 strings,
+// and other types become strings. Rows without type information (such as
+// RustConn results, keyed "0", "1", ...) emit a number for any value
+// whose text is a valid JSON number and a string otherwise.
+func (r Row) ToJSON() ([]byte, error) {
+	return r.appendJSON(nil)
+}
+
+// MarshalJSON implements json.Marshaler using ToJSON, so a []Row encodes
+// as an array of objects.
+func (r Row) MarshalJSON() ([]byte, error) {
+	return r.ToJSON()
+}
+
+// MarshalJSON encodes the result's rows as a JSON array of objects (see
+// Row.ToJSON). A result with no rows encodes as [].
+func (r *Result) MarshalJSON() ([]byte, error) {
+	buf := []byte{'['}
+	for i, row := range r.Rows {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		var err error
+		if buf, err = row.appendJSON(buf); err != nil {
+			return nil, err
+		}
+	}
+	return append(buf, ']'), nil
+}
+
+// GetRawJSON returns a json or jsonb column's JSON text, or nil for NULL.
+// The version byte that starts binary-format jsonb is stripped. The result
+// aliases the row's memory; see Result.Release.
+func (r Row) GetRawJSON(idx int) json.RawMessage {
+	b := r.Get(idx)
+	if b == nil {
+		return nil
+	}
+	if f, ok := r.field(idx); ok && f.Format == FormatBinary && f.TypeOID == OIDJSONB && len(b) > 0 {
+		b = b[1:]
+	}
+	return json.RawMessage(b)
+}
+
+// GetJSON unmarshals a json or jsonb column into dest with json.Unmarshal.
+// A NULL column unmarshals as JSON null, leaving most destinations
+// unchanged.
+func (r Row) GetJSON(idx int, dest any) error {
+	raw := r.GetRawJSON(idx)
+	if raw == nil {
+		raw = json.RawMessage("null")
+	}
+	if err := json.Unmarshal(raw, dest); err != nil {
+		return fmt.Errorf("column %d: %w", idx, err)
+	}
+	return nil
+}
+
+func (r Row) appendJSON(buf []byte) ([]byte, error) {
+	buf = append(buf, '{')
+	for i := range r.columns {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		name := strconv.Itoa(i)
+		if f, ok := r.field(i); ok {
+			name = f.Name
+		}
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, key...)
+		buf = append(buf, ':')
+		if buf, err = r.appendJSONValue(buf, i); err != nil {
+			return nil, err
+		}
+	}
+	return append(buf, '}'), nil
+}
+
+func (r Row) appendJSONValue(buf []byte, idx int) ([]byte, error) {
+	b := r.Get(idx)
+	if b == nil {
+		return append(buf, "null"...), nil
+	}
+
+	f, typed := r.field(idx)
+	if typed {
+		switch f.TypeOID {
+		case OIDInt2, OIDInt4, OIDInt8, OIDOid:
+			return strconv.AppendInt(buf, r.GetInt(idx), 10), nil
+		case OIDFloat4, OIDFloat8:
+			v := r.GetFloat64(idx)
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				// JSON has no NaN or Infinity; keep the server's spelling.
+				return appendJSONString(buf, strconv.FormatFloat(v, 'g', -1, 64))
+			}
+			return strconv.AppendFloat(buf, v, 'g', -1, 64), nil
+		case OIDBool:
+			return strconv.AppendBool(buf, r.GetBool(idx)), nil
+		case OIDDate, OIDTimestamp, OIDTimestampTz:
+			if t := r.GetTime(idx); !t.IsZero() {
+				return appendJSONString(buf, t.Format(time.RFC3339Nano))
+			}
+		case OIDJSON, OIDJSONB:
+			if raw := r.GetRawJSON(idx); json.Valid(raw) {
+				return append(buf, raw...), nil
+			}
+		}
+		if f.TypeOID != OIDNumeric {
+			return appendJSONString(buf, string(b))
+		}
+		b = []byte(r.GetDecimalString(idx))
+	}
+
+	// Numeric columns and untyped rows: emit a number when the text
+	// already is a valid JSON number (NaN and Infinity are not).
+	if isJSONNumber(b) {
+		return append(buf, b...), nil
+	}
+	return appendJSONString(buf, string(b))
+}
+
+func appendJSONString(buf []byte, s string) ([]byte, error) {
+	q, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, q...), nil
+}
+
+// isJSONNumber reports whether b is a complete JSON number literal.
+func isJSONNumber(b []byte) bool {
+	if len(b) == 0 || !(b[0] == '-' || b[0] >= '0' && b[0] <= '9') {
+		return false
+	}
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var n json.Number
+	if err := dec.Decode(&n); err != nil {
+		return false
+	}
+	return dec.InputOffset() == int64(len(b))
+}
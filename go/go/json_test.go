@@ -0,0 +1,103 @@
+package qail
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestGetJSONRoundTrip(t *testing.T) {
+	type doc struct {
+		Name string            `json:"name"`
+		Tags []string          `json:"tags"`
+		Meta map[string]string `json:"meta"`
+		N    float64           `json:"n"`
+	}
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != "SELECT $1::json, $1::jsonb" {
+			return qailtest.Response{}, false
+		}
+		r := qailtest.Response{
+			ParamOIDs: []uint32{OIDJSONB},
+			Columns:   []qailtest.Column{{Name: "json", OID: OIDJSON}, {Name: "jsonb", OID: OIDJSONB}},
+		}
+		if len(q.Args) == 1 {
+			var v any // a nil []byte would be sent as an empty value
+			if q.Args[0] != nil {
+				v = q.Args[0]
+			}
+			r.Rows = [][]any{{v, v}}
+		}
+		return r, true
+	})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+	if err := d.Prepare("echo", "SELECT $1::json, $1::jsonb"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := doc{Name: `quote " and \ backslash`, Tags: []string{"a", "ü"}, Meta: map[string]string{"k": "v"}, N: 1.5}
+	in, err := json.Marshal(want)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows, err := d.QueryPrepared("echo", string(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	for idx := range 2 {
+		if raw := rows[0].GetRawJSON(idx); string(raw) != string(in) {
+			t.Errorf("GetRawJSON(%d) = %s, want %s", idx, raw, in)
+		}
+		var got doc
+		if err := rows[0].GetJSON(idx, &got); err != nil {
+			t.Fatalf("GetJSON(%d): %v", idx, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("GetJSON(%d) = %+v, want %+v", idx, got, want)
+		}
+	}
+
+	rows, err = d.QueryPrepared("echo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if raw := rows[0].GetRawJSON(1); raw != nil {
+		t.Errorf("NULL GetRawJSON = %q, want nil", raw)
+	}
+	keep := doc{Name: "unchanged"}
+	if err := rows[0].GetJSON(1, &keep); err != nil || keep.Name != "unchanged" {
+		t.Errorf("NULL GetJSON = %+v, %v; want dest unchanged", keep, err)
+	}
+}
+
+func TestGetRawJSONBinary(t *testing.T) {
+	// Binary jsonb starts with a version byte; binary json is plain text.
+	r := Row{
+		columns: [][]byte{append([]byte{1}, `{"a":1}`...), []byte(`{"a":1}`), []byte(`{"a":`)},
+		fields: []ColumnInfo{
+			{TypeOID: OIDJSONB, Format: FormatBinary},
+			{TypeOID: OIDJSON, Format: FormatBinary},
+			{TypeOID: OIDJSONB, Format: FormatText},
+		},
+	}
+	for idx := range 2 {
+		if raw := r.GetRawJSON(idx); string(raw) != `{"a":1}` {
+			t.Errorf("GetRawJSON(%d) = %q, want {\"a\":1}", idx, raw)
+		}
+		var got map[string]int
+		if err := r.GetJSON(idx, &got); err != nil || got["a"] != 1 {
+			t.Errorf("GetJSON(%d) = %v, %v; want a=1", idx, got, err)
+		}
+	}
+	var got any
+	if err := r.GetJSON(2, &got); err == nil {
+		t.Error("GetJSON of truncated JSON: no error")
+	}
+}
@@ -0,0 +1,339 @@
+package qail
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Notification is one NOTIFY delivered to a Listener.
+type Notification struct {
+	PID     uint32 // backend process that sent it
+	Channel string
+	Payload string
+}
+
+// Listener holds a pooled connection that LISTENs on any number of
+// channels and delivers their notifications, tagged by channel, on one Go
+// channel:
+//
+//	l, err := d.Listen(ctx, "orders", "invoices")
+//	if err != nil { ... }
+//	defer l.Close()
+//	for n := range l.Notifications() {
+//		switch n.Channel { ... }
+//	}
+//
+// A goroutine reads the connection for the Listener's lifetime. Listen and
+// Unlisten may be called at any time, from any goroutine, including the
+// one receiving notifications. Notifications are delivered in the order
+// the server sends them; while nobody receives, they queue in memory
+// without bound, so a Listener whose Notifications are not drained grows
+// with every NOTIFY until it is closed.
+type Listener struct {
+	d *Driver
+	c *Conn
+
+	out       chan Notification
+	replies   chan error    // one per LISTEN/UNLISTEN command, from the reader
+	stop      chan struct{} // closed by Close: drop notifications, exit after the next reply
+	dead      chan struct{} // closed when the reader exits
+	delivered chan struct{} // closed when deliver exits, after out
+
+	queueMu sync.Mutex
+	queue   []Notification // read but not yet delivered
+	queued  chan struct{}  // signalled when queue becomes non-empty
+
+	cmdMu    sync.Mutex      // one command in flight at a time; guards channels and closed
+	channels map[string]bool // channels currently listened on
+	closed   bool
+
+	err error // why the reader exited, set before dead is closed
+}
+
+// Listen checks a connection out of the pool and LISTENs on channels,
+// which may be empty. ctx bounds only the checkout. Channel names are
+// quoted, so they match NOTIFY's exactly, case included.
+func (d *Driver) Listen(ctx context.Context, channels ...string) (*Listener, error) {
+	c, err := d.getConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The reader waits for notifications indefinitely.
+	c.suspendReadTimeout(true)
+	l := &Listener{
+		d:         d,
+		c:         c,
+		out:       make(chan Notification, 64),
+		replies:   make(chan error, 1),
+		stop:      make(chan struct{}),
+		dead:      make(chan struct{}),
+		delivered: make(chan struct{}),
+		queued:    make(chan struct{}, 1),
+		channels:  make(map[string]bool),
+	}
+	go l.read()
+	go l.deliver()
+	if len(channels) > 0 {
+		if err := l.Listen(channels...); err != nil {
+			l.Close()
+			return nil, err
+		}
+	}
+	return l, nil
+}
+
+// Notifications returns the channel notifications are delivered on. It
+// is closed once the Listener is closed or its connection fails; Err
+// tells which.
+func (l *Listener) Notifications() <-chan Notification {
+	return l.out
+}
+
+// Listen starts listening on channels. Channels already listened on are
+// unaffected.
+func (l *Listener) Listen(channels ...string) error {
+	l.cmdMu.Lock()
+	defer l.cmdMu.Unlock()
+	if err := l.command("LISTEN", channels); err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		l.channels[ch] = true
+	}
+	return nil
+}
+
+// Unlisten stops listening on channels. Notifications for them that the
+// server already sent may still be delivered.
+func (l *Listener) Unlisten(channels ...string) error {
+	l.cmdMu.Lock()
+	defer l.cmdMu.Unlock()
+	if err := l.command("UNLISTEN", channels); err != nil {
+		return err
+	}
+	for _, ch := range channels {
+		delete(l.channels, ch)
+	}
+	return nil
+}
+
+// Channels returns the channels currently listened on, sorted.
+func (l *Listener) Channels() []string {
+	l.cmdMu.Lock()
+	defer l.cmdMu.Unlock()
+	out := make([]string, 0, len(l.channels))
+	for ch := range l.channels {
+		out = append(out, ch)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// Err returns the connection error that stopped the Listener, or nil.
+func (l *Listener) Err() error {
+	select {
+	case <-l.dead:
+		if errors.Is(l.err, errListenerStopped) {
+			return nil
+		}
+		return l.err
+	default:
+		return nil
+	}
+}
+
+// Close UNLISTENs every channel, stops the reader and returns the
+// connection to the pool; if the connection failed it is discarded
+// instead. Pending notifications are dropped. Calling Close again is a
+// no-op.
+func (l *Listener) Close() (closeErr error) {
+	l.cmdMu.Lock()
+	defer l.cmdMu.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+
+	close(l.stop)
+	if replied, err := l.send("UNLISTEN *"); !replied {
+		// The reader may still be blocked reading; closing the transport
+		// ends it, and the failed read marks the connection poisoned
+		// from the reader's goroutine, so putConn discards it.
+		l.c.conn.Close()
+	} else if err != nil {
+		closeErr = err
+	}
+	<-l.dead
+	<-l.delivered
+	l.channels = nil
+	l.c.suspendReadTimeout(false)
+	l.d.putConn(l.c)
+	return closeErr
+}
+
+// errListenerStopped is the reader's exit reason after a clean Close.
+var errListenerStopped = errors.New("listener closed")
+
+// command sends LISTEN or UNLISTEN for channels as one simple query.
+// cmdMu must be held.
+func (l *Listener) command(verb string, channels []string) error {
+	if l.closed {
+		return ErrConnReleased
+	}
+	if len(channels) == 0 {
+		return nil
+	}
+	var sql strings.Builder
+	for _, ch := range channels {
+		if ch == "" {
+			return errors.New("empty channel name")
+		}
+		sql.WriteString(verb)
+		sql.WriteByte(' ')
+		sql.WriteString(quoteIdent(ch))
+		sql.WriteByte(';')
+	}
+	_, err := l.send(sql.String())
+	return err
+}
+
+// send writes sql and waits for the reader to see its ReadyForQuery,
+// reporting whether that reply arrived. cmdMu must be held.
+func (l *Listener) send(sql string) (replied bool, err error) {
+	select {
+	case <-l.dead:
+		return false, l.err
+	default:
+	}
+	if err := l.c.sendQuery(sql); err != nil {
+		return false, err
+	}
+	select {
+	case err := <-l.replies:
+		return true, err
+	case <-l.dead:
+		return false, l.err
+	}
+}
+
+// read is the Listener's reader goroutine. It queues notifications for
+// deliver and hands each command's outcome to the waiting sender, until
+// the connection fails or the reply to Close's UNLISTEN arrives. It never
+// waits for the consumer, so a command sent from the goroutine receiving
+// notifications still gets its reply.
+func (l *Listener) read() {
+	defer close(l.dead)
+
+	var cmdErr error
+	for {
+		msgType, data, err := l.c.readMessage()
+		if err != nil {
+			l.err = err
+			return
+		}
+		switch msgType {
+		case 'A': // NotificationResponse
+			n, err := parseNotification(data)
+			if err != nil {
+				l.c.poisoned.Store(true)
+				l.err = err
+				return
+			}
+			l.enqueue(n)
+		case 'E':
+			if cmdErr == nil {
+				cmdErr = serverError("listen error", data)
+			}
+		case 'Z':
+			l.replies <- cmdErr
+			cmdErr = nil
+			select {
+			case <-l.stop:
+				l.err = errListenerStopped
+				return
+			default:
+			}
+		}
+	}
+}
+
+// enqueue adds n to the delivery queue, or drops it once Close has begun.
+func (l *Listener) enqueue(n Notification) {
+	select {
+	case <-l.stop:
+		return
+	default:
+	}
+	l.queueMu.Lock()
+	l.queue = append(l.queue, n)
+	l.queueMu.Unlock()
+	select {
+	case l.queued <- struct{}{}:
+	default:
+	}
+}
+
+// deliver is the Listener's delivery goroutine. It moves queued
+// notifications to out until Close begins, or until the reader has exited
+// and the queue is drained, then closes out.
+func (l *Listener) deliver() {
+	defer close(l.delivered)
+	defer close(l.out)
+
+	for {
+		l.queueMu.Lock()
+		batch := l.queue
+		l.queue = nil
+		l.queueMu.Unlock()
+		for _, n := range batch {
+			select {
+			case l.out <- n:
+			case <-l.stop:
+				return
+			}
+		}
+		if len(batch) > 0 {
+			continue
+		}
+		select {
+		case <-l.queued:
+		case <-l.stop:
+			return
+		case <-l.dead:
+			// The reader queues nothing more; deliver what it left.
+			l.queueMu.Lock()
+			empty := len(l.queue) == 0
+			l.queueMu.Unlock()
+			if empty {
+				return
+			}
+		}
+	}
+}
+
+// parseNotification decodes a NotificationResponse body: the sender's
+// PID, then the channel and payload as C strings.
+func parseNotification(data []byte) (Notification, error) {
+	if len(data) < 4 {
+		return Notification{}, errMalformed("NotificationResponse")
+	}
+	n := Notification{PID: binary.BigEndian.Uint32(data)}
+	rest := data[4:]
+	i := bytes.IndexByte(rest, 0)
+	if i < 0 {
+		return Notification{}, errMalformed("NotificationResponse")
+	}
+	n.Channel = string(rest[:i])
+	rest = rest[i+1:]
+	j := bytes.IndexByte(rest, 0)
+	if j < 0 {
+		return Notification{}, errMalformed("NotificationResponse")
+	}
+	n.Payload = string(rest[:j])
+	return n, nil
+}
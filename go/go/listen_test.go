@@ -0,0 +1,149 @@
+package qail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestListenFromNotificationLoop(t *testing.T) {
+	// More notifications than the delivery channel buffers arrive before
+	// the first LISTEN's reply.
+	const pending = 200
+	notify := make([]qailtest.Notification, pending)
+	for i := range notify {
+		notify[i] = qailtest.Notification{Channel: "orders", Payload: fmt.Sprint(i)}
+	}
+	srv := qailtest.NewServer()
+	srv.Handle(`LISTEN "orders";`, qailtest.Response{Tag: "LISTEN", Notify: notify})
+	srv.Handle(`LISTEN "invoices";`, qailtest.Response{Tag: "LISTEN"})
+	srv.Handle("UNLISTEN *", qailtest.Response{Tag: "UNLISTEN"})
+	d := fakeDriver(t, srv, Config{})
+
+	done := make(chan error, 1)
+	go func() {
+		l, err := d.Listen(context.Background(), "orders")
+		if err != nil {
+			done <- err
+			return
+		}
+		got := 0
+		for n := range l.Notifications() {
+			if n.Channel != "orders" || n.Payload != fmt.Sprint(got) {
+				done <- fmt.Errorf("notification %d = %+v", got, n)
+				return
+			}
+			if got == 0 {
+				// A command from the consuming goroutine must not wait
+				// for the consumer.
+				if err := l.Listen("invoices"); err != nil {
+					done <- err
+					return
+				}
+			}
+			if got++; got == pending {
+				break
+			}
+		}
+		if ch := l.Channels(); len(ch) != 2 {
+			done <- fmt.Errorf("channels = %v, want orders and invoices", ch)
+			return
+		}
+		done <- l.Close()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlocked calling Listen from the notification loop")
+	}
+}
+
+func TestListenInterleavedChannels(t *testing.T) {
+	var notify []qailtest.Notification
+	for i := range 5 {
+		notify = append(notify,
+			qailtest.Notification{Channel: "orders", Payload: fmt.Sprint("order ", i)},
+			qailtest.Notification{Channel: "invoices", Payload: fmt.Sprint("invoice ", i)})
+	}
+	srv := qailtest.NewServer()
+	srv.Handle(`LISTEN "orders"`, qailtest.Response{Tag: "LISTEN"})
+	srv.Handle(`LISTEN "invoices"`, qailtest.Response{Tag: "LISTEN", Notify: notify})
+	srv.Handle("UNLISTEN *", qailtest.Response{Tag: "UNLISTEN"})
+	d := fakeDriver(t, srv, Config{})
+
+	l, err := d.Listen(context.Background(), "orders", "invoices")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	for i, want := range notify {
+		select {
+		case n := <-l.Notifications():
+			if n.Channel != want.Channel || n.Payload != want.Payload {
+				t.Fatalf("notification %d = %s %q, want %s %q", i, n.Channel, n.Payload, want.Channel, want.Payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("timed out waiting for notification %d", i)
+		}
+	}
+}
+
+func TestListenerCloseAfterConnectionFailure(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Handle(`LISTEN "orders"`, qailtest.Response{Tag: "LISTEN"})
+	srv.Handle("SELECT 1", qailtest.Response{Columns: []qailtest.Column{{Name: "one", OID: OIDInt4}}, Rows: [][]any{{1}}})
+	var (
+		mu    sync.Mutex
+		conns []net.Conn
+	)
+	d, err := NewDriver(Config{
+		User: "test", Database: "test", SSLMode: "disable",
+		DialFunc: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			c, err := srv.Dial(ctx, network, addr)
+			mu.Lock()
+			conns = append(conns, c)
+			mu.Unlock()
+			return c, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(d.Close)
+
+	l, err := d.Listen(context.Background(), "orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The transport fails under the blocked reader; Close's UNLISTEN gets
+	// no reply.
+	conns[0].Close()
+	if err := l.Close(); err != nil {
+		t.Errorf("Close = %v, want nil", err)
+	}
+	if _, ok := <-l.Notifications(); ok {
+		t.Error("Notifications still open after Close")
+	}
+	if l.Err() == nil {
+		t.Error("Err = nil after the connection failed")
+	}
+
+	// The failed connection was discarded, not pooled.
+	if _, err := d.SimpleExec("SELECT 1"); err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(conns) != 2 {
+		t.Errorf("dialed %d connections, want 2", len(conns))
+	}
+}
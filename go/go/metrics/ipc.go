@@ -0,0 +1,118 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/qail-lang/qail-go/ipc"
+)
+
+// IPCCollector records ipc.Client activity. It implements ipc.Metrics:
+//
+//	m := metrics.NewIPCCollector("")
+//	if err := m.Register(prometheus.DefaultRegisterer); err != nil {
+//	    return err
+//	}
+//	client, err := ipc.ConnectMetrics("", m)
+//
+// Metric names (with the default "qail" namespace):
+//
+//	qail_ipc_requests_total{type,status}      counter, status is "ok" or "error"
+//	qail_ipc_request_duration_seconds{type}   histogram
+//	qail_ipc_sent_bytes_total                 counter
+//	qail_ipc_received_bytes_total             counter
+//	qail_ipc_connects_total{status}           counter
+//
+// type is the daemon request type, e.g. "Get" or "Pipeline".
+type IPCCollector struct {
+	requests        *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	bytesSent       prometheus.Counter
+	bytesReceived   prometheus.Counter
+	connects        *prometheus.CounterVec
+}
+
+var _ ipc.Metrics = (*IPCCollector)(nil)
+
+// NewIPCCollector creates the metrics under namespace ("qail" if empty).
+// Call Register to expose them.
+func NewIPCCollector(namespace string) *IPCCollector {
+	if namespace == "" {
+		namespace = "qail"
+	}
+	const subsystem = "ipc"
+	return &IPCCollector{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Daemon round-trips, by request type and outcome.",
+		}, []string{"type", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Daemon round-trip latency, by request type.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16), // 100µs .. ~3.3s
+		}, []string{"type"}),
+		bytesSent: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "sent_bytes_total",
+			Help:      "Bytes written to the daemon socket.",
+		}),
+		bytesReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "received_bytes_total",
+			Help:      "Bytes read from the daemon socket.",
+		}),
+		connects: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "connects_total",
+			Help:      "Dials of the daemon socket, by outcome.",
+		}, []string{"status"}),
+	}
+}
+
+// Register registers every metric with r.
+func (c *IPCCollector) Register(r prometheus.Registerer) error {
+	for _, m := range []prometheus.Collector{
+		c.requests, c.requestDuration, c.bytesSent, c.bytesReceived, c.connects,
+	} {
+		if err := r.Register(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Request implements ipc.Metrics.
+func (c *IPCCollector) Request(typ string, elapsed time.Duration, err error) {
+	c.requests.WithLabelValues(typ, status(err)).Inc()
+	c.requestDuration.WithLabelValues(typ).Observe(elapsed.Seconds())
+}
+
+// BytesSent implements ipc.Metrics.
+func (c *IPCCollector) BytesSent(n int) {
+	c.bytesSent.Add(float64(n))
+}
+
+// BytesReceived implements ipc.Metrics.
+func (c *IPCCollector) BytesReceived(n int) {
+	c.bytesReceived.Add(float64(n))
+}
+
+// Connect implements ipc.Metrics.
+func (c *IPCCollector) Connect(err error) {
+	c.connects.WithLabelValues(status(err)).Inc()
+}
+
+func status(err error) string {
+	if err != nil {
+		return "error"
+	}
+	return "ok"
+}
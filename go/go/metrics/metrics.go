@@ -0,0 +1,141 @@
+// Package metrics exports qail Driver metrics to Prometheus.
+//
+// It lives in its own module so the core qail-go module stays free of the
+// Prometheus dependency. A Collector is a qail.Tracer, so collection is
+// opt-in through Config.Tracer:
+//
+//	m := metrics.NewCollector("")
+//	if err := m.Register(prometheus.DefaultRegisterer); err != nil {
+//	    return err
+//	}
+//	driver, err := qail.NewDriver(qail.Config{Host: "db", Tracer: m})
+//
+// Metric names (with the default "qail" namespace):
+//
+//	qail_driver_queries_total{op,status}      counter, status is "ok" or "error"
+//	qail_driver_query_duration_seconds{op}    histogram
+//	qail_driver_pool_wait_seconds             histogram
+//	qail_driver_connection_errors_total       counter
+//	qail_driver_read_bytes_total              counter
+//	qail_driver_written_bytes_total           counter
+//
+// op is the Driver method, e.g. "FetchAll" or "BatchExecute".
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	qail "github.com/qail-lang/qail-go"
+)
+
+// Collector records Driver activity. It implements qail.Tracer,
+// qail.PoolTracer and qail.IOTracer.
+type Collector struct {
+	queries       *prometheus.CounterVec
+	queryDuration *prometheus.HistogramVec
+	poolWait      prometheus.Histogram
+	connErrors    prometheus.Counter
+	bytesRead     prometheus.Counter
+	bytesWritten  prometheus.Counter
+}
+
+var (
+	_ qail.Tracer     = (*Collector)(nil)
+	_ qail.PoolTracer = (*Collector)(nil)
+	_ qail.IOTracer   = (*Collector)(nil)
+)
+
+// NewCollector creates the metrics under namespace ("qail" if empty).
+// Call Register to expose them.
+func NewCollector(namespace string) *Collector {
+	if namespace == "" {
+		namespace = "qail"
+	}
+	const subsystem = "driver"
+	return &Collector{
+		queries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "queries_total",
+			Help:      "Queries executed, by driver method and outcome.",
+		}, []string{"op", "status"}),
+		queryDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "query_duration_seconds",
+			Help:      "Query latency including connection checkout, by driver method.",
+			Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 16), // 100µs .. ~3.3s
+		}, []string{"op"}),
+		poolWait: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "pool_wait_seconds",
+			Help:      "Time spent obtaining a connection from the pool or dialing one.",
+			Buckets:   prometheus.ExponentialBuckets(0.00001, 4, 10), // 10µs .. ~2.6s
+		}),
+		connErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "connection_errors_total",
+			Help:      "Failed attempts to obtain a server connection.",
+		}),
+		bytesRead: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "read_bytes_total",
+			Help:      "Bytes read from server connections.",
+		}),
+		bytesWritten: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "written_bytes_total",
+			Help:      "Bytes written to server connections.",
+		}),
+	}
+}
+
+// Register registers every metric with r.
+func (c *Collector) Register(r prometheus.Registerer) error {
+	for _, m := range []prometheus.Collector{
+		c.queries, c.queryDuration, c.poolWait, c.connErrors, c.bytesRead, c.bytesWritten,
+	} {
+		if err := r.Register(m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QueryStart implements qail.Tracer.
+func (c *Collector) QueryStart(*qail.TraceQuery) {}
+
+// QueryEnd implements qail.Tracer.
+func (c *Collector) QueryEnd(q *qail.TraceQuery, rows int, err error, elapsed time.Duration) {
+	status := "ok"
+	if err != nil {
+		status = "error"
+	}
+	c.queries.WithLabelValues(q.Op, status).Inc()
+	c.queryDuration.WithLabelValues(q.Op).Observe(elapsed.Seconds())
+}
+
+// ConnAcquired implements qail.PoolTracer.
+func (c *Collector) ConnAcquired(wait time.Duration, err error) {
+	if err != nil {
+		c.connErrors.Inc()
+		return
+	}
+	c.poolWait.Observe(wait.Seconds())
+}
+
+// BytesRead implements qail.IOTracer.
+func (c *Collector) BytesRead(n int) {
+	c.bytesRead.Add(float64(n))
+}
+
+// BytesWritten implements qail.IOTracer.
+func (c *Collector) BytesWritten(n int) {
+	c.bytesWritten.Add(float64(n))
+}
@@ -0,0 +1,68 @@
+package qail
+
+import "fmt"
+
+// Param is a positional placeholder for a filter value that is supplied
+// at execute time instead of being built into the command:
+//
+//	cmd := qail.Set("users").Filter("id", qail.Eq, qail.Param(1))
+//	err := driver.ExecuteParams(cmd, 42)
+//
+// Param(n) refers to the nth argument (1-based). The command can be run
+// repeatedly with different arguments without being rebuilt, and argument
+// values never become part of the SQL text.
+type Param int
+
+// ExecuteParams executes a command that returns no rows, binding args to
+// its Param placeholders. Args are sent in text format and their types are
+// inferred by the server; see encodeTextArg for the supported Go types.
+// Literal filter values in the same command are bound as usual.
+func (d *Driver) ExecuteParams(cmd *Qail, args ...any) (err error) {
+	if d.tracer != nil {
+		q := d.traceStart("ExecuteParams", cmd.SQL())
+		defer func() { d.traceEnd(q, 0, err) }()
+	}
+
+	sql, params, err := cmd.sqlParams()
+	if err != nil {
+		return err
+	}
+	for i, arg := range args {
+		p, err := encodeTextArg(arg)
+		if err != nil {
+			return fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		params = append(params, p)
+	}
+	if len(params) > MaxParams {
+		return fmt.Errorf("%w: command binds %d parameters, limit is %d", ErrTooManyParams, len(params), MaxParams)
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return err
+	}
+	defer d.putConn(c)
+
+	buf := appendParse(nil, "", sql)
+	buf = appendBind(buf, "", "", params, FormatText)
+	buf = appendExecute(buf, "", 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+
+	var queryErr error
+	for {
+		msgType, data, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case 'Z':
+			return queryErr
+		case 'E':
+			queryErr = serverError("query error", data)
+		}
+	}
+}
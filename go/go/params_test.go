@@ -0,0 +1,26 @@
+package qail
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestExecuteParamsTooManyParams(t *testing.T) {
+	srv := qailtest.NewServer()
+	d := fakeDriver(t, srv, Config{})
+
+	cmd := Set("users").Filter("id", Eq, Param(1))
+	defer cmd.Free()
+	args := make([]any, MaxParams+1)
+	for i := range args {
+		args[i] = i
+	}
+	if err := d.ExecuteParams(cmd, args...); !errors.Is(err, ErrTooManyParams) {
+		t.Errorf("err = %v, want ErrTooManyParams", err)
+	}
+	if q := srv.Queries(); len(q) != 0 {
+		t.Errorf("server received %v; the command should fail before sending", q)
+	}
+}
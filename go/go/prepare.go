@@ -0,0 +1,372 @@
+package qail
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// StatementDescription is the server's Describe result for a prepared
+// statement: the parameter types it expects and the columns it returns.
+type StatementDescription struct {
+	Name      string
+	SQL       string
+	ParamOIDs []uint32
+	Fields    []ColumnInfo // nil for statements that return no rows
+}
+
+// Prepare registers a named statement on the driver. The statement is
+// parsed and described lazily on each pooled connection the first time it
+// is used there, and the description is cached on that connection.
+func (d *Driver) Prepare(name, sql string) error {
+	if name == "" {
+		return fmt.Errorf("%w: prepared statement name must not be empty", ErrInvalidArgument)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.statements == nil {
+		d.statements = make(map[string]string)
+	}
+	d.statements[name] = sql
+	return nil
+}
+
+// QueryPrepared executes a statement registered with Prepare and returns
+// its rows. args are sent in text format; see encodeTextArg.
+func (d *Driver) QueryPrepared(name string, args ...any) (rows []Row, err error) {
+	d.mu.Lock()
+	sql, ok := d.statements[name]
+	d.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("prepared statement %q not found", name)
+	}
+	if d.tracer != nil {
+		q := d.traceStart("QueryPrepared", sql)
+		defer func() { d.traceEnd(q, len(rows), err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	desc, err := c.Prepare(name, sql)
+	if err != nil {
+		return nil, err
+	}
+	return c.queryPrepared(desc, args)
+}
+
+// Prepare parses and describes a named statement on this connection.
+// Descriptions are cached per connection: repeated calls with the same
+// name and SQL return the cached description without a round trip.
+func (c *Conn) Prepare(name, sql string) (*StatementDescription, error) {
+	if desc, ok := c.stmts[name]; ok {
+		if desc.SQL == sql {
+			c.touchStmt(name)
+			if c.stmtStats != nil {
+				c.stmtStats.hits.Add(1)
+			}
+			return desc, nil
+		}
+		return nil, fmt.Errorf("prepared statement %q already exists with different SQL", name)
+	}
+	if c.stmtStats != nil {
+		c.stmtStats.misses.Add(1)
+	}
+
+	if c.maxStmts > 0 && len(c.stmts) >= c.maxStmts {
+		if err := c.evictStmt(); err != nil {
+			return nil, err
+		}
+	}
+	desc, err := c.describe(name, sql)
+	if err != nil {
+		return nil, err
+	}
+	if c.stmts == nil {
+		c.stmts = make(map[string]*StatementDescription)
+		c.stmtUsed = make(map[string]uint64)
+	}
+	c.stmts[name] = desc
+	c.touchStmt(name)
+	return desc, nil
+}
+
+// stmtCacheStats counts statement cache lookups across a Driver's
+// connections; see PoolStats.
+type stmtCacheStats struct {
+	hits, misses, evictions atomic.Int64
+}
+
+// touchStmt marks a cached statement as just used.
+func (c *Conn) touchStmt(name string) {
+	c.stmtTick++
+	c.stmtUsed[name] = c.stmtTick
+}
+
+// evictStmt closes the least recently used cached statement on the server
+// and drops it from the cache.
+func (c *Conn) evictStmt() error {
+	var victim string
+	var oldest uint64
+	for name, used := range c.stmtUsed {
+		if victim == "" || used < oldest {
+			victim, oldest = name, used
+		}
+	}
+
+	// Close(Statement) + Sync
+	buf := append([]byte(nil), 'C')
+	buf = binary.BigEndian.AppendUint32(buf, uint32(4+1+len(victim)+1))
+	buf = append(buf, 'S')
+	buf = append(buf, victim...)
+	buf = append(buf, 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return err
+	}
+
+	var closeErr error
+	for {
+		msgType, data, err := c.readMessage()
+		if err != nil {
+			return err
+		}
+		switch msgType {
+		case 'E':
+			if closeErr == nil {
+				closeErr = serverError("close statement error", data)
+			}
+		case 'Z':
+			if closeErr != nil {
+				return closeErr
+			}
+			delete(c.stmts, victim)
+			delete(c.stmtUsed, victim)
+			if c.stmtStats != nil {
+				c.stmtStats.evictions.Add(1)
+			}
+			return nil
+		}
+	}
+}
+
+// Describe returns the parameter types and result columns of cmd without
+// executing it: ParamOIDs lists the bind types the server inferred ($1..$L
+// for literal values, then Param placeholders), and Fields is nil for a
+// command that returns no rows. The command is parsed into the unnamed
+// statement, so nothing is left prepared on the connection.
+func (c *Conn) Describe(cmd *Qail) (*StatementDescription, error) {
+	sql, _, err := cmd.sqlParams()
+	if err != nil {
+		return nil, err
+	}
+	return c.describe("", sql)
+}
+
+// Describe is Conn.Describe on a pooled connection.
+func (d *Driver) Describe(cmd *Qail) (desc *StatementDescription, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("Describe", cmd.SQL())
+		defer func() { d.traceEnd(q, 0, err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	return c.Describe(cmd)
+}
+
+// describe parses sql into statement name and describes it.
+func (c *Conn) describe(name, sql string) (*StatementDescription, error) {
+	// Parse + Describe(Statement) + Sync
+	buf := appendParse(nil, name, sql)
+	buf = appendDescribe(buf, 'S', name)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	desc := &StatementDescription{Name: name, SQL: sql}
+	var descErr error
+	for {
+		msgType, data, err := c.readMessage()
+		if err != nil {
+			return nil, err
+		}
+		switch msgType {
+		case '1': // ParseComplete
+			continue
+		case 't': // ParameterDescription
+			if desc.ParamOIDs, err = parseParameterDescription(data); err != nil && descErr == nil {
+				descErr = err
+			}
+		case 'T': // RowDescription
+			if desc.Fields, err = parseRowDescription(data); err != nil && descErr == nil {
+				descErr = err
+			}
+		case 'n': // NoData
+			continue
+		case 'E':
+			if descErr == nil {
+				descErr = serverError("prepare error", data)
+			}
+		case 'Z':
+			if descErr != nil {
+				return nil, descErr
+			}
+			return desc, nil
+		}
+	}
+}
+
+// queryPrepared binds args to a described statement, executes it and
+// reads the rows. No Describe is sent: the cached description supplies the
+// column metadata.
+func (c *Conn) queryPrepared(desc *StatementDescription, args []any) ([]Row, error) {
+	params := make([][]byte, len(args))
+	for i, arg := range args {
+		p, err := encodeTextArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		params[i] = p
+	}
+
+	buf := appendBind(nil, "", desc.Name, params, c.resultFormat)
+	buf = appendExecute(buf, "", 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, err
+	}
+
+	fields := desc.Fields
+	if c.resultFormat != FormatText && len(fields) > 0 {
+		fields = make([]ColumnInfo, len(desc.Fields))
+		copy(fields, desc.Fields)
+		for i := range fields {
+			fields[i].Format = c.resultFormat
+		}
+	}
+	return c.readRowsWith(fields)
+}
+
+// appendParse appends a Parse message with no pre-specified parameter types.
+func appendParse(buf []byte, name, sql string) []byte {
+	length := 4 + len(name) + 1 + len(sql) + 1 + 2
+	buf = append(buf, 'P')
+	buf = binary.BigEndian.AppendUint32(buf, uint32(length))
+	buf = append(buf, name...)
+	buf = append(buf, 0)
+	buf = append(buf, sql...)
+	buf = append(buf, 0)
+	return binary.BigEndian.AppendUint16(buf, 0)
+}
+
+// appendDescribe appends a Describe message for a statement ('S') or
+// portal ('P').
+func appendDescribe(buf []byte, kind byte, name string) []byte {
+	buf = append(buf, 'D')
+	buf = binary.BigEndian.AppendUint32(buf, uint32(4+1+len(name)+1))
+	buf = append(buf, kind)
+	buf = append(buf, name...)
+	return append(buf, 0)
+}
+
+// appendBind appends a Bind message with text parameters. A nil param is
+// sent as NULL. All result columns use resultFormat.
+func appendBind(buf []byte, portal, stmt string, params [][]byte, resultFormat int16) []byte {
+	length := 4 + len(portal) + 1 + len(stmt) + 1 + 2 + 2 + 2 + 2
+	for _, p := range params {
+		length += 4 + len(p)
+	}
+	buf = append(buf, 'B')
+	buf = binary.BigEndian.AppendUint32(buf, uint32(length))
+	buf = append(buf, portal...)
+	buf = append(buf, 0)
+	buf = append(buf, stmt...)
+	buf = append(buf, 0)
+	buf = binary.BigEndian.AppendUint16(buf, 0) // all params text
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(params)))
+	for _, p := range params {
+		if p == nil {
+			buf = binary.BigEndian.AppendUint32(buf, 0xFFFFFFFF)
+			continue
+		}
+		buf = binary.BigEndian.AppendUint32(buf, uint32(len(p)))
+		buf = append(buf, p...)
+	}
+	buf = binary.BigEndian.AppendUint16(buf, 1)
+	return binary.BigEndian.AppendUint16(buf, uint16(resultFormat))
+}
+
+// appendExecute appends an Execute message. maxRows 0 means no limit.
+func appendExecute(buf []byte, portal string, maxRows int32) []byte {
+	buf = append(buf, 'E')
+	buf = binary.BigEndian.AppendUint32(buf, uint32(4+len(portal)+1+4))
+	buf = append(buf, portal...)
+	buf = append(buf, 0)
+	return binary.BigEndian.AppendUint32(buf, uint32(maxRows))
+}
+
+func parseParameterDescription(data []byte) ([]uint32, error) {
+	if len(data) < 2 {
+		return nil, errMalformed("ParameterDescription")
+	}
+	count := int(binary.BigEndian.Uint16(data[:2]))
+	if len(data) < 2+4*count {
+		return nil, errMalformed("ParameterDescription")
+	}
+	oids := make([]uint32, count)
+	for i := range oids {
+		oids[i] = binary.BigEndian.Uint32(data[2+4*i:])
+	}
+	return oids, nil
+}
+
+// encodeTextArg encodes a Go value as a text-format parameter.
+// nil encodes as SQL NULL. []byte is sent in bytea's hex form (\x...),
+// since the raw bytes need not be valid text in the client encoding.
+func encodeTextArg(arg any) ([]byte, error) {
+	switch v := arg.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(v), nil
+	case []byte:
+		if v == nil {
+			return nil, nil
+		}
+		return hex.AppendEncode([]byte(`\x`), v), nil
+	case int:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int16:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int32:
+		return strconv.AppendInt(nil, int64(v), 10), nil
+	case int64:
+		return strconv.AppendInt(nil, v, 10), nil
+	case uint32:
+		return strconv.AppendUint(nil, uint64(v), 10), nil
+	case float32:
+		return strconv.AppendFloat(nil, float64(v), 'g', -1, 32), nil
+	case float64:
+		return strconv.AppendFloat(nil, v, 'g', -1, 64), nil
+	case bool:
+		if v {
+			return []byte("t"), nil
+		}
+		return []byte("f"), nil
+	case time.Time:
+		return v.AppendFormat(nil, "2006-01-02 15:04:05.999999Z07:00"), nil
+	}
+	return nil, fmt.Errorf("unsupported parameter type %T", arg)
+}
@@ -0,0 +1,92 @@
+package qail
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestQueryPreparedBytea(t *testing.T) {
+	const echo = "SELECT $1::bytea"
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != echo {
+			return qailtest.Response{}, false
+		}
+		r := qailtest.Response{
+			ParamOIDs: []uint32{OIDBytea},
+			Columns:   []qailtest.Column{{Name: "bytea", OID: OIDBytea}},
+		}
+		if len(q.Args) == 1 {
+			r.Rows = [][]any{{q.Args[0]}}
+		}
+		return r, true
+	})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+	if err := d.Prepare("echo", echo); err != nil {
+		t.Fatal(err)
+	}
+
+	// Not valid UTF-8, with a NUL and a backslash that must not be read
+	// as an escape.
+	raw := []byte{0x00, 0xff, 0xfe, '\\', 'x', 0x80, 0x00}
+	rows, err := d.QueryPrepared("echo", raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if q := srv.Queries(); len(q) != 1 || string(q[0].Args[0]) != `\x00fffe5c788000` {
+		t.Fatalf("server received %q, want the hex form", q)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	got, err := rows[0].Value(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := got.([]byte); !ok || !bytes.Equal(b, raw) {
+		t.Errorf("round trip = %#v, want %#v", got, raw)
+	}
+}
+
+func TestQueryPreparedDescribesOncePerConnection(t *testing.T) {
+	srv := qailtest.NewServer()
+	describes := 0
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != "SELECT $1::int4" {
+			return qailtest.Response{}, false
+		}
+		r := qailtest.Response{
+			ParamOIDs: []uint32{OIDInt4},
+			Columns:   []qailtest.Column{{Name: "int4", OID: OIDInt4}},
+		}
+		if q.Args == nil {
+			describes++
+		} else {
+			r.Rows = [][]any{{q.Args[0]}}
+		}
+		return r, true
+	})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+	if err := d.Prepare("echo", "SELECT $1::int4"); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range 3 {
+		rows, err := d.QueryPrepared("echo", i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rows) != 1 || rows[0].GetInt(0) != int64(i) {
+			t.Fatalf("query %d returned %v", i, rows)
+		}
+	}
+	if describes != 1 {
+		t.Errorf("statement described %d times on one connection, want 1", describes)
+	}
+	if s := d.Stats(); s.StmtCacheMisses != 1 || s.StmtCacheHits != 2 {
+		t.Errorf("statement cache: %d misses, %d hits; want 1 and 2", s.StmtCacheMisses, s.StmtCacheHits)
+	}
+}
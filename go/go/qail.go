@@ -36,15 +36,40 @@ extern void qail_column(QailHandle handle, const char* col);
 extern void qail_filter_int(QailHandle handle, const char* col, int op, int64_t value);
 extern void qail_filter_str(QailHandle handle, const char* col, int op, const char* value);
 extern void qail_filter_bool(QailHandle handle, const char* col, int op, int value);
+extern void qail_filter_null(QailHandle handle, const char* col, int not_null);
+extern void qail_filter_param(QailHandle handle, const char* col, int op, int n);
+extern uint8_t* qail_encode_sql_params(QailHandle handle, size_t* out_len);
+extern void qail_aggregate(QailHandle handle, int func, const char* col, const char* alias);
+extern void qail_group_by(QailHandle handle, const char* col);
+extern void qail_begin_group(QailHandle handle, int any_of);
+extern void qail_end_group(QailHandle handle);
+extern void qail_filter_between_int(QailHandle handle, const char* col, int64_t low, int64_t high);
+extern void qail_filter_between_float(QailHandle handle, const char* col, double low, double high);
+extern void qail_filter_between_str(QailHandle handle, const char* col, const char* low, const char* high);
+extern void qail_filter_json_contains(QailHandle handle, const char* col, const char* json);
+extern void qail_filter_json_path(QailHandle handle, const char* col, const char* path);
 extern void qail_limit(QailHandle handle, int64_t limit);
 extern void qail_offset(QailHandle handle, int64_t offset);
+extern void qail_values_row(QailHandle handle);
+extern void qail_value_int(QailHandle handle, int64_t value);
+extern void qail_value_float(QailHandle handle, double value);
+extern void qail_value_str(QailHandle handle, const char* value);
+extern void qail_value_bool(QailHandle handle, int value);
+extern void qail_value_null(QailHandle handle);
+extern void qail_returning(QailHandle handle, const char* col);
 
 // Encode
 extern uint8_t* qail_encode(QailHandle handle, size_t* out_len);
+extern int64_t qail_param_count(QailHandle handle);
+extern char* qail_to_sql(QailHandle handle);
+extern void qail_string_free(char* ptr);
 extern uint8_t* qail_batch_encode(QailHandle* handles, size_t count, size_t* out_len);
 
 // Free
 extern void qail_free(QailHandle handle);
+extern void qail_reset(QailHandle handle);
+extern QailHandle qail_clone(QailHandle handle);
+extern int qail_is_read_only(QailHandle handle);
 extern void qail_bytes_free(uint8_t* ptr, size_t len);
 
 // OPTIMIZED: Single CGO call for entire batch!
@@ -56,21 +81,77 @@ extern uint8_t* qail_encode_select_batch_fast(
     size_t* out_len
 );
 
+// Same batch with a per-query OFFSET as well as LIMIT
+extern uint8_t* qail_encode_select_batch_paged(
+    const char* table,
+    const char* columns,
+    int64_t* limits,
+    int64_t* offsets,
+    size_t count,
+    size_t* out_len
+);
+
+// Same batch with one shared statement and binary int8 LIMIT parameters
+extern uint8_t* qail_encode_select_batch_binary(
+    const char* table,
+    const char* columns,
+    int64_t* limits,
+    size_t count,
+    size_t* out_len
+);
+
+// Same shared statement filtered on columns, with binary int8, float8 or
+// bool parameters per query
+extern uint8_t* qail_encode_select_batch_params(
+    const char* table,
+    const char* columns,
+    const char* filters,
+    const uint32_t* oids,
+    size_t nparams,
+    const uint64_t* values,
+    const uint8_t* nulls,
+    size_t count,
+    size_t* out_len
+);
+
 // RUST I/O: All TCP in Rust Tokio - bypasses Go I/O completely!
+// Failures record a message readable on the same thread via qail_last_error.
+extern const char* qail_last_error(void);
 typedef void* ConnHandle;
 extern ConnHandle qail_connect(const char* host, uint16_t port, const char* user, const char* database);
+extern ConnHandle qail_connect_config(const char* host, uint16_t port, const char* user, const char* database, const char* password, const char* sslmode, uint64_t connect_timeout_ms);
 extern int64_t qail_execute_batch(ConnHandle conn, const char* table, const char* columns, int64_t* limits, size_t count);
+extern uint8_t* qail_execute_batch_rows(ConnHandle conn, const char* table, const char* columns, int64_t* limits, size_t count, size_t* out_len);
 extern void qail_conn_close(ConnHandle handle);
 
+// Cancellation: Go sets the flag when its context ends; the running call
+// sends a CancelRequest, drops the connection and returns -2.
+typedef void* CancelFlag;
+extern CancelFlag qail_cancel_flag_new(void);
+extern void qail_cancel_flag_set(CancelFlag flag);
+extern void qail_cancel_flag_free(CancelFlag flag);
+extern int64_t qail_execute_batch_ctx(ConnHandle conn, const char* table, const char* columns, int64_t* limits, size_t count, CancelFlag flag);
+
 // V2: Channel-based async - NO block_on overhead!
 typedef void* ConnHandleV2;
 extern ConnHandleV2 qail_connect_v2(const char* host, uint16_t port, const char* user, const char* database);
+extern ConnHandleV2 qail_connect_config_v2(const char* host, uint16_t port, const char* user, const char* database, const char* password, const char* sslmode, uint64_t connect_timeout_ms);
 extern int64_t qail_execute_batch_v2(ConnHandleV2 conn, const char* table, const char* columns, int64_t* limits, size_t count);
+extern uint8_t* qail_execute_batch_rows_v2(ConnHandleV2 conn, const char* table, const char* columns, int64_t* limits, size_t count, size_t* out_len);
 extern void qail_conn_close_v2(ConnHandleV2 handle);
 */
 import "C"
 import (
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"math"
+	"reflect"
+	"runtime"
+	"strings"
+	"time"
 	"unsafe"
 )
 
@@ -84,9 +165,19 @@ const (
 	Lte = 5
 )
 
+// Aggregate function constants
+const (
+	aggCount = 0
+	aggSum   = 1
+	aggAvg   = 2
+	aggMin   = 3
+	aggMax   = 4
+)
+
 // Qail represents an AST-native query command.
 type Qail struct {
 	handle C.QailHandle
+	err    error // first builder error; reported by Err and on execution
 }
 
 // Get creates a SELECT command.
@@ -135,7 +226,11 @@ func (c *Qail) Column(col string) *Qail {
 	return c
 }
 
-// Filter adds a WHERE condition with int value.
+// Filter adds a WHERE condition with an int, string, bool or Param value.
+// Values are always sent as bind parameters, never spliced into the SQL
+// text, so quotes and backslashes in strings need no escaping and
+// standard_conforming_strings does not matter.
+// A Param value leaves a placeholder bound by Driver.ExecuteParams.
 func (c *Qail) Filter(col string, op int, value interface{}) *Qail {
 	cCol := C.CString(col)
 	defer C.free(unsafe.Pointer(cCol))
@@ -155,16 +250,319 @@ func (c *Qail) Filter(col string, op int, value interface{}) *Qail {
 			bVal = 1
 		}
 		C.qail_filter_bool(c.handle, cCol, C.int(op), C.int(bVal))
+	case Param:
+		C.qail_filter_param(c.handle, cCol, C.int(op), C.int(v))
 	}
 	return c
 }
 
+// FilterIsNull adds a `col IS NULL` condition, ANDed with other filters.
+func (c *Qail) FilterIsNull(col string) *Qail {
+	return c.filterNull(col, 0)
+}
+
+// FilterIsNotNull adds a `col IS NOT NULL` condition, ANDed with other
+// filters.
+func (c *Qail) FilterIsNotNull(col string) *Qail {
+	return c.filterNull(col, 1)
+}
+
+func (c *Qail) filterNull(col string, notNull C.int) *Qail {
+	cCol := C.CString(col)
+	defer C.free(unsafe.Pointer(cCol))
+	C.qail_filter_null(c.handle, cCol, notNull)
+	return c
+}
+
+// CountAll adds a COUNT(*) column, optionally named by alias.
+func (c *Qail) CountAll(alias ...string) *Qail {
+	return c.aggregate(aggCount, "*", alias)
+}
+
+// Count adds a COUNT(col) column, optionally named by alias.
+func (c *Qail) Count(col string, alias ...string) *Qail {
+	return c.aggregate(aggCount, col, alias)
+}
+
+// Sum adds a SUM(col) column, optionally named by alias.
+func (c *Qail) Sum(col string, alias ...string) *Qail {
+	return c.aggregate(aggSum, col, alias)
+}
+
+// Avg adds an AVG(col) column, optionally named by alias.
+func (c *Qail) Avg(col string, alias ...string) *Qail {
+	return c.aggregate(aggAvg, col, alias)
+}
+
+// Min adds a MIN(col) column, optionally named by alias.
+func (c *Qail) Min(col string, alias ...string) *Qail {
+	return c.aggregate(aggMin, col, alias)
+}
+
+// Max adds a MAX(col) column, optionally named by alias.
+func (c *Qail) Max(col string, alias ...string) *Qail {
+	return c.aggregate(aggMax, col, alias)
+}
+
+func (c *Qail) aggregate(fn C.int, col string, alias []string) *Qail {
+	cCol := C.CString(col)
+	defer C.free(unsafe.Pointer(cCol))
+
+	var cAlias *C.char
+	if len(alias) > 0 && alias[0] != "" {
+		cAlias = C.CString(alias[0])
+		defer C.free(unsafe.Pointer(cAlias))
+	}
+	C.qail_aggregate(c.handle, fn, cCol, cAlias)
+	return c
+}
+
+// GroupBy adds GROUP BY columns. Without it, plain columns selected
+// alongside aggregates are grouped automatically.
+func (c *Qail) GroupBy(cols ...string) *Qail {
+	for _, col := range cols {
+		cCol := C.CString(col)
+		C.qail_group_by(c.handle, cCol)
+		C.free(unsafe.Pointer(cCol))
+	}
+	return c
+}
+
+// Group collects the conditions of a parenthesized filter group; see
+// FilterOr and FilterAnd.
+type Group struct {
+	q *Qail
+}
+
+// FilterOr adds a parenthesized group whose conditions are joined with OR.
+// The group is ANDed with the command's other filters.
+//
+//	cmd.FilterOr(func(g *qail.Group) {
+//	    g.Filter("status", qail.Eq, "open")
+//	    g.FilterAnd(func(g *qail.Group) {
+//	        g.Filter("status", qail.Eq, "closed")
+//	        g.Filter("reopened", qail.Eq, true)
+//	    })
+//	})
+//	// WHERE (status = $1 OR (status = $2 AND reopened = $3))
+func (c *Qail) FilterOr(fn func(g *Group)) *Qail {
+	return c.group(1, fn)
+}
+
+// FilterAnd adds a parenthesized group whose conditions are joined with
+// AND. At the top level this is the same as calling Filter repeatedly; it
+// is mainly useful nested inside FilterOr.
+func (c *Qail) FilterAnd(fn func(g *Group)) *Qail {
+	return c.group(0, fn)
+}
+
+func (c *Qail) group(anyOf C.int, fn func(g *Group)) *Qail {
+	C.qail_begin_group(c.handle, anyOf)
+	defer C.qail_end_group(c.handle)
+	fn(&Group{q: c})
+	return c
+}
+
+// Filter adds a condition to the group; see Qail.Filter.
+func (g *Group) Filter(col string, op int, value interface{}) *Group {
+	g.q.Filter(col, op, value)
+	return g
+}
+
+// FilterIsNull adds a `col IS NULL` condition to the group.
+func (g *Group) FilterIsNull(col string) *Group {
+	g.q.FilterIsNull(col)
+	return g
+}
+
+// FilterIsNotNull adds a `col IS NOT NULL` condition to the group.
+func (g *Group) FilterIsNotNull(col string) *Group {
+	g.q.FilterIsNotNull(col)
+	return g
+}
+
+// FilterOr adds a nested OR group.
+func (g *Group) FilterOr(fn func(g *Group)) *Group {
+	g.q.group(1, fn)
+	return g
+}
+
+// FilterAnd adds a nested AND group.
+func (g *Group) FilterAnd(fn func(g *Group)) *Group {
+	g.q.group(0, fn)
+	return g
+}
+
+// FilterBetween adds a `col BETWEEN low AND high` condition. low and high
+// must have the same type: int, int64, float64, string or time.Time
+// (strings and times suit date and timestamp columns). Otherwise the
+// command records an error, returned by Err and by any Driver call that
+// executes it.
+func (c *Qail) FilterBetween(col string, low, high interface{}) *Qail {
+	if reflect.TypeOf(low) != reflect.TypeOf(high) {
+		return c.setErr(fmt.Errorf("FilterBetween(%q): bounds have different types %T and %T", col, low, high))
+	}
+
+	cCol := C.CString(col)
+	defer C.free(unsafe.Pointer(cCol))
+
+	switch lo := low.(type) {
+	case int:
+		C.qail_filter_between_int(c.handle, cCol, C.int64_t(lo), C.int64_t(high.(int)))
+	case int64:
+		C.qail_filter_between_int(c.handle, cCol, C.int64_t(lo), C.int64_t(high.(int64)))
+	case float64:
+		C.qail_filter_between_float(c.handle, cCol, C.double(lo), C.double(high.(float64)))
+	case string:
+		c.filterBetweenStr(cCol, lo, high.(string))
+	case time.Time:
+		const layout = "2006-01-02 15:04:05.999999Z07:00"
+		c.filterBetweenStr(cCol, lo.Format(layout), high.(time.Time).Format(layout))
+	default:
+		return c.setErr(fmt.Errorf("FilterBetween(%q): unsupported bound type %T", col, low))
+	}
+	return c
+}
+
+func (c *Qail) filterBetweenStr(cCol *C.char, low, high string) {
+	cLow := C.CString(low)
+	defer C.free(unsafe.Pointer(cLow))
+	cHigh := C.CString(high)
+	defer C.free(unsafe.Pointer(cHigh))
+	C.qail_filter_between_str(c.handle, cCol, cLow, cHigh)
+}
+
+// setErr records the first builder error.
+func (c *Qail) setErr(err error) *Qail {
+	if c.err == nil {
+		c.err = err
+	}
+	return c
+}
+
+// Err returns the first error recorded while building the command, if any.
+func (c *Qail) Err() error {
+	return c.err
+}
+
+// FilterJSONContains adds a `col @> json` JSONB containment condition.
+// json is bound as a parameter, e.g. FilterJSONContains("data", []byte(`{"active":true}`)).
+func (c *Qail) FilterJSONContains(col string, json []byte) *Qail {
+	cCol := C.CString(col)
+	defer C.free(unsafe.Pointer(cCol))
+	cJSON := C.CString(string(json))
+	defer C.free(unsafe.Pointer(cJSON))
+	C.qail_filter_json_contains(c.handle, cCol, cJSON)
+	return c
+}
+
+// FilterJSONPath adds a `col @@ path` JSON-path predicate condition.
+// path is bound as a jsonpath parameter, e.g. FilterJSONPath("data", "$.age > 18").
+func (c *Qail) FilterJSONPath(col, path string) *Qail {
+	cCol := C.CString(col)
+	defer C.free(unsafe.Pointer(cCol))
+	cPath := C.CString(path)
+	defer C.free(unsafe.Pointer(cPath))
+	C.qail_filter_json_path(c.handle, cCol, cPath)
+	return c
+}
+
 // Limit sets the LIMIT clause.
 func (c *Qail) Limit(limit int64) *Qail {
 	C.qail_limit(c.handle, C.int64_t(limit))
 	return c
 }
 
+// AddMany creates an INSERT of several rows in one statement:
+//
+//	INSERT INTO table (columns...) VALUES (...), (...), ...
+//
+// Each row holds one value per column: int, int16, int32, int64,
+// float32, float64, string, bool, time.Time, []byte (sent in bytea's hex
+// form) or nil for NULL. Every value is a bind
+// parameter, so len(rows)*len(columns) may not exceed MaxParams; use
+// Driver.InsertMany to split larger inserts. A bad row is recorded as a
+// builder error, returned by Err and by any Driver call that executes it.
+func AddMany(table string, columns []string, rows [][]any) *Qail {
+	c := Add(table).Columns(columns...)
+	if n := len(rows) * len(columns); n > MaxParams {
+		return c.setErr(fmt.Errorf("%w: %d rows of %d columns bind %d parameters, limit is %d",
+			ErrTooManyParams, len(rows), len(columns), n, MaxParams))
+	}
+	for i, row := range rows {
+		if len(row) != len(columns) {
+			return c.setErr(fmt.Errorf("AddMany: row %d has %d values, want %d", i, len(row), len(columns)))
+		}
+		C.qail_values_row(c.handle)
+		for j, v := range row {
+			if err := c.addValue(v); err != nil {
+				return c.setErr(fmt.Errorf("AddMany: row %d, column %q: %w", i, columns[j], err))
+			}
+		}
+	}
+	return c
+}
+
+// addValue appends v to the command's current VALUES row.
+func (c *Qail) addValue(v any) error {
+	switch v := v.(type) {
+	case nil:
+		C.qail_value_null(c.handle)
+	case int:
+		C.qail_value_int(c.handle, C.int64_t(v))
+	case int16:
+		C.qail_value_int(c.handle, C.int64_t(v))
+	case int32:
+		C.qail_value_int(c.handle, C.int64_t(v))
+	case int64:
+		C.qail_value_int(c.handle, C.int64_t(v))
+	case float32:
+		C.qail_value_float(c.handle, C.double(v))
+	case float64:
+		C.qail_value_float(c.handle, C.double(v))
+	case bool:
+		b := 0
+		if v {
+			b = 1
+		}
+		C.qail_value_bool(c.handle, C.int(b))
+	case string:
+		if strings.IndexByte(v, 0) >= 0 {
+			return errors.New("string contains a NUL byte")
+		}
+		cVal := C.CString(v)
+		C.qail_value_str(c.handle, cVal)
+		C.free(unsafe.Pointer(cVal))
+	case []byte:
+		if v == nil {
+			C.qail_value_null(c.handle)
+			break
+		}
+		// bytea's hex form, which also keeps NUL bytes out of the C string.
+		cVal := C.CString(`\x` + hex.EncodeToString(v))
+		C.qail_value_str(c.handle, cVal)
+		C.free(unsafe.Pointer(cVal))
+	case time.Time:
+		cVal := C.CString(v.Format("2006-01-02 15:04:05.999999Z07:00"))
+		C.qail_value_str(c.handle, cVal)
+		C.free(unsafe.Pointer(cVal))
+	default:
+		return fmt.Errorf("unsupported value type %T", v)
+	}
+	return nil
+}
+
+// Returning adds a RETURNING clause to an INSERT; "*" returns every
+// column.
+func (c *Qail) Returning(cols ...string) *Qail {
+	for _, col := range cols {
+		cCol := C.CString(col)
+		C.qail_returning(c.handle, cCol)
+		C.free(unsafe.Pointer(cCol))
+	}
+	return c
+}
+
 // Offset sets the OFFSET clause.
 func (c *Qail) Offset(offset int64) *Qail {
 	C.qail_offset(c.handle, C.int64_t(offset))
@@ -172,19 +570,109 @@ func (c *Qail) Offset(offset int64) *Qail {
 }
 
 // Encode returns PostgreSQL wire protocol bytes for this command.
+// Returns nil if encoding failed or produced no bytes.
 func (c *Qail) Encode() []byte {
+	if c.err != nil {
+		return nil
+	}
 	var outLen C.size_t
 	ptr := C.qail_encode(c.handle, &outLen)
+	return takeBytes(ptr, outLen)
+}
+
+// ParamCount returns the number of bind parameters this command encodes
+// to, or -1 if the handle is invalid.
+func (c *Qail) ParamCount() int {
+	return int(C.qail_param_count(c.handle))
+}
+
+// IsReadOnly reports whether the command only reads: it was built with
+// Get, takes no row lock (FOR UPDATE etc.), and any CTEs are reads too.
+// Driver uses it to send reads to Config.ReadHosts.
+func (c *Qail) IsReadOnly() bool {
+	return C.qail_is_read_only(c.handle) != 0
+}
+
+// SQL returns the SQL text this command encodes to, with $n placeholders
+// for bound parameters. Returns "" if the command cannot be rendered; use
+// ToSQL to learn why.
+func (c *Qail) SQL() string {
+	sql, _ := c.ToSQL()
+	return sql
+}
+
+// ToSQL is SQL reporting why a command cannot be rendered: the builder
+// error recorded by Err, or an action without a SQL form. Literal values
+// are $1..$L and Param(n) is $L+n, exactly as sent by ExecuteParams.
+func (c *Qail) ToSQL() (string, error) {
+	if c.err != nil {
+		return "", c.err
+	}
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	ptr := C.qail_to_sql(c.handle)
+	if ptr == nil {
+		return "", lastError()
+	}
+	defer C.qail_string_free(ptr)
+	return C.GoString(ptr), nil
+}
+
+// takeBytes copies an encoder result into Go memory and frees the Rust
+// buffer. A nil pointer or zero-length result is reported as nil: sending
+// zero bytes would leave the caller waiting on a response that never comes.
+func takeBytes(ptr *C.uint8_t, outLen C.size_t) []byte {
 	if ptr == nil {
 		return nil
 	}
-	
-	// Copy to Go-managed memory
+	if outLen == 0 {
+		// Empty boxed slices own no allocation; nothing to free.
+		return nil
+	}
 	bytes := C.GoBytes(unsafe.Pointer(ptr), C.int(outLen))
 	C.qail_bytes_free(ptr, outLen)
 	return bytes
 }
 
+// sqlParams renders the command for Driver.ExecuteParams: the SQL text and
+// its literal parameters, with Param placeholders numbered after them.
+func (c *Qail) sqlParams() (string, [][]byte, error) {
+	if c.err != nil {
+		return "", nil, c.err
+	}
+	var outLen C.size_t
+	buf := takeBytes(C.qail_encode_sql_params(c.handle, &outLen), outLen)
+	if len(buf) < 4 {
+		return "", nil, encodeError(c)
+	}
+
+	n := int(binary.BigEndian.Uint32(buf))
+	if len(buf) < 4+n+2 {
+		return "", nil, errMalformed("SQL params")
+	}
+	sql := string(buf[4 : 4+n])
+	pos := 4 + n
+	params := make([][]byte, binary.BigEndian.Uint16(buf[pos:]))
+	pos += 2
+	for i := range params {
+		if pos+4 > len(buf) {
+			return "", nil, errMalformed("SQL params")
+		}
+		l := int32(binary.BigEndian.Uint32(buf[pos:]))
+		pos += 4
+		if l < 0 {
+			continue // NULL
+		}
+		if pos+int(l) > len(buf) {
+			return "", nil, errMalformed("SQL params")
+		}
+		params[i] = buf[pos : pos+int(l) : pos+int(l)]
+		pos += int(l)
+	}
+	return sql, params, nil
+}
+
 // Free releases the command handle.
 func (c *Qail) Free() {
 	if c.handle != nil {
@@ -193,6 +681,24 @@ func (c *Qail) Free() {
 	}
 }
 
+// Clone returns an independent deep copy of the command, including any
+// recorded error. The copy shares no memory with c: either can be
+// modified, and each must be released with its own Free. Cloning a
+// template and adding filters per call avoids rebuilding the shared part.
+func (c *Qail) Clone() *Qail {
+	return &Qail{handle: C.qail_clone(c.handle), err: c.err}
+}
+
+// Reset clears columns, filters, limit and every other clause, keeping
+// the table and command kind, so a hot loop can rebuild and re-encode
+// one handle instead of allocating and freeing a command per query.
+// It also clears the error reported by Err.
+func (c *Qail) Reset() *Qail {
+	C.qail_reset(c.handle)
+	c.err = nil
+	return c
+}
+
 // EncodeBatch encodes multiple commands in a single CGO call.
 // This is the key optimization for beating pgx.
 func EncodeBatch(cmds []*Qail) []byte {
@@ -203,18 +709,15 @@ func EncodeBatch(cmds []*Qail) []byte {
 	// Build array of handles
 	handles := make([]C.QailHandle, len(cmds))
 	for i, cmd := range cmds {
+		if cmd.err != nil {
+			return nil
+		}
 		handles[i] = cmd.handle
 	}
 	
 	var outLen C.size_t
 	ptr := C.qail_batch_encode(&handles[0], C.size_t(len(cmds)), &outLen)
-	if ptr == nil {
-		return nil
-	}
-	
-	bytes := C.GoBytes(unsafe.Pointer(ptr), C.int(outLen))
-	C.qail_bytes_free(ptr, outLen)
-	return bytes
+	return takeBytes(ptr, outLen)
 }
 
 // EncodeSelectBatchFast encodes batch of SELECT queries in ONE CGO call.
@@ -243,13 +746,141 @@ func EncodeSelectBatchFast(table, columns string, limits []int64) []byte {
 		C.size_t(len(limits)),
 		&outLen,
 	)
-	if ptr == nil {
+	return takeBytes(ptr, outLen)
+}
+
+// EncodeSelectBatchBinary encodes the same batch as EncodeSelectBatchFast,
+// but as ONE parsed statement (`... LIMIT $1`) followed by a Bind + Execute
+// per query, with each limit sent as a binary int8 parameter. The server
+// parses the SQL once instead of once per query. A limit <= 0 means no limit.
+func EncodeSelectBatchBinary(table, columns string, limits []int64) []byte {
+	if len(limits) == 0 {
 		return nil
 	}
 
-	bytes := C.GoBytes(unsafe.Pointer(ptr), C.int(outLen))
-	C.qail_bytes_free(ptr, outLen)
-	return bytes
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	cColumns := C.CString(columns)
+	defer C.free(unsafe.Pointer(cColumns))
+
+	var outLen C.size_t
+	ptr := C.qail_encode_select_batch_binary(
+		cTable,
+		cColumns,
+		(*C.int64_t)(&limits[0]),
+		C.size_t(len(limits)),
+		&outLen,
+	)
+	return takeBytes(ptr, outLen)
+}
+
+// EncodeSelectBatchParams encodes a batch of SELECT queries that share
+// ONE parsed statement, filtered on filters (`f1 = $1 AND f2 = $2 ...`),
+// followed by a Bind + Execute per row of args. Args are sent as binary
+// parameters, so the server skips parsing their text: int64 as int8,
+// float64 as float8 and bool as bool, with nil binding NULL. Every row
+// has one arg per filter, and each filter's args share one type.
+func EncodeSelectBatchParams(table, columns string, filters []string, rows [][]any) ([]byte, error) {
+	if len(filters) == 0 || len(rows) == 0 {
+		return nil, fmt.Errorf("%w: empty batch", ErrInvalidArgument)
+	}
+	n := len(filters)
+	oids := make([]uint32, n) // 0 until a non-nil arg sets the type
+	values := make([]uint64, 0, n*len(rows))
+	nulls := make([]uint8, 0, n*len(rows))
+	for i, row := range rows {
+		if len(row) != n {
+			return nil, fmt.Errorf("%w: row %d has %d args for %d filters", ErrInvalidArgument, i, len(row), n)
+		}
+		for j, arg := range row {
+			var oid uint32
+			var bits uint64
+			switch v := arg.(type) {
+			case nil:
+				values = append(values, 0)
+				nulls = append(nulls, 1)
+				continue
+			case int64:
+				oid, bits = OIDInt8, uint64(v)
+			case float64:
+				oid, bits = OIDFloat8, math.Float64bits(v)
+			case bool:
+				oid = OIDBool
+				if v {
+					bits = 1
+				}
+			default:
+				return nil, fmt.Errorf("%w: row %d, %s: unsupported binary parameter type %T", ErrInvalidArgument, i, filters[j], arg)
+			}
+			if oids[j] == 0 {
+				oids[j] = oid
+			} else if oids[j] != oid {
+				return nil, fmt.Errorf("%w: row %d, %s: %T does not match the type of earlier rows", ErrInvalidArgument, i, filters[j], arg)
+			}
+			values = append(values, bits)
+			nulls = append(nulls, 0)
+		}
+	}
+
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	cColumns := C.CString(columns)
+	defer C.free(unsafe.Pointer(cColumns))
+
+	cFilters := C.CString(strings.Join(filters, ","))
+	defer C.free(unsafe.Pointer(cFilters))
+
+	var outLen C.size_t
+	ptr := C.qail_encode_select_batch_params(
+		cTable,
+		cColumns,
+		cFilters,
+		(*C.uint32_t)(&oids[0]),
+		C.size_t(n),
+		(*C.uint64_t)(&values[0]),
+		(*C.uint8_t)(&nulls[0]),
+		C.size_t(len(rows)),
+		&outLen,
+	)
+	if ptr == nil {
+		return nil, fmt.Errorf("%w: params batch", ErrEncode)
+	}
+	return takeBytes(ptr, outLen), nil
+}
+
+// EncodeSelectBatchPaged is EncodeSelectBatchFast with an OFFSET per
+// query as well as a LIMIT, for paginated bulk reads. limits and offsets
+// must have the same length; a value <= 0 omits that clause. An empty
+// batch is an ErrInvalidArgument, and a failed encode an ErrEncode.
+func EncodeSelectBatchPaged(table, columns string, limits, offsets []int64) ([]byte, error) {
+	if len(limits) != len(offsets) {
+		return nil, fmt.Errorf("%w: %d limits but %d offsets", ErrInvalidArgument, len(limits), len(offsets))
+	}
+	if len(limits) == 0 {
+		return nil, fmt.Errorf("%w: empty batch", ErrInvalidArgument)
+	}
+
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	cColumns := C.CString(columns)
+	defer C.free(unsafe.Pointer(cColumns))
+
+	var outLen C.size_t
+	ptr := C.qail_encode_select_batch_paged(
+		cTable,
+		cColumns,
+		(*C.int64_t)(&limits[0]),
+		(*C.int64_t)(&offsets[0]),
+		C.size_t(len(limits)),
+		&outLen,
+	)
+	if ptr == nil {
+		return nil, fmt.Errorf("%w: paged batch", ErrEncode)
+	}
+	return takeBytes(ptr, outLen), nil
 }
 
 // =============================================================================
@@ -262,21 +893,70 @@ type RustConn struct {
 	handle C.ConnHandle
 }
 
+// RustConfig configures a Rust I/O connection (RustConn or RustConnV2).
+type RustConfig struct {
+	Host     string
+	Port     uint16
+	User     string
+	Database string
+	Password string
+	SSLMode  string // "disable", "require", "prefer" (default)
+
+	// ConnectTimeout bounds the TCP connect, TLS handshake and
+	// authentication together. Zero means no timeout.
+	ConnectTimeout time.Duration
+}
+
+// rustConfigArgs holds the C strings for a RustConfig; free releases them.
+type rustConfigArgs struct {
+	host, user, database, password, sslMode *C.char
+	timeoutMs                               C.uint64_t
+}
+
+func newRustConfigArgs(cfg RustConfig) *rustConfigArgs {
+	return &rustConfigArgs{
+		host:      C.CString(cfg.Host),
+		user:      C.CString(cfg.User),
+		database:  C.CString(cfg.Database),
+		password:  C.CString(cfg.Password),
+		sslMode:   C.CString(cfg.SSLMode),
+		timeoutMs: C.uint64_t(cfg.ConnectTimeout.Milliseconds()),
+	}
+}
+
+func (a *rustConfigArgs) free() {
+	C.free(unsafe.Pointer(a.host))
+	C.free(unsafe.Pointer(a.user))
+	C.free(unsafe.Pointer(a.database))
+	C.free(unsafe.Pointer(a.password))
+	C.free(unsafe.Pointer(a.sslMode))
+}
+
 // RustConnect creates a connection using Rust Tokio for I/O.
 // This is the FAST PATH - all TCP is handled by Rust.
+// It connects without a password or SSL; see RustConnectConfig.
 func RustConnect(host string, port uint16, user, database string) (*RustConn, error) {
-	cHost := C.CString(host)
-	defer C.free(unsafe.Pointer(cHost))
+	return RustConnectConfig(RustConfig{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Database: database,
+		SSLMode:  "disable",
+	})
+}
 
-	cUser := C.CString(user)
-	defer C.free(unsafe.Pointer(cUser))
+// RustConnectConfig creates a RustConn from a RustConfig.
+func RustConnectConfig(cfg RustConfig) (*RustConn, error) {
+	args := newRustConfigArgs(cfg)
+	defer args.free()
 
-	cDatabase := C.CString(database)
-	defer C.free(unsafe.Pointer(cDatabase))
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 
-	handle := C.qail_connect(cHost, C.uint16_t(port), cUser, cDatabase)
+	handle := C.qail_connect_config(args.host, C.uint16_t(cfg.Port), args.user, args.database,
+		args.password, args.sslMode, args.timeoutMs)
 	if handle == nil {
-		return nil, fmt.Errorf("failed to connect to %s:%d", host, port)
+		return nil, fmt.Errorf("failed to connect to %s:%d: %w", cfg.Host, cfg.Port, lastError())
 	}
 
 	return &RustConn{handle: handle}, nil
@@ -295,6 +975,9 @@ func (c *RustConn) ExecuteBatch(table, columns string, limits []int64) (int64, e
 	cColumns := C.CString(columns)
 	defer C.free(unsafe.Pointer(cColumns))
 
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
 	result := C.qail_execute_batch(
 		c.handle,
 		cTable,
@@ -304,12 +987,107 @@ func (c *RustConn) ExecuteBatch(table, columns string, limits []int64) (int64, e
 	)
 
 	if result < 0 {
-		return 0, fmt.Errorf("batch execution failed")
+		return 0, fmt.Errorf("batch execution failed: %w", lastError())
 	}
 
 	return int64(result), nil
 }
 
+// ExecuteBatchContext is ExecuteBatch that gives up when ctx ends: the
+// Rust side is told through a shared cancellation flag, sends the server
+// a CancelRequest and returns, and ctx.Err() is returned. The batch's
+// responses are then still in flight, so the connection is dropped and
+// the RustConn must be closed and replaced.
+func (c *RustConn) ExecuteBatchContext(ctx context.Context, table, columns string, limits []int64) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if ctx.Done() == nil {
+		return c.ExecuteBatch(table, columns, limits)
+	}
+	if len(limits) == 0 {
+		return 0, nil
+	}
+
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	cColumns := C.CString(columns)
+	defer C.free(unsafe.Pointer(cColumns))
+
+	flag := C.qail_cancel_flag_new()
+	fired := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		C.qail_cancel_flag_set(flag)
+		close(fired)
+	})
+	defer func() {
+		// The flag must outlive a concurrent qail_cancel_flag_set.
+		if !stop() {
+			<-fired
+		}
+		C.qail_cancel_flag_free(flag)
+	}()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	result := C.qail_execute_batch_ctx(
+		c.handle,
+		cTable,
+		cColumns,
+		(*C.int64_t)(&limits[0]),
+		C.size_t(len(limits)),
+		flag,
+	)
+
+	if result == batchCancelled {
+		return 0, ctx.Err()
+	}
+	if result < 0 {
+		return 0, fmt.Errorf("batch execution failed: %w", lastError())
+	}
+
+	return int64(result), nil
+}
+
+// batchCancelled is qail_execute_batch_ctx's result when cancelled.
+const batchCancelled = -2
+
+// ExecuteBatchRows executes a batch of queries entirely in Rust and
+// returns the rows of each query. ONE CGO call for the whole batch; the
+// rows come back in a single buffer (see decodeBatchRows). Rows carry no
+// column metadata, so getters read them as text.
+func (c *RustConn) ExecuteBatchRows(table, columns string, limits []int64) ([][]Row, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	cColumns := C.CString(columns)
+	defer C.free(unsafe.Pointer(cColumns))
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var outLen C.size_t
+	ptr := C.qail_execute_batch_rows(
+		c.handle,
+		cTable,
+		cColumns,
+		(*C.int64_t)(&limits[0]),
+		C.size_t(len(limits)),
+		&outLen,
+	)
+	buf := takeBytes(ptr, outLen)
+	if buf == nil {
+		return nil, fmt.Errorf("batch execution failed: %w", lastError())
+	}
+	return decodeBatchRows(buf)
+}
+
 // Close closes the Rust connection.
 func (c *RustConn) Close() {
 	if c.handle != nil {
@@ -329,19 +1107,30 @@ type RustConnV2 struct {
 
 // RustConnectV2 creates a connection using channel-based async.
 // This is the FASTEST PATH - no block_on per query!
+// It connects without a password or SSL; see RustConnectConfigV2.
 func RustConnectV2(host string, port uint16, user, database string) (*RustConnV2, error) {
-	cHost := C.CString(host)
-	defer C.free(unsafe.Pointer(cHost))
+	return RustConnectConfigV2(RustConfig{
+		Host:     host,
+		Port:     port,
+		User:     user,
+		Database: database,
+		SSLMode:  "disable",
+	})
+}
 
-	cUser := C.CString(user)
-	defer C.free(unsafe.Pointer(cUser))
+// RustConnectConfigV2 creates a RustConnV2 from a RustConfig. It returns
+// once the connection is established, or with the reason it failed.
+func RustConnectConfigV2(cfg RustConfig) (*RustConnV2, error) {
+	args := newRustConfigArgs(cfg)
+	defer args.free()
 
-	cDatabase := C.CString(database)
-	defer C.free(unsafe.Pointer(cDatabase))
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
 
-	handle := C.qail_connect_v2(cHost, C.uint16_t(port), cUser, cDatabase)
+	handle := C.qail_connect_config_v2(args.host, C.uint16_t(cfg.Port), args.user, args.database,
+		args.password, args.sslMode, args.timeoutMs)
 	if handle == nil {
-		return nil, fmt.Errorf("failed to connect to %s:%d", host, port)
+		return nil, fmt.Errorf("failed to connect to %s:%d: %w", cfg.Host, cfg.Port, lastError())
 	}
 
 	return &RustConnV2{handle: handle}, nil
@@ -359,6 +1148,9 @@ func (c *RustConnV2) ExecuteBatch(table, columns string, limits []int64) (int64,
 	cColumns := C.CString(columns)
 	defer C.free(unsafe.Pointer(cColumns))
 
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
 	result := C.qail_execute_batch_v2(
 		c.handle,
 		cTable,
@@ -368,12 +1160,44 @@ func (c *RustConnV2) ExecuteBatch(table, columns string, limits []int64) (int64,
 	)
 
 	if result < 0 {
-		return 0, fmt.Errorf("batch execution failed")
+		return 0, fmt.Errorf("batch execution failed: %w", lastError())
 	}
 
 	return int64(result), nil
 }
 
+// ExecuteBatchRows executes a batch of queries via async channel and
+// returns the rows of each query. See RustConn.ExecuteBatchRows.
+func (c *RustConnV2) ExecuteBatchRows(table, columns string, limits []int64) ([][]Row, error) {
+	if len(limits) == 0 {
+		return nil, nil
+	}
+
+	cTable := C.CString(table)
+	defer C.free(unsafe.Pointer(cTable))
+
+	cColumns := C.CString(columns)
+	defer C.free(unsafe.Pointer(cColumns))
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	var outLen C.size_t
+	ptr := C.qail_execute_batch_rows_v2(
+		c.handle,
+		cTable,
+		cColumns,
+		(*C.int64_t)(&limits[0]),
+		C.size_t(len(limits)),
+		&outLen,
+	)
+	buf := takeBytes(ptr, outLen)
+	if buf == nil {
+		return nil, fmt.Errorf("batch execution failed: %w", lastError())
+	}
+	return decodeBatchRows(buf)
+}
+
 // Close closes the connection.
 func (c *RustConnV2) Close() {
 	if c.handle != nil {
@@ -381,3 +1205,74 @@ func (c *RustConnV2) Close() {
 		c.handle = nil
 	}
 }
+
+// lastError returns the message recorded by the last failed Rust call.
+// The message is thread-local on the Rust side, so callers must hold
+// runtime.LockOSThread across the failing call and this one.
+func lastError() error {
+	msg := C.qail_last_error()
+	if msg == nil {
+		return errors.New("unknown error")
+	}
+	return errors.New(C.GoString(msg))
+}
+
+// decodeBatchRows parses the row buffer returned by the Rust I/O path.
+// All integers are big-endian:
+//
+//	u32 query_count
+//	  per query: u32 row_count
+//	    per row: u16 column_count
+//	      per column: i32 length (-1 = NULL), then length bytes
+//
+// Column values alias buf.
+func decodeBatchRows(buf []byte) ([][]Row, error) {
+	pos := 0
+	need := func(n int) bool { return pos+n <= len(buf) }
+
+	if !need(4) {
+		return nil, errMalformed("batch rows")
+	}
+	nQueries := int(binary.BigEndian.Uint32(buf[pos:]))
+	pos += 4
+
+	// The counts come from the buffer, so cap the capacities by what the
+	// buffer can hold: each query takes at least 4 bytes, each row 2.
+	results := make([][]Row, 0, min(nQueries, (len(buf)-pos)/4))
+	for q := 0; q < nQueries; q++ {
+		if !need(4) {
+			return nil, errMalformed("batch rows")
+		}
+		nRows := int(binary.BigEndian.Uint32(buf[pos:]))
+		pos += 4
+
+		rows := make([]Row, 0, min(nRows, (len(buf)-pos)/2))
+		for r := 0; r < nRows; r++ {
+			if !need(2) {
+				return nil, errMalformed("batch rows")
+			}
+			nCols := int(binary.BigEndian.Uint16(buf[pos:]))
+			pos += 2
+
+			cols := make([][]byte, nCols)
+			for i := range cols {
+				if !need(4) {
+					return nil, errMalformed("batch rows")
+				}
+				n := int32(binary.BigEndian.Uint32(buf[pos:]))
+				pos += 4
+				if n < 0 {
+					continue // NULL
+				}
+				if !need(int(n)) {
+					return nil, errMalformed("batch rows")
+				}
+				cols[i] = buf[pos : pos+int(n) : pos+int(n)]
+				pos += int(n)
+			}
+			rows = append(rows, Row{columns: cols})
+		}
+		results = append(results, rows)
+	}
+	return results, nil
+}
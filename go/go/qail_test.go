@@ -0,0 +1,282 @@
+package qail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// complexLimits returns the LIMITs of the complex-query workload
+// (pg/examples/million_complex.rs): query i selects i%10+1 harbors.
+func complexLimits(n int) []int64 {
+	limits := make([]int64, n)
+	for i := range limits {
+		limits[i] = int64(i%10 + 1)
+	}
+	return limits
+}
+
+// BenchmarkEncodeSelectBatch compares the text-parameter fast batch
+// encoder with the binary one on a 10,000-query batch.
+func BenchmarkEncodeSelectBatch(b *testing.B) {
+	limits := complexLimits(10_000)
+	for _, bc := range []struct {
+		name   string
+		encode func(table, columns string, limits []int64) []byte
+	}{
+		{"text", EncodeSelectBatchFast},
+		{"binary", EncodeSelectBatchBinary},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			wire := bc.encode("harbors", "id,name", limits)
+			if len(wire) == 0 {
+				b.Fatal("encode failed")
+			}
+			b.SetBytes(int64(len(wire)))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				bc.encode("harbors", "id,name", limits)
+			}
+		})
+	}
+}
+
+// parsedSQL returns the query of each Parse message in wire.
+func parsedSQL(t *testing.T, wire []byte) []string {
+	t.Helper()
+	var sqls []string
+	for len(wire) > 0 {
+		if len(wire) < 5 {
+			t.Fatalf("truncated message header % x", wire)
+		}
+		n := int(binary.BigEndian.Uint32(wire[1:5])) + 1
+		if n < 5 || n > len(wire) {
+			t.Fatalf("bad length for message %q", wire[0])
+		}
+		if wire[0] == 'P' {
+			body := wire[5:n]
+			_, rest, _ := bytes.Cut(body, []byte{0}) // statement name
+			sql, _, _ := bytes.Cut(rest, []byte{0})
+			sqls = append(sqls, string(sql))
+		}
+		wire = wire[n:]
+	}
+	return sqls
+}
+
+func TestEncodeSelectBatchPaged(t *testing.T) {
+	wire, err := EncodeSelectBatchPaged("harbors", "id,name", []int64{10, 10, 0}, []int64{20, 0, 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqls := parsedSQL(t, wire)
+	if len(sqls) != 3 {
+		t.Fatalf("got %d queries, want 3: %q", len(sqls), sqls)
+	}
+	for i, tt := range []struct{ want, notWant []string }{
+		{want: []string{"LIMIT 10", "OFFSET 20"}},
+		{want: []string{"LIMIT 10"}, notWant: []string{"OFFSET"}},
+		{want: []string{"OFFSET 5"}, notWant: []string{"LIMIT"}},
+	} {
+		for _, w := range tt.want {
+			if !strings.Contains(sqls[i], w) {
+				t.Errorf("query %d = %q, want %s", i, sqls[i], w)
+			}
+		}
+		for _, w := range tt.notWant {
+			if strings.Contains(sqls[i], w) {
+				t.Errorf("query %d = %q, want no %s", i, sqls[i], w)
+			}
+		}
+	}
+
+	if _, err := EncodeSelectBatchPaged("harbors", "id", nil, nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("empty batch: err = %v, want ErrInvalidArgument", err)
+	}
+	if _, err := EncodeSelectBatchPaged("harbors", "id", []int64{1}, nil); !errors.Is(err, ErrInvalidArgument) {
+		t.Errorf("mismatched lengths: err = %v, want ErrInvalidArgument", err)
+	}
+}
+
+// boundParams returns the parameter values of each Bind message in wire,
+// nil for NULL.
+func boundParams(t *testing.T, wire []byte) [][][]byte {
+	t.Helper()
+	var binds [][][]byte
+	for len(wire) >= 5 {
+		n := int(binary.BigEndian.Uint32(wire[1:5])) + 1
+		if n < 5 || n > len(wire) {
+			t.Fatalf("bad length for message %q", wire[0])
+		}
+		if wire[0] == 'B' {
+			body := wire[5:n]
+			_, body, _ = bytes.Cut(body, []byte{0}) // portal
+			_, body, _ = bytes.Cut(body, []byte{0}) // statement
+			nFormats := int(binary.BigEndian.Uint16(body))
+			body = body[2+2*nFormats:]
+			params := make([][]byte, binary.BigEndian.Uint16(body))
+			body = body[2:]
+			for i := range params {
+				size := int32(binary.BigEndian.Uint32(body))
+				body = body[4:]
+				if size >= 0 {
+					params[i], body = body[:size], body[size:]
+				}
+			}
+			binds = append(binds, params)
+		}
+		wire = wire[n:]
+	}
+	return binds
+}
+
+func TestEncodeSelectBatchParams(t *testing.T) {
+	wire, err := EncodeSelectBatchParams("harbors", "id,name", []string{"id", "score", "active"}, [][]any{
+		{int64(7), 2.5, true},
+		{int64(-1), nil, false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqls := parsedSQL(t, wire)
+	if len(sqls) != 1 || !strings.Contains(sqls[0], "active = $3") {
+		t.Fatalf("parsed %q, want one statement with three parameters", sqls)
+	}
+	want := [][][]byte{
+		{{0, 0, 0, 0, 0, 0, 0, 7}, {0x40, 0x04, 0, 0, 0, 0, 0, 0}, {1}},
+		{{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, nil, {0}},
+	}
+	if got := boundParams(t, wire); !reflect.DeepEqual(got, want) {
+		t.Errorf("bound %v, want %v", got, want)
+	}
+
+	for name, rows := range map[string][][]any{
+		"empty":         nil,
+		"short row":     {{int64(1)}},
+		"text":          {{"7", 2.5, true}},
+		"int":           {{7, 2.5, true}},
+		"type mismatch": {{int64(1), 2.5, true}, {int64(2), true, true}},
+	} {
+		if _, err := EncodeSelectBatchParams("harbors", "id", []string{"id", "score", "active"}, rows); !errors.Is(err, ErrInvalidArgument) {
+			t.Errorf("%s: err = %v, want ErrInvalidArgument", name, err)
+		}
+	}
+}
+
+func TestDecodeBatchRowsHostileCounts(t *testing.T) {
+	// Counts far beyond what the buffer holds must fail as malformed, not
+	// size allocations: 2^32-1 rows would need hundreds of gigabytes.
+	oneRow := binary.BigEndian.AppendUint16(nil, 1)
+	oneRow = binary.BigEndian.AppendUint32(oneRow, 2)
+	oneRow = append(oneRow, "ok"...)
+	for _, tt := range []struct {
+		name          string
+		queries, rows uint32
+	}{
+		{"rows", 1, 0xFFFFFFFF},
+		{"queries", 0xFFFFFFFF, 1},
+	} {
+		buf := binary.BigEndian.AppendUint32(nil, tt.queries)
+		buf = binary.BigEndian.AppendUint32(buf, tt.rows)
+		buf = append(buf, oneRow...)
+		if _, err := decodeBatchRows(buf); err == nil {
+			t.Errorf("%s: no error for counts the buffer cannot hold", tt.name)
+		}
+	}
+}
+
+func TestAggregateSQL(t *testing.T) {
+	for _, tt := range []struct {
+		cmd  *Qail
+		want string
+	}{
+		{Get("orders").CountAll(), "SELECT COUNT(*) FROM orders"},
+		{Get("orders").Count("id", ""), "SELECT COUNT(id) FROM orders"},
+		{Get("orders").Avg("total").Min("total").Max("total", "top"),
+			"SELECT AVG(total), MIN(total), MAX(total) AS top FROM orders"},
+		// Plain columns are grouped automatically...
+		{Get("orders").Column("region").CountAll("n").Sum("total", "revenue"),
+			"SELECT region, COUNT(*) AS n, SUM(total) AS revenue FROM orders GROUP BY region"},
+		// ...unless GroupBy names the groups; repeated calls extend them.
+		{Get("orders").Columns("region", "day").Count("id").GroupBy("region").GroupBy("day"),
+			"SELECT region, day, COUNT(id) FROM orders GROUP BY region, day"},
+	} {
+		got, err := tt.cmd.ToSQL()
+		tt.cmd.Free()
+		if err != nil || got != tt.want {
+			t.Errorf("SQL = %q, %v; want %q", got, err, tt.want)
+		}
+	}
+}
+
+func TestFilterNullSQL(t *testing.T) {
+	for _, tt := range []struct {
+		cmd    *Qail
+		want   string
+		params [][]byte
+	}{
+		{Get("users").FilterIsNull("deleted_at").FilterIsNotNull("email"),
+			"SELECT * FROM users WHERE deleted_at IS NULL AND email IS NOT NULL", [][]byte{}},
+		// Inside a group the leaves go through the expression tree, which
+		// must not bind a parameter for the NULL either.
+		{Get("tickets").FilterOr(func(g *Group) {
+			g.FilterIsNull("closed_at")
+			g.Filter("status", Eq, "open")
+		}),
+			"SELECT * FROM tickets WHERE (closed_at IS NULL OR status = $1)", [][]byte{[]byte("open")}},
+		{Get("tickets").Filter("status", Eq, "open").FilterAnd(func(g *Group) {
+			g.FilterIsNotNull("assignee")
+			g.FilterIsNull("closed_at")
+		}),
+			"SELECT * FROM tickets WHERE status = $1 AND (assignee IS NOT NULL AND closed_at IS NULL)", [][]byte{[]byte("open")}},
+	} {
+		wire := tt.cmd.Encode()
+		tt.cmd.Free()
+		if sqls := parsedSQL(t, wire); len(sqls) != 1 || sqls[0] != tt.want {
+			t.Errorf("parsed %q, want %q", sqls, tt.want)
+			continue
+		}
+		if got := boundParams(t, wire); len(got) != 1 || !reflect.DeepEqual(got[0], tt.params) {
+			t.Errorf("%s: bound %q, want %q", tt.want, got, tt.params)
+		}
+	}
+}
+
+func TestReset(t *testing.T) {
+	cmd := Get("harbors").Columns("id", "name").Filter("id", Eq, 1).Limit(5)
+	defer cmd.Free()
+	cmd.Reset().Column("name").FilterIsNull("closed_at")
+	if got, want := cmd.SQL(), "SELECT name FROM harbors WHERE closed_at IS NULL"; got != want {
+		t.Errorf("SQL after Reset = %q, want %q", got, want)
+	}
+}
+
+// BenchmarkReset compares rebuilding one handle with Reset against
+// allocating and freeing a command for every query.
+func BenchmarkReset(b *testing.B) {
+	b.Run("reset", func(b *testing.B) {
+		cmd := Get("harbors")
+		defer cmd.Free()
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd.Reset().Columns("id", "name").Filter("id", Eq, i%10+1)
+			if len(cmd.Encode()) == 0 {
+				b.Fatal("encode failed")
+			}
+		}
+	})
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd := Get("harbors").Columns("id", "name").Filter("id", Eq, i%10+1)
+			if len(cmd.Encode()) == 0 {
+				b.Fatal("encode failed")
+			}
+			cmd.Free()
+		}
+	})
+}
@@ -0,0 +1,818 @@
+// Package qailtest provides a scriptable fake PostgreSQL server for
+// testing code that uses the qail driver without a real database.
+//
+// The server speaks the subset of the wire protocol the driver uses: the
+// startup handshake (trust or cleartext password, optionally over TLS),
+// simple queries, COPY FROM STDIN, and the extended protocol (Parse,
+// Bind, Describe, Execute with row limits, Flush, Sync, Close). Each
+// statement is answered from canned responses keyed by its SQL text, or
+// by a built command with HandleCmd:
+//
+//	srv := qailtest.NewServer()
+//	srv.Handle("SELECT id, name FROM users", qailtest.Response{
+//	    Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}, {Name: "name", OID: qailtest.OIDText}},
+//	    Rows:    [][]any{{1, "ada"}, {2, nil}},
+//	})
+//	d, err := qail.NewDriver(qail.Config{User: "test", Database: "test", SSLMode: "disable", DialFunc: srv.Dial})
+//
+// Values are always sent in text format. Each connection tracks its
+// transaction status from the statements it runs: BEGIN or START opens a
+// transaction block, COMMIT, END, ROLLBACK or ABORT closes it, and a
+// failed statement inside one leaves it failed, rejecting everything
+// until it is closed. ReadyForQuery reports that status as a real server would.
+package qailtest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Type OIDs for Column.OID.
+const (
+	OIDBool    uint32 = 16
+	OIDBytea   uint32 = 17
+	OIDInt8    uint32 = 20
+	OIDInt2    uint32 = 21
+	OIDInt4    uint32 = 23
+	OIDText    uint32 = 25
+	OIDFloat8  uint32 = 701
+	OIDNumeric uint32 = 1700
+)
+
+// Column describes one result column.
+type Column struct {
+	Name string
+	OID  uint32
+}
+
+// Error is an ErrorResponse sent in place of a result.
+type Error struct {
+	Severity string // default "ERROR"
+	Code     string // SQLSTATE, e.g. "23505"
+	Message  string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s (SQLSTATE %s)", e.Severity, e.Message, e.Code)
+}
+
+// Response is the canned answer to one statement. With Err set the
+// statement fails and the rest of the Response is ignored.
+type Response struct {
+	Columns   []Column // nil for a statement that returns no rows
+	Rows      [][]any  // text form of each value via fmt; nil is NULL, []byte is sent as is
+	Tag       string   // CommandComplete tag; default "SELECT n" with rows, else "OK"
+	ParamOIDs []uint32 // reported by Describe(Statement)
+	Notify    []Notification
+	Err       *Error
+}
+
+// Notification is a NotificationResponse the server sends, as if from its
+// own backend, after the statement completes.
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+// Query is a statement as the server received it.
+type Query struct {
+	SQL  string
+	Args [][]byte // bound parameter values, nil for NULL; none for simple queries
+	Copy []byte   // the CopyData a COPY ... FROM STDIN received, concatenated
+}
+
+// Server is a fake PostgreSQL server. It is safe for concurrent use and
+// serves any number of connections at once.
+type Server struct {
+	// Password, when set, makes the server require it with cleartext
+	// password authentication.
+	Password string
+
+	// TLSConfig, when set, makes the server accept SSLRequest and run the
+	// rest of the connection over TLS with it. Without it SSLRequest is
+	// refused.
+	TLSConfig *tls.Config
+
+	mu       sync.Mutex
+	handlers map[string]Response
+	fallback func(Query) (Response, bool)
+	queries  []Query
+	nextPID  uint32
+}
+
+// NewServer returns a server with no canned responses.
+func NewServer() *Server {
+	return &Server{handlers: make(map[string]Response)}
+}
+
+// Handle answers every statement whose SQL is exactly sql with r.
+func (s *Server) Handle(sql string, r Response) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.handlers[sql] = r
+}
+
+// HandleCmd is Handle keyed on the SQL cmd encodes to, such as a built
+// *qail.Qail. The driver sends that SQL rather than the QAIL text, so
+// key responses on the command itself: Handle("GET users", ...) never
+// matches what Get("users") sends.
+func (s *Server) HandleCmd(cmd interface{ SQL() string }, r Response) {
+	s.Handle(cmd.SQL(), r)
+}
+
+// HandleFunc answers statements that no Handle matches. fn reports false
+// for statements it does not know, which then fail.
+func (s *Server) HandleFunc(fn func(Query) (Response, bool)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fallback = fn
+}
+
+// Queries returns every statement received so far, in order.
+func (s *Server) Queries() []Query {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Query(nil), s.queries...)
+}
+
+// Dial connects to the server over an in-memory pipe. Its signature
+// matches qail.Config.DialFunc; network and addr are ignored.
+func (s *Server) Dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	client, server := net.Pipe()
+	go s.ServeConn(server)
+	return client, nil
+}
+
+// Serve accepts connections on ln and serves each in its own goroutine
+// until Accept fails.
+func (s *Server) Serve(ln net.Listener) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go s.ServeConn(conn)
+	}
+}
+
+// ServeConn serves one client connection until it terminates or fails,
+// then closes it.
+func (s *Server) ServeConn(conn net.Conn) error {
+	defer conn.Close()
+	sc := &serverConn{
+		s:          s,
+		conn:       conn,
+		r:          bufio.NewReader(conn),
+		w:          bufio.NewWriter(conn),
+		statements: make(map[string]string),
+		portals:    make(map[string]*portal),
+		tx:         'I',
+	}
+	if err := sc.startup(); err != nil {
+		return err
+	}
+	err := sc.serve()
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrClosedPipe) {
+		return nil
+	}
+	return err
+}
+
+// respond finds the canned response for q and records q.
+func (s *Server) respond(q Query) Response {
+	s.mu.Lock()
+	s.queries = append(s.queries, q)
+	r, ok := s.handlers[q.SQL]
+	fallback := s.fallback
+	s.mu.Unlock()
+
+	if !ok && fallback != nil {
+		r, ok = fallback(q)
+	}
+	if !ok {
+		return Response{Err: &Error{Code: "XX000", Message: "qailtest: no response for " + q.SQL}}
+	}
+	return r
+}
+
+// serverConn is the state of one client connection.
+type serverConn struct {
+	s    *Server
+	conn net.Conn // the TLS connection once SSLRequest is accepted
+	r    *bufio.Reader
+	w    *bufio.Writer
+
+	pid        uint32
+	statements map[string]string // prepared statement name -> SQL
+	portals    map[string]*portal
+	failed     bool // an extended-protocol error; skip messages until Sync
+	tx         byte // transaction status for ReadyForQuery: 'I', 'T' or 'E'
+}
+
+// portal is a bound statement. An Execute with a row limit leaves it
+// suspended part way through its rows.
+type portal struct {
+	q    Query
+	r    *Response // set by the first Execute
+	sent int       // rows sent so far
+}
+
+// Startup packet codes.
+const (
+	sslRequestCode    = 80877103
+	cancelRequestCode = 80877102
+	protocolVersion3  = 196608
+)
+
+func (c *serverConn) startup() error {
+	for {
+		var hdr [8]byte
+		if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+			return err
+		}
+		length := int(binary.BigEndian.Uint32(hdr[0:4]))
+		code := binary.BigEndian.Uint32(hdr[4:8])
+		if length < 8 {
+			return fmt.Errorf("invalid startup length %d", length)
+		}
+		body := make([]byte, length-8)
+		if _, err := io.ReadFull(c.r, body); err != nil {
+			return err
+		}
+		if code == sslRequestCode {
+			if c.s.TLSConfig == nil {
+				c.w.WriteByte('N')
+				if err := c.w.Flush(); err != nil {
+					return err
+				}
+				continue
+			}
+			c.w.WriteByte('S')
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+			tlsConn := tls.Server(c.conn, c.s.TLSConfig)
+			if err := tlsConn.Handshake(); err != nil {
+				return err
+			}
+			c.conn = tlsConn
+			c.r, c.w = bufio.NewReader(tlsConn), bufio.NewWriter(tlsConn)
+			continue
+		}
+		if code == cancelRequestCode {
+			return io.EOF // nothing is ever running long enough to cancel
+		}
+		if code != protocolVersion3 {
+			return fmt.Errorf("unsupported protocol version %d", code)
+		}
+		break
+	}
+
+	if c.s.Password != "" {
+		c.msg('R', binary.BigEndian.AppendUint32(nil, 3)) // CleartextPassword
+		if err := c.w.Flush(); err != nil {
+			return err
+		}
+		typ, body, err := c.read()
+		if err != nil {
+			return err
+		}
+		if typ != 'p' || cstring(body) != c.s.Password {
+			c.sendError(&Error{Severity: "FATAL", Code: "28P01", Message: "password authentication failed"})
+			return c.w.Flush()
+		}
+	}
+
+	c.msg('R', binary.BigEndian.AppendUint32(nil, 0)) // AuthenticationOk
+	for _, kv := range [][2]string{{"client_encoding", "UTF8"}, {"server_version", "16.0"}, {"integer_datetimes", "on"}} {
+		c.msg('S', []byte(kv[0]+"\x00"+kv[1]+"\x00"))
+	}
+	c.s.mu.Lock()
+	c.s.nextPID++
+	c.pid = c.s.nextPID
+	c.s.mu.Unlock()
+	key := binary.BigEndian.AppendUint32(nil, c.pid)
+	c.msg('K', binary.BigEndian.AppendUint32(key, c.pid*7919))
+	c.msg('Z', []byte{'I'})
+	return c.w.Flush()
+}
+
+func (c *serverConn) serve() error {
+	for {
+		typ, body, err := c.read()
+		if err != nil {
+			return err
+		}
+		if c.failed && typ != 'S' {
+			continue
+		}
+		switch typ {
+		case 'Q':
+			c.simpleQuery(cstring(body))
+		case 'P':
+			c.parse(body)
+		case 'B':
+			c.bind(body)
+		case 'D':
+			c.describe(body)
+		case 'E':
+			c.execute(body)
+		case 'C':
+			c.closeMsg(body)
+		case 'S':
+			// Sync ends the implicit transaction, and its portals with it.
+			c.failed = false
+			clear(c.portals)
+			c.msg('Z', []byte{c.tx})
+		case 'H':
+		case 'X':
+			return c.w.Flush()
+		default:
+			c.protocolError(fmt.Sprintf("unsupported message type %q", typ))
+		}
+		if typ == 'Q' || typ == 'S' || typ == 'H' || c.failed {
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// simpleQuery answers a Query message. A string of several statements
+// is answered as a whole if Handle registered exactly that string;
+// otherwise each statement is answered in turn, as a server would, up to
+// the first error.
+func (c *serverConn) simpleQuery(sql string) {
+	stmts := []string{sql}
+	if !c.s.handles(sql) {
+		stmts = splitStatements(sql)
+	}
+	if len(stmts) == 0 {
+		c.msg('I', nil) // EmptyQueryResponse
+	}
+	for _, stmt := range stmts {
+		var r Response
+		if isCopyIn(stmt) {
+			var ok bool
+			if r, ok = c.copyIn(stmt); !ok {
+				return
+			}
+		} else {
+			r = c.run(Query{SQL: stmt})
+		}
+		if r.Err != nil {
+			c.sendError(r.Err)
+			break
+		}
+		if r.Columns != nil {
+			c.rowDescription(r.Columns)
+		}
+		c.rows(r)
+	}
+	c.msg('Z', []byte{c.tx})
+}
+
+// isCopyIn reports whether sql is a COPY ... FROM STDIN.
+func isCopyIn(sql string) bool {
+	upper := strings.ToUpper(sql)
+	return strings.HasPrefix(upper, "COPY ") && strings.Contains(upper, " FROM STDIN")
+}
+
+// copyIn runs a COPY ... FROM STDIN. A statement with no response fails
+// before the copy starts, as an unknown table would; otherwise the
+// CopyData up to CopyDone is collected into the Query passed to the
+// handler, whose Tag should be "COPY n". A CopyFail from the client fails
+// the statement. ok is false if the connection failed.
+func (c *serverConn) copyIn(sql string) (r Response, ok bool) {
+	if c.tx == 'E' || c.s.lookup(sql).Err != nil {
+		return c.run(Query{SQL: sql}), true
+	}
+	format := byte(0)
+	if strings.Contains(strings.ToUpper(sql), "FORMAT BINARY") {
+		format = 1
+	}
+	c.msg('G', []byte{format, 0, 0}) // CopyInResponse, no per-column formats
+	if err := c.w.Flush(); err != nil {
+		return Response{}, false
+	}
+
+	var data []byte
+	for {
+		typ, body, err := c.read()
+		if err != nil {
+			return Response{}, false
+		}
+		switch typ {
+		case 'd': // CopyData
+			data = append(data, body...)
+		case 'c': // CopyDone
+			return c.run(Query{SQL: sql, Copy: data}), true
+		case 'f': // CopyFail
+			c.s.mu.Lock()
+			c.s.queries = append(c.s.queries, Query{SQL: sql, Copy: data})
+			c.s.mu.Unlock()
+			if c.tx != 'I' {
+				c.tx = 'E'
+			}
+			return Response{Err: &Error{Code: "57014", Message: "COPY from stdin failed: " + cstring(body)}}, true
+		case 'H', 'S': // ignored during COPY
+		default:
+			return Response{Err: &Error{Code: "08P01", Message: fmt.Sprintf("unexpected message type %q during COPY", typ)}}, true
+		}
+	}
+}
+
+// handles reports whether Handle registered sql.
+func (s *Server) handles(sql string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.handlers[sql]
+	return ok
+}
+
+// splitStatements splits a simple-query string on the semicolons outside
+// quotes, dropping empty statements.
+func splitStatements(sql string) []string {
+	var stmts []string
+	var quote rune
+	start := 0
+	for i, ch := range sql {
+		switch {
+		case quote != 0:
+			if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == ';':
+			if stmt := strings.TrimSpace(sql[start:i]); stmt != "" {
+				stmts = append(stmts, stmt)
+			}
+			start = i + 1
+		}
+	}
+	if stmt := strings.TrimSpace(sql[start:]); stmt != "" {
+		stmts = append(stmts, stmt)
+	}
+	return stmts
+}
+
+// run answers q and updates the transaction status. In a failed
+// transaction only statements that end it are answered.
+func (c *serverConn) run(q Query) Response {
+	verb, rest, _ := strings.Cut(strings.ToUpper(strings.TrimSpace(q.SQL)), " ")
+	verb = strings.TrimRight(verb, ";")
+	rollbackTo := verb == "ROLLBACK" && strings.HasPrefix(strings.TrimSpace(rest), "TO ")
+	ends := verb == "COMMIT" || verb == "END" || verb == "ROLLBACK" || verb == "ABORT"
+	if c.tx == 'E' && !ends {
+		c.s.mu.Lock()
+		c.s.queries = append(c.s.queries, q)
+		c.s.mu.Unlock()
+		return Response{Err: &Error{Code: "25P02", Message: "current transaction is aborted, commands ignored until end of transaction block"}}
+	}
+	r := c.s.respond(q)
+	switch {
+	case r.Err != nil:
+		if c.tx != 'I' {
+			c.tx = 'E'
+		}
+	case verb == "BEGIN" || verb == "START":
+		c.tx = 'T'
+	case rollbackTo:
+		if c.tx != 'I' {
+			c.tx = 'T'
+		}
+	case ends:
+		if c.tx == 'E' && (verb == "COMMIT" || verb == "END") {
+			r.Tag = "ROLLBACK" // as PostgreSQL answers COMMIT in a failed transaction
+		}
+		c.tx = 'I'
+	}
+	return r
+}
+
+func (c *serverConn) parse(body []byte) {
+	name, rest, ok := cut(body)
+	if !ok {
+		c.protocolError("malformed Parse")
+		return
+	}
+	sql, _, ok := cut(rest)
+	if !ok {
+		c.protocolError("malformed Parse")
+		return
+	}
+	c.statements[name] = sql
+	c.msg('1', nil) // ParseComplete
+}
+
+func (c *serverConn) bind(body []byte) {
+	name, rest, ok := cut(body)
+	if ok {
+		var stmt string
+		stmt, rest, ok = cut(rest)
+		if ok {
+			var args [][]byte
+			if args, ok = parseBindArgs(rest); ok {
+				sql, found := c.statements[stmt]
+				if !found {
+					c.sendError(&Error{Code: "26000", Message: fmt.Sprintf("prepared statement %q does not exist", stmt)})
+					c.failed = true
+					return
+				}
+				c.portals[name] = &portal{q: Query{SQL: sql, Args: args}}
+				c.msg('2', nil) // BindComplete
+				return
+			}
+		}
+	}
+	c.protocolError("malformed Bind")
+}
+
+// parseBindArgs reads the parameter values of a Bind body that starts at
+// the parameter format codes.
+func parseBindArgs(b []byte) ([][]byte, bool) {
+	if len(b) < 2 {
+		return nil, false
+	}
+	nFormats := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	if len(b) < 2*nFormats+2 {
+		return nil, false
+	}
+	b = b[2*nFormats:]
+	n := int(binary.BigEndian.Uint16(b))
+	b = b[2:]
+	args := make([][]byte, n)
+	for i := range args {
+		if len(b) < 4 {
+			return nil, false
+		}
+		l := int32(binary.BigEndian.Uint32(b))
+		b = b[4:]
+		if l < 0 {
+			continue
+		}
+		if len(b) < int(l) {
+			return nil, false
+		}
+		args[i] = b[:l:l]
+		b = b[l:]
+	}
+	return args, true
+}
+
+func (c *serverConn) describe(body []byte) {
+	if len(body) < 2 {
+		c.protocolError("malformed Describe")
+		return
+	}
+	name, _, _ := cut(body[1:])
+	var q Query
+	switch body[0] {
+	case 'S':
+		sql, ok := c.statements[name]
+		if !ok {
+			c.sendError(&Error{Code: "26000", Message: fmt.Sprintf("prepared statement %q does not exist", name)})
+			c.failed = true
+			return
+		}
+		q = Query{SQL: sql}
+	case 'P':
+		p, ok := c.portals[name]
+		if !ok {
+			c.sendError(&Error{Code: "34000", Message: fmt.Sprintf("portal %q does not exist", name)})
+			c.failed = true
+			return
+		}
+		q = p.q
+	default:
+		c.protocolError("malformed Describe")
+		return
+	}
+
+	r := c.s.lookup(q.SQL)
+	if r.Err != nil {
+		c.sendError(r.Err)
+		c.failed = true
+		return
+	}
+	if body[0] == 'S' {
+		pd := binary.BigEndian.AppendUint16(nil, uint16(len(r.ParamOIDs)))
+		for _, oid := range r.ParamOIDs {
+			pd = binary.BigEndian.AppendUint32(pd, oid)
+		}
+		c.msg('t', pd) // ParameterDescription
+	}
+	if r.Columns == nil {
+		c.msg('n', nil) // NoData
+		return
+	}
+	c.rowDescription(r.Columns)
+}
+
+// execute runs a portal, honoring the row limit: with rows left over it
+// sends PortalSuspended, and the next Execute resumes where it stopped.
+func (c *serverConn) execute(body []byte) {
+	name, rest, ok := cut(body)
+	if !ok || len(rest) < 4 {
+		c.protocolError("malformed Execute")
+		return
+	}
+	maxRows := int(int32(binary.BigEndian.Uint32(rest)))
+	p, ok := c.portals[name]
+	if !ok {
+		c.sendError(&Error{Code: "34000", Message: fmt.Sprintf("portal %q does not exist", name)})
+		c.failed = true
+		return
+	}
+	if p.r == nil {
+		r := c.run(p.q)
+		if r.Err != nil {
+			c.sendError(r.Err)
+			c.failed = true
+			return
+		}
+		p.r = &r
+	}
+	rows := p.r.Rows[p.sent:]
+	if maxRows > 0 && len(rows) > maxRows {
+		c.dataRows(rows[:maxRows])
+		p.sent += maxRows
+		c.msg('s', nil) // PortalSuspended
+		return
+	}
+	c.dataRows(rows)
+	p.sent += len(rows)
+	c.commandComplete(*p.r)
+}
+
+func (c *serverConn) closeMsg(body []byte) {
+	if len(body) < 2 {
+		c.protocolError("malformed Close")
+		return
+	}
+	name, _, _ := cut(body[1:])
+	if body[0] == 'S' {
+		delete(c.statements, name)
+	} else {
+		delete(c.portals, name)
+	}
+	c.msg('3', nil) // CloseComplete
+}
+
+// lookup is respond without recording the query, for Describe.
+func (s *Server) lookup(sql string) Response {
+	s.mu.Lock()
+	r, ok := s.handlers[sql]
+	fallback := s.fallback
+	s.mu.Unlock()
+	if !ok && fallback != nil {
+		r, ok = fallback(Query{SQL: sql})
+	}
+	if !ok {
+		return Response{Err: &Error{Code: "XX000", Message: "qailtest: no response for " + sql}}
+	}
+	return r
+}
+
+func (c *serverConn) rowDescription(cols []Column) {
+	b := binary.BigEndian.AppendUint16(nil, uint16(len(cols)))
+	for _, col := range cols {
+		b = append(b, col.Name...)
+		b = append(b, 0)
+		b = binary.BigEndian.AppendUint32(b, 0) // table OID
+		b = binary.BigEndian.AppendUint16(b, 0) // column number
+		b = binary.BigEndian.AppendUint32(b, col.OID)
+		b = binary.BigEndian.AppendUint16(b, 0xFFFF) // type size: variable
+		b = binary.BigEndian.AppendUint32(b, 0xFFFFFFFF)
+		b = binary.BigEndian.AppendUint16(b, 0) // text format
+	}
+	c.msg('T', b)
+}
+
+// rows sends r's DataRows and CommandComplete.
+func (c *serverConn) rows(r Response) {
+	c.dataRows(r.Rows)
+	c.commandComplete(r)
+}
+
+func (c *serverConn) dataRows(rows [][]any) {
+	for _, row := range rows {
+		b := binary.BigEndian.AppendUint16(nil, uint16(len(row)))
+		for _, v := range row {
+			switch v := v.(type) {
+			case nil:
+				b = binary.BigEndian.AppendUint32(b, 0xFFFFFFFF)
+			case []byte:
+				b = binary.BigEndian.AppendUint32(b, uint32(len(v)))
+				b = append(b, v...)
+			case bool:
+				s := "f"
+				if v {
+					s = "t"
+				}
+				b = binary.BigEndian.AppendUint32(b, 1)
+				b = append(b, s...)
+			default:
+				s := fmt.Sprint(v)
+				b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+				b = append(b, s...)
+			}
+		}
+		c.msg('D', b)
+	}
+}
+
+func (c *serverConn) commandComplete(r Response) {
+	tag := r.Tag
+	if tag == "" {
+		if r.Columns != nil {
+			tag = fmt.Sprintf("SELECT %d", len(r.Rows))
+		} else {
+			tag = "OK"
+		}
+	}
+	c.msg('C', append([]byte(tag), 0))
+	for _, n := range r.Notify {
+		b := binary.BigEndian.AppendUint32(nil, c.pid)
+		b = append(append(b, n.Channel...), 0)
+		b = append(append(b, n.Payload...), 0)
+		c.msg('A', b)
+	}
+}
+
+// sendError writes an ErrorResponse.
+func (c *serverConn) sendError(e *Error) {
+	sev := e.Severity
+	if sev == "" {
+		sev = "ERROR"
+	}
+	var b []byte
+	for _, f := range []struct {
+		code byte
+		val  string
+	}{{'S', sev}, {'V', sev}, {'C', e.Code}, {'M', e.Message}} {
+		b = append(b, f.code)
+		b = append(b, f.val...)
+		b = append(b, 0)
+	}
+	c.msg('E', append(b, 0))
+}
+
+func (c *serverConn) protocolError(msg string) {
+	c.sendError(&Error{Code: "08P01", Message: msg})
+	c.failed = true
+}
+
+// msg buffers one backend message.
+func (c *serverConn) msg(typ byte, body []byte) {
+	var hdr [5]byte
+	hdr[0] = typ
+	binary.BigEndian.PutUint32(hdr[1:], uint32(4+len(body)))
+	c.w.Write(hdr[:])
+	c.w.Write(body)
+}
+
+// read reads one frontend message.
+func (c *serverConn) read() (byte, []byte, error) {
+	var hdr [5]byte
+	if _, err := io.ReadFull(c.r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	length := int(binary.BigEndian.Uint32(hdr[1:]))
+	if length < 4 {
+		return 0, nil, fmt.Errorf("invalid message length %d", length)
+	}
+	body := make([]byte, length-4)
+	if _, err := io.ReadFull(c.r, body); err != nil {
+		return 0, nil, err
+	}
+	return hdr[0], body, nil
+}
+
+// cut splits a NUL-terminated string off the front of b.
+func cut(b []byte) (string, []byte, bool) {
+	for i, ch := range b {
+		if ch == 0 {
+			return string(b[:i]), b[i+1:], true
+		}
+	}
+	return "", nil, false
+}
+
+func cstring(b []byte) string {
+	s, _, ok := cut(b)
+	if !ok {
+		return string(b)
+	}
+	return s
+}
@@ -0,0 +1,27 @@
+package qail
+
+import "context"
+
+// Querier is the query surface shared by Driver, Tx and PooledConn, so
+// code can be written once and run against the pool, inside a
+// transaction, or on a pinned connection:
+//
+//	func deleteSessions(q qail.Querier, userID int64) error {
+//		return q.Execute(qail.Del("sessions").Filter("user_id", qail.Eq, userID))
+//	}
+//
+//	deleteSessions(d, id)  // autocommit on the pool
+//	deleteSessions(tx, id) // inside tx
+type Querier interface {
+	FetchAll(cmd *Qail) ([]Row, error)
+	FetchAllContext(ctx context.Context, cmd *Qail) ([]Row, error)
+	FetchOne(cmd *Qail) (Row, error)
+	Execute(cmd *Qail) error
+	ExecuteContext(ctx context.Context, cmd *Qail) error
+}
+
+var (
+	_ Querier = (*Driver)(nil)
+	_ Querier = (*Tx)(nil)
+	_ Querier = (*PooledConn)(nil)
+)
@@ -0,0 +1,93 @@
+package qail
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+// pruneSessions is written once against Querier.
+func pruneSessions(q Querier) (int64, error) {
+	if err := q.Execute(Del("sessions")); err != nil {
+		return 0, err
+	}
+	row, err := q.FetchOne(Get("numbers"))
+	if err != nil {
+		return 0, err
+	}
+	return row.GetInt(0), nil
+}
+
+func TestQuerier(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		open func(t *testing.T, d *Driver) (q Querier, done func())
+	}{
+		{"Driver", func(t *testing.T, d *Driver) (Querier, func()) {
+			return d, func() {}
+		}},
+		{"Tx", func(t *testing.T, d *Driver) (Querier, func()) {
+			tx, err := d.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			return tx, func() { tx.Rollback() }
+		}},
+		{"PooledConn", func(t *testing.T, d *Driver) (Querier, func()) {
+			pc, err := d.Acquire(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pc, pc.Release
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			slow := Get("slow").SQL()
+			srv := qailtest.NewServer()
+			srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+			srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+			srv.HandleCmd(Del("sessions"), qailtest.Response{Tag: "DELETE 2"})
+			srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+				if q.SQL == slow {
+					<-release
+				}
+				return intRows(7, 8)(q)
+			})
+			d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+			q, done := tt.open(t, d)
+			if n, err := pruneSessions(q); err != nil || n != 7 {
+				t.Fatalf("pruneSessions = %d, %v; want 7", n, err)
+			}
+			rows, err := q.FetchAllContext(context.Background(), Get("numbers"))
+			if err != nil || len(rows) != 2 {
+				t.Fatalf("FetchAllContext = %d rows, %v; want 2", len(rows), err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			if err := q.ExecuteContext(ctx, Del("sessions")); !errors.Is(err, context.Canceled) {
+				t.Errorf("ExecuteContext with a cancelled ctx: err = %v, want Canceled", err)
+			}
+			ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+			if _, err := q.FetchAllContext(ctx, Get("slow")); !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("FetchAllContext past its deadline: err = %v, want DeadlineExceeded", err)
+			}
+			close(release)
+			done()
+
+			// The abandoned connection is closed, not pooled.
+			if s := d.Stats(); s.InUse != 0 || s.Idle != 0 {
+				t.Errorf("after cancel: InUse = %d, Idle = %d; want the connection discarded", s.InUse, s.Idle)
+			}
+			if _, err := d.FetchOne(Get("numbers")); err != nil {
+				t.Fatalf("next query: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package qail
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// Query executes cmd and scans every row into a T. A struct T is filled
+// as by ScanStruct; any other T (int64, string, time.Time, a sql.Scanner,
+// ...) is treated as a scalar and requires a single-column result.
+//
+//	harbors, err := qail.Query[Harbor](d, qail.Get("harbors"))
+//	ids, err := qail.Query[int64](d, qail.Get("harbors").Column("id"))
+func Query[T any](d *Driver, cmd *Qail) ([]T, error) {
+	rows, err := d.FetchAll(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return scanRows[T](rows)
+}
+
+// QueryOne is Query for a single row. It returns ErrNoRows if the result
+// is empty and ignores any rows after the first.
+func QueryOne[T any](d *Driver, cmd *Qail) (T, error) {
+	var zero T
+	rows, err := d.FetchAll(cmd)
+	if err != nil {
+		return zero, err
+	}
+	if len(rows) == 0 {
+		return zero, ErrNoRows
+	}
+	out, err := scanRows[T](rows[:1])
+	if err != nil {
+		return zero, err
+	}
+	return out[0], nil
+}
+
+func scanRows[T any](rows []Row) ([]T, error) {
+	out := make([]T, len(rows))
+	if len(rows) == 0 {
+		return out, nil
+	}
+
+	t := reflect.TypeOf(out).Elem()
+	if isScanStruct(t) {
+		plan := newStructPlan(t, rows[0].fields)
+		for i, r := range rows {
+			if err := plan.scan(r, reflect.ValueOf(&out[i]).Elem()); err != nil {
+				return nil, fmt.Errorf("row %d: %w", i, err)
+			}
+		}
+		return out, nil
+	}
+
+	for i, r := range rows {
+		if len(r.columns) != 1 {
+			return nil, fmt.Errorf("scanning into %s needs exactly 1 column, got %d", t, len(r.columns))
+		}
+		if err := r.assign(0, reflect.ValueOf(&out[i]).Elem()); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// isScanStruct reports whether t is scanned field by field rather than as
+// a single value.
+func isScanStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(scannerType)
+}
@@ -0,0 +1,83 @@
+package qail
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func queryServer(t *testing.T) *Driver {
+	t.Helper()
+	srv := qailtest.NewServer()
+	srv.HandleCmd(Get("harbors"), qailtest.Response{
+		Columns: []qailtest.Column{{Name: "id", OID: OIDInt8}, {Name: "name", OID: OIDText}, {Name: "opened", OID: OIDDate}},
+		Rows:    [][]any{{1, "Valletta", "1566-03-28"}, {2, nil, nil}},
+	})
+	srv.HandleCmd(Get("harbors").Column("id"), qailtest.Response{
+		Columns: []qailtest.Column{{Name: "id", OID: OIDInt8}},
+		Rows:    [][]any{{1}, {2}},
+	})
+	srv.HandleCmd(Get("empty"), qailtest.Response{Columns: []qailtest.Column{{Name: "id", OID: OIDInt8}}})
+	return fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+}
+
+func TestQueryStructs(t *testing.T) {
+	type harbor struct {
+		ID     int64
+		Name   *string
+		Opened time.Time `db:"opened"`
+	}
+	d := queryServer(t)
+
+	got, err := Query[harbor](d, Get("harbors"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("got %d harbors, want 2", len(got))
+	}
+	opened := time.Date(1566, 3, 28, 0, 0, 0, 0, time.UTC)
+	if h := got[0]; h.ID != 1 || h.Name == nil || *h.Name != "Valletta" || !h.Opened.Equal(opened) {
+		t.Errorf("harbor 0 = %+v, want 1 Valletta %v", h, opened)
+	}
+	if h := got[1]; h.ID != 2 || h.Name != nil || !h.Opened.IsZero() {
+		t.Errorf("harbor 1 = %+v, want 2 with NULL name and date", h)
+	}
+
+	empty, err := Query[harbor](d, Get("empty"))
+	if err != nil || len(empty) != 0 {
+		t.Errorf("empty result: got %v, %v; want no rows", empty, err)
+	}
+}
+
+func TestQueryScalars(t *testing.T) {
+	d := queryServer(t)
+
+	ids, err := Query[int64](d, Get("harbors").Column("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 2}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("ids = %v, want %v", ids, want)
+	}
+
+	if _, err := Query[int64](d, Get("harbors")); err == nil {
+		t.Error("scalar scan of a 3-column result: no error")
+	}
+}
+
+func TestQueryOne(t *testing.T) {
+	d := queryServer(t)
+
+	id, err := QueryOne[int64](d, Get("harbors").Column("id"))
+	if err != nil || id != 1 {
+		t.Errorf("QueryOne = %d, %v; want the first row, 1", id, err)
+	}
+
+	if _, err := QueryOne[int64](d, Get("empty")); !errors.Is(err, ErrNoRows) {
+		t.Errorf("empty result: err = %v, want ErrNoRows", err)
+	}
+}
@@ -0,0 +1,174 @@
+package qail
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"runtime"
+)
+
+// PooledConn is a connection checked out of the pool with Acquire for
+// exclusive use. Everything run through it happens in one server session,
+// so session state (SET, temporary tables, advisory locks) carries over
+// from call to call. Release returns it to the pool.
+//
+// A PooledConn is not safe for concurrent use. A PooledConn that is
+// dropped without Release is reclaimed by a finalizer, which closes the
+// connection rather than pool it, since its session state is unknown.
+type PooledConn struct {
+	d *Driver
+	c *Conn // nil once released
+}
+
+// Acquire checks a connection out of the pool, dialing one if none is
+// idle; ctx bounds the dial.
+func (d *Driver) Acquire(ctx context.Context) (*PooledConn, error) {
+	c, err := d.getConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	pc := &PooledConn{d: d, c: c}
+	runtime.SetFinalizer(pc, (*PooledConn).reclaim)
+	return pc, nil
+}
+
+// Release returns the connection to the pool, or discards it if it was
+// closed or its I/O failed. Calling Release again is a no-op.
+func (pc *PooledConn) Release() {
+	if pc.c == nil {
+		return
+	}
+	runtime.SetFinalizer(pc, nil)
+	pc.d.putConn(pc.c)
+	pc.c = nil
+}
+
+// reclaim is the finalizer for a PooledConn that was never released.
+// Every method that uses pc.c defers runtime.KeepAlive(pc), so it cannot
+// run while one is using the connection.
+func (pc *PooledConn) reclaim() {
+	if pc.c != nil {
+		pc.c.Close()
+		pc.d.release()
+		pc.c = nil
+	}
+}
+
+// Conn returns the underlying connection for raw protocol access, or nil
+// after Release.
+//
+// The *Conn must not outlive pc: once pc is unreachable its finalizer
+// closes the connection, even while the *Conn is in use. Keep pc
+// reachable until you are done with the *Conn, e.g. by calling Release
+// afterwards.
+func (pc *PooledConn) Conn() *Conn {
+	return pc.c
+}
+
+// FetchAll executes a query on the pinned connection and returns all rows.
+func (pc *PooledConn) FetchAll(cmd *Qail) ([]Row, error) {
+	if pc.c == nil {
+		return nil, ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	return pc.c.fetchAll(cmd)
+}
+
+// FetchAllContext is FetchAll that stops waiting when ctx ends: the
+// server is sent a CancelRequest and ctx.Err() is returned. The
+// connection is then closed on Release rather than pooled.
+func (pc *PooledConn) FetchAllContext(ctx context.Context, cmd *Qail) (rows []Row, err error) {
+	if pc.c == nil {
+		return nil, ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	err = pc.c.withContext(ctx, func() (err error) {
+		rows, err = pc.c.fetchAll(cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// FetchOne executes a query on the pinned connection and returns its
+// first row, or ErrNoRows if the result is empty.
+func (pc *PooledConn) FetchOne(cmd *Qail) (Row, error) {
+	return firstRow(pc.FetchAll(cmd))
+}
+
+// Execute executes a command that returns no rows on the pinned
+// connection.
+func (pc *PooledConn) Execute(cmd *Qail) error {
+	if pc.c == nil {
+		return ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	return pc.c.execute(cmd)
+}
+
+// ExecuteContext is Execute that stops waiting when ctx ends, as
+// FetchAllContext does.
+func (pc *PooledConn) ExecuteContext(ctx context.Context, cmd *Qail) error {
+	if pc.c == nil {
+		return ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	return pc.c.withContext(ctx, func() error { return pc.c.execute(cmd) })
+}
+
+// ExecuteSimple runs raw SQL on the pinned connection using the simple
+// query protocol. Rows are discarded.
+func (pc *PooledConn) ExecuteSimple(sql string) error {
+	if pc.c == nil {
+		return ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	return pc.c.simpleExec(sql)
+}
+
+// SimpleExec is Driver.SimpleExec on the pinned connection.
+func (pc *PooledConn) SimpleExec(sql string) ([]*Result, error) {
+	if pc.c == nil {
+		return nil, ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	if err := pc.c.sendQuery(sql); err != nil {
+		return nil, err
+	}
+	return pc.c.readResults()
+}
+
+// WriteMessage sends one frontend message: msgType, the length, then
+// body. It is written immediately, unbuffered.
+//
+// The driver does not track what is sent this way. Before
+// PooledConn.Release, the connection must be back at ReadyForQuery (every
+// message answered and read up to 'Z') or the next user of the connection
+// will read stale responses. If that can't be guaranteed, Close the
+// connection before Release so it is discarded.
+func (c *Conn) WriteMessage(msgType byte, body []byte) error {
+	if len(body) > maxMessageBody {
+		return fmt.Errorf("message body of %d bytes is too large", len(body))
+	}
+	buf := make([]byte, 5, 5+len(body))
+	buf[0] = msgType
+	binary.BigEndian.PutUint32(buf[1:5], uint32(4+len(body)))
+	buf = append(buf, body...)
+	if _, err := c.conn.Write(buf); err != nil {
+		return fmt.Errorf("write failed: %w", err)
+	}
+	return nil
+}
+
+// ReadMessage reads one backend message and returns its type and body,
+// which the caller may keep. ParameterStatus ('S') messages are absorbed
+// into Parameter and never returned. See WriteMessage for the rules on
+// leaving the connection in sync.
+func (c *Conn) ReadMessage() (byte, []byte, error) {
+	return c.readMessage()
+}
+
+// maxMessageBody keeps the length field, which counts itself, within int32.
+const maxMessageBody = 1<<31 - 1 - 4
@@ -0,0 +1,219 @@
+package qail
+
+// Rows is a streaming cursor over the output of a simple-query script,
+// returned by Driver.QueryRows. Rows are read from the connection one at
+// a time rather than buffered, so Config.MaxResultBytes does not apply.
+//
+// Each statement in the script is one result set, in order; statements
+// that return no rows give an empty set with just a CommandTag. Next steps
+// through the rows of the current set and NextResultSet moves to the next
+// set, much like database/sql:
+//
+//	rows, err := d.QueryRows("SELECT id FROM a; SELECT name, age FROM b")
+//	if err != nil { ... }
+//	defer rows.Close()
+//	for {
+//		for rows.Next() {
+//			r := rows.Row()
+//			...
+//		}
+//		if !rows.NextResultSet() {
+//			break
+//		}
+//	}
+//	if err := rows.Err(); err != nil { ... }
+//
+// Rows holds a pooled connection until Close, which must be called.
+type Rows struct {
+	d *Driver
+	c *Conn
+
+	fields []ColumnInfo
+	row    Row
+	tag    string
+
+	setDone bool // the current set's CommandComplete has been read
+	done    bool // ReadyForQuery has been read, or the connection failed
+	err     error
+
+	trace *TraceQuery // ended by Close
+	n     int         // rows read, for the tracer
+}
+
+// QueryRows runs sql, which may hold several semicolon-separated
+// statements, using the simple query protocol and returns a cursor
+// positioned on the first statement's result set. A server error is
+// reported by Rows.Err once the statements before it have been read.
+func (d *Driver) QueryRows(sql string) (*Rows, error) {
+	var q *TraceQuery
+	if d.tracer != nil {
+		q = d.traceStart("QueryRows", sql)
+	}
+	c, err := d.getConn()
+	if err == nil {
+		if err = c.sendQuery(sql); err != nil {
+			d.putConn(c)
+		}
+	}
+	if err != nil {
+		if q != nil {
+			d.traceEnd(q, 0, err)
+		}
+		return nil, err
+	}
+	r := &Rows{d: d, c: c, trace: q}
+	r.startSet()
+	return r, nil
+}
+
+// startSet reads up to the start of the next result set: a RowDescription,
+// or the CommandComplete of a statement without rows. It reports false at
+// ReadyForQuery.
+func (r *Rows) startSet() bool {
+	r.fields, r.tag, r.setDone = nil, "", false
+	for !r.done {
+		msgType, data, err := r.c.readMessage()
+		if err != nil {
+			r.fail(err)
+			return false
+		}
+		switch msgType {
+		case 'T': // RowDescription
+			if r.fields, err = parseRowDescription(data); err != nil {
+				r.fail(err)
+				return false
+			}
+			return true
+		case 'C': // CommandComplete of a statement without rows
+			r.tag = cstring(data)
+			r.setDone = true
+			return true
+		case 'E':
+			// The server skips the remaining statements but still sends 'Z'.
+			r.err = serverError("query error", data)
+		case 'Z':
+			r.done = true
+		}
+	}
+	r.setDone = true
+	return false
+}
+
+// Next advances to the next row of the current result set, reporting
+// false at the end of the set or on error.
+func (r *Rows) Next() bool {
+	r.row = Row{}
+	if r.setDone || r.done {
+		return false
+	}
+	for {
+		msgType, data, err := r.c.readMessage()
+		if err != nil {
+			r.fail(err)
+			return false
+		}
+		switch msgType {
+		case 'D': // DataRow; data is freshly allocated, so rows may be kept
+			cols, err := parseDataRow(data)
+			if err != nil {
+				r.fail(err)
+				return false
+			}
+			r.row = Row{columns: cols, fields: r.fields}
+			r.n++
+			return true
+		case 'C':
+			r.tag = cstring(data)
+			r.setDone = true
+			return false
+		case 'E':
+			r.err = serverError("query error", data)
+			r.setDone = true
+			r.drain()
+			return false
+		case 'Z':
+			r.done = true
+			r.setDone = true
+			return false
+		}
+	}
+}
+
+// NextResultSet skips any unread rows of the current result set and
+// moves to the next one, reporting false when there are no more sets or
+// on error.
+func (r *Rows) NextResultSet() bool {
+	for r.Next() {
+	}
+	if r.done {
+		return false
+	}
+	return r.startSet()
+}
+
+// Row returns the current row. It stays valid after Next.
+func (r *Rows) Row() Row {
+	return r.row
+}
+
+// Fields returns the column metadata of the current result set, or nil
+// for a statement that returns no rows.
+func (r *Rows) Fields() []ColumnInfo {
+	return r.fields
+}
+
+// CommandTag returns the current statement's command tag once its rows
+// have all been read, e.g. "SELECT 3".
+func (r *Rows) CommandTag() string {
+	return r.tag
+}
+
+// Err returns the error, if any, that ended iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close discards any unread results and returns the connection to the
+// pool. It is safe to call more than once.
+func (r *Rows) Close() error {
+	if r.c == nil {
+		return r.err
+	}
+	r.drain()
+	r.d.putConn(r.c)
+	r.c = nil
+	if r.trace != nil {
+		r.d.traceEnd(r.trace, r.n, r.err)
+	}
+	return r.err
+}
+
+// drain reads to ReadyForQuery so the connection can be reused.
+func (r *Rows) drain() {
+	for !r.done {
+		msgType, data, err := r.c.readMessageFast()
+		if err != nil {
+			r.fail(err)
+			return
+		}
+		switch msgType {
+		case 'E':
+			if r.err == nil {
+				r.err = serverError("query error", data)
+			}
+		case 'Z':
+			r.done = true
+		}
+	}
+}
+
+// fail ends iteration on a connection or protocol error. The connection
+// is poisoned, so putConn closes it instead of pooling it.
+func (r *Rows) fail(err error) {
+	if r.err == nil {
+		r.err = err
+	}
+	r.c.poisoned.Store(true)
+	r.done = true
+	r.setDone = true
+}
@@ -0,0 +1,70 @@
+package qail
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestQueryRowsResultSets(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Handle("SELECT id, name FROM users", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}, {Name: "name", OID: qailtest.OIDText}},
+		Rows:    [][]any{{1, "ada"}, {2, "grace"}},
+	})
+	srv.Handle("UPDATE users SET seen = true", qailtest.Response{Tag: "UPDATE 2"})
+	srv.Handle("SELECT count(*) FROM users", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "count", OID: qailtest.OIDInt8}},
+		Rows:    [][]any{{2}},
+	})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	rows, err := d.QueryRows("SELECT id, name FROM users; UPDATE users SET seen = true; SELECT count(*) FROM users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	type set struct {
+		columns []string
+		rows    []string
+		tag     string
+	}
+	var got []set
+	for {
+		var s set
+		for _, f := range rows.Fields() {
+			s.columns = append(s.columns, f.Name)
+		}
+		for rows.Next() {
+			r := rows.Row()
+			var vals []string
+			for i := range s.columns {
+				vals = append(vals, r.GetString(i))
+			}
+			s.rows = append(s.rows, strings.Join(vals, ","))
+		}
+		s.tag = rows.CommandTag()
+		got = append(got, s)
+		if !rows.NextResultSet() {
+			break
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := []set{
+		{columns: []string{"id", "name"}, rows: []string{"1,ada", "2,grace"}, tag: "SELECT 2"},
+		{tag: "UPDATE 2"},
+		{columns: []string{"count"}, rows: []string{"2"}, tag: "SELECT 1"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("result sets:\n got %+v\nwant %+v", got, want)
+	}
+	if rows.NextResultSet() {
+		t.Error("NextResultSet after the last set = true")
+	}
+}
@@ -0,0 +1,111 @@
+package qail
+
+import "sync"
+
+// RustPool shares up to size RustConn connections between goroutines, with
+// the same take-or-dial / return-or-close semantics as the Driver pool.
+// Connections are dialed lazily. A connection whose call fails is closed
+// instead of returned, since the Rust side may have left it mid-pipeline;
+// the next call dials a replacement.
+type RustPool struct {
+	host     string
+	port     uint16
+	user     string
+	database string
+
+	pool   chan *RustConn
+	mu     sync.Mutex
+	closed bool // guarded by mu
+}
+
+// NewRustPool creates a pool of at most size Rust I/O connections
+// (10 if size <= 0).
+func NewRustPool(host string, port uint16, user, database string, size int) *RustPool {
+	if size <= 0 {
+		size = 10
+	}
+	return &RustPool{
+		host:     host,
+		port:     port,
+		user:     user,
+		database: database,
+		pool:     make(chan *RustConn, size),
+	}
+}
+
+// getConn gets a connection from pool or creates new one.
+func (p *RustPool) getConn() (*RustConn, error) {
+	p.mu.Lock()
+	closed := p.closed
+	p.mu.Unlock()
+	if closed {
+		return nil, ErrDriverClosed
+	}
+
+	select {
+	case c := <-p.pool:
+		return c, nil
+	default:
+		return RustConnect(p.host, p.port, p.user, p.database)
+	}
+}
+
+// putConn returns connection to pool, or closes it if the call that used
+// it failed, the pool is full, or the pool is closed.
+func (p *RustPool) putConn(c *RustConn, err error) {
+	if err != nil {
+		c.Close()
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		c.Close()
+		return
+	}
+	select {
+	case p.pool <- c:
+	default:
+		c.Close()
+	}
+}
+
+// ExecuteBatch runs RustConn.ExecuteBatch on a pooled connection.
+func (p *RustPool) ExecuteBatch(table, columns string, limits []int64) (int64, error) {
+	c, err := p.getConn()
+	if err != nil {
+		return 0, err
+	}
+	n, err := c.ExecuteBatch(table, columns, limits)
+	p.putConn(c, err)
+	return n, err
+}
+
+// ExecuteBatchRows runs RustConn.ExecuteBatchRows on a pooled connection.
+func (p *RustPool) ExecuteBatchRows(table, columns string, limits []int64) ([][]Row, error) {
+	c, err := p.getConn()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := c.ExecuteBatchRows(table, columns, limits)
+	p.putConn(c, err)
+	return rows, err
+}
+
+// Close closes idle connections. Connections in use are closed when
+// their calls return them; later calls fail with ErrDriverClosed.
+func (p *RustPool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	p.mu.Unlock()
+
+	for {
+		select {
+		case c := <-p.pool:
+			c.Close()
+		default:
+			return
+		}
+	}
+}
@@ -0,0 +1,214 @@
+package qail
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType    = reflect.TypeOf(time.Time{})
+	scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// structPlan maps result columns to struct fields. fieldIndex[i] is the
+// reflect field index path for column i, or nil if the column is unmapped.
+type structPlan struct {
+	typ        reflect.Type
+	fieldIndex [][]int
+}
+
+// newStructPlan resolves columns to fields of struct type t. A field
+// matches a column by its `db:"name"` tag, or else by a case-insensitive
+// match on the field name. Tagging a field `db:"-"` skips it. Fields of
+// embedded structs are matched as if they belonged to the outer struct.
+func newStructPlan(t reflect.Type, fields []ColumnInfo) *structPlan {
+	byName := make(map[string][]int)
+	collectFields(t, nil, byName)
+
+	plan := &structPlan{typ: t, fieldIndex: make([][]int, len(fields))}
+	for i, f := range fields {
+		if idx, ok := byName[f.Name]; ok {
+			plan.fieldIndex[i] = idx
+		} else if idx, ok := byName[strings.ToLower(f.Name)]; ok {
+			plan.fieldIndex[i] = idx
+		}
+	}
+	return plan
+}
+
+func collectFields(t reflect.Type, prefix []int, byName map[string][]int) {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := sf.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+		index := append(append([]int(nil), prefix...), i)
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct && sf.Type != timeType {
+			collectFields(sf.Type, index, byName)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		name := tag
+		if name == "" {
+			name = strings.ToLower(sf.Name)
+		}
+		// Outer fields win over embedded ones
+		if _, exists := byName[name]; !exists {
+			byName[name] = index
+		}
+	}
+}
+
+// scan fills the struct v from row r.
+func (p *structPlan) scan(r Row, v reflect.Value) error {
+	for col, index := range p.fieldIndex {
+		if index == nil {
+			continue
+		}
+		if err := r.assign(col, v.FieldByIndex(index)); err != nil {
+			return fmt.Errorf("column %q: %w", r.fields[col].Name, err)
+		}
+	}
+	return nil
+}
+
+// ScanStruct fills the struct pointed to by dest from the row's columns.
+// Columns without a matching field are ignored. A NULL column sets a
+// pointer field to nil and an sql.Scanner field such as sql.NullString to
+// its invalid value; any other field gets its zero value.
+func (r Row) ScanStruct(dest any) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return errors.New("ScanStruct: dest must be a non-nil pointer to a struct")
+	}
+	return newStructPlan(v.Elem().Type(), r.fields).scan(r, v.Elem())
+}
+
+// ScanAll fills dest, a pointer to a slice of structs (or of struct
+// pointers), with one element per row. The column-to-field mapping is
+// resolved once from the first row and reused for the rest.
+//
+//	var harbors []Harbor
+//	err := qail.ScanAll(rows, &harbors)
+func ScanAll(rows []Row, dest any) error {
+	v := reflect.ValueOf(dest)
+	if v.Kind() != reflect.Pointer || v.IsNil() || v.Elem().Kind() != reflect.Slice {
+		return errors.New("ScanAll: dest must be a non-nil pointer to a slice of structs")
+	}
+	slice := v.Elem()
+	elemType := slice.Type().Elem()
+	isPtr := elemType.Kind() == reflect.Pointer
+	structType := elemType
+	if isPtr {
+		structType = elemType.Elem()
+	}
+	if structType.Kind() != reflect.Struct {
+		return errors.New("ScanAll: dest must be a non-nil pointer to a slice of structs")
+	}
+
+	out := reflect.MakeSlice(slice.Type(), len(rows), len(rows))
+	var plan *structPlan
+	for i, r := range rows {
+		if plan == nil {
+			plan = newStructPlan(structType, r.fields)
+		}
+		elem := out.Index(i)
+		if isPtr {
+			elem.Set(reflect.New(structType))
+			elem = elem.Elem()
+		}
+		if err := plan.scan(r, elem); err != nil {
+			return fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	slice.Set(out)
+	return nil
+}
+
+// assign converts column idx into the settable value v.
+func (r Row) assign(idx int, v reflect.Value) error {
+	b := r.Get(idx)
+
+	if v.CanAddr() && v.Addr().Type().Implements(scannerType) {
+		return v.Addr().Interface().(sql.Scanner).Scan(r.driverValue(idx))
+	}
+
+	if b == nil {
+		// NULL: pointers become nil, everything else its zero value
+		v.Set(reflect.Zero(v.Type()))
+		return nil
+	}
+
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := r.assign(idx, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	if v.Type() == timeType {
+		v.Set(reflect.ValueOf(r.GetTime(idx)))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(r.GetString(idx))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n := r.GetInt(idx)
+		if v.OverflowInt(n) {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n := r.GetInt(idx)
+		if n < 0 || v.OverflowUint(uint64(n)) {
+			return fmt.Errorf("value %d overflows %s", n, v.Type())
+		}
+		v.SetUint(uint64(n))
+	case reflect.Float32, reflect.Float64:
+		v.SetFloat(r.GetFloat64(idx))
+	case reflect.Bool:
+		v.SetBool(r.GetBool(idx))
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.Uint8 {
+			return fmt.Errorf("unsupported destination type %s", v.Type())
+		}
+		v.SetBytes(append([]byte(nil), b...))
+	default:
+		return fmt.Errorf("unsupported destination type %s", v.Type())
+	}
+	return nil
+}
+
+// driverValue returns column idx as a database/sql driver value (nil,
+// int64, float64, bool, time.Time or []byte) for sql.Scanner targets.
+func (r Row) driverValue(idx int) any {
+	b := r.Get(idx)
+	if b == nil {
+		return nil
+	}
+	if r.isBinary(idx) {
+		f, _ := r.field(idx)
+		switch f.TypeOID {
+		case OIDInt2, OIDInt4, OIDInt8, OIDOid:
+			return r.GetInt(idx)
+		case OIDFloat4, OIDFloat8:
+			return r.GetFloat64(idx)
+		case OIDBool:
+			return r.GetBool(idx)
+		case OIDDate, OIDTimestamp, OIDTimestampTz:
+			return r.GetTime(idx)
+		}
+	}
+	return append([]byte(nil), b...)
+}
@@ -0,0 +1,259 @@
+package qail
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"strconv"
+	"strings"
+)
+
+// SASL mechanism names offered in AuthenticationSASL.
+const (
+	scramSHA256     = "SCRAM-SHA-256"
+	scramSHA256Plus = "SCRAM-SHA-256-PLUS"
+)
+
+// scramClient runs one SCRAM-SHA-256 exchange (RFC 5802, RFC 7677). With
+// cbData set it uses SCRAM-SHA-256-PLUS and binds the exchange to the TLS
+// channel; see tlsServerEndPoint.
+type scramClient struct {
+	password    string
+	clientNonce string
+	gs2Header   string // "n,,", "y,," or "p=tls-server-end-point,,"
+	cbData      []byte // channel binding data, nil without PLUS
+
+	clientFirstBare string
+	serverSignature []byte // expected in server-final-message
+}
+
+// newScramClient picks the mechanism for the server's offer: PLUS when
+// the server offers it and serverCert (the TLS peer certificate) is
+// known, plain SCRAM-SHA-256 otherwise.
+func newScramClient(mechanisms []string, password string, serverCert *x509.Certificate) (*scramClient, string, error) {
+	offered := func(name string) bool {
+		for _, m := range mechanisms {
+			if m == name {
+				return true
+			}
+		}
+		return false
+	}
+	var nonce [18]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, "", err
+	}
+	s := &scramClient{password: password, clientNonce: base64.StdEncoding.EncodeToString(nonce[:])}
+
+	switch {
+	case serverCert != nil && offered(scramSHA256Plus):
+		s.gs2Header = "p=tls-server-end-point,,"
+		s.cbData = tlsServerEndPoint(serverCert)
+		return s, scramSHA256Plus, nil
+	case offered(scramSHA256):
+		// "y" tells a server that strips PLUS from its offer (a MITM
+		// downgrade) that we could have bound the channel.
+		s.gs2Header = "n,,"
+		if serverCert != nil {
+			s.gs2Header = "y,,"
+		}
+		return s, scramSHA256, nil
+	}
+	return nil, "", fmt.Errorf("%w: server offers SASL %s", ErrUnsupportedAuth, strings.Join(mechanisms, ", "))
+}
+
+// clientFirst returns the client-first-message. The user name is left
+// empty: the server takes it from the startup packet.
+func (s *scramClient) clientFirst() []byte {
+	s.clientFirstBare = "n=,r=" + s.clientNonce
+	return []byte(s.gs2Header + s.clientFirstBare)
+}
+
+// clientFinal checks the server-first-message and returns the
+// client-final-message with the client proof.
+func (s *scramClient) clientFinal(serverFirst []byte) ([]byte, error) {
+	var nonce, salt string
+	iterations := 0
+	for _, attr := range strings.Split(string(serverFirst), ",") {
+		key, value, ok := strings.Cut(attr, "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "r":
+			nonce = value
+		case "s":
+			salt = value
+		case "i":
+			iterations, _ = strconv.Atoi(value)
+		}
+	}
+	if !strings.HasPrefix(nonce, s.clientNonce) || len(nonce) == len(s.clientNonce) {
+		return nil, errors.New("SCRAM: server nonce does not extend the client nonce")
+	}
+	saltBytes, err := base64.StdEncoding.DecodeString(salt)
+	if err != nil || len(saltBytes) == 0 {
+		return nil, errors.New("SCRAM: invalid salt")
+	}
+	if iterations <= 0 {
+		return nil, errors.New("SCRAM: invalid iteration count")
+	}
+
+	cbind := append([]byte(s.gs2Header), s.cbData...)
+	withoutProof := "c=" + base64.StdEncoding.EncodeToString(cbind) + ",r=" + nonce
+	authMessage := []byte(s.clientFirstBare + "," + string(serverFirst) + "," + withoutProof)
+
+	salted := pbkdf2SHA256([]byte(s.password), saltBytes, iterations)
+	clientKey := hmacSHA256(salted, []byte("Client Key"))
+	storedKey := sha256.Sum256(clientKey)
+	clientSig := hmacSHA256(storedKey[:], authMessage)
+	proof := make([]byte, len(clientKey))
+	for i := range clientKey {
+		proof[i] = clientKey[i] ^ clientSig[i]
+	}
+	serverKey := hmacSHA256(salted, []byte("Server Key"))
+	s.serverSignature = hmacSHA256(serverKey, authMessage)
+
+	return []byte(withoutProof + ",p=" + base64.StdEncoding.EncodeToString(proof)), nil
+}
+
+// verifyServerFinal checks the server signature in server-final-message,
+// proving the server knows the password too.
+func (s *scramClient) verifyServerFinal(serverFinal []byte) error {
+	msg := string(serverFinal)
+	if e, ok := strings.CutPrefix(msg, "e="); ok {
+		return fmt.Errorf("SCRAM: server error: %s", e)
+	}
+	v, ok := strings.CutPrefix(msg, "v=")
+	if !ok {
+		return errors.New("SCRAM: malformed server-final-message")
+	}
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	sig, err := base64.StdEncoding.DecodeString(v)
+	if err != nil || !hmac.Equal(sig, s.serverSignature) {
+		return errors.New("SCRAM: server signature mismatch")
+	}
+	return nil
+}
+
+// tlsServerEndPoint returns the tls-server-end-point channel binding data
+// for cert (RFC 5929): its hash under the certificate's own signature
+// hash, with MD5 and SHA-1 upgraded to SHA-256.
+func tlsServerEndPoint(cert *x509.Certificate) []byte {
+	var h hash.Hash
+	switch cert.SignatureAlgorithm {
+	case x509.SHA384WithRSA, x509.SHA384WithRSAPSS, x509.ECDSAWithSHA384:
+		h = sha512.New384()
+	case x509.SHA512WithRSA, x509.SHA512WithRSAPSS, x509.ECDSAWithSHA512:
+		h = sha512.New()
+	default:
+		h = sha256.New()
+	}
+	h.Write(cert.Raw)
+	return h.Sum(nil)
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write(data)
+	return m.Sum(nil)
+}
+
+// pbkdf2SHA256 is PBKDF2 (RFC 8018) with HMAC-SHA-256, for one 32-byte
+// block: SCRAM's Hi function.
+func pbkdf2SHA256(password, salt []byte, iterations int) []byte {
+	m := hmac.New(sha256.New, password)
+	m.Write(salt)
+	m.Write([]byte{0, 0, 0, 1})
+	u := m.Sum(nil)
+	out := bytes.Clone(u)
+	for i := 1; i < iterations; i++ {
+		m.Reset()
+		m.Write(u)
+		u = m.Sum(u[:0])
+		for j := range out {
+			out[j] ^= u[j]
+		}
+	}
+	return out
+}
+
+// authSCRAM runs SASL authentication after AuthenticationSASL, whose body
+// (after the auth type) lists the server's mechanisms. It returns once
+// the server's signature checks out; AuthenticationOk follows.
+func (c *Conn) authSCRAM(password string, mechList []byte) error {
+	var mechanisms []string
+	for _, m := range bytes.Split(mechList, []byte{0}) {
+		if len(m) > 0 {
+			mechanisms = append(mechanisms, string(m))
+		}
+	}
+	s, mech, err := newScramClient(mechanisms, password, c.serverCert)
+	if err != nil {
+		return err
+	}
+	c.authMethod = strings.ToLower(mech)
+
+	// SASLInitialResponse: mechanism, then the length-prefixed response.
+	first := s.clientFirst()
+	body := append([]byte(mech), 0)
+	body = binary.BigEndian.AppendUint32(body, uint32(len(first)))
+	body = append(body, first...)
+	if err := c.writePasswordMessage(body); err != nil {
+		return err
+	}
+
+	serverFirst, err := c.readSASL(11)
+	if err != nil {
+		return err
+	}
+	final, err := s.clientFinal(serverFirst)
+	if err != nil {
+		return err
+	}
+	if err := c.writePasswordMessage(final); err != nil {
+		return err
+	}
+
+	serverFinal, err := c.readSASL(12)
+	if err != nil {
+		return err
+	}
+	return s.verifyServerFinal(serverFinal)
+}
+
+// readSASL reads the Authentication message of the given SASL type
+// (11 = SASLContinue, 12 = SASLFinal) and returns its data.
+func (c *Conn) readSASL(want uint32) ([]byte, error) {
+	msgType, data, err := c.readMessage()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case msgType == 'E':
+		return nil, serverError("auth error", data)
+	case msgType != 'R' || len(data) < 4 || binary.BigEndian.Uint32(data) != want:
+		return nil, fmt.Errorf("SCRAM: unexpected message %q during SASL exchange", msgType)
+	}
+	return data[4:], nil
+}
+
+// writePasswordMessage sends a 'p' message: PasswordMessage,
+// SASLInitialResponse and SASLResponse all share it.
+func (c *Conn) writePasswordMessage(body []byte) error {
+	buf := make([]byte, 5, 5+len(body))
+	buf[0] = 'p'
+	binary.BigEndian.PutUint32(buf[1:5], uint32(4+len(body)))
+	_, err := c.conn.Write(append(buf, body...))
+	return err
+}
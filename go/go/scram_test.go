@@ -0,0 +1,77 @@
+package qail
+
+import (
+	"strings"
+	"testing"
+)
+
+// rfc7677Client is the client side of the RFC 7677 example exchange. The
+// driver sends an empty user name, so clientFirstBare is set to the RFC's
+// to reproduce its proof.
+func rfc7677Client() *scramClient {
+	return &scramClient{
+		password:        "pencil",
+		clientNonce:     "rOprNGfwEbeRWgbNEkqO",
+		gs2Header:       "n,,",
+		clientFirstBare: "n=user,r=rOprNGfwEbeRWgbNEkqO",
+	}
+}
+
+const rfc7677ServerFirst = "r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096"
+
+func TestScramRFC7677(t *testing.T) {
+	s := rfc7677Client()
+	final, err := s.clientFinal([]byte(rfc7677ServerFirst))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "c=biws,r=rOprNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,p=dHzbZapWIk4jUhN+Ute9ytag9zjfMHgsqmmiz7AndVQ="
+	if string(final) != want {
+		t.Fatalf("client-final = %q, want %q", final, want)
+	}
+	if err := s.verifyServerFinal([]byte("v=6rriTRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=")); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScramClientFirst(t *testing.T) {
+	s, mech, err := newScramClient([]string{scramSHA256}, "pencil", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mech != scramSHA256 {
+		t.Fatalf("mechanism = %q", mech)
+	}
+	if got, want := string(s.clientFirst()), "n,,n=,r="+s.clientNonce; got != want {
+		t.Fatalf("client-first = %q, want %q", got, want)
+	}
+}
+
+func TestScramRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		serverFirst string
+		serverFinal string
+		want        string
+	}{
+		{"foreign nonce", "r=xxxxNGfwEbeRWgbNEkqO%hvYDpWUa2RaTCAfuxFIlj)hNlF$k0,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", "", "does not extend"},
+		{"unextended nonce", "r=rOprNGfwEbeRWgbNEkqO,s=W22ZaJ0SNY7soEsUEjb6gQ==,i=4096", "", "does not extend"},
+		{"bad salt", "r=rOprNGfwEbeRWgbNEkqOxyz,s=!!,i=4096", "", "invalid salt"},
+		{"no iterations", "r=rOprNGfwEbeRWgbNEkqOxyz,s=W22ZaJ0SNY7soEsUEjb6gQ==", "", "invalid iteration count"},
+		{"signature mismatch", rfc7677ServerFirst, "v=AAAATRBi23WpRR/wtup+mMhUZUn/dB5nLTJRsjl95G4=", "signature mismatch"},
+		{"server error", rfc7677ServerFirst, "e=invalid-proof", "server error: invalid-proof"},
+		{"malformed final", rfc7677ServerFirst, "x=1", "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := rfc7677Client()
+			_, err := s.clientFinal([]byte(tt.serverFirst))
+			if err == nil && tt.serverFinal != "" {
+				err = s.verifyServerFinal([]byte(tt.serverFinal))
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package qail
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Set sets the session parameter param (a GUC such as "search_path",
+// "role", "TimeZone" or a custom "app.tenant_id") to value for the rest
+// of the session, as SET would:
+//
+//	pc, err := d.Acquire(ctx)
+//	...
+//	err = pc.Set("search_path", "tenant_42, public")
+//
+// Run it on a connection that stays pinned, such as one from Acquire: on
+// a connection shared through the pool the setting would leak to
+// unrelated queries. param must be a plain or dotted identifier; value is
+// bound as a parameter, never spliced into SQL.
+func (c *Conn) Set(param, value string) error {
+	if err := validateParamName(param); err != nil {
+		return err
+	}
+	_, err := c.sessionQuery("SELECT set_config($1, $2, false)", param, value)
+	return err
+}
+
+// Show returns the current value of the session parameter param, as SHOW
+// would.
+func (c *Conn) Show(param string) (string, error) {
+	if err := validateParamName(param); err != nil {
+		return "", err
+	}
+	return c.sessionQuery("SELECT current_setting($1)", param)
+}
+
+// sessionQuery runs a one-value query with text parameters and returns
+// the value.
+func (c *Conn) sessionQuery(sql string, args ...string) (string, error) {
+	params := make([][]byte, len(args))
+	for i, a := range args {
+		params[i] = []byte(a)
+	}
+	buf := appendParse(nil, "", sql)
+	buf = appendBind(buf, "", "", params, FormatText)
+	buf = appendDescribe(buf, 'P', "")
+	buf = appendExecute(buf, "", 0)
+	buf = append(buf, 'S', 0, 0, 0, 4)
+	if _, err := c.conn.Write(buf); err != nil {
+		return "", fmt.Errorf("write failed: %w", err)
+	}
+	rows, err := c.readRows()
+	if err != nil {
+		return "", err
+	}
+	if len(rows) == 0 {
+		return "", ErrNoRows
+	}
+	return rows[0].GetString(0), nil
+}
+
+// validateParamName accepts a parameter name made of identifiers joined
+// by dots, e.g. "search_path" or "app.tenant_id".
+func validateParamName(param string) error {
+	for _, part := range strings.Split(param, ".") {
+		if err := validateIdentifier(part); err != nil {
+			return fmt.Errorf("session parameter: %w", err)
+		}
+	}
+	return nil
+}
+
+// Set is Conn.Set on the pinned connection.
+func (pc *PooledConn) Set(param, value string) error {
+	if pc.c == nil {
+		return ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	return pc.c.Set(param, value)
+}
+
+// Show is Conn.Show on the pinned connection.
+func (pc *PooledConn) Show(param string) (string, error) {
+	if pc.c == nil {
+		return "", ErrConnReleased
+	}
+	defer runtime.KeepAlive(pc)
+	return pc.c.Show(param)
+}
@@ -0,0 +1,219 @@
+package qail
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Result holds the output of one statement: its rows, column metadata and
+// command tag.
+type Result struct {
+	Rows       []Row
+	CommandTag string // e.g. "SELECT 3", "INSERT 0 1"
+
+	fields []ColumnInfo
+}
+
+// Fields returns the column metadata of the statement's result set,
+// or nil if the statement returned no rows.
+func (r *Result) Fields() []ColumnInfo {
+	return r.fields
+}
+
+// Columns returns the column names of the statement's result set, in
+// order, or nil if the statement returned no rows.
+func (r *Result) Columns() []string {
+	if r.fields == nil {
+		return nil
+	}
+	names := make([]string, len(r.fields))
+	for i, f := range r.fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// Release returns the result's row storage to a pool for reuse by later
+// queries, cutting allocations in tight query loops. The Result, its Rows
+// and any []byte obtained from Row.Get must not be used afterwards;
+// values already copied out (strings, numbers) are unaffected. Calling
+// Release is optional.
+func (r *Result) Release() {
+	if r.Rows == nil {
+		return
+	}
+	for i := range r.Rows {
+		if cols := r.Rows[i].columns; cols != nil {
+			clear(cols)
+			cols = cols[:0]
+			columnsPool.Put(&cols)
+		}
+		r.Rows[i] = Row{}
+	}
+	rows := r.Rows[:0]
+	rowsPool.Put(&rows)
+	r.Rows = nil
+}
+
+// RowsAffected returns the row count reported in the command tag.
+func (r *Result) RowsAffected() int64 {
+	return tagRows(r.CommandTag)
+}
+
+// tagRows parses the row count that ends a command tag, e.g. "COPY 3".
+func tagRows(tag string) int64 {
+	i := strings.LastIndexByte(tag, ' ')
+	if i < 0 {
+		return 0
+	}
+	n, _ := strconv.ParseInt(tag[i+1:], 10, 64)
+	return n
+}
+
+// SimpleExec runs sql using the simple query protocol. sql may contain
+// several semicolon-separated statements; one Result is returned per
+// statement, split on the CommandComplete boundaries. On error, the
+// results of the statements that completed before it are still returned.
+func (d *Driver) SimpleExec(sql string) (results []*Result, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("SimpleExec", sql)
+		defer func() { d.traceEnd(q, len(results), err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	if err := c.sendQuery(sql); err != nil {
+		return nil, err
+	}
+	return c.readResults()
+}
+
+// ExecuteSimple runs sql, which may hold several semicolon-separated
+// statements (e.g. a migration script), in a single round trip using the
+// simple query protocol. Rows are read and discarded as they arrive, so
+// none are buffered. The statements run in one implicit transaction
+// unless the script manages its own.
+//
+// On failure the error names the statement that failed (1-based, counting
+// statements that completed before it) and wraps the server's *PgError,
+// whose Position is the character offset into sql.
+func (d *Driver) ExecuteSimple(sql string) (err error) {
+	var completed int
+	if d.tracer != nil {
+		q := d.traceStart("ExecuteSimple", sql)
+		defer func() { d.traceEnd(q, completed, err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return err
+	}
+	defer d.putConn(c)
+
+	completed, err = c.execScript(sql)
+	var pgErr *PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Position > 0 {
+			return fmt.Errorf("statement %d (at character %d): %w", completed+1, pgErr.Position, err)
+		}
+		return fmt.Errorf("statement %d: %w", completed+1, err)
+	}
+	return err
+}
+
+// execScript sends sql as a simple Query and reads to ReadyForQuery,
+// discarding rows. It returns the number of statements that completed,
+// counting an empty query, which the server answers with
+// EmptyQueryResponse instead of CommandComplete.
+func (c *Conn) execScript(sql string) (completed int, err error) {
+	if err := c.sendQuery(sql); err != nil {
+		return 0, err
+	}
+	var queryErr error
+	for {
+		// Nothing is kept past the next read, so the scratch buffer will do.
+		msgType, data, err := c.readMessageFast()
+		if err != nil {
+			return completed, err
+		}
+		switch msgType {
+		case 'C', 'I': // CommandComplete, EmptyQueryResponse
+			completed++
+		case 'E':
+			// The server skips the remaining statements but still sends 'Z'.
+			queryErr = serverError("query error", data)
+		case 'Z': // ReadyForQuery
+			return completed, queryErr
+		}
+	}
+}
+
+// sendQuery sends a simple Query ('Q') message.
+func (c *Conn) sendQuery(sql string) error {
+	length := 4 + len(sql) + 1
+	buf := make([]byte, 1+length)
+	buf[0] = 'Q'
+	binary.BigEndian.PutUint32(buf[1:5], uint32(length))
+	copy(buf[5:], sql)
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+// readResults reads simple-query responses until ReadyForQuery,
+// producing one Result per completed statement.
+func (c *Conn) readResults() ([]*Result, error) {
+	var results []*Result
+	var queryErr error
+	cur := &Result{}
+
+	for {
+		msgType, data, err := c.readMessage()
+		if err != nil {
+			return results, err
+		}
+
+		switch msgType {
+		case 'T': // RowDescription - starts a new row-returning statement
+			if cur.fields, err = parseRowDescription(data); err != nil && queryErr == nil {
+				queryErr = err
+			}
+		case 'D': // DataRow
+			cols, err := parseDataRow(data)
+			if err != nil {
+				if queryErr == nil {
+					queryErr = err
+				}
+				continue
+			}
+			cur.Rows = append(cur.Rows, Row{columns: cols, fields: cur.fields})
+		case 'C': // CommandComplete - statement boundary
+			cur.CommandTag = cstring(data)
+			results = append(results, cur)
+			cur = &Result{}
+		case 'I': // EmptyQueryResponse
+			continue
+		case 'E':
+			// The server skips the remaining statements but still sends 'Z'.
+			queryErr = serverError("query error", data)
+		case 'Z': // ReadyForQuery
+			return results, queryErr
+		}
+	}
+}
+
+// cstring returns data up to the first null byte.
+func cstring(data []byte) string {
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i])
+		}
+	}
+	return string(data)
+}
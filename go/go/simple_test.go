@@ -0,0 +1,115 @@
+package qail
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestSimpleExecSplitsResults(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Handle("SELECT 1", qailtest.Response{Columns: []qailtest.Column{{Name: "?column?", OID: qailtest.OIDInt4}}, Rows: [][]any{{1}}})
+	srv.Handle("SELECT id, name FROM users", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}, {Name: "name", OID: qailtest.OIDText}},
+		Rows:    [][]any{{1, "ada"}, {2, "grace"}},
+	})
+	srv.Handle("SELECT name FROM users WHERE false", qailtest.Response{Columns: []qailtest.Column{{Name: "name", OID: qailtest.OIDText}}})
+	d := fakeDriver(t, srv, Config{})
+
+	results, err := d.SimpleExec("SELECT 1; SELECT id, name FROM users; SELECT name FROM users WHERE false")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("got %d results, want 3", len(results))
+	}
+	for i, want := range []struct {
+		tag     string
+		columns []string
+		rows    int
+	}{
+		{"SELECT 1", []string{"?column?"}, 1},
+		{"SELECT 2", []string{"id", "name"}, 2},
+		{"SELECT 0", []string{"name"}, 0},
+	} {
+		r := results[i]
+		if r.CommandTag != want.tag || !reflect.DeepEqual(r.Columns(), want.columns) || len(r.Rows) != want.rows {
+			t.Errorf("result %d: tag %q, columns %v, %d rows; want %q, %v, %d",
+				i, r.CommandTag, r.Columns(), len(r.Rows), want.tag, want.columns, want.rows)
+		}
+	}
+	if got := results[1].Rows[1].GetString(1); got != "grace" {
+		t.Errorf("second result, row 2 = %q, want grace", got)
+	}
+}
+
+func TestExecuteSimpleNamesFailingStatement(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Handle("CREATE TABLE t (id int)", qailtest.Response{Tag: "CREATE TABLE"})
+	srv.Handle("SELECT id FROM t", qailtest.Response{
+		Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}},
+		Rows:    [][]any{{1}, {2}},
+	})
+	srv.Handle("INSERT INTO t VALUES ('x')", qailtest.Response{Err: &qailtest.Error{Code: "22P02", Message: "invalid input syntax for type integer"}})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1})
+
+	if err := d.ExecuteSimple("CREATE TABLE t (id int); SELECT id FROM t"); err != nil {
+		t.Fatal(err)
+	}
+	err := d.ExecuteSimple("CREATE TABLE t (id int); SELECT id FROM t; INSERT INTO t VALUES ('x')")
+	var pgErr *PgError
+	if !errors.As(err, &pgErr) || pgErr.Code != "22P02" || !strings.HasPrefix(err.Error(), "statement 3: ") {
+		t.Errorf("err = %v, want statement 3 to fail with 22P02", err)
+	}
+
+	// An empty query is answered with EmptyQueryResponse, not CommandComplete.
+	if err := d.ExecuteSimple(""); err != nil {
+		t.Errorf("empty query: %v", err)
+	}
+	if err := d.ExecuteSimple("SELECT id FROM t"); err != nil {
+		t.Errorf("connection unusable after the failed script: %v", err)
+	}
+}
+
+// BenchmarkResultRelease reads a 100-row result with and without handing
+// its storage back through Release, to show the allocations pooling
+// saves.
+func BenchmarkResultRelease(b *testing.B) {
+	rows := make([][]any, 100)
+	for i := range rows {
+		rows[i] = []any{i, "ada"}
+	}
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		return qailtest.Response{
+			Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}, {Name: "name", OID: qailtest.OIDText}},
+			Rows:    rows,
+		}, true
+	})
+	d := fakeDriver(b, srv, Config{})
+	cmd := Get("users")
+	for _, release := range []bool{false, true} {
+		name := "keep"
+		if release {
+			name = "release"
+		}
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				res, err := d.FetchResult(cmd)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(res.Rows) != len(rows) {
+					b.Fatalf("got %d rows, want %d", len(res.Rows), len(rows))
+				}
+				if release {
+					res.Release()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package qail
+
+import "fmt"
+
+// Template is a command rendered once so it can be run many times with
+// different Param values, without rebuilding or re-encoding it:
+//
+//	t, err := qail.NewTemplate(qail.Get("harbors").
+//	    Columns("id", "name").
+//	    Filter("id", qail.Eq, qail.Param(1)))
+//	for i := range n {
+//	    rows, err := driver.FetchTemplate(t, i)
+//	    ...
+//	}
+//
+// The SQL text and the Parse message are built by NewTemplate; each call
+// only encodes its arguments into a Bind. A Template holds no handle, so
+// the command may be freed or changed afterwards, and it is safe for
+// concurrent use.
+type Template struct {
+	sql      string
+	literals [][]byte // the command's literal filter values, $1..$L
+	parse    []byte   // Parse message for the unnamed statement
+}
+
+// NewTemplate renders cmd into a Template. Literal filter values are
+// fixed at this point; Param placeholders are bound per call.
+func NewTemplate(cmd *Qail) (*Template, error) {
+	sql, literals, err := cmd.sqlParams()
+	if err != nil {
+		return nil, err
+	}
+	return &Template{sql: sql, literals: literals, parse: appendParse(nil, "", sql)}, nil
+}
+
+// SQL returns the template's SQL text, with literal values as $1..$L and
+// Param(n) as $L+n.
+func (t *Template) SQL() string {
+	return t.sql
+}
+
+// Bind returns the wire bytes that run the template with args bound to
+// its Param placeholders: Parse + Bind + Describe(Portal) + Execute + Sync.
+// Args are sent in text format; see encodeTextArg.
+func (t *Template) Bind(args ...any) ([]byte, error) {
+	return t.bind(FormatText, args)
+}
+
+func (t *Template) bind(resultFormat int16, args []any) ([]byte, error) {
+	if len(t.literals)+len(args) > MaxParams {
+		return nil, fmt.Errorf("%w: template binds %d parameters, limit is %d", ErrTooManyParams, len(t.literals)+len(args), MaxParams)
+	}
+	params := make([][]byte, len(t.literals), len(t.literals)+len(args))
+	copy(params, t.literals)
+	for i, arg := range args {
+		p, err := encodeTextArg(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i+1, err)
+		}
+		params = append(params, p)
+	}
+
+	buf := make([]byte, 0, len(t.parse)+64)
+	buf = append(buf, t.parse...)
+	buf = appendBind(buf, "", "", params, resultFormat)
+	buf = appendDescribe(buf, 'P', "")
+	buf = appendExecute(buf, "", 0)
+	return append(buf, 'S', 0, 0, 0, 4), nil
+}
+
+// FetchTemplate runs t with args bound to its Param placeholders and
+// returns all rows. It always runs on the primary pool.
+func (d *Driver) FetchTemplate(t *Template, args ...any) (rows []Row, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("FetchTemplate", t.sql)
+		defer func() { d.traceEnd(q, len(rows), err) }()
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	buf, err := t.bind(c.resultFormat, args)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+	return c.readRows()
+}
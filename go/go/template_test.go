@@ -0,0 +1,107 @@
+package qail
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestTemplateRerender(t *testing.T) {
+	cmd := Get("harbors").Columns("id", "name").Filter("id", Eq, Param(1))
+	tmpl, err := NewTemplate(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sql := tmpl.SQL()
+	cmd.Free() // the template keeps no reference to the command
+
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != sql {
+			return qailtest.Response{}, false
+		}
+		r := qailtest.Response{Columns: []qailtest.Column{{Name: "id", OID: qailtest.OIDInt4}, {Name: "name", OID: qailtest.OIDText}}}
+		if len(q.Args) == 1 { // nil for Describe
+			r.Rows = [][]any{{q.Args[0], "harbor " + string(q.Args[0])}}
+		}
+		return r, true
+	})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	for id := 1; id <= 3; id++ {
+		rows, err := d.FetchTemplate(tmpl, id)
+		if err != nil {
+			t.Fatalf("id %d: %v", id, err)
+		}
+		if len(rows) != 1 || rows[0].GetInt(0) != int64(id) || rows[0].GetString(1) != "harbor "+strconv.Itoa(id) {
+			t.Errorf("id %d: got %d rows, first %q", id, len(rows), rows[0].GetString(1))
+		}
+	}
+	var args [][]byte
+	for _, q := range srv.Queries() {
+		if q.Args != nil {
+			args = append(args, q.Args...)
+		}
+	}
+	if want := [][]byte{[]byte("1"), []byte("2"), []byte("3")}; !reflect.DeepEqual(args, want) {
+		t.Errorf("bound args = %q, want %q", args, want)
+	}
+
+	// Each Bind reuses the rendered Parse message; only the Bind differs.
+	a, err := tmpl.Bind(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := tmpl.Bind(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(a, tmpl.parse) || !bytes.HasPrefix(b, tmpl.parse) {
+		t.Error("Bind output does not start with the template's Parse message")
+	}
+	if bytes.Equal(a, b) {
+		t.Error("Bind(1) and Bind(2) produced the same bytes")
+	}
+
+	if _, err := tmpl.Bind(struct{}{}); err == nil {
+		t.Error("Bind with an unsupported argument: no error")
+	}
+	if _, err := tmpl.Bind(make([]any, MaxParams+1)...); !errors.Is(err, ErrTooManyParams) {
+		t.Errorf("Bind over MaxParams: err = %v, want ErrTooManyParams", err)
+	}
+}
+
+// BenchmarkTemplate compares binding new values into a Template with
+// rebuilding and encoding the command for every query, the sequential
+// path the Template replaces.
+func BenchmarkTemplate(b *testing.B) {
+	b.Run("template", func(b *testing.B) {
+		cmd := Get("harbors").Columns("id", "name").Filter("id", Eq, Param(1))
+		tmpl, err := NewTemplate(cmd)
+		cmd.Free()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := tmpl.Bind(i%10 + 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("rebuild", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			cmd := Get("harbors").Columns("id", "name").Filter("id", Eq, i%10+1)
+			if len(cmd.Encode()) == 0 {
+				b.Fatal("encode failed")
+			}
+			cmd.Free()
+		}
+	})
+}
@@ -0,0 +1,89 @@
+package qail
+
+import (
+	"net"
+	"strings"
+	"time"
+)
+
+// Tracer observes queries issued through a Driver, e.g. for slow-query
+// logging or APM integration. Methods are called synchronously on the
+// querying goroutine and must be safe for concurrent use.
+//
+// Only Driver methods are traced. Statements run on a Tx, a PooledConn,
+// a Cursor or a Listener are not, nor are the pool's keepalive pings.
+// For QueryRows, QueryEnd is called by Rows.Close with the rows read.
+type Tracer interface {
+	// QueryStart is called before the query is sent.
+	QueryStart(q *TraceQuery)
+	// QueryEnd is called when the query finishes. rows is the number of
+	// rows returned, or the number of completed commands for batches.
+	QueryEnd(q *TraceQuery, rows int, err error, elapsed time.Duration)
+}
+
+// TraceQuery describes one traced call.
+type TraceQuery struct {
+	Op    string // driver method, e.g. "FetchAll", "BatchExecute"
+	SQL   string // statement text with $n placeholders; batches are joined with "; "
+	Start time.Time
+}
+
+func (d *Driver) traceStart(op, sql string) *TraceQuery {
+	q := &TraceQuery{Op: op, SQL: sql, Start: time.Now()}
+	d.tracer.QueryStart(q)
+	return q
+}
+
+func (d *Driver) traceEnd(q *TraceQuery, rows int, err error) {
+	d.tracer.QueryEnd(q, rows, err, time.Since(q.Start))
+}
+
+// batchSQL joins the SQL text of cmds for tracing.
+func batchSQL(cmds []*Qail) string {
+	var b strings.Builder
+	for i, cmd := range cmds {
+		if i > 0 {
+			b.WriteString("; ")
+		}
+		b.WriteString(cmd.SQL())
+	}
+	return b.String()
+}
+
+// PoolTracer may additionally be implemented by a Tracer to observe
+// connection checkout.
+type PoolTracer interface {
+	// ConnAcquired is called when a query obtains a connection, with the
+	// time spent taking it from the pool or dialing a new one. err is
+	// non-nil if no connection could be obtained.
+	ConnAcquired(wait time.Duration, err error)
+}
+
+// IOTracer may additionally be implemented by a Tracer to count the bytes
+// read from and written to server connections.
+type IOTracer interface {
+	BytesRead(n int)
+	BytesWritten(n int)
+}
+
+// countingConn reports the bytes moved over a net.Conn to an IOTracer.
+type countingConn struct {
+	net.Conn
+	io IOTracer
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.io.BytesRead(n)
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.io.BytesWritten(n)
+	}
+	return n, err
+}
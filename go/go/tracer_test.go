@@ -0,0 +1,90 @@
+package qail
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+type traced struct {
+	op, sql string
+	rows    int
+	err     error
+}
+
+// recordingTracer records each QueryEnd.
+type recordingTracer struct {
+	mu    sync.Mutex
+	calls []traced
+}
+
+func (t *recordingTracer) QueryStart(*TraceQuery) {}
+
+func (t *recordingTracer) QueryEnd(q *TraceQuery, rows int, err error, _ time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.calls = append(t.calls, traced{q.Op, q.SQL, rows, err})
+}
+
+func (t *recordingTracer) last(tb testing.TB) traced {
+	tb.Helper()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.calls) == 0 {
+		tb.Fatal("nothing traced")
+	}
+	c := t.calls[len(t.calls)-1]
+	t.calls = nil
+	return c
+}
+
+func TestTracerRawSQL(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != "SELECT n" {
+			return qailtest.Response{}, false
+		}
+		return intRows(1, 2, 3)(q)
+	})
+	tr := &recordingTracer{}
+	d := fakeDriver(t, srv, Config{Tracer: tr, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	if _, err := d.SimpleExec("SELECT n; SELECT n"); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.last(t); c.op != "SimpleExec" || c.sql != "SELECT n; SELECT n" || c.rows != 2 || c.err != nil {
+		t.Errorf("SimpleExec traced as %+v, want 2 statements", c)
+	}
+
+	rows, err := d.QueryRows("SELECT n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.last(t); c.op != "QueryRows" || c.sql != "SELECT n" || c.rows != 3 || c.err != nil {
+		t.Errorf("QueryRows traced as %+v, want 3 rows", c)
+	}
+
+	if err := d.Prepare("n", "SELECT n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.QueryPrepared("n"); err != nil {
+		t.Fatal(err)
+	}
+	if c := tr.last(t); c.op != "QueryPrepared" || c.sql != "SELECT n" || c.rows != 3 || c.err != nil {
+		t.Errorf("QueryPrepared traced as %+v, want 3 rows", c)
+	}
+
+	if _, err := d.SimpleExec("SELECT missing"); err == nil {
+		t.Fatal("unhandled statement: no error")
+	}
+	if c := tr.last(t); c.op != "SimpleExec" || c.err == nil {
+		t.Errorf("failed SimpleExec traced as %+v, want its error", c)
+	}
+}
@@ -0,0 +1,408 @@
+package qail
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// ErrTxDone is returned by operations on a transaction that has already
+// been committed or rolled back.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
+// ErrTxRolledBack is returned by Commit when the transaction had failed:
+// the server answers COMMIT in a failed transaction by rolling it back.
+var ErrTxRolledBack = errors.New("transaction was rolled back instead of committed")
+
+// Tx is a transaction pinned to one pooled connection. It must be ended
+// with Commit or Rollback, which return the connection to the pool.
+//
+// A Tx is not safe for concurrent use: its statements share one
+// connection and must run one after another. Overlapping calls panic
+// rather than interleave protocol messages. Driver methods remain usable
+// while a Tx is open; they use other pooled connections.
+type Tx struct {
+	d    *Driver
+	c    *Conn
+	done atomic.Bool // set by Commit or Rollback; read by Status without the busy guard
+	busy atomic.Bool // set while a method is using c
+	held int         // open WITH HOLD cursors; they keep c checked out after the Tx ends
+	kept bool        // the Tx committed, so its WITH HOLD cursors survived it
+}
+
+// enter marks the Tx busy for the duration of a call.
+func (tx *Tx) enter() {
+	if !tx.busy.CompareAndSwap(false, true) {
+		panic("qail: concurrent use of Tx")
+	}
+}
+
+func (tx *Tx) exit() {
+	tx.busy.Store(false)
+}
+
+// TxStatus is the transaction status a server reports with every
+// ReadyForQuery.
+type TxStatus byte
+
+const (
+	TxIdle   TxStatus = 'I' // not in a transaction block
+	TxActive TxStatus = 'T' // in a transaction block
+	TxFailed TxStatus = 'E' // in a failed transaction block: queries are rejected until ROLLBACK
+)
+
+func (s TxStatus) String() string {
+	switch s {
+	case TxIdle:
+		return "idle"
+	case TxActive:
+		return "in transaction"
+	case TxFailed:
+		return "failed transaction"
+	}
+	return fmt.Sprintf("TxStatus(%q)", byte(s))
+}
+
+// TxStatus returns the transaction status reported by the server's last
+// ReadyForQuery. putConn rolls back connections that aren't TxIdle
+// before pooling them.
+func (c *Conn) TxStatus() TxStatus {
+	return TxStatus(c.txStatus.Load())
+}
+
+// Status returns the transaction's status as of its last statement:
+// TxActive, or TxFailed after an error, when only Rollback or RollbackTo
+// can proceed. It is TxIdle once the Tx has ended.
+//
+// Unlike the Tx's other methods, Status may be called from any goroutine
+// while a statement runs; it then reports the status before that
+// statement.
+func (tx *Tx) Status() TxStatus {
+	s := tx.c.TxStatus()
+	// Checked after the read: a Tx still open then had not yet returned c
+	// to the pool, so s is this transaction's status.
+	if tx.done.Load() {
+		return TxIdle
+	}
+	return s
+}
+
+// Begin starts a transaction on a connection taken from the pool.
+func (d *Driver) Begin() (*Tx, error) {
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.simpleExec("BEGIN"); err != nil {
+		d.putConn(c)
+		return nil, err
+	}
+	return &Tx{d: d, c: c}, nil
+}
+
+// TxOptions configures BeginTx and RunInTx.
+type TxOptions struct {
+	// Isolation is "SERIALIZABLE", "REPEATABLE READ", "READ COMMITTED",
+	// or "" for the server default.
+	Isolation string
+	ReadOnly  bool
+
+	// MaxAttempts bounds how many times RunInTx runs the transaction,
+	// counting the first; default 5.
+	MaxAttempts int
+	// RetryBackoff is the delay before the first retry; it doubles, with
+	// jitter, on each further retry, capped at one second. Default 10ms.
+	RetryBackoff time.Duration
+}
+
+// beginSQL returns the BEGIN statement for opts.
+func (opts TxOptions) beginSQL() (string, error) {
+	sql := "BEGIN"
+	switch opts.Isolation {
+	case "":
+	case "SERIALIZABLE", "REPEATABLE READ", "READ COMMITTED":
+		sql += " ISOLATION LEVEL " + opts.Isolation
+	default:
+		return "", fmt.Errorf("unsupported isolation level %q", opts.Isolation)
+	}
+	if opts.ReadOnly {
+		sql += " READ ONLY"
+	}
+	return sql, nil
+}
+
+// BeginTx is Begin with an isolation level and access mode. ctx bounds
+// the wait for a connection.
+func (d *Driver) BeginTx(ctx context.Context, opts TxOptions) (*Tx, error) {
+	begin, err := opts.beginSQL()
+	if err != nil {
+		return nil, err
+	}
+	c, err := d.getConnContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.simpleExec(begin); err != nil {
+		d.putConn(c)
+		return nil, err
+	}
+	return &Tx{d: d, c: c}, nil
+}
+
+// TxRetryError is returned by RunInTx when the transaction still failed
+// with a retryable error after its last attempt.
+type TxRetryError struct {
+	Attempts int
+	Err      error // the last attempt's error
+}
+
+func (e *TxRetryError) Error() string {
+	return fmt.Sprintf("transaction failed after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *TxRetryError) Unwrap() error { return e.Err }
+
+// IsRetryable reports whether err is a serialization failure (SQLSTATE
+// 40001) or a detected deadlock (40P01): errors that mean the whole
+// transaction should be run again.
+func IsRetryable(err error) bool {
+	var pgErr *PgError
+	return errors.As(err, &pgErr) && (pgErr.Code == "40001" || pgErr.Code == "40P01")
+}
+
+// RunInTx runs fn in a transaction begun with opts and commits it if fn
+// returns nil, or rolls it back if fn returns an error or panics. When fn,
+// a statement in it, or the commit fails with a retryable error (see
+// IsRetryable), the transaction is rolled back and run again from the
+// start after a backoff, up to opts.MaxAttempts times; fn must therefore
+// be safe to repeat and should only affect the database through tx.
+//
+// Other errors are returned as they are. Running out of attempts returns
+// a *TxRetryError with the attempt count; ctx ending during a backoff
+// returns ctx's error.
+func (d *Driver) RunInTx(ctx context.Context, opts TxOptions, fn func(*Tx) error) error {
+	if _, err := opts.beginSQL(); err != nil {
+		return err
+	}
+	attempts := opts.MaxAttempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	backoff := opts.RetryBackoff
+	if backoff <= 0 {
+		backoff = 10 * time.Millisecond
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := d.runTxOnce(ctx, opts, fn)
+		if err == nil || !IsRetryable(err) {
+			return err
+		}
+		if attempt == attempts {
+			return &TxRetryError{Attempts: attempt, Err: err}
+		}
+
+		// Full jitter: sleep a random time up to the current backoff.
+		timer := time.NewTimer(rand.N(backoff) + 1)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+		backoff = min(2*backoff, time.Second)
+	}
+}
+
+// runTxOnce is one RunInTx attempt.
+func (d *Driver) runTxOnce(ctx context.Context, opts TxOptions, fn func(*Tx) error) (err error) {
+	tx, err := d.BeginTx(ctx, opts)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(tx); err != nil {
+		tx.Rollback() // ErrTxDone if fn already ended it
+		return err
+	}
+	if err := tx.Commit(); err != nil && !errors.Is(err, ErrTxDone) {
+		return err
+	}
+	return nil
+}
+
+// FetchAll executes a query inside the transaction and returns all rows.
+func (tx *Tx) FetchAll(cmd *Qail) ([]Row, error) {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return nil, ErrTxDone
+	}
+	return tx.c.fetchAll(cmd)
+}
+
+// FetchAllContext is FetchAll that stops waiting when ctx ends: the
+// server is sent a CancelRequest and ctx.Err() is returned. The
+// connection can't be trusted after that, so the transaction can only be
+// ended, and its connection is closed rather than pooled.
+func (tx *Tx) FetchAllContext(ctx context.Context, cmd *Qail) (rows []Row, err error) {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return nil, ErrTxDone
+	}
+	err = tx.c.withContext(ctx, func() (err error) {
+		rows, err = tx.c.fetchAll(cmd)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// FetchOne executes a query inside the transaction and returns its first
+// row, or ErrNoRows if the result is empty.
+func (tx *Tx) FetchOne(cmd *Qail) (Row, error) {
+	return firstRow(tx.FetchAll(cmd))
+}
+
+// Execute executes a command that returns no rows inside the transaction.
+func (tx *Tx) Execute(cmd *Qail) error {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return ErrTxDone
+	}
+	return tx.c.execute(cmd)
+}
+
+// ExecuteContext is Execute that stops waiting when ctx ends, as
+// FetchAllContext does.
+func (tx *Tx) ExecuteContext(ctx context.Context, cmd *Qail) error {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return ErrTxDone
+	}
+	return tx.c.withContext(ctx, func() error { return tx.c.execute(cmd) })
+}
+
+// ExecuteSimple runs raw SQL inside the transaction using the simple
+// query protocol. Rows are discarded.
+func (tx *Tx) ExecuteSimple(sql string) error {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return ErrTxDone
+	}
+	return tx.c.simpleExec(sql)
+}
+
+// Commit commits the transaction and releases its connection. If a
+// statement in the transaction failed, the server rolls back instead and
+// Commit returns ErrTxRolledBack.
+func (tx *Tx) Commit() error {
+	return tx.end("COMMIT")
+}
+
+// Rollback aborts the transaction and releases its connection. Calling
+// Rollback after Commit or Rollback returns ErrTxDone.
+func (tx *Tx) Rollback() error {
+	return tx.end("ROLLBACK")
+}
+
+func (tx *Tx) end(sql string) error {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return ErrTxDone
+	}
+	tx.done.Store(true)
+	failed := tx.c.TxStatus() == TxFailed
+	err := tx.c.simpleExec(sql)
+	tx.kept = err == nil && !failed && sql == "COMMIT"
+	if !tx.kept {
+		// Only a clean commit keeps WITH HOLD cursors; the server dropped them.
+		tx.held = 0
+	}
+	if tx.held == 0 {
+		tx.d.putConn(tx.c)
+	}
+	// tx.c is kept for Status, which checks done before trusting it.
+	if err == nil && failed && sql == "COMMIT" {
+		// The server rolled back; its ROLLBACK tag is not an error.
+		return ErrTxRolledBack
+	}
+	return err
+}
+
+// Savepoint creates a savepoint that RollbackTo can return to.
+func (tx *Tx) Savepoint(name string) error {
+	return tx.savepointCmd("SAVEPOINT ", name)
+}
+
+// RollbackTo undoes everything done since the named savepoint, including
+// leaving the aborted state after a failed statement. The savepoint
+// remains and can be rolled back to again.
+func (tx *Tx) RollbackTo(name string) error {
+	return tx.savepointCmd("ROLLBACK TO SAVEPOINT ", name)
+}
+
+// ReleaseSavepoint discards the named savepoint, keeping its changes.
+func (tx *Tx) ReleaseSavepoint(name string) error {
+	return tx.savepointCmd("RELEASE SAVEPOINT ", name)
+}
+
+func (tx *Tx) savepointCmd(prefix, name string) error {
+	tx.enter()
+	defer tx.exit()
+
+	if tx.done.Load() {
+		return ErrTxDone
+	}
+	if err := validateIdentifier(name); err != nil {
+		return fmt.Errorf("savepoint: %w", err)
+	}
+	return tx.c.simpleExec(prefix + name)
+}
+
+// validateIdentifier accepts unquoted SQL identifiers only: a letter or
+// underscore followed by letters, digits, underscores or '$', at most 63
+// bytes (NAMEDATALEN-1).
+func validateIdentifier(name string) error {
+	if name == "" || len(name) > 63 {
+		return fmt.Errorf("invalid identifier %q: length must be 1-63", name)
+	}
+	for i := 0; i < len(name); i++ {
+		ch := name[i]
+		switch {
+		case ch >= 'a' && ch <= 'z', ch >= 'A' && ch <= 'Z', ch == '_':
+		case i > 0 && (ch >= '0' && ch <= '9' || ch == '$'):
+		default:
+			return fmt.Errorf("invalid identifier %q", name)
+		}
+	}
+	return nil
+}
+
+// simpleExec runs sql with the simple query protocol, discarding rows.
+func (c *Conn) simpleExec(sql string) error {
+	_, err := c.execScript(sql)
+	return err
+}
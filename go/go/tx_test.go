@@ -0,0 +1,257 @@
+package qail
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestPutConnRollsBackOpenTransaction(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		script []string // run on the leaked connection
+		status TxStatus
+	}{
+		{"active", []string{"BEGIN"}, TxActive},
+		{"failed", []string{"BEGIN", "SELECT missing"}, TxFailed},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := qailtest.NewServer()
+			srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+			srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+			srv.Handle("SELECT missing", qailtest.Response{Err: &qailtest.Error{Code: "42703", Message: `column "missing" does not exist`}})
+			srv.Handle("SELECT 1", qailtest.Response{Columns: []qailtest.Column{{Name: "?column?", OID: qailtest.OIDInt4}}, Rows: [][]any{{1}}})
+			d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+			pc, err := d.Acquire(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, sql := range tt.script {
+				pc.ExecuteSimple(sql)
+			}
+			if s := pc.Conn().TxStatus(); s != tt.status {
+				t.Fatalf("status before Release = %v, want %v", s, tt.status)
+			}
+			// Released without COMMIT or ROLLBACK: the transaction leaks.
+			pc.Release()
+
+			pc, err = d.Acquire(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer pc.Release()
+			if s := pc.Conn().TxStatus(); s != TxIdle {
+				t.Errorf("pooled connection status = %v, want idle", s)
+			}
+			if _, err := pc.SimpleExec("SELECT 1"); err != nil {
+				t.Fatalf("next query: %v", err)
+			}
+			queries := srv.Queries()
+			if n := len(queries); n < 2 || queries[n-2].SQL != "ROLLBACK" {
+				t.Errorf("queries = %v, want ROLLBACK before the next query", queries)
+			}
+		})
+	}
+}
+
+func TestTxStatusDuringStatement(t *testing.T) {
+	running, release := make(chan struct{}), make(chan struct{})
+	srv := qailtest.NewServer()
+	srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+	srv.Handle("COMMIT", qailtest.Response{Tag: "COMMIT"})
+	srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != "UPDATE slow" {
+			return qailtest.Response{}, false
+		}
+		close(running)
+		<-release
+		return qailtest.Response{Tag: "UPDATE 1"}, true
+	})
+	d := fakeDriver(t, srv, Config{})
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done, stmtDone := make(chan error, 1), make(chan struct{})
+	go func() {
+		done <- tx.ExecuteSimple("UPDATE slow")
+		close(stmtDone)
+	}()
+	var unblock sync.Once
+	finish := func() { unblock.Do(func() { close(release) }) }
+	defer func() {
+		// Even if the test fails, end the statement and the Tx so the
+		// driver can close.
+		finish()
+		<-stmtDone
+		tx.Rollback()
+	}()
+	<-running
+
+	// A monitor may ask while the statement runs.
+	if s := tx.Status(); s != TxActive {
+		t.Errorf("status during statement = %v, want %v", s, TxActive)
+	}
+	finish()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if s := tx.Status(); s != TxIdle {
+		t.Errorf("status after Commit = %v, want %v", s, TxIdle)
+	}
+}
+
+func TestCommitFailedTransaction(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+	srv.Handle("COMMIT", qailtest.Response{Tag: "COMMIT"})
+	srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+	srv.Handle("UPDATE bad", qailtest.Response{Err: &qailtest.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	t.Run("Commit", func(t *testing.T) {
+		tx, err := d.Begin()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := tx.ExecuteSimple("UPDATE bad"); err == nil {
+			t.Fatal("UPDATE bad succeeded")
+		}
+		if err := tx.Commit(); !errors.Is(err, ErrTxRolledBack) {
+			t.Fatalf("Commit = %v, want ErrTxRolledBack", err)
+		}
+		if err := tx.Rollback(); !errors.Is(err, ErrTxDone) {
+			t.Errorf("Rollback after Commit = %v, want ErrTxDone", err)
+		}
+	})
+
+	t.Run("RunInTx", func(t *testing.T) {
+		err := d.RunInTx(context.Background(), TxOptions{}, func(tx *Tx) error {
+			tx.ExecuteSimple("UPDATE bad") // error ignored by the caller
+			return nil
+		})
+		if !errors.Is(err, ErrTxRolledBack) {
+			t.Fatalf("RunInTx = %v, want ErrTxRolledBack", err)
+		}
+	})
+
+	pc, err := d.Acquire(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Release()
+	if s := pc.Conn().TxStatus(); s != TxIdle {
+		t.Errorf("pooled connection status = %v, want idle", s)
+	}
+}
+
+func TestRollbackToSavepointAfterError(t *testing.T) {
+	srv := qailtest.NewServer()
+	for _, sql := range []string{"BEGIN", "COMMIT", "SAVEPOINT before_dup", "ROLLBACK TO SAVEPOINT before_dup", "SAVEPOINT after", "RELEASE SAVEPOINT after"} {
+		srv.Handle(sql, qailtest.Response{Tag: strings.SplitN(sql, " ", 2)[0]})
+	}
+	srv.Handle("INSERT 1", qailtest.Response{Tag: "INSERT 0 1"})
+	srv.Handle("INSERT 2", qailtest.Response{Tag: "INSERT 0 1"})
+	srv.Handle("INSERT dup", qailtest.Response{Err: &qailtest.Error{Code: "23505", Message: "duplicate key value violates unique constraint"}})
+	d := fakeDriver(t, srv, Config{MaxOpenConns: 1, ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.ExecuteSimple("INSERT 1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Savepoint("before_dup"); err != nil {
+		t.Fatal(err)
+	}
+	var pgErr *PgError
+	if err := tx.ExecuteSimple("INSERT dup"); !errors.As(err, &pgErr) || pgErr.Code != "23505" {
+		t.Fatalf("INSERT dup = %v, want a unique violation", err)
+	}
+	if s := tx.Status(); s != TxFailed {
+		t.Fatalf("status after the violation = %v, want failed", s)
+	}
+	if err := tx.RollbackTo("before_dup"); err != nil {
+		t.Fatal(err)
+	}
+	if s := tx.Status(); s != TxActive {
+		t.Fatalf("status after RollbackTo = %v, want active", s)
+	}
+	// The transaction is usable again, savepoints included.
+	if err := tx.Savepoint("after"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.ExecuteSimple("INSERT 2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.ReleaseSavepoint("after"); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit = %v, want the work before the savepoint committed", err)
+	}
+
+	var got []string
+	for _, q := range srv.Queries() {
+		got = append(got, q.SQL)
+	}
+	want := []string{"BEGIN", "INSERT 1", "SAVEPOINT before_dup", "INSERT dup", "ROLLBACK TO SAVEPOINT before_dup",
+		"SAVEPOINT after", "INSERT 2", "RELEASE SAVEPOINT after", "COMMIT"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("server received %q, want %q", got, want)
+	}
+}
+
+func TestTxConcurrentUsePanics(t *testing.T) {
+	running, release := make(chan struct{}), make(chan struct{})
+	srv := qailtest.NewServer()
+	srv.Handle("BEGIN", qailtest.Response{Tag: "BEGIN"})
+	srv.Handle("ROLLBACK", qailtest.Response{Tag: "ROLLBACK"})
+	srv.HandleFunc(func(q qailtest.Query) (qailtest.Response, bool) {
+		if q.SQL != "UPDATE slow" {
+			return qailtest.Response{}, false
+		}
+		close(running)
+		<-release
+		return qailtest.Response{Tag: "UPDATE 1"}, true
+	})
+	d := fakeDriver(t, srv, Config{})
+
+	tx, err := d.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- tx.ExecuteSimple("UPDATE slow") }()
+	<-running
+
+	func() {
+		defer func() {
+			if r := recover(); r != "qail: concurrent use of Tx" {
+				t.Errorf("overlapping call: recovered %v, want the concurrent use panic", r)
+			}
+		}()
+		tx.ExecuteSimple("UPDATE other")
+	}()
+
+	close(release)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+}
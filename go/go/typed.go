@@ -0,0 +1,99 @@
+package qail
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// FetchAllTyped executes a query and returns each row as a map from
+// column name to a Go value chosen by the column's type; see Row.Value.
+// It suits scripts and admin tools that don't want to write getters.
+func (d *Driver) FetchAllTyped(cmd *Qail) ([]map[string]any, error) {
+	rows, err := d.FetchAll(cmd)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]map[string]any, len(rows))
+	for i, r := range rows {
+		if out[i], err = r.ToMap(); err != nil {
+			return nil, fmt.Errorf("row %d: %w", i, err)
+		}
+	}
+	return out, nil
+}
+
+// ToMap returns the row as a map from column name to Value. When two
+// columns share a name, the later one wins.
+func (r Row) ToMap() (map[string]any, error) {
+	if len(r.fields) != len(r.columns) {
+		return nil, errors.New("row has no column metadata")
+	}
+	m := make(map[string]any, len(r.columns))
+	for i, f := range r.fields {
+		v, err := r.Value(i)
+		if err != nil {
+			return nil, fmt.Errorf("column %q: %w", f.Name, err)
+		}
+		m[f.Name] = v
+	}
+	return m, nil
+}
+
+// Value returns column idx as the natural Go type for its type OID:
+//
+//	int2, int4, int8      int16, int32, int64
+//	oid                   uint32
+//	float4, float8        float32, float64
+//	bool                  bool
+//	numeric               *big.Rat (nil for NaN and infinities)
+//	date, timestamp(tz)   time.Time
+//	uuid                  [16]byte
+//	bytea                 []byte
+//	json, jsonb           json.RawMessage
+//	anything else         string
+//
+// NULL is nil. Values are copied, so they outlive the Result.
+func (r Row) Value(idx int) (any, error) {
+	b := r.Get(idx)
+	if b == nil {
+		return nil, nil
+	}
+	f, _ := r.field(idx)
+	switch f.TypeOID {
+	case OIDInt2:
+		return int16(r.GetInt(idx)), nil
+	case OIDInt4:
+		return int32(r.GetInt(idx)), nil
+	case OIDInt8:
+		return r.GetInt(idx), nil
+	case OIDOid:
+		return uint32(r.GetInt(idx)), nil
+	case OIDFloat4:
+		return float32(r.GetFloat64(idx)), nil
+	case OIDFloat8:
+		return r.GetFloat64(idx), nil
+	case OIDBool:
+		return r.GetBool(idx), nil
+	case OIDNumeric:
+		return r.GetDecimal(idx), nil
+	case OIDDate, OIDTimestamp, OIDTimestampTz:
+		return r.ParseTime(idx)
+	case OIDUUID:
+		return r.ParseUUID(idx)
+	case OIDBytea:
+		if !r.isBinary(idx) && bytes.HasPrefix(b, []byte(`\x`)) {
+			out := make([]byte, hex.DecodedLen(len(b)-2))
+			if _, err := hex.Decode(out, b[2:]); err != nil {
+				return nil, fmt.Errorf("invalid bytea: %w", err)
+			}
+			return out, nil
+		}
+		return append([]byte(nil), b...), nil
+	case OIDJSON, OIDJSONB:
+		return append(json.RawMessage(nil), r.GetRawJSON(idx)...), nil
+	}
+	return string(b), nil
+}
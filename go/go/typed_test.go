@@ -0,0 +1,83 @@
+package qail
+
+import (
+	"encoding/json"
+	"errors"
+	"math/big"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func TestFetchAllTyped(t *testing.T) {
+	when := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	cols := []struct {
+		oid  uint32
+		text any
+		want any
+	}{
+		{OIDInt2, "-7", int16(-7)},
+		{OIDInt4, "42", int32(42)},
+		{OIDInt8, "9000000000", int64(9000000000)},
+		{OIDOid, "16384", uint32(16384)},
+		{OIDFloat4, "1.5", float32(1.5)},
+		{OIDFloat8, "-2.25", -2.25},
+		{OIDBool, "t", true},
+		{OIDNumeric, "12.50", big.NewRat(25, 2)},
+		{OIDDate, "2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{OIDTimestamp, "2024-03-01 12:30:00", when},
+		{OIDTimestampTz, "2024-03-01 13:30:00+01", when},
+		{OIDUUID, "a0eebc99-9c0b-4ef8-bb6d-6bb9bd380a11", [16]byte{0xa0, 0xee, 0xbc, 0x99, 0x9c, 0x0b, 0x4e, 0xf8, 0xbb, 0x6d, 0x6b, 0xb9, 0xbd, 0x38, 0x0a, 0x11}},
+		{OIDBytea, `\xdeadbeef`, []byte{0xde, 0xad, 0xbe, 0xef}},
+		{OIDJSONB, `{"a": 1}`, json.RawMessage(`{"a": 1}`)},
+		{OIDText, "ada", "ada"},
+		{OIDVarchar, nil, nil},
+	}
+	resp := qailtest.Response{Rows: [][]any{{}}}
+	for i, c := range cols {
+		resp.Columns = append(resp.Columns, qailtest.Column{Name: "c" + strconv.Itoa(i), OID: c.oid})
+		resp.Rows[0] = append(resp.Rows[0], c.text)
+	}
+	srv := qailtest.NewServer()
+	srv.HandleCmd(Get("everything"), resp)
+	srv.HandleCmd(Get("bad"), qailtest.Response{Columns: []qailtest.Column{{Name: "id", OID: OIDUUID}}, Rows: [][]any{{"not-a-uuid"}}})
+	d := fakeDriver(t, srv, Config{ReadTimeout: time.Second, WriteTimeout: time.Second})
+
+	rows, err := d.FetchAllTyped(Get("everything"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("got %d rows, want 1", len(rows))
+	}
+	for i, c := range cols {
+		name := resp.Columns[i].Name
+		got, ok := rows[0][name]
+		if !ok {
+			t.Errorf("OID %d: column %s missing", c.oid, name)
+			continue
+		}
+		switch want := c.want.(type) {
+		case *big.Rat:
+			if r, ok := got.(*big.Rat); !ok || r.Cmp(want) != 0 {
+				t.Errorf("OID %d: got %#v, want %v", c.oid, got, want)
+			}
+		case time.Time:
+			if tm, ok := got.(time.Time); !ok || !tm.Equal(want) {
+				t.Errorf("OID %d: got %#v, want %v", c.oid, got, want)
+			}
+		default:
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("OID %d: got %#v (%T), want %#v (%T)", c.oid, got, got, want, want)
+			}
+		}
+	}
+
+	var pgErr *PgError
+	if _, err := d.FetchAllTyped(Get("bad")); err == nil || errors.As(err, &pgErr) {
+		t.Errorf("malformed uuid: err = %v, want a decode error", err)
+	}
+}
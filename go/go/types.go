@@ -0,0 +1,314 @@
+package qail
+
+import (
+	"encoding/binary"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Result format codes (as carried in RowDescription and Bind).
+const (
+	FormatText   int16 = 0
+	FormatBinary int16 = 1
+)
+
+// Common PostgreSQL type OIDs.
+const (
+	OIDBool        uint32 = 16
+	OIDBytea       uint32 = 17
+	OIDInt8        uint32 = 20
+	OIDInt2        uint32 = 21
+	OIDInt4        uint32 = 23
+	OIDText        uint32 = 25
+	OIDOid         uint32 = 26
+	OIDJSON        uint32 = 114
+	OIDFloat4      uint32 = 700
+	OIDFloat8      uint32 = 701
+	OIDVarchar     uint32 = 1043
+	OIDDate        uint32 = 1082
+	OIDTimestamp   uint32 = 1114
+	OIDTimestampTz uint32 = 1184
+	OIDUUID        uint32 = 2950
+	OIDNumeric     uint32 = 1700
+	OIDJSONB       uint32 = 3802
+)
+
+// PostgreSQL binary timestamps count microseconds from 2000-01-01 UTC.
+var pgEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// pgEpochMicros is pgEpoch in Unix microseconds. Converting through Unix
+// time covers PostgreSQL's whole timestamp range, where a time.Duration
+// from pgEpoch overflows past about 292 years.
+const pgEpochMicros = 946684800 * 1_000_000
+
+// decodeBinaryInt decodes a binary int2/int4/int8/oid value.
+func decodeBinaryInt(b []byte) (int64, bool) {
+	switch len(b) {
+	case 2:
+		return int64(int16(binary.BigEndian.Uint16(b))), true
+	case 4:
+		return int64(int32(binary.BigEndian.Uint32(b))), true
+	case 8:
+		return int64(binary.BigEndian.Uint64(b)), true
+	}
+	return 0, false
+}
+
+// decodeBinaryFloat decodes a binary float4/float8 value.
+func decodeBinaryFloat(b []byte) (float64, bool) {
+	switch len(b) {
+	case 4:
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), true
+	case 8:
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), true
+	}
+	return 0, false
+}
+
+// decodeBinaryTime decodes a binary timestamp/timestamptz/date value.
+func decodeBinaryTime(oid uint32, b []byte) (time.Time, bool) {
+	switch {
+	case oid == OIDDate && len(b) == 4:
+		days := int32(binary.BigEndian.Uint32(b))
+		return pgEpoch.AddDate(0, 0, int(days)), true
+	case len(b) == 8:
+		micros := int64(binary.BigEndian.Uint64(b))
+		if micros == math.MaxInt64 || micros == math.MinInt64 {
+			return time.Time{}, false // infinity, -infinity
+		}
+		return time.UnixMicro(micros + pgEpochMicros).UTC(), true
+	}
+	return time.Time{}, false
+}
+
+// decodeBinaryNumeric decodes a binary numeric value to the text the
+// server would send for it, e.g. "-12.50", "NaN" or "Infinity". The
+// layout is ndigits, weight, sign and dscale (int16 each) followed by
+// ndigits base-10000 digits, the first worth 10000^weight.
+func decodeBinaryNumeric(b []byte) (string, bool) {
+	if len(b) < 8 {
+		return "", false
+	}
+	ndigits := int(binary.BigEndian.Uint16(b[0:2]))
+	weight := int(int16(binary.BigEndian.Uint16(b[2:4])))
+	sign := binary.BigEndian.Uint16(b[4:6])
+	dscale := int(binary.BigEndian.Uint16(b[6:8]))
+	if len(b) != 8+2*ndigits {
+		return "", false
+	}
+	switch sign {
+	case 0xC000:
+		return "NaN", true
+	case 0xD000:
+		return "Infinity", true
+	case 0xF000:
+		return "-Infinity", true
+	}
+	digit := func(i int) int {
+		if i < 0 || i >= ndigits {
+			return 0
+		}
+		return int(binary.BigEndian.Uint16(b[8+2*i:]))
+	}
+
+	out := make([]byte, 0, 4*max(weight+2, 1)+dscale+2)
+	if sign == 0x4000 {
+		out = append(out, '-')
+	}
+	if weight < 0 {
+		out = append(out, '0')
+	}
+	for i := 0; i <= weight; i++ {
+		if i == 0 {
+			out = strconv.AppendInt(out, int64(digit(i)), 10)
+		} else {
+			out = appendDigitGroup(out, digit(i))
+		}
+	}
+	if dscale > 0 {
+		out = append(out, '.')
+		start := len(out)
+		for i := weight + 1; len(out)-start < dscale; i++ {
+			out = appendDigitGroup(out, digit(i))
+		}
+		out = out[:start+dscale]
+	}
+	return string(out), true
+}
+
+// appendDigitGroup appends a base-10000 digit as four decimal digits.
+func appendDigitGroup(out []byte, d int) []byte {
+	return append(out, byte('0'+d/1000), byte('0'+d/100%10), byte('0'+d/10%10), byte('0'+d%10))
+}
+
+// parseTextInt parses a text-format integer (with optional sign).
+func parseTextInt(b []byte) int64 {
+	var n int64
+	neg := false
+	for i, c := range b {
+		if i == 0 && c == '-' {
+			neg = true
+			continue
+		}
+		if c >= '0' && c <= '9' {
+			n = n*10 + int64(c-'0')
+		}
+	}
+	if neg {
+		return -n
+	}
+	return n
+}
+
+// parseTextFloat parses a text-format float, including NaN and Infinity.
+func parseTextFloat(b []byte) float64 {
+	f, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+// Text timestamp layouts emitted by PostgreSQL with DateStyle=ISO.
+var timeLayouts = []string{
+	"2006-01-02 15:04:05.999999999Z07:00:00",
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999Z07",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02",
+}
+
+// parseTextTime parses a text-format date/timestamp/timestamptz in the
+// ISO DateStyle: fractional seconds, offsets with or without minutes and
+// seconds, and a trailing " BC" for years before 1 AD. "infinity" and
+// "-infinity" have no time.Time equivalent and are rejected.
+func parseTextTime(b []byte) (time.Time, bool) {
+	s := string(b)
+	bc := false
+	if strings.HasSuffix(s, " BC") {
+		s, bc = s[:len(s)-3], true
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			if bc {
+				// 1 BC is year 0 in Go's proleptic calendar.
+				t = t.AddDate(1-2*t.Year(), 0, 0)
+			}
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parseUUID parses the 36-character hyphenated text form of a UUID.
+func parseUUID(b []byte) ([16]byte, bool) {
+	var u [16]byte
+	if len(b) != 36 || b[8] != '-' || b[13] != '-' || b[18] != '-' || b[23] != '-' {
+		return u, false
+	}
+	j := 0
+	for i := 0; i < 36; i++ {
+		if i == 8 || i == 13 || i == 18 || i == 23 {
+			continue
+		}
+		hi, ok1 := fromHex(b[i])
+		lo, ok2 := fromHex(b[i+1])
+		if !ok1 || !ok2 {
+			return [16]byte{}, false
+		}
+		u[j] = hi<<4 | lo
+		j++
+		i++
+	}
+	return u, true
+}
+
+func fromHex(c byte) (byte, bool) {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0', true
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10, true
+	case c >= 'A' && c <= 'F':
+		return c - 'A' + 10, true
+	}
+	return 0, false
+}
+
+// setResultFormat rewrites every Bind message in wire so that all result
+// columns are requested in the given format. Other messages are copied as-is.
+func setResultFormat(wire []byte, format int16) []byte {
+	out := make([]byte, 0, len(wire)+8)
+	for off := 0; off+5 <= len(wire); {
+		msgType := wire[off]
+		msgLen := int(binary.BigEndian.Uint32(wire[off+1 : off+5]))
+		end := off + 1 + msgLen
+		if msgLen < 4 || end > len(wire) {
+			// Not a well-formed stream; leave the remainder untouched.
+			return append(out, wire[off:]...)
+		}
+		if msgType != 'B' {
+			out = append(out, wire[off:end]...)
+			off = end
+			continue
+		}
+
+		body := wire[off+5 : end]
+		cut, ok := bindResultFormatOffset(body)
+		if !ok {
+			out = append(out, wire[off:end]...)
+			off = end
+			continue
+		}
+
+		newLen := 4 + cut + 2 + 2
+		out = append(out, 'B')
+		out = binary.BigEndian.AppendUint32(out, uint32(newLen))
+		out = append(out, body[:cut]...)
+		out = binary.BigEndian.AppendUint16(out, 1)
+		out = binary.BigEndian.AppendUint16(out, uint16(format))
+		off = end
+	}
+	return out
+}
+
+// bindResultFormatOffset returns the offset of the result-format count
+// within a Bind message body.
+func bindResultFormatOffset(body []byte) (int, bool) {
+	pos := 0
+	// Portal and statement names
+	for i := 0; i < 2; i++ {
+		for pos < len(body) && body[pos] != 0 {
+			pos++
+		}
+		pos++
+	}
+	if pos+2 > len(body) {
+		return 0, false
+	}
+	nFormats := int(binary.BigEndian.Uint16(body[pos:]))
+	pos += 2 + 2*nFormats
+	if pos+2 > len(body) {
+		return 0, false
+	}
+	nParams := int(binary.BigEndian.Uint16(body[pos:]))
+	pos += 2
+	for i := 0; i < nParams; i++ {
+		if pos+4 > len(body) {
+			return 0, false
+		}
+		n := int32(binary.BigEndian.Uint32(body[pos:]))
+		pos += 4
+		if n > 0 {
+			pos += int(n)
+		}
+	}
+	if pos+2 > len(body) {
+		return 0, false
+	}
+	return pos, true
+}
@@ -0,0 +1,115 @@
+package qail
+
+import (
+	"encoding/hex"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// wireHex decodes a binary column value written as hex, with spaces
+// between fields for readability.
+func wireHex(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(strings.ReplaceAll(s, " ", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestDecodeBinaryInt(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int64
+		ok   bool
+	}{
+		{"ffff", -1, true},
+		{"7fff", math.MaxInt16, true},
+		{"00000001", 1, true},
+		{"80000000", math.MinInt32, true},
+		{"7fffffffffffffff", math.MaxInt64, true},
+		{"fffffffffffffffe", -2, true},
+		{"", 0, false},
+		{"000001", 0, false},
+	} {
+		got, ok := decodeBinaryInt(wireHex(t, tt.in))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("decodeBinaryInt(%s) = %d, %v; want %d, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDecodeBinaryFloat(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want float64
+		ok   bool
+	}{
+		{"3fc00000", 1.5, true},
+		{"c2f6e979", float64(float32(-123.456)), true},
+		{"400921fb54442d18", math.Pi, true},
+		{"fff0000000000000", math.Inf(-1), true},
+		{"0000000000", 0, false},
+	} {
+		got, ok := decodeBinaryFloat(wireHex(t, tt.in))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("decodeBinaryFloat(%s) = %v, %v; want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+	if got, ok := decodeBinaryFloat(wireHex(t, "7ff8000000000000")); !ok || !math.IsNaN(got) {
+		t.Errorf("decodeBinaryFloat(NaN) = %v, %v", got, ok)
+	}
+}
+
+func TestDecodeBinaryTime(t *testing.T) {
+	for _, tt := range []struct {
+		oid  uint32
+		in   string
+		want time.Time
+		ok   bool
+	}{
+		{OIDDate, "00000000", pgEpoch, true},
+		{OIDDate, "0000223e", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{OIDDate, "fffffe5c", time.Date(1998, 11, 7, 0, 0, 0, 0, time.UTC), true},
+		{OIDTimestamp, "0002b0d5d4e94001", time.Date(2024, 1, 1, 0, 0, 0, 1000, time.UTC), true},
+		{OIDTimestampTz, "ffffffffffffffff", time.Date(1999, 12, 31, 23, 59, 59, 999999000, time.UTC), true},
+		{OIDTimestamp, "7fffffffffffffff", time.Time{}, false},   // infinity
+		{OIDTimestampTz, "8000000000000000", time.Time{}, false}, // -infinity
+		{OIDTimestamp, "00000000", time.Time{}, false},
+	} {
+		got, ok := decodeBinaryTime(tt.oid, wireHex(t, tt.in))
+		if !got.Equal(tt.want) || ok != tt.ok {
+			t.Errorf("decodeBinaryTime(%d, %s) = %v, %v; want %v, %v", tt.oid, tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestDecodeBinaryNumeric(t *testing.T) {
+	// ndigits, weight, sign, dscale, then the base-10000 digits.
+	for _, tt := range []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"0000 0000 0000 0000", "0", true},
+		{"0000 0000 0000 0002", "0.00", true},
+		{"0002 0000 4000 0002 000c 1388", "-12.50", true},
+		{"0003 0002 0000 0000 0001 0929 1a85", "123456789", true},
+		{"0001 0001 0000 0000 0001", "10000", true},
+		{"0001 ffff 0000 0004 0001", "0.0001", true},
+		{"0001 fffe 0000 0008 04d2", "0.00001234", true},
+		{"0002 0000 0000 0003 0003 058c", "3.142", true},
+		{"0000 0000 c000 0000", "NaN", true},
+		{"0000 0000 d000 0000", "Infinity", true},
+		{"0000 0000 f000 0000", "-Infinity", true},
+		{"0001 0000 0000 0000", "", false}, // a digit is missing
+		{"0000 0000 0000", "", false},
+	} {
+		got, ok := decodeBinaryNumeric(wireHex(t, tt.in))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("decodeBinaryNumeric(%s) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
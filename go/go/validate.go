@@ -0,0 +1,75 @@
+package qail
+
+import "fmt"
+
+// StatementError reports a batch command that the server rejected.
+type StatementError struct {
+	Index int   // position of the command in the batch
+	Err   error // wraps the server's *PgError
+}
+
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("statement %d: %v", e.Index, e.Err)
+}
+
+func (e *StatementError) Unwrap() error { return e.Err }
+
+// ValidateBatch checks every command against the server without running
+// any of them, and returns one StatementError per command that failed.
+// The error result is for I/O and encoding failures only.
+//
+// Each command is sent as Parse + Describe(Statement) + Sync on the
+// unnamed statement, all pipelined in one round trip:
+//
+//	P D S  P D S  ...
+//	1 t (T|n) Z   or   E Z   per command
+//
+// The Sync after each command keeps one failure from skipping the rest.
+// This catches what the server finds while parsing and analyzing a
+// statement: syntax errors, unknown tables or columns, type mismatches.
+// The statement is not planned or executed, so errors that only surface
+// at execution (constraint violations, bad casts of data) are not found.
+func (d *Driver) ValidateBatch(cmds []*Qail) (failed []StatementError, err error) {
+	if d.tracer != nil {
+		q := d.traceStart("ValidateBatch", batchSQL(cmds))
+		defer func() { d.traceEnd(q, len(cmds)-len(failed), err) }()
+	}
+
+	var buf []byte
+	for i, cmd := range cmds {
+		sql, _, err := cmd.sqlParams()
+		if err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+		buf = appendParse(buf, "", sql)
+		buf = appendDescribe(buf, 'S', "")
+		buf = append(buf, 'S', 0, 0, 0, 4)
+	}
+	if len(cmds) == 0 {
+		return nil, nil
+	}
+
+	c, err := d.getConn()
+	if err != nil {
+		return nil, err
+	}
+	defer d.putConn(c)
+
+	if _, err := c.conn.Write(buf); err != nil {
+		return nil, fmt.Errorf("write failed: %w", err)
+	}
+
+	for i := 0; i < len(cmds); {
+		msgType, data, err := c.readMessage()
+		if err != nil {
+			return failed, err
+		}
+		switch msgType {
+		case 'E':
+			failed = append(failed, StatementError{Index: i, Err: serverError("validate error", data)})
+		case 'Z':
+			i++
+		}
+	}
+	return failed, nil
+}
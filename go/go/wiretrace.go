@@ -0,0 +1,208 @@
+package qail
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+)
+
+// Wire trace frame directions.
+const (
+	TraceSent     byte = '>' // client to server
+	TraceReceived byte = '<' // server to client
+)
+
+// TraceFrame is one chunk of bytes captured by Config.WireTrace.
+//
+// On disk each frame is: direction (1 byte), connection id (uint32),
+// payload length (uint32), payload. Integers are big-endian. Frames are
+// written in the order the reads and writes completed.
+type TraceFrame struct {
+	Dir    byte   // TraceSent or TraceReceived
+	ConnID uint32 // numbered per Driver, starting at 1
+	Data   []byte
+}
+
+// traceWriter serializes frames from every pooled connection onto one
+// io.Writer.
+type traceWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	nextID uint32
+}
+
+func (t *traceWriter) newConnID() uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.nextID++
+	return t.nextID
+}
+
+func (t *traceWriter) frame(dir byte, id uint32, p []byte) {
+	var hdr [9]byte
+	hdr[0] = dir
+	binary.BigEndian.PutUint32(hdr[1:5], id)
+	binary.BigEndian.PutUint32(hdr[5:9], uint32(len(p)))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// Tracing is best-effort: a failing trace writer must not fail queries.
+	if _, err := t.w.Write(hdr[:]); err == nil {
+		t.w.Write(p)
+	}
+}
+
+// tracedConn tees all bytes read from and written to a net.Conn.
+type tracedConn struct {
+	net.Conn
+	trace *traceWriter
+	id    uint32
+}
+
+func (c *tracedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.trace.frame(TraceReceived, c.id, p[:n])
+	}
+	return n, err
+}
+
+func (c *tracedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.trace.frame(TraceSent, c.id, p[:n])
+	}
+	return n, err
+}
+
+// ReadTrace loads every frame of a trace written via Config.WireTrace.
+func ReadTrace(r io.Reader) ([]TraceFrame, error) {
+	br := bufio.NewReader(r)
+	var frames []TraceFrame
+	for {
+		var hdr [9]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if err == io.EOF {
+				return frames, nil
+			}
+			return frames, fmt.Errorf("trace frame %d: %w", len(frames), err)
+		}
+		if hdr[0] != TraceSent && hdr[0] != TraceReceived {
+			return frames, fmt.Errorf("trace frame %d: bad direction %q", len(frames), hdr[0])
+		}
+		data := make([]byte, binary.BigEndian.Uint32(hdr[5:9]))
+		if _, err := io.ReadFull(br, data); err != nil {
+			return frames, fmt.Errorf("trace frame %d: %w", len(frames), err)
+		}
+		frames = append(frames, TraceFrame{
+			Dir:    hdr[0],
+			ConnID: binary.BigEndian.Uint32(hdr[1:5]),
+			Data:   data,
+		})
+	}
+}
+
+// ServeTrace plays the server side of a recorded trace on ln, so a Driver
+// pointed at ln's address sees exactly the recorded responses. It accepts
+// one connection per recorded connection id, in id order, and checks that
+// the client sends the same bytes it sent when the trace was recorded.
+//
+// Traces are captured after any TLS upgrade; ServeTrace declines an
+// SSLRequest so that "prefer" falls back to a plain connection. Trust,
+// cleartext and MD5 authentication replay as recorded; a trace that
+// authenticated with SCRAM cannot be, and ServeTrace returns
+// ErrSCRAMTrace for it before accepting any connection.
+func ServeTrace(ln net.Listener, frames []TraceFrame) error {
+	var ids []uint32
+	byConn := make(map[uint32][]TraceFrame)
+	for _, f := range frames {
+		if _, ok := byConn[f.ConnID]; !ok {
+			ids = append(ids, f.ConnID)
+		}
+		byConn[f.ConnID] = append(byConn[f.ConnID], f)
+	}
+	for _, id := range ids {
+		if usesSASL(byConn[id]) {
+			return fmt.Errorf("trace connection %d: %w", id, ErrSCRAMTrace)
+		}
+	}
+
+	for _, id := range ids {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		err = replayConn(conn, byConn[id])
+		conn.Close()
+		if err != nil {
+			return fmt.Errorf("trace connection %d: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// usesSASL reports whether the server asked for SASL authentication on
+// a traced connection. It walks the received messages up to the first
+// ReadyForQuery, which ends the startup phase.
+func usesSASL(frames []TraceFrame) bool {
+	var recv []byte
+	for _, f := range frames {
+		if f.Dir == TraceReceived {
+			recv = append(recv, f.Data...)
+		}
+	}
+	for len(recv) >= 5 {
+		n := int(binary.BigEndian.Uint32(recv[1:5]))
+		if n < 4 || n > len(recv)-1 {
+			return false
+		}
+		switch recv[0] {
+		case 'R':
+			if n >= 8 && binary.BigEndian.Uint32(recv[5:9]) == 10 {
+				return true
+			}
+		case 'Z':
+			return false
+		}
+		recv = recv[1+n:]
+	}
+	return false
+}
+
+// sslRequestBytes is the SSLRequest message sent by upgradeToSSL.
+var sslRequestBytes = []byte{0, 0, 0, 8, 4, 210, 22, 47}
+
+func replayConn(conn net.Conn, frames []TraceFrame) error {
+	r := bufio.NewReader(conn)
+	if head, err := r.Peek(len(sslRequestBytes)); err == nil && bytes.Equal(head, sslRequestBytes) {
+		r.Discard(len(sslRequestBytes))
+		if _, err := conn.Write([]byte{'N'}); err != nil {
+			return err
+		}
+	}
+
+	for i, f := range frames {
+		switch f.Dir {
+		case TraceSent:
+			got := make([]byte, len(f.Data))
+			if _, err := io.ReadFull(r, got); err != nil {
+				return fmt.Errorf("frame %d: %w", i, err)
+			}
+			if !bytes.Equal(got, f.Data) {
+				return fmt.Errorf("frame %d: client sent %q, trace has %q", i, got, f.Data)
+			}
+		case TraceReceived:
+			if _, err := conn.Write(f.Data); err != nil {
+				return fmt.Errorf("frame %d: %w", i, err)
+			}
+		default:
+			return errors.New("bad trace frame direction")
+		}
+	}
+	return nil
+}
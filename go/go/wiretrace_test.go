@@ -0,0 +1,94 @@
+package qail
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+
+	"github.com/qail-lang/qail-go/qailtest"
+)
+
+func fetchInts(t *testing.T, d *Driver) []int64 {
+	t.Helper()
+	rows, err := d.FetchAll(Get("numbers"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []int64
+	for _, r := range rows {
+		got = append(got, r.GetInt(0))
+	}
+	return got
+}
+
+func TestWireTraceReplay(t *testing.T) {
+	srv := qailtest.NewServer()
+	srv.HandleFunc(intRows(4, 5, 6))
+	var trace bytes.Buffer
+	d := fakeDriver(t, srv, Config{WireTrace: &trace})
+	want := []int64{4, 5, 6}
+	if got := fetchInts(t, d); !reflect.DeepEqual(got, want) {
+		t.Fatalf("rows = %v, want %v", got, want)
+	}
+	d.Close()
+
+	frames, err := ReadTrace(&trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := map[byte]bool{}
+	for _, f := range frames {
+		if f.ConnID != 1 {
+			t.Fatalf("frame on connection %d, want every frame on 1", f.ConnID)
+		}
+		dirs[f.Dir] = true
+	}
+	if !dirs[TraceSent] || !dirs[TraceReceived] {
+		t.Fatalf("trace directions = %v, want both", dirs)
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	served := make(chan error, 1)
+	go func() { served <- ServeTrace(ln, frames) }()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	replay, err := NewDriver(Config{Host: host, Port: port, User: "test", Database: "test", SSLMode: "disable"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := fetchInts(t, replay); !reflect.DeepEqual(got, want) {
+		t.Errorf("replayed rows = %v, want %v", got, want)
+	}
+	replay.Close()
+	if err := <-served; err != nil {
+		t.Fatalf("ServeTrace: %v", err)
+	}
+}
+
+func TestServeTraceRejectsSCRAM(t *testing.T) {
+	// AuthenticationSASL offering SCRAM-SHA-256, split across two reads.
+	sasl := binary.BigEndian.AppendUint32([]byte{'R'}, uint32(4+4+len("SCRAM-SHA-256")+2))
+	sasl = binary.BigEndian.AppendUint32(sasl, 10)
+	sasl = append(sasl, "SCRAM-SHA-256\x00\x00"...)
+	frames := []TraceFrame{
+		{Dir: TraceSent, ConnID: 1, Data: []byte("startup")},
+		{Dir: TraceReceived, ConnID: 1, Data: sasl[:3]},
+		{Dir: TraceReceived, ConnID: 1, Data: sasl[3:]},
+	}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	if err := ServeTrace(ln, frames); !errors.Is(err, ErrSCRAMTrace) {
+		t.Fatalf("ServeTrace = %v, want ErrSCRAMTrace", err)
+	}
+}
@@ -8,11 +8,70 @@
 
 use qail_core::prelude::*;
 use qail_pg::protocol::AstEncoder;
-use std::ffi::{CStr, c_char, c_int};
+use std::cell::RefCell;
+use std::ffi::{CStr, CString, c_char, c_int};
+
+thread_local! {
+    static LAST_ERROR: RefCell<Option<String>> = const { RefCell::new(None) };
+}
+
+fn set_error(msg: String) {
+    LAST_ERROR.with(|e| {
+        *e.borrow_mut() = Some(msg);
+    });
+}
+
+fn clear_error() {
+    LAST_ERROR.with(|e| {
+        *e.borrow_mut() = None;
+    });
+}
+
+/// Get the last error message set on this thread by a Rust I/O call.
+/// Returns NULL if no error.
+/// The returned string is valid until the next QAIL function call.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_last_error() -> *const c_char {
+    thread_local! {
+        static ERROR_CSTRING: RefCell<Option<CString>> = const { RefCell::new(None) };
+    }
+
+    LAST_ERROR.with(|e| {
+        let error = e.borrow();
+        match &*error {
+            Some(msg) => ERROR_CSTRING.with(|ec| {
+                let c_str = CString::new(msg.clone()).unwrap_or_default();
+                let ptr = c_str.as_ptr();
+                *ec.borrow_mut() = Some(c_str);
+                ptr
+            }),
+            None => std::ptr::null(),
+        }
+    })
+}
 
 /// Opaque handle to Qail
+#[derive(Clone)]
 pub struct QailHandle {
     cmd: Qail,
+    /// Filter groups opened by qail_begin_group, innermost last
+    groups: Vec<FilterGroup>,
+}
+
+impl QailHandle {
+    fn new(cmd: Qail) -> Self {
+        QailHandle {
+            cmd,
+            groups: Vec::new(),
+        }
+    }
+}
+
+/// An open filter group: its connector and the terms added so far.
+#[derive(Clone)]
+struct FilterGroup {
+    connector: BinaryOp,
+    terms: Vec<Expr>,
 }
 
 /// Create a GET command
@@ -21,7 +80,7 @@ pub struct QailHandle {
 pub extern "C" fn qail_get(table: *const c_char) -> *mut QailHandle {
     let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
     let cmd = Qail::get(table);
-    Box::into_raw(Box::new(QailHandle { cmd }))
+    Box::into_raw(Box::new(QailHandle::new(cmd)))
 }
 
 /// Create an ADD (INSERT) command
@@ -29,7 +88,7 @@ pub extern "C" fn qail_get(table: *const c_char) -> *mut QailHandle {
 pub extern "C" fn qail_add(table: *const c_char) -> *mut QailHandle {
     let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
     let cmd = Qail::add(table);
-    Box::into_raw(Box::new(QailHandle { cmd }))
+    Box::into_raw(Box::new(QailHandle::new(cmd)))
 }
 
 /// Create a SET (UPDATE) command
@@ -37,7 +96,7 @@ pub extern "C" fn qail_add(table: *const c_char) -> *mut QailHandle {
 pub extern "C" fn qail_set(table: *const c_char) -> *mut QailHandle {
     let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
     let cmd = Qail::set(table);
-    Box::into_raw(Box::new(QailHandle { cmd }))
+    Box::into_raw(Box::new(QailHandle::new(cmd)))
 }
 
 /// Create a DEL (DELETE) command
@@ -45,7 +104,7 @@ pub extern "C" fn qail_set(table: *const c_char) -> *mut QailHandle {
 pub extern "C" fn qail_del(table: *const c_char) -> *mut QailHandle {
     let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
     let cmd = Qail::del(table);
-    Box::into_raw(Box::new(QailHandle { cmd }))
+    Box::into_raw(Box::new(QailHandle::new(cmd)))
 }
 
 /// Add column to command
@@ -60,6 +119,76 @@ pub extern "C" fn qail_column(handle: *mut QailHandle, col: *const c_char) {
     }
 }
 
+// Aggregate function constants (must match Go side)
+const AGG_COUNT: c_int = 0;
+const AGG_SUM: c_int = 1;
+const AGG_AVG: c_int = 2;
+const AGG_MIN: c_int = 3;
+const AGG_MAX: c_int = 4;
+
+/// Add an aggregate column: FUNC(col) [AS alias]. col "*" is allowed for
+/// COUNT(*); alias may be NULL or empty for no alias.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_aggregate(
+    handle: *mut QailHandle,
+    func: c_int,
+    col: *const c_char,
+    alias: *const c_char,
+) {
+    if handle.is_null() {
+        return;
+    }
+    let func = match func {
+        AGG_COUNT => AggregateFunc::Count,
+        AGG_SUM => AggregateFunc::Sum,
+        AGG_AVG => AggregateFunc::Avg,
+        AGG_MIN => AggregateFunc::Min,
+        AGG_MAX => AggregateFunc::Max,
+        _ => return,
+    };
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("*") };
+    let alias = if alias.is_null() {
+        None
+    } else {
+        let a = unsafe { CStr::from_ptr(alias).to_str().unwrap_or("") };
+        (!a.is_empty()).then(|| a.to_string())
+    };
+    unsafe {
+        (*handle).cmd.columns.push(Expr::Aggregate {
+            col: col.to_string(),
+            func,
+            distinct: false,
+            filter: None,
+            alias,
+        });
+    }
+}
+
+/// Add a GROUP BY column. Repeated calls extend the same GROUP BY list.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_group_by(handle: *mut QailHandle, col: *const c_char) {
+    if handle.is_null() {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    let h = unsafe { &mut *handle };
+    if let Some(cage) = h
+        .cmd
+        .cages
+        .iter_mut()
+        .find(|c| c.kind == CageKind::Partition)
+    {
+        cage.conditions.push(Condition {
+            left: Expr::Named(col.to_string()),
+            op: Operator::Eq,
+            value: Value::Null,
+            is_array_unnest: false,
+        });
+    } else {
+        h.cmd = h.cmd.clone().group_by([col]);
+    }
+}
+
 /// Add filter condition with int value
 #[unsafe(no_mangle)]
 pub extern "C" fn qail_filter_int(
@@ -73,9 +202,7 @@ pub extern "C" fn qail_filter_int(
     }
     let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
     let operator = int_to_operator(op);
-    unsafe {
-        (*handle).cmd = (*handle).cmd.clone().filter(col, operator, value);
-    }
+    add_filter(handle, col, operator, Value::Int(value));
 }
 
 /// Add filter with string value
@@ -92,9 +219,7 @@ pub extern "C" fn qail_filter_str(
     let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
     let value = unsafe { CStr::from_ptr(value).to_str().unwrap_or("") };
     let operator = int_to_operator(op);
-    unsafe {
-        (*handle).cmd = (*handle).cmd.clone().filter(col, operator, value);
-    }
+    add_filter(handle, col, operator, Value::String(value.to_string()));
 }
 
 /// Add filter with bool value
@@ -111,8 +236,216 @@ pub extern "C" fn qail_filter_bool(
     let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
     let operator = int_to_operator(op);
     let bool_val = value != 0;
+    add_filter(handle, col, operator, Value::Bool(bool_val));
+}
+
+/// Add filter condition bound to positional argument `n` (1-based) of
+/// qail_encode_sql_params's caller, instead of a literal value
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_param(
+    handle: *mut QailHandle,
+    col: *const c_char,
+    op: c_int,
+    n: c_int,
+) {
+    if handle.is_null() || n < 1 {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    let operator = int_to_operator(op);
+    add_filter(handle, col, operator, Value::Param(n as usize));
+}
+
+/// Add `col IS NULL` (not_null == 0) or `col IS NOT NULL` filter
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_null(handle: *mut QailHandle, col: *const c_char, not_null: c_int) {
+    if handle.is_null() {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    let operator = if not_null != 0 {
+        Operator::IsNotNull
+    } else {
+        Operator::IsNull
+    };
+    add_filter(handle, col, operator, Value::Null);
+}
+
+/// Add a filter condition to the innermost open group, or to the command's
+/// WHERE clause (ANDed) when no group is open.
+fn add_filter(handle: *mut QailHandle, col: &str, op: Operator, value: Value) {
+    let h = unsafe { &mut *handle };
+    if let Some(group) = h.groups.last_mut() {
+        let leaf = Expr::Binary {
+            left: Box::new(Expr::Named(col.to_string())),
+            op: operator_to_binary(op),
+            right: Box::new(Expr::Literal(value)),
+            alias: None,
+        };
+        group.terms.push(leaf);
+    } else {
+        h.cmd = h.cmd.clone().filter(col, op, value);
+    }
+}
+
+/// Map a comparison operator to its expression-tree form.
+fn operator_to_binary(op: Operator) -> BinaryOp {
+    match op {
+        Operator::Ne => BinaryOp::Ne,
+        Operator::Gt => BinaryOp::Gt,
+        Operator::Gte => BinaryOp::Gte,
+        Operator::Lt => BinaryOp::Lt,
+        Operator::Lte => BinaryOp::Lte,
+        Operator::IsNull => BinaryOp::IsNull,
+        Operator::IsNotNull => BinaryOp::IsNotNull,
+        _ => BinaryOp::Eq,
+    }
+}
+
+/// Open a filter group. Conditions added until the matching qail_end_group
+/// are joined with OR (any_of != 0) or AND (any_of == 0). Groups nest.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_begin_group(handle: *mut QailHandle, any_of: c_int) {
+    if handle.is_null() {
+        return;
+    }
+    let connector = if any_of != 0 { BinaryOp::Or } else { BinaryOp::And };
     unsafe {
-        (*handle).cmd = (*handle).cmd.clone().filter(col, operator, bool_val);
+        (*handle).groups.push(FilterGroup {
+            connector,
+            terms: Vec::new(),
+        });
+    }
+}
+
+/// Close the innermost filter group. The parenthesized group becomes one
+/// term of the enclosing group, or is ANDed into the WHERE clause.
+/// Empty groups are dropped.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_end_group(handle: *mut QailHandle) {
+    if handle.is_null() {
+        return;
+    }
+    let h = unsafe { &mut *handle };
+    let Some(group) = h.groups.pop() else {
+        return;
+    };
+    let mut terms = group.terms.into_iter();
+    let Some(first) = terms.next() else {
+        return;
+    };
+    let tree = terms.fold(first, |acc, term| Expr::Binary {
+        left: Box::new(acc),
+        op: group.connector,
+        right: Box::new(term),
+        alias: None,
+    });
+
+    if let Some(parent) = h.groups.last_mut() {
+        parent.terms.push(tree);
+    } else {
+        // Encoded by the pg AST encoder as the bare (parameterized) tree
+        h.cmd = h.cmd.clone().filter_cond(Condition {
+            left: tree,
+            op: Operator::Eq,
+            value: Value::Bool(true),
+            is_array_unnest: false,
+        });
+    }
+}
+
+/// Add BETWEEN filter with int bounds (col BETWEEN low AND high)
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_between_int(
+    handle: *mut QailHandle,
+    col: *const c_char,
+    low: i64,
+    high: i64,
+) {
+    filter_between(handle, col, Value::Int(low), Value::Int(high));
+}
+
+/// Add BETWEEN filter with float bounds
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_between_float(
+    handle: *mut QailHandle,
+    col: *const c_char,
+    low: f64,
+    high: f64,
+) {
+    filter_between(handle, col, Value::Float(low), Value::Float(high));
+}
+
+/// Add BETWEEN filter with string bounds (text, dates, timestamps)
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_between_str(
+    handle: *mut QailHandle,
+    col: *const c_char,
+    low: *const c_char,
+    high: *const c_char,
+) {
+    if low.is_null() || high.is_null() {
+        return;
+    }
+    let low = unsafe { CStr::from_ptr(low).to_str().unwrap_or("") };
+    let high = unsafe { CStr::from_ptr(high).to_str().unwrap_or("") };
+    filter_between(
+        handle,
+        col,
+        Value::String(low.to_string()),
+        Value::String(high.to_string()),
+    );
+}
+
+fn filter_between(handle: *mut QailHandle, col: *const c_char, low: Value, high: Value) {
+    if handle.is_null() {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    unsafe {
+        (*handle).cmd = (*handle).cmd.clone().filter(
+            col,
+            Operator::Between,
+            Value::Array(vec![low, high]),
+        );
+    }
+}
+
+/// Add JSONB containment filter (col @> json), json bound as a parameter
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_json_contains(
+    handle: *mut QailHandle,
+    col: *const c_char,
+    json: *const c_char,
+) {
+    if handle.is_null() {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    let json = unsafe { CStr::from_ptr(json).to_str().unwrap_or("") };
+    unsafe {
+        (*handle).cmd = (*handle).cmd.clone().filter(
+            col,
+            Operator::Contains,
+            Value::Json(json.to_string()),
+        );
+    }
+}
+
+/// Add JSON-path predicate filter (col @@ path), path bound as a parameter
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_filter_json_path(
+    handle: *mut QailHandle,
+    col: *const c_char,
+    path: *const c_char,
+) {
+    if handle.is_null() {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    let path = unsafe { CStr::from_ptr(path).to_str().unwrap_or("") };
+    unsafe {
+        (*handle).cmd = (*handle).cmd.clone().filter(col, Operator::JsonPathMatch, path);
     }
 }
 
@@ -138,6 +471,106 @@ pub extern "C" fn qail_offset(handle: *mut QailHandle, offset: i64) {
     }
 }
 
+/// Start a new VALUES row on an ADD command; qail_value_* append to it
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_values_row(handle: *mut QailHandle) {
+    if handle.is_null() {
+        return;
+    }
+    unsafe {
+        (*handle).cmd.cages.push(Cage {
+            kind: CageKind::Payload,
+            conditions: Vec::new(),
+            logical_op: LogicalOp::And,
+        });
+    }
+}
+
+/// Append a value to the current VALUES row, starting one if needed
+fn push_row_value(handle: *mut QailHandle, value: Value) {
+    let h = unsafe { &mut *handle };
+    if !matches!(h.cmd.cages.last(), Some(c) if c.kind == CageKind::Payload) {
+        h.cmd.cages.push(Cage {
+            kind: CageKind::Payload,
+            conditions: Vec::new(),
+            logical_op: LogicalOp::And,
+        });
+    }
+    let Some(cage) = h.cmd.cages.last_mut() else {
+        return;
+    };
+    let n = cage.conditions.len() + 1;
+    cage.conditions.push(Condition {
+        left: Expr::Named(format!("${}", n)),
+        op: Operator::Eq,
+        value,
+        is_array_unnest: false,
+    });
+}
+
+/// Append an int value to the current VALUES row
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_value_int(handle: *mut QailHandle, value: i64) {
+    if handle.is_null() {
+        return;
+    }
+    push_row_value(handle, Value::Int(value));
+}
+
+/// Append a float value to the current VALUES row
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_value_float(handle: *mut QailHandle, value: f64) {
+    if handle.is_null() {
+        return;
+    }
+    push_row_value(handle, Value::Float(value));
+}
+
+/// Append a string value to the current VALUES row
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_value_str(handle: *mut QailHandle, value: *const c_char) {
+    if handle.is_null() {
+        return;
+    }
+    let value = unsafe { CStr::from_ptr(value).to_str().unwrap_or("") };
+    push_row_value(handle, Value::String(value.to_string()));
+}
+
+/// Append a bool value to the current VALUES row
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_value_bool(handle: *mut QailHandle, value: c_int) {
+    if handle.is_null() {
+        return;
+    }
+    push_row_value(handle, Value::Bool(value != 0));
+}
+
+/// Append NULL to the current VALUES row
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_value_null(handle: *mut QailHandle) {
+    if handle.is_null() {
+        return;
+    }
+    push_row_value(handle, Value::Null);
+}
+
+/// Add a RETURNING column; "*" returns every column
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_returning(handle: *mut QailHandle, col: *const c_char) {
+    if handle.is_null() {
+        return;
+    }
+    let col = unsafe { CStr::from_ptr(col).to_str().unwrap_or("") };
+    let expr = if col == "*" {
+        Expr::Star
+    } else {
+        Expr::Named(col.to_string())
+    };
+    unsafe {
+        (*handle).cmd.returning.get_or_insert_with(Vec::new).push(expr);
+    }
+}
+
 /// Encode command to PostgreSQL wire protocol bytes
 /// Returns pointer to bytes, sets out_len to length
 /// Caller must free with qail_bytes_free
@@ -151,7 +584,16 @@ pub extern "C" fn qail_encode(handle: *const QailHandle, out_len: *mut usize) ->
     }
 
     let cmd = unsafe { &(*handle).cmd };
-    let (wire_bytes, _params) = AstEncoder::encode_cmd(cmd);
+    let wire_bytes = match AstEncoder::try_encode_cmd(cmd) {
+        Ok((wire_bytes, _params)) => wire_bytes,
+        Err(_) => {
+            // e.g. too many bind parameters; Go reports it via qail_param_count
+            unsafe {
+                *out_len = 0;
+            }
+            return std::ptr::null_mut();
+        }
+    };
     let bytes = wire_bytes.to_vec();
 
     let len = bytes.len();
@@ -163,6 +605,161 @@ pub extern "C" fn qail_encode(handle: *const QailHandle, out_len: *mut usize) ->
     ptr
 }
 
+/// Number of bind parameters the command encodes to.
+/// Used by Go to explain encode failures (PostgreSQL Bind limit).
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_param_count(handle: *const QailHandle) -> i64 {
+    if handle.is_null() {
+        return -1;
+    }
+    let cmd = unsafe { &(*handle).cmd };
+    AstEncoder::encode_cmd_params_only(cmd).len() as i64
+}
+
+/// 1 if the command only reads: a SELECT without a row lock whose CTEs
+/// are read-only too. Used by Go to route queries to replicas.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_is_read_only(handle: *const QailHandle) -> c_int {
+    if handle.is_null() {
+        return 0;
+    }
+    let cmd = unsafe { &(*handle).cmd };
+    is_read_only(cmd) as c_int
+}
+
+fn is_read_only(cmd: &Qail) -> bool {
+    matches!(cmd.action, Action::Get | Action::With)
+        && cmd.lock_mode.is_none()
+        && cmd.ctes.iter().all(|cte| {
+            is_read_only(&cte.base_query)
+                && cte.recursive_query.as_deref().is_none_or(is_read_only)
+        })
+}
+
+/// Render the command as the SQL text sent to the server ($n placeholders).
+/// Returns a NUL-terminated string, caller must free with qail_string_free.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_to_sql(handle: *const QailHandle) -> *mut c_char {
+    if handle.is_null() {
+        return std::ptr::null_mut();
+    }
+    clear_error();
+    let cmd = unsafe { &(*handle).cmd };
+    if !renders_sql(cmd) {
+        set_error(format!("cannot render {:?} command as SQL", cmd.action));
+        return std::ptr::null_mut();
+    }
+    let (sql, _params) = AstEncoder::encode_cmd_sql(&with_shifted_params(cmd));
+    match CString::new(sql) {
+        Ok(s) => s.into_raw(),
+        Err(_) => {
+            set_error("SQL contains a NUL byte".to_string());
+            std::ptr::null_mut()
+        }
+    }
+}
+
+/// Actions encode_cmd_sql supports (it panics on the rest).
+fn renders_sql(cmd: &Qail) -> bool {
+    matches!(
+        cmd.action,
+        Action::Get
+            | Action::With
+            | Action::Add
+            | Action::Set
+            | Action::Del
+            | Action::Export
+            | Action::Make
+            | Action::Index
+    )
+}
+
+/// Copy of cmd with qail_filter_param arguments renumbered after its
+/// literal parameters: literals take $1..$L and argument n becomes $L+n.
+fn with_shifted_params(cmd: &Qail) -> Qail {
+    let mut cmd = cmd.clone();
+    let literals = AstEncoder::encode_cmd_params_only(&cmd).len();
+    for cage in &mut cmd.cages {
+        for cond in &mut cage.conditions {
+            shift_params(&mut cond.value, literals);
+            shift_expr_params(&mut cond.left, literals);
+        }
+    }
+    cmd
+}
+
+/// Render the command as SQL plus its literal parameters, for callers
+/// that bind positional arguments (qail_filter_param) themselves.
+///
+/// Literal values take $1..$L; argument n is renumbered to $L+n, so the
+/// caller binds the returned literals followed by its arguments.
+///
+/// Layout (big-endian): u32 SQL length, SQL bytes, u16 literal count, then
+/// per literal an i32 length (-1 = NULL) and its text-format bytes.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_encode_sql_params(handle: *const QailHandle, out_len: *mut usize) -> *mut u8 {
+    if handle.is_null() || out_len.is_null() {
+        return std::ptr::null_mut();
+    }
+    let cmd = unsafe { &(*handle).cmd };
+    if !renders_sql(cmd) {
+        unsafe {
+            *out_len = 0;
+        }
+        return std::ptr::null_mut();
+    }
+    let (sql, params) = AstEncoder::encode_cmd_sql(&with_shifted_params(cmd));
+    if params.len() > u16::MAX as usize {
+        unsafe {
+            *out_len = 0;
+        }
+        return std::ptr::null_mut();
+    }
+
+    let mut out = Vec::with_capacity(4 + sql.len() + 2 + params.len() * 8);
+    out.extend_from_slice(&(sql.len() as u32).to_be_bytes());
+    out.extend_from_slice(sql.as_bytes());
+    out.extend_from_slice(&(params.len() as u16).to_be_bytes());
+    for p in &params {
+        match p {
+            None => out.extend_from_slice(&(-1i32).to_be_bytes()),
+            Some(data) => {
+                out.extend_from_slice(&(data.len() as i32).to_be_bytes());
+                out.extend_from_slice(data);
+            }
+        }
+    }
+    into_raw_buffer(out, out_len)
+}
+
+fn shift_params(value: &mut Value, by: usize) {
+    if let Value::Param(n) = value {
+        *n += by;
+    }
+}
+
+/// Shift Param literals inside a filter group tree (see qail_begin_group).
+fn shift_expr_params(expr: &mut Expr, by: usize) {
+    match expr {
+        Expr::Binary { left, right, .. } => {
+            shift_expr_params(left, by);
+            shift_expr_params(right, by);
+        }
+        Expr::Literal(value) => shift_params(value, by),
+        _ => {}
+    }
+}
+
+/// Free a string returned by qail_to_sql
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_string_free(ptr: *mut c_char) {
+    if !ptr.is_null() {
+        unsafe {
+            drop(CString::from_raw(ptr));
+        }
+    }
+}
+
 /// Encode batch of commands to PostgreSQL wire protocol bytes
 /// Returns single buffer with all commands encoded
 #[unsafe(no_mangle)]
@@ -189,7 +786,15 @@ pub extern "C" fn qail_batch_encode(
     }
 
     // Encode batch
-    let wire_bytes = AstEncoder::encode_batch(&cmds);
+    let wire_bytes = match AstEncoder::try_encode_batch(&cmds) {
+        Ok(wire_bytes) => wire_bytes,
+        Err(_) => {
+            unsafe {
+                *out_len = 0;
+            }
+            return std::ptr::null_mut();
+        }
+    };
     let bytes = wire_bytes.to_vec();
 
     let len = bytes.len();
@@ -211,6 +816,33 @@ pub extern "C" fn qail_free(handle: *mut QailHandle) {
     }
 }
 
+/// Deep-copy a command into a new handle that must be freed separately.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_clone(handle: *const QailHandle) -> *mut QailHandle {
+    if handle.is_null() {
+        return std::ptr::null_mut();
+    }
+    let h = unsafe { &*handle };
+    Box::into_raw(Box::new(h.clone()))
+}
+
+/// Clear everything but the table and action, so the handle can be
+/// rebuilt and re-encoded without a new allocation.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_reset(handle: *mut QailHandle) {
+    if handle.is_null() {
+        return;
+    }
+    let h = unsafe { &mut *handle };
+    let table = std::mem::take(&mut h.cmd.table);
+    h.cmd = Qail {
+        action: h.cmd.action,
+        table,
+        ..Default::default()
+    };
+    h.groups.clear();
+}
+
 /// Free bytes allocated by encode functions
 #[unsafe(no_mangle)]
 pub extern "C" fn qail_bytes_free(ptr: *mut u8, len: usize) {
@@ -330,10 +962,253 @@ pub extern "C" fn qail_encode_select_batch_fast(
     let len = bytes.len();
     let ptr = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
 
-    unsafe {
-        *out_len = len;
+    unsafe {
+        *out_len = len;
+    }
+    ptr
+}
+
+/// Encode batch of SELECT queries like qail_encode_select_batch_fast, with
+/// a per-query OFFSET as well as LIMIT. `limits` and `offsets` both hold
+/// `count` values; a value <= 0 omits that clause.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_encode_select_batch_paged(
+    table: *const c_char,
+    columns: *const c_char,
+    limits: *const i64,
+    offsets: *const i64,
+    count: usize,
+    out_len: *mut usize,
+) -> *mut u8 {
+    let cmds: Vec<Qail> = build_select_batch(table, columns, limits, count)
+        .into_iter()
+        .enumerate()
+        .map(|(i, cmd)| {
+            let offset = unsafe { *offsets.add(i) };
+            if offset > 0 { cmd.offset(offset) } else { cmd }
+        })
+        .collect();
+
+    let bytes = AstEncoder::encode_batch(&cmds).to_vec();
+    into_raw_buffer(bytes, out_len)
+}
+
+/// PostgreSQL type OIDs of the binary parameters the batch encoders bind.
+const BOOL_OID: u32 = 16;
+const INT8_OID: u32 = 20;
+const FLOAT8_OID: u32 = 701;
+
+/// Append one binary-format Bind parameter (length, then value) of type
+/// `oid`. `bits` carries the value: an int8's i64 bits, a float8's
+/// f64::to_bits, or 0/1 for a bool. Returns false for any other type.
+fn put_binary_param(bytes: &mut Vec<u8>, oid: u32, bits: u64) -> bool {
+    match oid {
+        INT8_OID | FLOAT8_OID => {
+            bytes.extend_from_slice(&8i32.to_be_bytes());
+            bytes.extend_from_slice(&bits.to_be_bytes());
+        }
+        BOOL_OID => {
+            bytes.extend_from_slice(&1i32.to_be_bytes());
+            bytes.push((bits != 0) as u8);
+        }
+        _ => return false,
+    }
+    true
+}
+
+/// Encode batch of SELECT queries that share ONE statement with a binary
+/// int8 LIMIT parameter: Parse once, then Bind + Execute per limit.
+/// The server parses the SQL once and skips text-to-int conversion per query.
+/// limit <= 0 binds NULL (LIMIT NULL means no limit).
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_encode_select_batch_binary(
+    table: *const c_char,
+    columns: *const c_char, // comma-separated
+    limits: *const i64,     // array of limit values
+    count: usize,
+    out_len: *mut usize,
+) -> *mut u8 {
+    if limits.is_null() || count == 0 {
+        unsafe {
+            *out_len = 0;
+        }
+        return std::ptr::null_mut();
+    }
+
+    let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
+    let columns_str = unsafe { CStr::from_ptr(columns).to_str().unwrap_or("*") };
+
+    let mut cmd = Qail::get(table);
+    if !columns_str.is_empty() && columns_str != "*" {
+        for col in columns_str.split(',') {
+            cmd.columns.push(Expr::Named(col.trim().to_string()));
+        }
+    }
+    let (mut sql, _params) = AstEncoder::encode_cmd_sql(&cmd);
+    sql.push_str(" LIMIT $1");
+
+    // Parse + per query (Bind 27 bytes + Execute 10 bytes) + Sync
+    let mut bytes: Vec<u8> = Vec::with_capacity(16 + sql.len() + count * 37 + 5);
+
+    // PARSE (unnamed statement, one int8 parameter)
+    bytes.push(b'P');
+    let parse_len = (4 + 1 + sql.len() + 1 + 2 + 4) as i32;
+    bytes.extend_from_slice(&parse_len.to_be_bytes());
+    bytes.push(0);
+    bytes.extend_from_slice(sql.as_bytes());
+    bytes.push(0);
+    bytes.extend_from_slice(&1i16.to_be_bytes());
+    bytes.extend_from_slice(&INT8_OID.to_be_bytes());
+
+    for i in 0..count {
+        let limit = unsafe { *limits.add(i) };
+        let param_len = if limit > 0 { 8 } else { 0 };
+
+        // BIND (unnamed portal/statement, one binary param, text results)
+        bytes.push(b'B');
+        let bind_len = (4 + 1 + 1 + 2 + 2 + 2 + 4 + param_len + 2) as i32;
+        bytes.extend_from_slice(&bind_len.to_be_bytes());
+        bytes.push(0);
+        bytes.push(0);
+        bytes.extend_from_slice(&1i16.to_be_bytes()); // one format code
+        bytes.extend_from_slice(&1i16.to_be_bytes()); // binary
+        bytes.extend_from_slice(&1i16.to_be_bytes()); // one param
+        if limit > 0 {
+            bytes.extend_from_slice(&8i32.to_be_bytes());
+            bytes.extend_from_slice(&limit.to_be_bytes());
+        } else {
+            bytes.extend_from_slice(&(-1i32).to_be_bytes());
+        }
+        bytes.extend_from_slice(&0i16.to_be_bytes()); // result formats: text
+
+        // EXECUTE
+        bytes.push(b'E');
+        bytes.extend_from_slice(&9i32.to_be_bytes());
+        bytes.push(0);
+        bytes.extend_from_slice(&0i32.to_be_bytes());
+    }
+
+    // Single SYNC at the end
+    bytes.extend_from_slice(&[b'S', 0, 0, 0, 4]);
+
+    let len = bytes.len();
+    let ptr = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
+
+    unsafe {
+        *out_len = len;
+    }
+    ptr
+}
+
+/// Encode batch of SELECT queries that share ONE statement filtered on
+/// `filters` (comma-separated columns, `col1 = $1 AND col2 = $2 ...`):
+/// Parse once, then Bind + Execute per row of binary parameters.
+/// `oids` gives each parameter's type: int8, float8, bool, or 0 to let the
+/// server infer it for a parameter that is NULL in every row. `values`
+/// holds `count` rows of `nparams` values in put_binary_param's bit form;
+/// `nulls`, if not NULL, is laid out the same and flags NULL values.
+/// Returns NULL for an empty batch, a filter count other than `nparams`,
+/// or a non-NULL value of unsupported type.
+#[unsafe(no_mangle)]
+#[allow(clippy::too_many_arguments)]
+pub extern "C" fn qail_encode_select_batch_params(
+    table: *const c_char,
+    columns: *const c_char, // comma-separated
+    filters: *const c_char, // comma-separated, one per parameter
+    oids: *const u32,
+    nparams: usize,
+    values: *const u64, // count * nparams, row-major
+    nulls: *const u8,   // count * nparams, or NULL for none
+    count: usize,
+    out_len: *mut usize,
+) -> *mut u8 {
+    unsafe {
+        *out_len = 0;
+    }
+    if oids.is_null()
+        || values.is_null()
+        || nparams == 0
+        || nparams > i16::MAX as usize
+        || count == 0
+    {
+        return std::ptr::null_mut();
+    }
+    let oids = unsafe { std::slice::from_raw_parts(oids, nparams) };
+    let values = unsafe { std::slice::from_raw_parts(values, count * nparams) };
+    let nulls = if nulls.is_null() {
+        None
+    } else {
+        Some(unsafe { std::slice::from_raw_parts(nulls, count * nparams) })
+    };
+
+    let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
+    let columns_str = unsafe { CStr::from_ptr(columns).to_str().unwrap_or("*") };
+    let filters_str = unsafe { CStr::from_ptr(filters).to_str().unwrap_or("") };
+
+    let mut cmd = Qail::get(table);
+    if !columns_str.is_empty() && columns_str != "*" {
+        for col in columns_str.split(',') {
+            cmd.columns.push(Expr::Named(col.trim().to_string()));
+        }
+    }
+    let filter_cols: Vec<&str> = filters_str.split(',').map(str::trim).collect();
+    if filter_cols.len() != nparams || filter_cols.iter().any(|c| c.is_empty()) {
+        return std::ptr::null_mut();
+    }
+    for (i, col) in filter_cols.iter().enumerate() {
+        cmd = cmd.filter(*col, Operator::Eq, Value::Param(i + 1));
+    }
+    let (sql, _params) = AstEncoder::encode_cmd_sql(&cmd);
+
+    // Parse + per query (Bind 17 bytes + at most 12 per param, Execute 10
+    // bytes) + Sync
+    let mut bytes: Vec<u8> =
+        Vec::with_capacity(12 + sql.len() + 4 * nparams + count * (27 + 12 * nparams) + 5);
+
+    // PARSE (unnamed statement, typed parameters)
+    bytes.push(b'P');
+    let parse_len = (4 + 1 + sql.len() + 1 + 2 + 4 * nparams) as i32;
+    bytes.extend_from_slice(&parse_len.to_be_bytes());
+    bytes.push(0);
+    bytes.extend_from_slice(sql.as_bytes());
+    bytes.push(0);
+    bytes.extend_from_slice(&(nparams as i16).to_be_bytes());
+    for oid in oids {
+        bytes.extend_from_slice(&oid.to_be_bytes());
+    }
+
+    for row in 0..count {
+        // BIND (unnamed portal/statement, every param binary, text results)
+        bytes.push(b'B');
+        let len_at = bytes.len();
+        bytes.extend_from_slice(&0i32.to_be_bytes()); // set below
+        bytes.push(0);
+        bytes.push(0);
+        bytes.extend_from_slice(&1i16.to_be_bytes()); // one format code
+        bytes.extend_from_slice(&1i16.to_be_bytes()); // binary, for every param
+        bytes.extend_from_slice(&(nparams as i16).to_be_bytes());
+        for (j, &oid) in oids.iter().enumerate() {
+            let k = row * nparams + j;
+            if nulls.is_some_and(|n| n[k] != 0) {
+                bytes.extend_from_slice(&(-1i32).to_be_bytes());
+            } else if !put_binary_param(&mut bytes, oid, values[k]) {
+                return std::ptr::null_mut();
+            }
+        }
+        bytes.extend_from_slice(&0i16.to_be_bytes()); // result formats: text
+        let bind_len = (bytes.len() - len_at) as i32;
+        bytes[len_at..len_at + 4].copy_from_slice(&bind_len.to_be_bytes());
+
+        // EXECUTE
+        bytes.push(b'E');
+        bytes.extend_from_slice(&9i32.to_be_bytes());
+        bytes.push(0);
+        bytes.extend_from_slice(&0i32.to_be_bytes());
     }
-    ptr
+
+    // Single SYNC at the end
+    bytes.extend_from_slice(&[b'S', 0, 0, 0, 4]);
+    into_raw_buffer(bytes, out_len)
 }
 
 // =============================================================================
@@ -341,10 +1216,12 @@ pub extern "C" fn qail_encode_select_batch_fast(
 // =============================================================================
 
 use once_cell::sync::Lazy;
-use qail_pg::PgConnection;
+use qail_pg::{PgConnection, PgError};
 use std::sync::Mutex;
+use std::sync::atomic::{AtomicBool, Ordering};
+use std::time::Duration;
 use tokio::runtime::Runtime;
-use tokio::sync::{mpsc, oneshot};
+use tokio::sync::{Notify, mpsc, oneshot};
 
 /// Global Tokio runtime - multi-thread for CGO compatibility
 static RUNTIME: Lazy<Runtime> = Lazy::new(|| {
@@ -355,12 +1232,101 @@ static RUNTIME: Lazy<Runtime> = Lazy::new(|| {
         .expect("Failed to create Tokio runtime")
 });
 
+/// Connection options for qail_connect_config and qail_connect_config_v2.
+struct ConnConfig {
+    host: String,
+    port: u16,
+    user: String,
+    database: String,
+    password: Option<String>,
+    ssl_mode: String,
+    connect_timeout_ms: u64,
+}
+
+impl ConnConfig {
+    /// Read the C arguments. A NULL or empty password means none; a NULL
+    /// or empty sslmode means "prefer".
+    unsafe fn from_c(
+        host: *const c_char,
+        port: u16,
+        user: *const c_char,
+        database: *const c_char,
+        password: *const c_char,
+        sslmode: *const c_char,
+        connect_timeout_ms: u64,
+    ) -> Result<Self, String> {
+        let read = |ptr: *const c_char, what: &str| -> Result<String, String> {
+            if ptr.is_null() {
+                return Ok(String::new());
+            }
+            unsafe { CStr::from_ptr(ptr) }
+                .to_str()
+                .map(str::to_string)
+                .map_err(|e| format!("Invalid UTF-8 in {}: {}", what, e))
+        };
+
+        let password = read(password, "password")?;
+        let ssl_mode = match read(sslmode, "sslmode")?.as_str() {
+            "" | "prefer" => "prefer".to_string(),
+            mode @ ("disable" | "require") => mode.to_string(),
+            other => return Err(format!("Unsupported sslmode: {}", other)),
+        };
+
+        Ok(Self {
+            host: read(host, "host")?,
+            port,
+            user: read(user, "user")?,
+            database: read(database, "database")?,
+            password: (!password.is_empty()).then_some(password),
+            ssl_mode,
+            connect_timeout_ms,
+        })
+    }
+
+    async fn connect(&self) -> Result<PgConnection, String> {
+        let (host, port, user, database) = (&self.host, self.port, &self.user, &self.database);
+        let password = self.password.as_deref();
+
+        let connect = async {
+            match self.ssl_mode.as_str() {
+                "disable" => {
+                    PgConnection::connect_with_password(host, port, user, database, password).await
+                }
+                "require" => PgConnection::connect_tls(host, port, user, database, password).await,
+                // prefer: fall back to plain TCP unless TLS got as far as auth
+                _ => match PgConnection::connect_tls(host, port, user, database, password).await {
+                    Err(e) if !matches!(e, PgError::Auth(_)) => {
+                        PgConnection::connect_with_password(host, port, user, database, password)
+                            .await
+                    }
+                    result => result,
+                },
+            }
+        };
+
+        if self.connect_timeout_ms == 0 {
+            return connect.await.map_err(|e| e.to_string());
+        }
+        match tokio::time::timeout(Duration::from_millis(self.connect_timeout_ms), connect).await {
+            Ok(result) => result.map_err(|e| e.to_string()),
+            Err(_) => Err(format!(
+                "connect to {}:{} timed out after {}ms",
+                host, port, self.connect_timeout_ms
+            )),
+        }
+    }
+}
+
 /// Command sent to the connection task
 enum ConnCmd {
     ExecuteBatch {
         cmds: Vec<Qail>,
         reply: oneshot::Sender<Result<usize, String>>,
     },
+    FetchBatch {
+        cmds: Vec<Qail>,
+        reply: oneshot::Sender<Result<Vec<Vec<RawRow>>, String>>,
+    },
     Close,
 }
 
@@ -400,6 +1366,10 @@ pub extern "C" fn qail_connect_v2(
                     let result = conn.pipeline_ast_fast(&cmds).await;
                     let _ = reply.send(result.map_err(|e| e.to_string()));
                 }
+                ConnCmd::FetchBatch { cmds, reply } => {
+                    let result = conn.pipeline_ast(&cmds).await;
+                    let _ = reply.send(result.map_err(|e| e.to_string()));
+                }
                 ConnCmd::Close => break,
             }
         }
@@ -411,6 +1381,74 @@ pub extern "C" fn qail_connect_v2(
     Box::into_raw(Box::new(ConnHandleV2 { tx }))
 }
 
+/// Connect like qail_connect_v2, with password, sslmode ("disable",
+/// "require", "prefer") and connect timeout (0 = none). Unlike
+/// qail_connect_v2 this waits for the connection and returns null with
+/// qail_last_error set if it fails.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_connect_config_v2(
+    host: *const c_char,
+    port: u16,
+    user: *const c_char,
+    database: *const c_char,
+    password: *const c_char,
+    sslmode: *const c_char,
+    connect_timeout_ms: u64,
+) -> *mut ConnHandleV2 {
+    clear_error();
+    let cfg = match unsafe {
+        ConnConfig::from_c(host, port, user, database, password, sslmode, connect_timeout_ms)
+    } {
+        Ok(cfg) => cfg,
+        Err(e) => {
+            set_error(e);
+            return std::ptr::null_mut();
+        }
+    };
+
+    let (tx, mut rx) = mpsc::unbounded_channel::<ConnCmd>();
+    let (ready_tx, ready_rx) = oneshot::channel::<Result<(), String>>();
+
+    RUNTIME.spawn(async move {
+        let mut conn = match cfg.connect().await {
+            Ok(c) => {
+                let _ = ready_tx.send(Ok(()));
+                c
+            }
+            Err(e) => {
+                let _ = ready_tx.send(Err(e));
+                return;
+            }
+        };
+
+        while let Some(cmd) = rx.recv().await {
+            match cmd {
+                ConnCmd::ExecuteBatch { cmds, reply } => {
+                    let result = conn.pipeline_ast_fast(&cmds).await;
+                    let _ = reply.send(result.map_err(|e| e.to_string()));
+                }
+                ConnCmd::FetchBatch { cmds, reply } => {
+                    let result = conn.pipeline_ast(&cmds).await;
+                    let _ = reply.send(result.map_err(|e| e.to_string()));
+                }
+                ConnCmd::Close => break,
+            }
+        }
+    });
+
+    match ready_rx.blocking_recv() {
+        Ok(Ok(())) => Box::into_raw(Box::new(ConnHandleV2 { tx })),
+        Ok(Err(e)) => {
+            set_error(e);
+            std::ptr::null_mut()
+        }
+        Err(_) => {
+            set_error("connection task is not running".to_string());
+            std::ptr::null_mut()
+        }
+    }
+}
+
 /// Execute batch of SELECT queries via async task.
 /// Uses oneshot channel - much faster than block_on!
 #[unsafe(no_mangle)]
@@ -421,6 +1459,7 @@ pub extern "C" fn qail_execute_batch_v2(
     limits: *const i64,
     count: usize,
 ) -> i64 {
+    clear_error();
     if conn_handle.is_null() || count == 0 {
         return -1;
     }
@@ -462,13 +1501,70 @@ pub extern "C" fn qail_execute_batch_v2(
         })
         .is_err()
     {
+        set_error("connection task is not running".to_string());
         return -1;
     }
 
     // Wait for result via oneshot (this DOES block, but with less overhead)
     match reply_rx.blocking_recv() {
         Ok(Ok(n)) => n as i64,
-        _ => -1,
+        Ok(Err(e)) => {
+            set_error(e);
+            -1
+        }
+        Err(_) => {
+            set_error("connection task is not running".to_string());
+            -1
+        }
+    }
+}
+
+/// Execute batch of SELECT queries via async task and return the rows,
+/// serialized as described on `serialize_batch_rows`.
+/// Returns null on failure; free the buffer with qail_bytes_free.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_execute_batch_rows_v2(
+    conn_handle: *mut ConnHandleV2,
+    table: *const c_char,
+    columns: *const c_char,
+    limits: *const i64,
+    count: usize,
+    out_len: *mut usize,
+) -> *mut u8 {
+    clear_error();
+    unsafe {
+        *out_len = 0;
+    }
+    if conn_handle.is_null() || count == 0 {
+        return std::ptr::null_mut();
+    }
+
+    let cmds = build_select_batch(table, columns, limits, count);
+
+    let handle = unsafe { &*conn_handle };
+    let (reply_tx, reply_rx) = oneshot::channel();
+    if handle
+        .tx
+        .send(ConnCmd::FetchBatch {
+            cmds,
+            reply: reply_tx,
+        })
+        .is_err()
+    {
+        set_error("connection task is not running".to_string());
+        return std::ptr::null_mut();
+    }
+
+    match reply_rx.blocking_recv() {
+        Ok(Ok(results)) => into_raw_buffer(serialize_batch_rows(&results), out_len),
+        Ok(Err(e)) => {
+            set_error(e);
+            std::ptr::null_mut()
+        }
+        Err(_) => {
+            set_error("connection task is not running".to_string());
+            std::ptr::null_mut()
+        }
     }
 }
 
@@ -485,6 +1581,9 @@ pub extern "C" fn qail_conn_close_v2(handle: *mut ConnHandleV2) {
 /// Opaque connection handle (old block_on version)
 pub struct ConnHandle {
     conn: Mutex<Option<PgConnection>>,
+    // Server address, for the CancelRequest sent by qail_execute_batch_ctx
+    host: String,
+    port: u16,
 }
 
 #[unsafe(no_mangle)]
@@ -494,6 +1593,7 @@ pub extern "C" fn qail_connect(
     user: *const c_char,
     database: *const c_char,
 ) -> *mut ConnHandle {
+    clear_error();
     let host = unsafe { CStr::from_ptr(host).to_str().unwrap_or("127.0.0.1") };
     let user = unsafe { CStr::from_ptr(user).to_str().unwrap_or("postgres") };
     let database = unsafe { CStr::from_ptr(database).to_str().unwrap_or("postgres") };
@@ -504,8 +1604,49 @@ pub extern "C" fn qail_connect(
     match result {
         Ok(conn) => Box::into_raw(Box::new(ConnHandle {
             conn: Mutex::new(Some(conn)),
+            host: host.to_string(),
+            port,
+        })),
+        Err(e) => {
+            set_error(e.to_string());
+            std::ptr::null_mut()
+        }
+    }
+}
+
+/// Connect like qail_connect, with password, sslmode ("disable",
+/// "require", "prefer") and connect timeout (0 = none).
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_connect_config(
+    host: *const c_char,
+    port: u16,
+    user: *const c_char,
+    database: *const c_char,
+    password: *const c_char,
+    sslmode: *const c_char,
+    connect_timeout_ms: u64,
+) -> *mut ConnHandle {
+    clear_error();
+    let cfg = match unsafe {
+        ConnConfig::from_c(host, port, user, database, password, sslmode, connect_timeout_ms)
+    } {
+        Ok(cfg) => cfg,
+        Err(e) => {
+            set_error(e);
+            return std::ptr::null_mut();
+        }
+    };
+
+    match RUNTIME.block_on(cfg.connect()) {
+        Ok(conn) => Box::into_raw(Box::new(ConnHandle {
+            conn: Mutex::new(Some(conn)),
+            host: cfg.host,
+            port: cfg.port,
         })),
-        Err(_) => std::ptr::null_mut(),
+        Err(e) => {
+            set_error(e);
+            std::ptr::null_mut()
+        }
     }
 }
 
@@ -517,6 +1658,7 @@ pub extern "C" fn qail_execute_batch(
     limits: *const i64,
     count: usize,
 ) -> i64 {
+    clear_error();
     if conn_handle.is_null() || count == 0 {
         return -1;
     }
@@ -552,13 +1694,163 @@ pub extern "C" fn qail_execute_batch(
 
         match result {
             Ok(n) => n as i64,
-            Err(_) => -1,
+            Err(e) => {
+                set_error(e.to_string());
+                -1
+            }
         }
     } else {
+        set_error("connection is closed".to_string());
         -1
     }
 }
 
+/// Cancellation flag for qail_execute_batch_ctx. Go sets it from another
+/// thread when its context ends; the running call is woken and aborts.
+pub struct CancelFlag {
+    cancelled: AtomicBool,
+    notify: Notify,
+}
+
+impl CancelFlag {
+    /// Resolves once the flag is set. notify_one stores a permit when no
+    /// one is waiting yet, so a set before the wait is not lost.
+    async fn wait(&self) {
+        if !self.cancelled.load(Ordering::Acquire) {
+            self.notify.notified().await;
+        }
+    }
+}
+
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_cancel_flag_new() -> *mut CancelFlag {
+    Box::into_raw(Box::new(CancelFlag {
+        cancelled: AtomicBool::new(false),
+        notify: Notify::new(),
+    }))
+}
+
+/// Set the flag. Safe to call from any thread while a call is using it.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_cancel_flag_set(flag: *mut CancelFlag) {
+    if flag.is_null() {
+        return;
+    }
+    let flag = unsafe { &*flag };
+    flag.cancelled.store(true, Ordering::Release);
+    flag.notify.notify_one();
+}
+
+/// Free a flag. No call may still be using it.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_cancel_flag_free(flag: *mut CancelFlag) {
+    if !flag.is_null() {
+        unsafe {
+            drop(Box::from_raw(flag));
+        }
+    }
+}
+
+/// Result of qail_execute_batch_ctx when the flag was set.
+const BATCH_CANCELLED: i64 = -2;
+
+/// qail_execute_batch that gives up when `flag` is set: the server is sent
+/// a CancelRequest and BATCH_CANCELLED (-2) is returned. The batch's
+/// responses are then still in flight, so the connection is dropped and
+/// later calls on the handle fail.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_execute_batch_ctx(
+    conn_handle: *mut ConnHandle,
+    table: *const c_char,
+    columns: *const c_char,
+    limits: *const i64,
+    count: usize,
+    flag: *mut CancelFlag,
+) -> i64 {
+    clear_error();
+    if conn_handle.is_null() || flag.is_null() || count == 0 {
+        return -1;
+    }
+
+    let cmds = build_select_batch(table, columns, limits, count);
+    let handle = unsafe { &*conn_handle };
+    let flag = unsafe { &*flag };
+    let mut guard = handle.conn.lock().unwrap();
+
+    let Some(conn) = guard.as_mut() else {
+        set_error("connection is closed".to_string());
+        return -1;
+    };
+
+    let (pid, secret) = conn.get_cancel_key();
+    let result = RUNTIME.block_on(async {
+        tokio::select! {
+            r = conn.pipeline_ast_fast(&cmds) => Some(r),
+            _ = flag.wait() => None,
+        }
+    });
+
+    match result {
+        Some(Ok(n)) => n as i64,
+        Some(Err(e)) => {
+            set_error(e.to_string());
+            -1
+        }
+        None => {
+            // Best effort: stop the server's work; the connection is
+            // discarded either way.
+            let _ = RUNTIME.block_on(PgConnection::cancel_query(
+                &handle.host,
+                handle.port,
+                pid,
+                secret,
+            ));
+            *guard = None;
+            set_error("batch cancelled".to_string());
+            BATCH_CANCELLED
+        }
+    }
+}
+
+/// Execute batch of SELECT queries and return the rows, serialized as
+/// described on `serialize_batch_rows`.
+/// Returns null on failure; free the buffer with qail_bytes_free.
+#[unsafe(no_mangle)]
+pub extern "C" fn qail_execute_batch_rows(
+    conn_handle: *mut ConnHandle,
+    table: *const c_char,
+    columns: *const c_char,
+    limits: *const i64,
+    count: usize,
+    out_len: *mut usize,
+) -> *mut u8 {
+    clear_error();
+    unsafe {
+        *out_len = 0;
+    }
+    if conn_handle.is_null() || count == 0 {
+        return std::ptr::null_mut();
+    }
+
+    let cmds = build_select_batch(table, columns, limits, count);
+
+    let handle = unsafe { &*conn_handle };
+    let mut guard = handle.conn.lock().unwrap();
+
+    if let Some(conn) = guard.as_mut() {
+        match RUNTIME.block_on(async { conn.pipeline_ast(&cmds).await }) {
+            Ok(results) => into_raw_buffer(serialize_batch_rows(&results), out_len),
+            Err(e) => {
+                set_error(e.to_string());
+                std::ptr::null_mut()
+            }
+        }
+    } else {
+        set_error("connection is closed".to_string());
+        std::ptr::null_mut()
+    }
+}
+
 #[unsafe(no_mangle)]
 pub extern "C" fn qail_conn_close(handle: *mut ConnHandle) {
     if !handle.is_null() {
@@ -567,3 +1859,223 @@ pub extern "C" fn qail_conn_close(handle: *mut ConnHandle) {
         }
     }
 }
+
+/// One DataRow: column values, None for NULL.
+type RawRow = Vec<Option<Vec<u8>>>;
+
+/// Build one SELECT per limit (limit <= 0 means no LIMIT).
+fn build_select_batch(
+    table: *const c_char,
+    columns: *const c_char,
+    limits: *const i64,
+    count: usize,
+) -> Vec<Qail> {
+    let table = unsafe { CStr::from_ptr(table).to_str().unwrap_or("") };
+    let columns_str = unsafe { CStr::from_ptr(columns).to_str().unwrap_or("*") };
+
+    let col_exprs: Vec<Expr> = if !columns_str.is_empty() && columns_str != "*" {
+        columns_str
+            .split(',')
+            .map(|col| Expr::Named(col.trim().to_string()))
+            .collect()
+    } else {
+        vec![]
+    };
+
+    let mut cmds = Vec::with_capacity(count);
+    for i in 0..count {
+        let limit = unsafe { *limits.add(i) };
+        let mut cmd = Qail::get(table);
+        cmd.columns = col_exprs.clone();
+        if limit > 0 {
+            cmd = cmd.limit(limit);
+        }
+        cmds.push(cmd);
+    }
+    cmds
+}
+
+/// Serialize pipeline results for Go. All integers are big-endian:
+///
+/// ```text
+/// u32 query_count
+///   per query: u32 row_count
+///     per row: u16 column_count
+///       per column: i32 length (-1 = NULL), then length bytes
+/// ```
+fn serialize_batch_rows(results: &[Vec<RawRow>]) -> Vec<u8> {
+    let mut size = 4;
+    for rows in results {
+        size += 4;
+        for row in rows {
+            size += 2;
+            for col in row {
+                size += 4 + col.as_ref().map_or(0, |v| v.len());
+            }
+        }
+    }
+
+    let mut buf = Vec::with_capacity(size);
+    buf.extend_from_slice(&(results.len() as u32).to_be_bytes());
+    for rows in results {
+        buf.extend_from_slice(&(rows.len() as u32).to_be_bytes());
+        for row in rows {
+            buf.extend_from_slice(&(row.len() as u16).to_be_bytes());
+            for col in row {
+                match col {
+                    Some(v) => {
+                        buf.extend_from_slice(&(v.len() as i32).to_be_bytes());
+                        buf.extend_from_slice(v);
+                    }
+                    None => buf.extend_from_slice(&(-1i32).to_be_bytes()),
+                }
+            }
+        }
+    }
+    buf
+}
+
+/// Hand a buffer to the caller; free with qail_bytes_free.
+fn into_raw_buffer(bytes: Vec<u8>, out_len: *mut usize) -> *mut u8 {
+    let len = bytes.len();
+    let ptr = Box::into_raw(bytes.into_boxed_slice()) as *mut u8;
+    unsafe {
+        *out_len = len;
+    }
+    ptr
+}
+
+#[cfg(test)]
+mod tests {
+    use super::*;
+
+    #[test]
+    fn test_put_binary_param() {
+        let cases: [(u32, u64, &[u8]); 6] = [
+            (
+                INT8_OID,
+                (-42i64) as u64,
+                &[0, 0, 0, 8, 255, 255, 255, 255, 255, 255, 255, 214],
+            ),
+            (
+                FLOAT8_OID,
+                1.5f64.to_bits(),
+                &[0, 0, 0, 8, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0],
+            ),
+            (
+                FLOAT8_OID,
+                (-0.0f64).to_bits(),
+                &[0, 0, 0, 8, 0x80, 0, 0, 0, 0, 0, 0, 0],
+            ),
+            (BOOL_OID, 1, &[0, 0, 0, 1, 1]),
+            (BOOL_OID, 0, &[0, 0, 0, 1, 0]),
+            (BOOL_OID, 7, &[0, 0, 0, 1, 1]),
+        ];
+        for (oid, bits, want) in cases {
+            let mut got = Vec::new();
+            assert!(put_binary_param(&mut got, oid, bits), "oid {oid}");
+            assert_eq!(got, want, "oid {oid} bits {bits:#x}");
+        }
+
+        let mut got = Vec::new();
+        assert!(
+            !put_binary_param(&mut got, 25, 0),
+            "text is not a binary param type"
+        );
+        assert!(got.is_empty());
+    }
+
+    /// Split wire into (type, body) messages.
+    fn messages(mut wire: &[u8]) -> Vec<(u8, &[u8])> {
+        let mut out = Vec::new();
+        while !wire.is_empty() {
+            let len = u32::from_be_bytes(wire[1..5].try_into().unwrap()) as usize;
+            out.push((wire[0], &wire[5..1 + len]));
+            wire = &wire[1 + len..];
+        }
+        out
+    }
+
+    fn encode_params(filters: &str, oids: &[u32], values: &[u64], nulls: &[u8]) -> Option<Vec<u8>> {
+        let table = CString::new("harbors").unwrap();
+        let columns = CString::new("id,name").unwrap();
+        let filters = CString::new(filters).unwrap();
+        let mut len = 0;
+        let ptr = qail_encode_select_batch_params(
+            table.as_ptr(),
+            columns.as_ptr(),
+            filters.as_ptr(),
+            oids.as_ptr(),
+            oids.len(),
+            values.as_ptr(),
+            nulls.as_ptr(),
+            values.len() / oids.len(),
+            &mut len,
+        );
+        if ptr.is_null() {
+            return None;
+        }
+        let wire = unsafe { std::slice::from_raw_parts(ptr, len) }.to_vec();
+        qail_bytes_free(ptr, len);
+        Some(wire)
+    }
+
+    #[test]
+    fn test_encode_select_batch_params() {
+        let oids = [INT8_OID, FLOAT8_OID, BOOL_OID];
+        let values = [7, 2.5f64.to_bits(), 1, (-1i64) as u64, 0, 0];
+        let nulls = [0, 0, 0, 0, 1, 0];
+        let wire = encode_params("id, score, active", &oids, &values, &nulls).unwrap();
+
+        let msgs = messages(&wire);
+        let types: Vec<u8> = msgs.iter().map(|(t, _)| *t).collect();
+        assert_eq!(types, b"PBEBES");
+
+        let parse = msgs[0].1;
+        let sql_end = 1 + parse[1..].iter().position(|&b| b == 0).unwrap();
+        let sql = std::str::from_utf8(&parse[1..sql_end]).unwrap();
+        for want in ["id = $1", "score = $2", "active = $3"] {
+            assert!(sql.contains(want), "{sql}");
+        }
+        let mut want_types = vec![0, 3];
+        for oid in oids {
+            want_types.extend_from_slice(&oid.to_be_bytes());
+        }
+        assert_eq!(&parse[sql_end + 1..], want_types);
+
+        let bind = |int8: &[u8], float8: &[u8], bool: &[u8]| {
+            let mut b = vec![0, 0, 0, 1, 0, 1, 0, 3];
+            b.extend_from_slice(int8);
+            b.extend_from_slice(float8);
+            b.extend_from_slice(bool);
+            b.extend_from_slice(&[0, 0]);
+            b
+        };
+        assert_eq!(
+            msgs[1].1,
+            bind(
+                &[0, 0, 0, 8, 0, 0, 0, 0, 0, 0, 0, 7],
+                &[0, 0, 0, 8, 0x40, 0x04, 0, 0, 0, 0, 0, 0],
+                &[0, 0, 0, 1, 1],
+            )
+        );
+        assert_eq!(
+            msgs[3].1,
+            bind(
+                &[0, 0, 0, 8, 255, 255, 255, 255, 255, 255, 255, 255],
+                &[255, 255, 255, 255],
+                &[0, 0, 0, 1, 0],
+            )
+        );
+    }
+
+    #[test]
+    fn test_encode_select_batch_params_rejects() {
+        // An unsupported type, unless every value of it is NULL.
+        assert!(encode_params("name", &[25], &[0], &[0]).is_none());
+        assert!(encode_params("name", &[0], &[0], &[1]).is_some());
+        // One filter column per parameter.
+        assert!(encode_params("id", &[INT8_OID, INT8_OID], &[1, 2], &[0, 0]).is_none());
+        assert!(encode_params("id,", &[INT8_OID, INT8_OID], &[1, 2], &[0, 0]).is_none());
+    }
+}
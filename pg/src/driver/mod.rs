@@ -744,6 +744,52 @@ impl PgDriver {
         Ok(results)
     }
 
+    /// Execute pre-encoded queries in one round-trip, each with its own
+    /// Sync, and report every query's outcome separately.
+    ///
+    /// An `Err` entry (e.g. an encode error) is not sent; it is returned in
+    /// its place, so outcomes always line up with `queries`. See
+    /// `PgConnection::pipeline_isolated`.
+    pub async fn pipeline_fetch_each(
+        &mut self,
+        queries: Vec<PgResult<bytes::BytesMut>>,
+    ) -> PgResult<Vec<PgResult<(Vec<PgRow>, u64)>>> {
+        let mut wires = Vec::with_capacity(queries.len());
+        let mut unsent = Vec::with_capacity(queries.len());
+        for q in queries {
+            match q {
+                Ok(wire) => {
+                    wires.push(wire);
+                    unsent.push(None);
+                }
+                Err(e) => unsent.push(Some(e)),
+            }
+        }
+        let mut sent = self.connection.pipeline_isolated(&wires).await?.into_iter();
+
+        let outcomes = unsent
+            .into_iter()
+            .map(|unsent| {
+                if let Some(e) = unsent {
+                    return Err(e);
+                }
+                let (rows, affected) = sent
+                    .next()
+                    .ok_or_else(|| PgError::Protocol("missing pipeline result".to_string()))??;
+                let rows = rows
+                    .into_iter()
+                    .map(|columns| PgRow {
+                        columns,
+                        column_info: None,
+                    })
+                    .collect();
+                Ok((rows, affected))
+            })
+            .collect();
+
+        Ok(outcomes)
+    }
+
     /// Prepare a SQL statement for repeated execution.
     pub async fn prepare(&mut self, sql: &str) -> PgResult<PreparedStatement> {
         self.connection.prepare(sql).await
@@ -10,7 +10,7 @@
 //! 6. `pipeline_ast` - Full results collection
 //! 7. `query_pipeline` - SQL-based pipelining
 
-use super::{PgConnection, PgError, PgResult};
+use super::{PgConnection, PgError, PgResult, parse_affected_rows};
 use crate::protocol::{AstEncoder, BackendMessage, PgEncoder};
 use bytes::BytesMut;
 use tokio::io::AsyncWriteExt;
@@ -106,6 +106,60 @@ impl PgConnection {
         }
     }
 
+    /// Execute pre-encoded queries in a single round-trip, each isolated by
+    /// its own Sync, so a failing query aborts only itself.
+    ///
+    /// Every entry of `queries` must be one complete extended query ending
+    /// in Sync (e.g. from `PgEncoder::encode_extended_query`). Returns one
+    /// outcome per query, in order: its rows and affected count, or the
+    /// error it failed with. The outer error is for the connection itself.
+    pub async fn pipeline_isolated(
+        &mut self,
+        queries: &[BytesMut],
+    ) -> PgResult<Vec<PgResult<(Vec<Vec<Option<Vec<u8>>>>, u64)>>> {
+        let mut buf = BytesMut::with_capacity(queries.iter().map(|q| q.len()).sum());
+        for q in queries {
+            buf.extend_from_slice(q);
+        }
+        self.stream.write_all(&buf).await?;
+
+        let mut outcomes = Vec::with_capacity(queries.len());
+        let mut rows: Vec<Vec<Option<Vec<u8>>>> = Vec::new();
+        let mut affected = 0u64;
+        let mut error: Option<PgError> = None;
+
+        while outcomes.len() < queries.len() {
+            let msg = self.recv().await?;
+            match msg {
+                BackendMessage::DataRow(data) => {
+                    if error.is_none() {
+                        rows.push(data);
+                    }
+                }
+                BackendMessage::CommandComplete(tag) => {
+                    affected = parse_affected_rows(&tag);
+                }
+                BackendMessage::ErrorResponse(err) => {
+                    if error.is_none() {
+                        error = Some(PgError::Query(err.message));
+                    }
+                }
+                BackendMessage::ReadyForQuery(_) => {
+                    // Each query's Sync ends it, successful or not.
+                    outcomes.push(match error.take() {
+                        Some(err) => Err(err),
+                        None => Ok((std::mem::take(&mut rows), affected)),
+                    });
+                    rows.clear();
+                    affected = 0;
+                }
+                _ => {}
+            }
+        }
+
+        Ok(outcomes)
+    }
+
     /// FAST AST pipeline - returns only query count, no result parsing.
     pub async fn pipeline_ast_fast(&mut self, cmds: &[qail_core::ast::Qail]) -> PgResult<usize> {
         let buf = AstEncoder::encode_batch(cmds);
@@ -12,7 +12,7 @@ use crate::protocol::EncodeError;
 /// Build Extended Query protocol: Parse + Bind + Describe + Execute + Sync.
 /// Includes Describe to get RowDescription (column metadata).
 pub fn build_extended_query(sql: &[u8], params: &[Option<Vec<u8>>]) -> Result<BytesMut, EncodeError> {
-    if params.len() > i16::MAX as usize {
+    if params.len() > u16::MAX as usize {
         return Err(EncodeError::TooManyParameters(params.len()));
     }
 
@@ -88,51 +88,86 @@ pub fn encode_batch(cmds: &[Qail]) -> BytesMut {
             _ => panic!("Unsupported action {:?} in AST-native batch encoder.", cmd.action),
         }.ok();
 
-        let sql_bytes = sql_buf.freeze();
-        let params_size: usize = params
-            .iter()
-            .map(|p| 4 + p.as_ref().map_or(0, |v| v.len()))
-            .sum();
-
-        // PARSE
-        total_buf.extend_from_slice(b"P");
-        let parse_len = (1 + sql_bytes.len() + 1 + 2 + 4) as i32;
-        total_buf.extend_from_slice(&parse_len.to_be_bytes());
-        total_buf.extend_from_slice(&[0]);
-        total_buf.extend_from_slice(&sql_bytes);
-        total_buf.extend_from_slice(&[0]);
-        total_buf.extend_from_slice(&0i16.to_be_bytes());
-
-        // BIND
-        total_buf.extend_from_slice(b"B");
-        let bind_len = (1 + 1 + 2 + 2 + params_size + 2 + 4) as i32;
-        total_buf.extend_from_slice(&bind_len.to_be_bytes());
-        total_buf.extend_from_slice(&[0]);
-        total_buf.extend_from_slice(&[0]);
-        total_buf.extend_from_slice(&0i16.to_be_bytes());
-        total_buf.extend_from_slice(&(params.len() as i16).to_be_bytes());
-        for param in &params {
-            match param {
-                None => total_buf.extend_from_slice(&(-1i32).to_be_bytes()),
-                Some(data) => {
-                    total_buf.extend_from_slice(&(data.len() as i32).to_be_bytes());
-                    total_buf.extend_from_slice(data);
-                }
-            }
+        write_batch_entry(&mut total_buf, &sql_buf, &params);
+    }
+
+    // Single SYNC at the end
+    total_buf.extend_from_slice(&[b'S', 0, 0, 0, 4]);
+
+    total_buf
+}
+
+/// Encode multiple Qails as a pipeline batch, reporting encode errors
+/// (NULL bytes, parameter limit, unsupported action) instead of emitting a
+/// corrupt batch or panicking.
+pub fn try_encode_batch(cmds: &[Qail]) -> Result<BytesMut, EncodeError> {
+    let mut total_buf = BytesMut::with_capacity(cmds.len() * 256);
+
+    for cmd in cmds {
+        let mut sql_buf = BytesMut::with_capacity(256);
+        let mut params: Vec<Option<Vec<u8>>> = Vec::new();
+
+        match cmd.action {
+            Action::Get => encode_select(cmd, &mut sql_buf, &mut params),
+            Action::Add => encode_insert(cmd, &mut sql_buf, &mut params),
+            Action::Set => encode_update(cmd, &mut sql_buf, &mut params),
+            Action::Del => encode_delete(cmd, &mut sql_buf, &mut params),
+            action => return Err(EncodeError::UnsupportedAction(action)),
+        }?;
+
+        if params.len() > u16::MAX as usize {
+            return Err(EncodeError::TooManyParameters(params.len()));
         }
-        total_buf.extend_from_slice(&0i16.to_be_bytes());
 
-        // EXECUTE
-        total_buf.extend_from_slice(b"E");
-        total_buf.extend_from_slice(&9i32.to_be_bytes());
-        total_buf.extend_from_slice(&[0]);
-        total_buf.extend_from_slice(&0i32.to_be_bytes());
+        write_batch_entry(&mut total_buf, &sql_buf, &params);
     }
 
     // Single SYNC at the end
     total_buf.extend_from_slice(&[b'S', 0, 0, 0, 4]);
 
-    total_buf
+    Ok(total_buf)
+}
+
+/// Append Parse + Bind + Execute for one batch entry (no Sync).
+fn write_batch_entry(total_buf: &mut BytesMut, sql_bytes: &[u8], params: &[Option<Vec<u8>>]) {
+    let params_size: usize = params
+        .iter()
+        .map(|p| 4 + p.as_ref().map_or(0, |v| v.len()))
+        .sum();
+
+    // PARSE
+    total_buf.extend_from_slice(b"P");
+    let parse_len = (1 + sql_bytes.len() + 1 + 2 + 4) as i32;
+    total_buf.extend_from_slice(&parse_len.to_be_bytes());
+    total_buf.extend_from_slice(&[0]);
+    total_buf.extend_from_slice(sql_bytes);
+    total_buf.extend_from_slice(&[0]);
+    total_buf.extend_from_slice(&0i16.to_be_bytes());
+
+    // BIND
+    total_buf.extend_from_slice(b"B");
+    let bind_len = (1 + 1 + 2 + 2 + params_size + 2 + 4) as i32;
+    total_buf.extend_from_slice(&bind_len.to_be_bytes());
+    total_buf.extend_from_slice(&[0]);
+    total_buf.extend_from_slice(&[0]);
+    total_buf.extend_from_slice(&0i16.to_be_bytes());
+    total_buf.extend_from_slice(&(params.len() as i16).to_be_bytes());
+    for param in params {
+        match param {
+            None => total_buf.extend_from_slice(&(-1i32).to_be_bytes()),
+            Some(data) => {
+                total_buf.extend_from_slice(&(data.len() as i32).to_be_bytes());
+                total_buf.extend_from_slice(data);
+            }
+        }
+    }
+    total_buf.extend_from_slice(&0i16.to_be_bytes());
+
+    // EXECUTE
+    total_buf.extend_from_slice(b"E");
+    total_buf.extend_from_slice(&9i32.to_be_bytes());
+    total_buf.extend_from_slice(&[0]);
+    total_buf.extend_from_slice(&0i32.to_be_bytes());
 }
 
 /// Encode multiple Qails using Simple Query Protocol.
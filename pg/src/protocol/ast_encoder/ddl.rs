@@ -5,6 +5,8 @@
 use bytes::BytesMut;
 use qail_core::ast::{Constraint, Expr, Qail, TableConstraint};
 
+use super::helpers::write_string_literal;
+
 /// Map QAIL types to PostgreSQL types.
 #[inline]
 pub fn map_type(t: &str) -> &'static str {
@@ -91,9 +93,7 @@ pub fn encode_make(cmd: &Qail, buf: &mut BytesMut) {
                         if i > 0 {
                             buf.extend_from_slice(b", ");
                         }
-                        buf.extend_from_slice(b"'");
-                        buf.extend_from_slice(v.as_bytes());
-                        buf.extend_from_slice(b"'");
+                        write_string_literal(buf, v);
                     }
                     buf.extend_from_slice(b"))");
                 }
@@ -282,9 +282,14 @@ pub fn encode_insert(cmd: &Qail, buf: &mut BytesMut, params: &mut Vec<Option<Vec
         buf.extend_from_slice(b")");
     }
 
-    // VALUES
-    if let Some(cage) = payload_cage {
-        buf.extend_from_slice(b" VALUES (");
+    // VALUES - one row per payload cage
+    for (r, cage) in cmd
+        .cages
+        .iter()
+        .filter(|c| c.kind == CageKind::Payload)
+        .enumerate()
+    {
+        buf.extend_from_slice(if r == 0 { b" VALUES (" } else { b", (" });
         for (i, cond) in cage.conditions.iter().enumerate() {
             if i > 0 {
                 buf.extend_from_slice(b", ");
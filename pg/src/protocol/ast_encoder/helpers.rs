@@ -87,3 +87,23 @@ pub fn i64_to_bytes(n: i64) -> Vec<u8> {
         n.to_string().into_bytes()
     }
 }
+
+/// Write a string as a quoted SQL literal.
+/// Single quotes are doubled. A string containing a backslash is written as
+/// an E'' literal with the backslashes doubled, so it means the same thing
+/// whatever the server's standard_conforming_strings setting.
+pub fn write_string_literal(buf: &mut BytesMut, s: &str) {
+    if s.contains('\\') {
+        buf.extend_from_slice(b"E'");
+    } else {
+        buf.extend_from_slice(b"'");
+    }
+    for &b in s.as_bytes() {
+        match b {
+            b'\'' => buf.extend_from_slice(b"''"),
+            b'\\' => buf.extend_from_slice(b"\\\\"),
+            _ => buf.extend_from_slice(&[b]),
+        }
+    }
+    buf.extend_from_slice(b"'");
+}
@@ -62,6 +62,38 @@ impl AstEncoder {
         (wire, params)
     }
 
+    /// Encode a Qail to Extended Query protocol bytes, returning encode
+    /// errors (NULL bytes, parameter limit, unsupported action) instead of
+    /// panicking.
+    /// Same output as `encode_cmd` on success.
+    pub fn try_encode_cmd(cmd: &Qail) -> Result<(BytesMut, Vec<Option<Vec<u8>>>), EncodeError> {
+        let mut sql_buf = BytesMut::with_capacity(256);
+        let mut params: Vec<Option<Vec<u8>>> = Vec::new();
+
+        match cmd.action {
+            Action::Get | Action::With => dml::encode_select(cmd, &mut sql_buf, &mut params)?,
+            Action::Add => dml::encode_insert(cmd, &mut sql_buf, &mut params)?,
+            Action::Set => dml::encode_update(cmd, &mut sql_buf, &mut params)?,
+            Action::Del => dml::encode_delete(cmd, &mut sql_buf, &mut params)?,
+            Action::Export => dml::encode_export(cmd, &mut sql_buf, &mut params)?,
+            Action::Make => ddl::encode_make(cmd, &mut sql_buf),
+            Action::Index => ddl::encode_index(cmd, &mut sql_buf),
+            Action::Drop => ddl::encode_drop_table(cmd, &mut sql_buf),
+            Action::DropIndex => ddl::encode_drop_index(cmd, &mut sql_buf),
+            Action::Alter => ddl::encode_alter_add_column(cmd, &mut sql_buf),
+            Action::AlterDrop => ddl::encode_alter_drop_column(cmd, &mut sql_buf),
+            Action::AlterType => ddl::encode_alter_column_type(cmd, &mut sql_buf),
+            Action::CreateView => ddl::encode_create_view(cmd, &mut sql_buf, &mut params),
+            Action::DropView => ddl::encode_drop_view(cmd, &mut sql_buf),
+            action => return Err(EncodeError::UnsupportedAction(action)),
+        }
+
+        let sql_bytes = sql_buf.freeze();
+        let wire = batch::build_extended_query(&sql_bytes, &params)?;
+
+        Ok((wire, params))
+    }
+
     /// Encode a Qail using CALLER'S BUFFERS (ZERO-ALLOC).
     /// Clears and reuses the provided buffers to avoid allocations.
     /// Returns wire protocol bytes ready to send.
@@ -152,6 +184,12 @@ impl AstEncoder {
         batch::encode_batch(cmds)
     }
 
+    /// Encode multiple Qails as a pipeline batch, returning encode errors
+    /// instead of emitting a corrupt batch.
+    pub fn try_encode_batch(cmds: &[Qail]) -> Result<BytesMut, EncodeError> {
+        batch::try_encode_batch(cmds)
+    }
+
     /// Encode multiple Qails using Simple Query Protocol.
     #[inline]
     pub fn encode_batch_simple(cmds: &[Qail]) -> BytesMut {
@@ -252,4 +290,128 @@ mod tests {
         assert!(sql.contains("recent_orders"), "SQL should have second CTE: {}", sql);
         assert!(sql.starts_with("WITH"), "SQL should start with WITH: {}", sql);
     }
+
+    #[test]
+    fn test_encode_json_filters() {
+        use qail_core::ast::{Operator, Value};
+
+        let cmd = Qail::get("events")
+            .columns(["id"])
+            .filter(
+                "data",
+                Operator::Contains,
+                Value::Json(r#"{"kind":"login"}"#.to_string()),
+            )
+            .filter("data", Operator::JsonPathMatch, "$.age > 18");
+
+        let (sql, params) = AstEncoder::encode_cmd_sql(&cmd);
+
+        assert!(sql.contains("data @> $1"), "{sql}");
+        assert!(sql.contains("data @@ $2"), "{sql}");
+        assert_eq!(
+            params,
+            vec![
+                Some(br#"{"kind":"login"}"#.to_vec()),
+                Some(b"$.age > 18".to_vec())
+            ]
+        );
+    }
+
+    #[test]
+    fn test_string_filter_is_bound_not_inlined() {
+        use qail_core::ast::Operator;
+
+        for value in ["O'Brien", r"C:\temp\", "'; DROP TABLE users; --", "naïve 日本語"] {
+            let cmd = Qail::get("users")
+                .columns(["id"])
+                .filter("name", Operator::Eq, value);
+
+            let (sql, params) = AstEncoder::encode_cmd_sql(&cmd);
+
+            assert!(sql.contains("name = $1"), "{sql}");
+            assert!(!sql.contains(value), "{sql}");
+            assert_eq!(params, vec![Some(value.as_bytes().to_vec())]);
+        }
+    }
+
+    #[test]
+    fn test_write_string_literal() {
+        let cases = [
+            ("plain", "'plain'"),
+            ("O'Brien", "'O''Brien'"),
+            (r"a\b", r"E'a\\b'"),
+            (r"it's\", r"E'it''s\\'"),
+            ("naïve 日本語", "'naïve 日本語'"),
+        ];
+        for (input, want) in cases {
+            let mut buf = BytesMut::new();
+            helpers::write_string_literal(&mut buf, input);
+            assert_eq!(std::str::from_utf8(&buf).unwrap(), want);
+        }
+    }
+
+    #[test]
+    fn test_inlined_literals_resist_quote_injection() {
+        use qail_core::ast::{AggregateFunc, Condition, Expr, Operator, Value};
+
+        let mut cmd = Qail::get("events");
+        cmd.columns.push(Expr::JsonAccess {
+            column: "data".to_string(),
+            path_segments: vec![("x'||pg_sleep(10)||'".to_string(), true)],
+            alias: None,
+        });
+        cmd.columns.push(Expr::Aggregate {
+            col: "id".to_string(),
+            func: AggregateFunc::Count,
+            distinct: false,
+            filter: Some(vec![Condition {
+                left: Expr::Named("status".to_string()),
+                op: Operator::Eq,
+                value: Value::String("x') OR ('1'='1".to_string()),
+                is_array_unnest: false,
+            }]),
+            alias: None,
+        });
+
+        let (sql, _) = AstEncoder::encode_cmd_sql(&cmd);
+
+        assert!(sql.contains("(data->>'x''||pg_sleep(10)||''')"), "{sql}");
+        assert!(
+            sql.contains("FILTER (WHERE status = 'x'') OR (''1''=''1')"),
+            "{sql}"
+        );
+    }
+
+    #[test]
+    fn test_encode_insert_multi_row() {
+        use qail_core::ast::Value;
+
+        let cmd = Qail::add("users")
+            .columns(["id", "name"])
+            .values([Value::Int(1), Value::String("a".to_string())])
+            .values([Value::Int(2), Value::String("b".to_string())])
+            .returning(["id"]);
+
+        let (sql, params) = AstEncoder::encode_cmd_sql(&cmd);
+
+        assert_eq!(
+            sql,
+            "INSERT INTO users (id, name) VALUES ($1, $2), ($3, $4) RETURNING id"
+        );
+        assert_eq!(params.len(), 4);
+    }
+
+    #[test]
+    fn test_try_encode_unsupported_action_is_an_error() {
+        let cmd = Qail::listen("jobs");
+
+        assert_eq!(
+            AstEncoder::try_encode_cmd(&cmd).unwrap_err(),
+            EncodeError::UnsupportedAction(Action::Listen)
+        );
+        assert_eq!(
+            AstEncoder::try_encode_batch(&[Qail::get("users"), cmd]).unwrap_err(),
+            EncodeError::UnsupportedAction(Action::Listen)
+        );
+    }
 }
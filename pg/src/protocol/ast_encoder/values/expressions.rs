@@ -3,9 +3,13 @@
 //! Functions for encoding Expr, Value, Operator, and conditions to wire format.
 
 use bytes::BytesMut;
-use qail_core::ast::{Action, CageKind, Condition, Expr, FrameBound, Operator, SortOrder, Value, WindowFrame};
+use qail_core::ast::{
+    Action, BinaryOp, CageKind, Condition, Expr, FrameBound, Operator, SortOrder, Value, WindowFrame,
+};
 
-use super::super::helpers::{i64_to_bytes, write_param_placeholder, NUMERIC_VALUES};
+use super::super::helpers::{
+    i64_to_bytes, write_param_placeholder, write_string_literal, NUMERIC_VALUES,
+};
 
 /// Encode column list to buffer.
 pub fn encode_columns(columns: &[Expr], buf: &mut BytesMut) {
@@ -58,11 +62,7 @@ pub fn encode_column_expr(col: &Expr, buf: &mut BytesMut) {
                     // Handle Value::Expr specially for complex expressions like NOW() - INTERVAL
                     match &cond.value {
                         Value::Expr(expr) => encode_column_expr(expr, buf),
-                        Value::String(s) => {
-                            buf.extend_from_slice(b"'");
-                            buf.extend_from_slice(s.as_bytes());
-                            buf.extend_from_slice(b"'");
-                        }
+                        Value::String(s) => write_string_literal(buf, s),
                         Value::Int(n) => buf.extend_from_slice(n.to_string().as_bytes()),
                         Value::Bool(b) => buf.extend_from_slice(if *b { b"TRUE" } else { b"FALSE" }),
                         Value::Null => buf.extend_from_slice(b"NULL"),
@@ -73,9 +73,7 @@ pub fn encode_column_expr(col: &Expr, buf: &mut BytesMut) {
                                     buf.extend_from_slice(b", ");
                                 }
                                 if let Value::String(s) = v {
-                                    buf.extend_from_slice(b"'");
-                                    buf.extend_from_slice(s.as_bytes());
-                                    buf.extend_from_slice(b"'");
+                                    write_string_literal(buf, s);
                                 } else {
                                     buf.extend_from_slice(v.to_string().as_bytes());
                                 }
@@ -196,18 +194,16 @@ pub fn encode_column_expr(col: &Expr, buf: &mut BytesMut) {
                         buf.extend_from_slice(b"->>");
                         buf.extend_from_slice(key.as_bytes());
                     } else {
-                        buf.extend_from_slice(b"->>'");
-                        buf.extend_from_slice(key.as_bytes());
-                        buf.extend_from_slice(b"'");
+                        buf.extend_from_slice(b"->>");
+                        write_string_literal(buf, key);
                     }
                 } else {
                     if is_integer {
                         buf.extend_from_slice(b"->");
                         buf.extend_from_slice(key.as_bytes());
                     } else {
-                        buf.extend_from_slice(b"->'");
-                        buf.extend_from_slice(key.as_bytes());
-                        buf.extend_from_slice(b"'");
+                        buf.extend_from_slice(b"->");
+                        write_string_literal(buf, key);
                     }
                 }
             }
@@ -390,6 +386,7 @@ pub fn encode_operator(op: &Operator, buf: &mut BytesMut) {
         Operator::Contains => b"@>",
         Operator::ContainedBy => b"<@",
         Operator::Overlaps => b"&&",
+        Operator::JsonPathMatch => b"@@",
         Operator::KeyExists => b"?",
         Operator::JsonExists => b"JSON_EXISTS",
         Operator::JsonQuery => b"JSON_QUERY",
@@ -439,6 +436,17 @@ pub fn encode_conditions(
         if i > 0 {
             buf.extend_from_slice(b" AND ");
         }
+
+        // Grouped predicate: `<bool expr tree> = TRUE`, see encode_filter_expr
+        if let Expr::Binary { op, .. } = &cond.left
+            && is_filter_op(*op)
+            && cond.op == Operator::Eq
+            && matches!(cond.value, Value::Bool(true))
+        {
+            encode_filter_expr(&cond.left, buf, params)?;
+            continue;
+        }
+
         encode_expr(&cond.left, buf);
 
         match cond.op {
@@ -518,6 +526,7 @@ pub fn encode_conditions(
             Operator::Contains => buf.extend_from_slice(b" @> "),
             Operator::ContainedBy => buf.extend_from_slice(b" <@ "),
             Operator::Overlaps => buf.extend_from_slice(b" && "),
+            Operator::JsonPathMatch => buf.extend_from_slice(b" @@ "),
             Operator::Fuzzy => buf.extend_from_slice(b" ILIKE "),
             Operator::KeyExists => buf.extend_from_slice(b" ? "),
             Operator::JsonExists | Operator::JsonQuery | Operator::JsonValue => {
@@ -533,6 +542,60 @@ pub fn encode_conditions(
     Ok(())
 }
 
+/// Logical and comparison operators allowed in a filter expression tree.
+fn is_filter_op(op: BinaryOp) -> bool {
+    matches!(
+        op,
+        BinaryOp::And
+            | BinaryOp::Or
+            | BinaryOp::Eq
+            | BinaryOp::Ne
+            | BinaryOp::Gt
+            | BinaryOp::Gte
+            | BinaryOp::Lt
+            | BinaryOp::Lte
+            | BinaryOp::IsNull
+            | BinaryOp::IsNotNull
+    )
+}
+
+/// Encode a boolean filter expression: nested AND/OR groups of
+/// `column <cmp> literal` leaves built from Expr::Binary. Unlike
+/// encode_column_expr, literal operands are bound as parameters.
+/// Each AND/OR node is parenthesized so nesting keeps its meaning.
+pub fn encode_filter_expr(
+    expr: &Expr,
+    buf: &mut BytesMut,
+    params: &mut Vec<Option<Vec<u8>>>,
+) -> Result<(), crate::protocol::EncodeError> {
+    match expr {
+        Expr::Binary { left, op, right, .. } => match op {
+            BinaryOp::And | BinaryOp::Or => {
+                buf.extend_from_slice(b"(");
+                encode_filter_expr(left, buf, params)?;
+                buf.extend_from_slice(if *op == BinaryOp::And { b" AND " } else { b" OR " });
+                encode_filter_expr(right, buf, params)?;
+                buf.extend_from_slice(b")");
+            }
+            BinaryOp::IsNull | BinaryOp::IsNotNull => {
+                encode_filter_expr(left, buf, params)?;
+                buf.extend_from_slice(b" ");
+                buf.extend_from_slice(op.to_string().as_bytes());
+            }
+            _ => {
+                encode_filter_expr(left, buf, params)?;
+                buf.extend_from_slice(b" ");
+                buf.extend_from_slice(op.to_string().as_bytes());
+                buf.extend_from_slice(b" ");
+                encode_filter_expr(right, buf, params)?;
+            }
+        },
+        Expr::Literal(value) => encode_value(value, buf, params)?,
+        _ => encode_expr(expr, buf),
+    }
+    Ok(())
+}
+
 /// Encode value - extract to parameter or inline.
 /// Returns Err if the value contains invalid data (e.g., NULL byte in string).
 pub fn encode_value(value: &Value, buf: &mut BytesMut, params: &mut Vec<Option<Vec<u8>>>) -> Result<(), crate::protocol::EncodeError> {
@@ -675,7 +738,7 @@ pub fn write_value_to_array(buf: &mut Vec<u8>, value: &Value) {
         Value::String(s) => {
             buf.push(b'"');
             for byte in s.bytes() {
-                if byte == b'"' {
+                if byte == b'"' || byte == b'\\' {
                     buf.push(b'\\');
                 }
                 buf.push(byte);
@@ -113,7 +113,7 @@ impl PgEncoder {
     /// - for each parameter: length (4 bytes, -1 for NULL), data
     /// - result format count (2 bytes) - we use 0 (all text)
     pub fn encode_bind(portal: &str, statement: &str, params: &[Option<Vec<u8>>]) -> Result<BytesMut, EncodeError> {
-        if params.len() > i16::MAX as usize {
+        if params.len() > u16::MAX as usize {
             return Err(EncodeError::TooManyParameters(params.len()));
         }
 
@@ -225,7 +225,7 @@ impl PgEncoder {
     /// This combines Parse + Bind + Execute + Sync in a single buffer.
     /// Zero intermediate allocations - writes directly to pre-sized BytesMut.
     pub fn encode_extended_query(sql: &str, params: &[Option<Vec<u8>>]) -> Result<BytesMut, EncodeError> {
-        if params.len() > i16::MAX as usize {
+        if params.len() > u16::MAX as usize {
             return Err(EncodeError::TooManyParameters(params.len()));
         }
 
@@ -407,7 +407,7 @@ impl PgEncoder {
     /// - Single allocation check
     #[inline]
     pub fn encode_bind_ultra<'a>(buf: &mut BytesMut, statement: &str, params: &[Param<'a>]) -> Result<(), EncodeError> {
-        if params.len() > i16::MAX as usize {
+        if params.len() > u16::MAX as usize {
             return Err(EncodeError::TooManyParameters(params.len()));
         }
 
@@ -479,7 +479,7 @@ impl PgEncoder {
     /// This is the hot path optimization - no intermediate Vec allocation.
     #[inline]
     pub fn encode_bind_to(buf: &mut BytesMut, statement: &str, params: &[Option<Vec<u8>>]) -> Result<(), EncodeError> {
-        if params.len() > i16::MAX as usize {
+        if params.len() > u16::MAX as usize {
             return Err(EncodeError::TooManyParameters(params.len()));
         }
 
@@ -2,6 +2,7 @@
 //!
 //! Shared by `PgEncoder` and `AstEncoder`.
 
+use qail_core::ast::Action;
 use std::fmt;
 
 /// Errors that can occur during wire protocol encoding.
@@ -9,8 +10,10 @@ use std::fmt;
 pub enum EncodeError {
     /// A string value contains a literal NULL byte (0x00).
     NullByte,
-    /// Too many parameters for the protocol (limit is i16::MAX = 32767).
+    /// Too many parameters for the protocol (limit is u16::MAX = 65535).
     TooManyParameters(usize),
+    /// The action has no AST-native encoding (e.g. LISTEN, transactions).
+    UnsupportedAction(Action),
 }
 
 impl fmt::Display for EncodeError {
@@ -20,7 +23,10 @@ impl fmt::Display for EncodeError {
                 write!(f, "Value contains NULL byte (0x00) which is invalid in PostgreSQL")
             }
             EncodeError::TooManyParameters(count) => {
-                write!(f, "Too many parameters: {} (Limit is 32767)", count)
+                write!(f, "Too many parameters: {} (Limit is 65535)", count)
+            }
+            EncodeError::UnsupportedAction(action) => {
+                write!(f, "Unsupported action {:?} in AST-native encoder", action)
             }
         }
     }
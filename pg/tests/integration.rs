@@ -58,3 +58,35 @@ async fn test_filtered_query() -> PgResult<()> {
 
     Ok(())
 }
+
+/// Test that a failing query in an isolated pipeline fails alone
+#[tokio::test]
+#[ignore = "Requires PostgreSQL server - run manually"]
+async fn test_pipeline_fetch_each_mixed() -> PgResult<()> {
+    use qail_pg::PgEncoder;
+
+    let mut driver =
+        PgDriver::connect_with_password("127.0.0.1", 5432, "qail", "qail_test", "qail").await?;
+
+    let encode = |sql: &str| {
+        PgEncoder::encode_extended_query(sql, &[])
+            .map_err(|e| qail_pg::PgError::Encode(e.to_string()))
+    };
+    let outcomes = driver
+        .pipeline_fetch_each(vec![
+            encode("SELECT 1 UNION ALL SELECT 2"),
+            encode("SELECT * FROM no_such_table_qail"),
+            encode("SELECT 3"),
+        ])
+        .await?;
+
+    assert_eq!(outcomes.len(), 3);
+    let (rows, affected) = outcomes[0].as_ref().expect("first query should succeed");
+    assert_eq!(rows.len(), 2);
+    assert_eq!(*affected, 2);
+    assert!(outcomes[1].is_err(), "second query should fail");
+    let (rows, _) = outcomes[2].as_ref().expect("third query should still run");
+    assert_eq!(rows.len(), 1);
+
+    Ok(())
+}